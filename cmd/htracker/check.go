@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/robots"
+	"gitlab.com/henri.philipps/htracker/scraper"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"gitlab.com/henri.philipps/htracker/storage/file"
+	"gitlab.com/henri.philipps/htracker/storage/postgres"
+)
+
+// exitCodeChanged is the exit code of the check command if the content of the site changed.
+const exitCodeChanged = 2
+
+// errChanged is returned by the check command if the content of the site changed.
+var errChanged = errors.New("site content changed")
+
+// newCheckCmd is creating the check command for scraping a single site once.
+func newCheckCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	filter := fs.String("filter", "", "css selector or regexp for filtering the site content")
+	contentType := fs.String("contenttype", "", "content type of the site, 'feed' for comparing the items of RSS and Atom feeds, 'links' for comparing the links of a sitemap or of the anchors matching the filter")
+	useChrome := fs.Bool("chrome", false, "render the site with chrome")
+	fallback := fs.Bool("fallback", false, "scrape the site without rendering if chrome is unavailable")
+	screenshot := fs.Bool("screenshot", false, "capture a screenshot of the rendered site (of the element matching the filter) and report visual changes")
+	normalize := fs.String("normalize", "", "normalization of the content before comparing it, 'whitespace' for collapsing whitespace, 'none' for comparing the content as scraped")
+	diffMode := fs.String("diffmode", "", "units compared when diffing the content, 'line' or 'word' instead of characters, e.g. for big pages")
+	minChange := fs.Float64("minchange", 0, "min percentage of changed content reported as update, smaller changes are archived silently")
+	minPixelChange := fs.Float64("minpixelchange", 0, "min percentage of changed pixels of the screenshot reported as update, smaller visual changes are archived silently")
+	method := fs.String("method", "GET", "http method used for requesting the site (GET|POST)")
+	body := fs.String("body", "", "request body, e.g. form fields 'a=1&b=2' (use -header for other content types than forms)")
+	chromeWS := fs.String("ws", "ws://localhost:3000", "websocket url of chrome instance to connect to for site rendering")
+	timeout := fs.Duration("timeout", time.Minute, "timeout for scraping the site")
+	statePath := fs.String("state", "htracker-state.json", "path of the local state file")
+	obeyRobots := fs.Bool("robots", true, "obey robots.txt of the site")
+	pguri := fs.String("pguri", "", "postgres connection uri - if set, the state is kept in postgres instead of the state file")
+	rf := registerRequestFlags(fs)
+	df := registerDiffFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "check",
+		ShortUsage: "htracker <flags> check [<check flags>] <url>",
+		ShortHelp:  "check a single site for changes once",
+		LongHelp: fmt.Sprintf(`The check subcommand is scraping a single site once, comparing the content with the last check
+and printing the diff. It exits with status 0 if the site was unchanged or checked for the first time,
+with status %d if the content changed and with status 1 on errors.`, exitCodeChanged),
+		FlagSet: fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one url, got %d arguments", len(args))
+			}
+			if err := setEnvFlags(fs, "pguri"); err != nil {
+				return err
+			}
+
+			logger, err := createLogger(*logLevelFlag)
+			if err != nil {
+				return err
+			}
+
+			var siteStorage storage.SiteStorage = file.NewSiteStorage(*statePath, logger)
+			archiveOpts := []service.SiteArchiveOpt{service.WithArchiveLogger(logger)}
+			if *pguri != "" {
+				db, err := postgres.New(*pguri, logger)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+				// the versions are pruned by the server
+				siteStorage = db
+				archiveOpts = append(archiveOpts, service.WithVersionHistory(db))
+			}
+			archive := service.NewSiteArchive(siteStorage, archiveOpts...)
+
+			subscription := &htracker.Subscription{URL: args[0], Filter: *filter, ContentType: *contentType, UseChrome: *useChrome,
+				ChromeFallback: *fallback, Screenshot: *screenshot, Normalize: *normalize, DiffMode: *diffMode, MinChange: *minChange,
+				MinPixelChange: *minPixelChange, Method: *method, Body: *body}
+			if subscription.Request, err = rf.requestOptions(); err != nil {
+				return err
+			}
+			if err := subscription.Validate(); err != nil {
+				return err
+			}
+			// fail early for unknown diff formats
+			if _, err := df.render(nil); err != nil {
+				return err
+			}
+			collector := &siteCollector{}
+			scraperOpts := []scraper.Opt{
+				scraper.WithExporters([]exporter.Interface{collector}),
+				scraper.WithTimeout(*timeout),
+				scraper.WithLogger(logger),
+				scraper.WithLogDisabled(true),
+			}
+			if *useChrome {
+				scraperOpts = append(scraperOpts, scraper.WithBrowserEndpoint(*chromeWS))
+			}
+			if *obeyRobots {
+				scraperOpts = append(scraperOpts, scraper.WithRobots(robots.New(scraper.DefaultUserAgent, robots.WithLogger(logger))))
+			}
+			scraper.NewScraper([]*htracker.Subscription{subscription}, scraperOpts...).Start()
+
+			if len(collector.sites) == 0 {
+				return fmt.Errorf("failed to scrape %s", subscription.URL)
+			}
+
+			diff, err := archive.Update(ctx, collector.sites[0])
+			if err != nil {
+				return err
+			}
+			if collector.sites[0].State == htracker.SiteStateBlockedByRobots {
+				return fmt.Errorf("can't check %s: %w", subscription.URL, htracker.ErrRobotsDisallowed)
+			}
+			if collector.sites[0].State == htracker.SiteStateBrowserUnavailable {
+				return fmt.Errorf("can't check %s: %w", subscription.URL, scraper.ErrBrowserUnavailable)
+			}
+			if diff.Empty() {
+				return nil
+			}
+
+			rendered, err := df.render(diff)
+			if err != nil {
+				return err
+			}
+			fmt.Print(rendered)
+			return errChanged
+		},
+	}
+}
+
+// siteCollector is an exporter collecting the scraped sites.
+type siteCollector struct {
+	sites []*htracker.Site
+}
+
+// Export is reading all scraped sites from the exports channel.
+func (c *siteCollector) Export(exports chan interface{}) error {
+	for res := range exports {
+		site, ok := res.(*htracker.Site)
+		if !ok {
+			return fmt.Errorf("siteCollector.Export(): expected response of type *Site, got %T", res)
+		}
+		c.sites = append(c.sites, site)
+	}
+	return nil
+}
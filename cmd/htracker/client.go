@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"gitlab.com/henri.philipps/htracker"
+	httptransport "gitlab.com/henri.philipps/htracker/http"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"gitlab.com/henri.philipps/htracker/storage/postgres"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// siteGetter is the part of the SiteArchive used by the command line tools.
+type siteGetter interface {
+	Get(context.Context, *htracker.Subscription) (*htracker.Site, error)
+}
+
+// clientFlags are the flags shared by all subcommands talking to a htracker server or storage backend.
+type clientFlags struct {
+	fs        *flag.FlagSet
+	server    *string
+	pguri     *string
+	secretKey *string
+	output    *string
+}
+
+// registerClientFlags is adding the flags shared by all client subcommands to the given FlagSet.
+func registerClientFlags(fs *flag.FlagSet) *clientFlags {
+	return &clientFlags{
+		fs:        fs,
+		server:    fs.String("server", "http://localhost:8080", "base url of the htracker server api"),
+		pguri:     fs.String("pguri", "", "postgres connection uri - if set, the postgres storage is used directly instead of the server api"),
+		secretKey: fs.String("secretkey", "", "base64 encoded key for encrypting credentials of subscriptions at rest, used with -pguri"),
+	}
+}
+
+// withOutput is adding the output format flag to the given FlagSet, for subcommands printing results.
+func (cf *clientFlags) withOutput(fs *flag.FlagSet) *clientFlags {
+	cf.output = fs.String("output", outputTable, "output format (table|json)")
+	return cf
+}
+
+// parseEnv is setting the client flags which were not given on the command line from their HTRACKER_*
+// env vars. It is called by services, but must be called before other uses of the client flags.
+func (cf *clientFlags) parseEnv() error {
+	return setEnvFlags(cf.fs, "server", "pguri", "secretkey")
+}
+
+// services is returning the services to be used by a client subcommand, either talking to
+// the server api or directly to the configured storage backend, and a func for closing the
+// connection to the storage backend when done.
+func (cf *clientFlags) services() (service.SubscriptionSvc, siteGetter, func(), error) {
+	if err := cf.parseEnv(); err != nil {
+		return nil, nil, nil, err
+	}
+	if cf.output != nil && *cf.output != outputTable && *cf.output != outputJSON {
+		return nil, nil, nil, fmt.Errorf("output format %s not supported", *cf.output)
+	}
+
+	if *cf.pguri == "" {
+		client := httptransport.NewClient(*cf.server)
+		return client, client, func() {}, nil
+	}
+
+	logger, err := createLogger(*logLevelFlag)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cipher, err := storage.ParseCipher(*cf.secretKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	db, err := postgres.New(*cf.pguri, logger, postgres.WithCipher(cipher))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	closeDB := func() {
+		if err := db.Close(); err != nil {
+			logger.Error("failed to close postgres connection", err)
+		}
+	}
+	return service.NewSubscriptionSvc(db, service.WithLogger(logger)), service.NewSiteArchive(db), closeDB, nil
+}
+
+// print is writing data in the configured output format. In table mode,
+// the given header and rows are used, otherwise data is encoded as JSON.
+func (cf *clientFlags) print(data any, header []string, rows [][]string) error {
+	if *cf.output == outputJSON {
+		return printJSON(os.Stdout, data)
+	}
+	return printTable(os.Stdout, header, rows)
+}
+
+// printJSON is writing data as indented JSON to w.
+func printJSON(w io.Writer, data any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// printTable is writing header and rows as aligned table to w.
+func printTable(w io.Writer, header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range append([][]string{header}, rows...) {
+		for i, col := range row {
+			if i > 0 {
+				fmt.Fprint(tw, "\t")
+			}
+			fmt.Fprint(tw, col)
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// requireFlags is returning an error if one of the named flags of fs is empty.
+func requireFlags(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if f := fs.Lookup(name); f == nil || f.Value.String() == "" {
+			return fmt.Errorf("flag -%s is required", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/scraper"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+)
+
+// config is the configuration of the serve command. It can be loaded from a YAML file,
+// with flags and HTRACKER_* env vars overriding the values found in the file.
+type config struct {
+	LogLevel      string              `yaml:"log_level"`
+	Server        serverConfig        `yaml:"server"`
+	Storage       storageConfig       `yaml:"storage"`
+	Watcher       watcherConfig       `yaml:"watcher"`
+	Scraper       scraperConfig       `yaml:"scraper"`
+	Archive       archiveConfig       `yaml:"archive"`
+	Subscriptions subscriptionsConfig `yaml:"subscriptions"`
+}
+
+type serverConfig struct {
+	Addr        string        `yaml:"addr"`
+	GracePeriod time.Duration `yaml:"grace_period"`
+	// ExportRequestOptions is including the request options of subscriptions in clear text in the
+	// dumps of GET /api/admin/export. They are left out of all responses otherwise, as they might
+	// contain credentials.
+	ExportRequestOptions bool `yaml:"export_request_options"`
+}
+
+type storageConfig struct {
+	Backend     string `yaml:"backend"`
+	PostgresURI string `yaml:"postgres_uri"`
+	// SecretKey is the base64 encoded key for encrypting the request options of subscriptions at rest.
+	SecretKey string `yaml:"secret_key"`
+	// GCInterval is the interval of removing subscriptions, sites and blobs nobody is subscribed to, 0 disables it.
+	GCInterval time.Duration `yaml:"gc_interval"`
+}
+
+type watcherConfig struct {
+	Interval  time.Duration `yaml:"interval"`
+	Threads   int           `yaml:"threads"`
+	BatchSize int           `yaml:"batch_size"`
+	// LeaderElection is making only one of the instances sharing the postgres database scrape.
+	LeaderElection bool `yaml:"leader_election"`
+}
+
+type scraperConfig struct {
+	BrowserEndpoint   string        `yaml:"browser_endpoint"`
+	BrowserTabs       int           `yaml:"browser_tabs"`
+	Timeout           time.Duration `yaml:"timeout"`
+	UserAgent         string        `yaml:"user_agent"`
+	AllowedDomains    []string      `yaml:"allowed_domains"`
+	MaxBodySize       int64         `yaml:"max_body_size"`
+	RequestsPerSecond float64       `yaml:"requests_per_second"`
+	HostLimits        hostLimits    `yaml:"host_limits"`
+	Robots            bool          `yaml:"robots"`
+}
+
+// hostLimits is configuring the limits per host shared by all scrapers,
+// with overrides per domain which are applied to subdomains as well.
+type hostLimits struct {
+	hostLimit `yaml:",inline"`
+	Domains   map[string]hostLimit `yaml:"domains"`
+}
+
+type hostLimit struct {
+	MaxConcurrency int           `yaml:"max_concurrency"`
+	MinDelay       time.Duration `yaml:"min_delay"`
+}
+
+type archiveConfig struct {
+	DiffMaxSize int           `yaml:"diff_max_size"`
+	DiffTimeout time.Duration `yaml:"diff_timeout"`
+	// BlobStorage is the storage of the content of sites, keyed by its checksum. If empty,
+	// the content is stored with the sites.
+	BlobStorage string `yaml:"blob_storage"`
+	BlobDir     string `yaml:"blob_dir"`
+	// Compression is the compression of new blobs, only gzip is supported.
+	Compression string `yaml:"compression"`
+	// BlobGCGrace is the time blobs are kept by the garbage collector after their last put, even
+	// if no site is referencing them (yet).
+	BlobGCGrace time.Duration `yaml:"blob_gc_grace"`
+	// Retention is the retention policy of the versions of sites, whose subscription has none, in the
+	// format of htracker.ParseRetentionPolicy.
+	Retention string `yaml:"retention"`
+	// PruneInterval is the interval of removing the versions not kept by the retention policies, 0 disables it.
+	PruneInterval time.Duration `yaml:"prune_interval"`
+}
+
+type subscriptionsConfig struct {
+	SubscriberLimit   int `yaml:"subscriber_limit"`
+	SubscriptionLimit int `yaml:"subscription_limit"`
+}
+
+// defaultConfig is returning the configuration used if neither config file nor flags are given.
+func defaultConfig() *config {
+	return &config{
+		LogLevel: slog.LevelInfo.String(),
+		Server: serverConfig{
+			Addr:        ":8080",
+			GracePeriod: 10 * time.Second,
+		},
+		Storage: storageConfig{
+			Backend:     memoryBackend,
+			PostgresURI: "postgres://localhost?sslmode=disable",
+			GCInterval:  time.Hour,
+		},
+		Watcher: watcherConfig{
+			Interval:  time.Hour,
+			Threads:   2,
+			BatchSize: 4,
+		},
+		Scraper: scraperConfig{
+			BrowserEndpoint: "ws://localhost:3000",
+			BrowserTabs:     scraper.DefaultMaxTabs,
+			Timeout:         3 * time.Minute,
+			UserAgent:       scraper.DefaultUserAgent,
+			HostLimits: hostLimits{
+				hostLimit: hostLimit{MaxConcurrency: 1, MinDelay: time.Second},
+			},
+			Robots: true,
+		},
+		Archive: archiveConfig{
+			DiffMaxSize:   service.DefaultDiffMaxSize,
+			DiffTimeout:   service.DefaultDiffTimeout,
+			BlobGCGrace:   time.Hour,
+			Retention:     "last=10,daily=7,weekly=4",
+			PruneInterval: time.Hour,
+		},
+		Subscriptions: subscriptionsConfig{
+			SubscriberLimit:   100,
+			SubscriptionLimit: 100,
+		},
+	}
+}
+
+// loadConfig is reading the YAML config file at path on top of the default configuration.
+// Unknown keys are rejected, to not silently ignore typos.
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// applyFlags is overriding the configuration with all flags of the given FlagSets that were
+// set explicitly, either on the command line or via env vars.
+func (cfg *config) applyFlags(fss ...*flag.FlagSet) {
+	for _, fs := range fss {
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "loglevel":
+				cfg.LogLevel = *logLevelFlag
+			case "addr":
+				cfg.Server.Addr = *addrFlag
+			case "grace":
+				cfg.Server.GracePeriod = time.Duration(*gracePeriodFlag) * time.Second
+			case "backend":
+				cfg.Storage.Backend = *backendFlag
+			case "pguri":
+				cfg.Storage.PostgresURI = *postgresFlag
+			case "secretkey":
+				cfg.Storage.SecretKey = *secretKeyFlag
+			case "interval":
+				cfg.Watcher.Interval = time.Duration(*intervalFlag) * time.Second
+			case "threads":
+				cfg.Watcher.Threads = *threadsFlag
+			case "batchsize":
+				cfg.Watcher.BatchSize = *batchSizeFlag
+			case "ws":
+				cfg.Scraper.BrowserEndpoint = *chromeWSFlag
+			case "timeout":
+				cfg.Scraper.Timeout = *timeoutFlag
+			case "useragent":
+				cfg.Scraper.UserAgent = *userAgentFlag
+			case "domains":
+				cfg.Scraper.AllowedDomains = splitList(*domainsFlag)
+			case "maxbodysize":
+				cfg.Scraper.MaxBodySize = *maxBodySizeFlag
+			case "rps":
+				cfg.Scraper.RequestsPerSecond = *rpsFlag
+			case "hostconcurrency":
+				cfg.Scraper.HostLimits.MaxConcurrency = *hostConcurrencyFlag
+			case "hostdelay":
+				cfg.Scraper.HostLimits.MinDelay = *hostDelayFlag
+			case "robots":
+				cfg.Scraper.Robots = *robotsFlag
+			case "subscriberlimit":
+				cfg.Subscriptions.SubscriberLimit = *subscriberLimitFlag
+			case "subscriptionlimit":
+				cfg.Subscriptions.SubscriptionLimit = *subscriptionLimitFlag
+			}
+		})
+	}
+}
+
+// validate is checking the configuration for invalid values.
+func (cfg *config) validate() error {
+	var errs []string
+
+	if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if cfg.Server.Addr == "" {
+		errs = append(errs, "server.addr must not be empty")
+	}
+	if cfg.Server.GracePeriod < 0 {
+		errs = append(errs, "server.grace_period must not be negative")
+	}
+	switch cfg.Storage.Backend {
+	case memoryBackend:
+	case postgresBackend:
+		if cfg.Storage.PostgresURI == "" {
+			errs = append(errs, "storage.postgres_uri must be set for the postgres backend")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("storage backend %s not supported", cfg.Storage.Backend))
+	}
+	if _, err := storage.ParseCipher(cfg.Storage.SecretKey); err != nil {
+		errs = append(errs, "storage.secret_key: "+err.Error())
+	}
+	if cfg.Watcher.LeaderElection && cfg.Storage.Backend != postgresBackend {
+		errs = append(errs, "watcher.leader_election requires the postgres storage backend")
+	}
+	if cfg.Storage.GCInterval < 0 {
+		errs = append(errs, "storage.gc_interval must not be negative")
+	}
+	if cfg.Watcher.Interval <= 0 {
+		errs = append(errs, "watcher.interval must be positive")
+	}
+	if cfg.Watcher.Threads < 1 {
+		errs = append(errs, "watcher.threads must be at least 1")
+	}
+	if cfg.Watcher.BatchSize < 1 {
+		errs = append(errs, "watcher.batch_size must be at least 1")
+	}
+	if cfg.Scraper.BrowserTabs < 1 {
+		errs = append(errs, "scraper.browser_tabs must be at least 1")
+	}
+	if cfg.Scraper.Timeout < 0 {
+		errs = append(errs, "scraper.timeout must not be negative")
+	}
+	if cfg.Scraper.MaxBodySize < 0 {
+		errs = append(errs, "scraper.max_body_size must not be negative")
+	}
+	if cfg.Scraper.RequestsPerSecond < 0 {
+		errs = append(errs, "scraper.requests_per_second must not be negative")
+	}
+	if err := cfg.Scraper.HostLimits.validate("scraper.host_limits"); err != nil {
+		errs = append(errs, err.Error())
+	}
+	for domain, limit := range cfg.Scraper.HostLimits.Domains {
+		if err := limit.validate("scraper.host_limits.domains." + domain); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if cfg.Archive.DiffMaxSize < 0 {
+		errs = append(errs, "archive.diff_max_size must not be negative")
+	}
+	if cfg.Archive.DiffTimeout < 0 {
+		errs = append(errs, "archive.diff_timeout must not be negative")
+	}
+	switch cfg.Archive.BlobStorage {
+	case "":
+	case fileBlobStorage:
+		if cfg.Archive.BlobDir == "" {
+			errs = append(errs, "archive.blob_dir must be set for the file blob storage")
+		}
+	case postgresBackend:
+		if cfg.Storage.Backend != postgresBackend {
+			errs = append(errs, "the postgres blob storage requires the postgres storage backend")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("archive.blob_storage %s not supported", cfg.Archive.BlobStorage))
+	}
+	if cfg.Archive.BlobGCGrace <= 0 {
+		errs = append(errs, "archive.blob_gc_grace must be positive")
+	}
+	if _, err := htracker.ParseRetentionPolicy(cfg.Archive.Retention); err != nil {
+		errs = append(errs, "archive.retention: "+err.Error())
+	}
+	if cfg.Archive.PruneInterval < 0 {
+		errs = append(errs, "archive.prune_interval must not be negative")
+	}
+	if _, err := storage.ParseCompression(cfg.Archive.Compression); err != nil {
+		errs = append(errs, "archive.compression: "+err.Error())
+	}
+	if cfg.Subscriptions.SubscriberLimit < 1 {
+		errs = append(errs, "subscriptions.subscriber_limit must be at least 1")
+	}
+	if cfg.Subscriptions.SubscriptionLimit == 0 || cfg.Subscriptions.SubscriptionLimit < -1 {
+		errs = append(errs, "subscriptions.subscription_limit must be at least 1 or -1 for unlimited")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (l hostLimit) validate(key string) error {
+	if l.MaxConcurrency < 0 || l.MinDelay < 0 {
+		return fmt.Errorf("%s must not be negative", key)
+	}
+	return nil
+}
+
+// scraperLimits is returning the default limit and the overrides per domain for the HostLimiter.
+func (l hostLimits) scraperLimits() (scraper.HostLimit, map[string]scraper.HostLimit) {
+	overrides := make(map[string]scraper.HostLimit, len(l.Domains))
+	for domain, o := range l.Domains {
+		overrides[domain] = scraper.HostLimit(o)
+	}
+	return scraper.HostLimit(l.hostLimit), overrides
+}
+
+// restartRequired is returning the settings differing from the active configuration,
+// which can't be applied to the running components.
+func (cfg *config) restartRequired(active *config) []string {
+	changed := []string{}
+	if cfg.Server != active.Server {
+		changed = append(changed, "server")
+	}
+	if cfg.Storage != active.Storage {
+		changed = append(changed, "storage")
+	}
+	if cfg.Subscriptions != active.Subscriptions {
+		changed = append(changed, "subscriptions")
+	}
+	if cfg.Archive != active.Archive {
+		changed = append(changed, "archive")
+	}
+	if cfg.Scraper.Robots != active.Scraper.Robots {
+		changed = append(changed, "scraper.robots")
+	}
+	if cfg.Scraper.BrowserTabs != active.Scraper.BrowserTabs {
+		changed = append(changed, "scraper.browser_tabs")
+	}
+	if cfg.Watcher.LeaderElection != active.Watcher.LeaderElection {
+		changed = append(changed, "watcher.leader_election")
+	}
+	return changed
+}
+
+// keepRestartSettings is replacing the settings reported by restartRequired with the ones
+// of the active configuration.
+func (cfg *config) keepRestartSettings(active *config) {
+	cfg.Server = active.Server
+	cfg.Storage = active.Storage
+	cfg.Subscriptions = active.Subscriptions
+	cfg.Archive = active.Archive
+	cfg.Scraper.Robots = active.Scraper.Robots
+	cfg.Scraper.BrowserTabs = active.Scraper.BrowserTabs
+	cfg.Watcher.LeaderElection = active.Watcher.LeaderElection
+}
+
+// splitList is splitting a comma separated list, ignoring empty elements.
+func splitList(str string) []string {
+	list := []string{}
+	for _, s := range strings.Split(str, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_loadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		want    func(*config)
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			content: "",
+			want:    func(*config) {},
+		},
+		{
+			name: "partial",
+			content: `
+log_level: DEBUG
+watcher:
+  interval: 5m
+scraper:
+  allowed_domains: [example.com, example.org]
+`,
+			want: func(cfg *config) {
+				cfg.LogLevel = "DEBUG"
+				cfg.Watcher.Interval = 5 * time.Minute
+				cfg.Scraper.AllowedDomains = []string{"example.com", "example.org"}
+			},
+		},
+		{
+			name:    "unknown key",
+			content: "watcher:\n  intervall: 5m\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			content: "watcher:\n  interval: often\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := defaultConfig()
+			tt.want(want)
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("loadConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func Test_loadConfig_example(t *testing.T) {
+	cfg, err := loadConfig("htracker.example.yaml")
+	if err != nil {
+		t.Fatalf("loadConfig() failed: %v", err)
+	}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate() failed for example config: %v", err)
+	}
+}
+
+func Test_config_applyFlags(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Watcher.Threads = 8
+	cfg.Scraper.UserAgent = "from file"
+
+	if err := servefs.Parse([]string{"-interval", "60", "-domains", "a.test, b.test,", "-rps", "0.5"}); err != nil {
+		t.Fatal(err)
+	}
+	cfg.applyFlags(servefs)
+
+	if want, got := time.Minute, cfg.Watcher.Interval; want != got {
+		t.Errorf("Expected interval %v, got %v", want, got)
+	}
+	if want, got := []string{"a.test", "b.test"}, cfg.Scraper.AllowedDomains; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected allowed domains %v, got %v", want, got)
+	}
+	if want, got := 0.5, cfg.Scraper.RequestsPerSecond; want != got {
+		t.Errorf("Expected requests per second %v, got %v", want, got)
+	}
+	// flags not set explicitly must not override the values of the config file
+	if want, got := 8, cfg.Watcher.Threads; want != got {
+		t.Errorf("Expected threads %d, got %d", want, got)
+	}
+	if want, got := "from file", cfg.Scraper.UserAgent; want != got {
+		t.Errorf("Expected user agent %q, got %q", want, got)
+	}
+}
+
+func Test_config_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*config)
+		wantErr bool
+	}{
+		{name: "default", modify: func(*config) {}},
+		{name: "unlimited subscriptions", modify: func(cfg *config) { cfg.Subscriptions.SubscriptionLimit = -1 }},
+		{name: "log level", modify: func(cfg *config) { cfg.LogLevel = "LOUD" }, wantErr: true},
+		{name: "backend", modify: func(cfg *config) { cfg.Storage.Backend = "sqlite" }, wantErr: true},
+		{name: "postgres without uri", modify: func(cfg *config) {
+			cfg.Storage.Backend = postgresBackend
+			cfg.Storage.PostgresURI = ""
+		}, wantErr: true},
+		{name: "secret key", modify: func(cfg *config) { cfg.Storage.SecretKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" }},
+		{name: "short secret key", modify: func(cfg *config) { cfg.Storage.SecretKey = "MDEyMzQ1Njc4OWFiY2RlZg==" }, wantErr: true},
+		{name: "interval", modify: func(cfg *config) { cfg.Watcher.Interval = 0 }, wantErr: true},
+		{name: "threads", modify: func(cfg *config) { cfg.Watcher.Threads = 0 }, wantErr: true},
+		{name: "rps", modify: func(cfg *config) { cfg.Scraper.RequestsPerSecond = -1 }, wantErr: true},
+		{name: "browser tabs", modify: func(cfg *config) { cfg.Scraper.BrowserTabs = 0 }, wantErr: true},
+		{name: "unlimited diff size", modify: func(cfg *config) { cfg.Archive.DiffMaxSize = 0 }},
+		{name: "leader election without postgres", modify: func(cfg *config) { cfg.Watcher.LeaderElection = true }, wantErr: true},
+		{name: "gc disabled", modify: func(cfg *config) { cfg.Storage.GCInterval = 0 }},
+		{name: "gc interval", modify: func(cfg *config) { cfg.Storage.GCInterval = -time.Second }, wantErr: true},
+		{name: "diff timeout", modify: func(cfg *config) { cfg.Archive.DiffTimeout = -time.Second }, wantErr: true},
+		{name: "file blob storage", modify: func(cfg *config) {
+			cfg.Archive.BlobStorage, cfg.Archive.BlobDir, cfg.Archive.Compression = fileBlobStorage, "/var/lib/htracker", "gzip"
+		}},
+		{name: "file blob storage without dir", modify: func(cfg *config) { cfg.Archive.BlobStorage = fileBlobStorage }, wantErr: true},
+		{name: "postgres blob storage without postgres", modify: func(cfg *config) { cfg.Archive.BlobStorage = postgresBackend }, wantErr: true},
+		{name: "unknown blob storage", modify: func(cfg *config) { cfg.Archive.BlobStorage = "s3" }, wantErr: true},
+		{name: "unknown compression", modify: func(cfg *config) { cfg.Archive.Compression = "brotli" }, wantErr: true},
+		{name: "zstd compression", modify: func(cfg *config) { cfg.Archive.Compression = "zstd" }, wantErr: true},
+		{name: "keep all versions", modify: func(cfg *config) { cfg.Archive.Retention = "all" }},
+		{name: "retention", modify: func(cfg *config) { cfg.Archive.Retention = "monthly=3" }, wantErr: true},
+		{name: "prune interval", modify: func(cfg *config) { cfg.Archive.PruneInterval = -time.Second }, wantErr: true},
+		{name: "blob gc grace", modify: func(cfg *config) { cfg.Archive.BlobGCGrace = 0 }, wantErr: true},
+		{name: "subscription limit", modify: func(cfg *config) { cfg.Subscriptions.SubscriptionLimit = 0 }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			tt.modify(cfg)
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_config_restartRequired(t *testing.T) {
+	active := defaultConfig()
+	loaded := func() *config {
+		cfg := defaultConfig()
+		cfg.LogLevel = "DEBUG"
+		cfg.Server.Addr = ":9090"
+		cfg.Archive.DiffMaxSize = 1024
+		cfg.Scraper.BrowserTabs = 8
+		return cfg
+	}
+
+	// the settings requiring a restart are reported on every reload, until the restart
+	want := []string{"server", "archive", "scraper.browser_tabs"}
+	for i := 0; i < 2; i++ {
+		cfg := loaded()
+		if got := cfg.restartRequired(active); !reflect.DeepEqual(want, got) {
+			t.Errorf("reload %d: restartRequired() = %v, want %v", i, got, want)
+		}
+		cfg.keepRestartSettings(active)
+		if got := cfg.restartRequired(active); len(got) > 0 {
+			t.Errorf("reload %d: Expected all settings requiring a restart to be kept, got %v", i, got)
+		}
+		if cfg.LogLevel != "DEBUG" {
+			t.Errorf("reload %d: Expected log level to be applied, got %s", i, cfg.LogLevel)
+		}
+		active = cfg
+	}
+}
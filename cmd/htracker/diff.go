@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+)
+
+// diffFlags are the flags for rendering diffs.
+type diffFlags struct {
+	format  *string
+	context *int
+}
+
+// registerDiffFlags is adding the flags for rendering diffs to the given FlagSet.
+func registerDiffFlags(fs *flag.FlagSet) *diffFlags {
+	return &diffFlags{
+		format: fs.String("diffformat", service.DiffFormatUnified,
+			fmt.Sprintf("format of printed diffs (%s)", strings.Join(service.DiffFormats(), "|"))),
+		context: fs.Int("diffcontext", service.DefaultDiffContext, "unchanged lines printed around changes, -1 for all lines"),
+	}
+}
+
+// render is returning the diff in the format given by the flags.
+func (df *diffFlags) render(d htracker.Diff) (string, error) {
+	return service.FormatDiff(d, *df.format, *df.context)
+}
@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/peterbourgon/ff/v3"
 	"github.com/peterbourgon/ff/v3/ffcli"
@@ -25,43 +27,84 @@ func main() {
 		Name:       "serve",
 		ShortUsage: "htracker <flags> serve <serve flags>",
 		ShortHelp:  "start tracking sites and serving requests",
-		LongHelp:   `The serve subcommand is starting up all components for tracking websites, notifying subscribers and listening to requests.`,
+		LongHelp:   `The serve subcommand is starting up all components for tracking websites, notifying subscribers and listening to requests. On SIGHUP the configuration is reloaded and the log level, watcher and scraper settings are applied without a restart.`,
 		FlagSet:    servefs,
 		Exec:       newServeFunc(),
 		Options:    []ff.Option{ff.WithEnvVarPrefix(envVarPrefix)},
 	}
 
 	rootcmd := ffcli.Command{
-		Name:        "htracker",
-		ShortUsage:  "htracker <flags> cmd <cmd_flags>",
-		ShortHelp:   "htracker is a tool for tracking changes on websites",
-		FlagSet:     rootfs,
-		Subcommands: []*ffcli.Command{servecmd},
-		Options:     []ff.Option{ff.WithEnvVarPrefix(envVarPrefix)},
+		Name:       "htracker",
+		ShortUsage: "htracker <flags> cmd <cmd_flags>",
+		ShortHelp:  "htracker is a tool for tracking changes on websites",
+		FlagSet:    rootfs,
+		Subcommands: []*ffcli.Command{servecmd, newCheckCmd(), newSubscriberCmd(), newSubscriptionCmd(), newSiteCmd(),
+			newExportCmd(), newImportCmd()},
+		Options: []ff.Option{ff.WithEnvVarPrefix(envVarPrefix)},
 	}
 
 	if err := rootcmd.ParseAndRun(ctx, os.Args[1:]); err != nil {
-		fmt.Println(err)
+		if errors.Is(err, errChanged) {
+			os.Exit(exitCodeChanged)
+		}
+		// usage was already printed if help was requested
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
 
+// setEnvFlags is setting the named flags of fs, which were not given on the command line, from their
+// HTRACKER_* env vars. The subcommands other than serve are reading the env vars of the settings shared
+// with serve only, as generic names like HTRACKER_URL or HTRACKER_TIMEOUT would apply to all of them.
+func setEnvFlags(fs *flag.FlagSet, names ...string) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, name := range names {
+		if given[name] {
+			continue
+		}
+		key := envVarPrefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		if value, ok := os.LookupEnv(key); ok {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("failed to set flag -%s from %s: %w", name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
 func createLogger(levelStr string) (*slog.Logger, error) {
-	var lvl slog.Level
+	logger, _, err := createLevelLogger(levelStr)
+	return logger, err
+}
+
+// createLevelLogger is returning a logger together with its LevelVar, for changing the log level at runtime.
+func createLevelLogger(levelStr string) (*slog.Logger, *slog.LevelVar, error) {
+	lvl, err := parseLogLevel(levelStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	levelVar := &slog.LevelVar{}
+	levelVar.Set(lvl)
+	return slog.New(slog.HandlerOptions{Level: levelVar}.NewTextHandler(os.Stdout)), levelVar, nil
+}
 
+// parseLogLevel is parsing the log levels supported by the loglevel flag.
+func parseLogLevel(levelStr string) (slog.Level, error) {
 	switch levelStr {
 	case slog.LevelDebug.String():
-		lvl = slog.LevelDebug
+		return slog.LevelDebug, nil
 	case slog.LevelInfo.String():
-		lvl = slog.LevelInfo
+		return slog.LevelInfo, nil
 	case slog.LevelWarn.String():
-		lvl = slog.LevelWarn
+		return slog.LevelWarn, nil
 	case slog.LevelError.String():
-		lvl = slog.LevelError
+		return slog.LevelError, nil
 	case "OFF":
-		lvl = slog.Level(99)
+		return slog.Level(99), nil
 	default:
-		return nil, fmt.Errorf("log level %s not supported", *logLevelFlag)
+		return 0, fmt.Errorf("log level %s not supported", levelStr)
 	}
-	return slog.New(slog.HandlerOptions{Level: lvl}.NewTextHandler(os.Stdout)), nil
 }
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"testing"
+)
+
+func Test_setEnvFlags(t *testing.T) {
+	t.Setenv("HTRACKER_PGURI", "postgres://env")
+	t.Setenv("HTRACKER_SERVER", "http://env")
+	t.Setenv("HTRACKER_URL", "http://site.example")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cf := registerClientFlags(fs)
+	url := fs.String("url", "", "url of the site")
+	if err := fs.Parse([]string{"-server", "http://flag"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.parseEnv(); err != nil {
+		t.Fatalf("parseEnv() failed: %v", err)
+	}
+
+	if want, got := "postgres://env", *cf.pguri; want != got {
+		t.Errorf("Expected pguri %q, got %q", want, got)
+	}
+	// flags given on the command line are overriding the env vars
+	if want, got := "http://flag", *cf.server; want != got {
+		t.Errorf("Expected server %q, got %q", want, got)
+	}
+	// other flags of the subcommands are not read from env vars
+	if *url != "" {
+		t.Errorf("Expected url to be left empty, got %q", *url)
+	}
+}
@@ -8,13 +8,18 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/oklog/run"
+	"gitlab.com/henri.philipps/htracker"
 	httptransport "gitlab.com/henri.philipps/htracker/http"
+	"gitlab.com/henri.philipps/htracker/robots"
 	"gitlab.com/henri.philipps/htracker/scraper"
 	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"gitlab.com/henri.philipps/htracker/storage/file"
 	"gitlab.com/henri.philipps/htracker/storage/memory"
 	"gitlab.com/henri.philipps/htracker/storage/postgres"
 	"gitlab.com/henri.philipps/htracker/watcher"
@@ -23,69 +28,183 @@ import (
 
 const memoryBackend = "memory"
 const postgresBackend = "postgres"
+const fileBlobStorage = "file"
 
 var (
-	servefs         = flag.NewFlagSet("serve", flag.ExitOnError)
-	addrFlag        = servefs.String("addr", ":8080", "address the server is listening on")
-	chromeWSFlag    = servefs.String("ws", "ws://localhost:3000", "websocket url of chrome instance to connect to for site rendering")
-	intervalFlag    = servefs.Int("interval", 3600, "interval in seconds between watcher runs")
-	gracePeriodFlag = servefs.Int("grace", 10, "shutdown grace period in seconds")
-	backendFlag     = servefs.String("backend", memoryBackend, "the storage backend (memory|postgres)")
-	postgresFlag    = servefs.String("pguri", "postgres://localhost?sslmode=disable", "postgres connection uri")
+	defaults              = defaultConfig()
+	servefs               = flag.NewFlagSet("serve", flag.ExitOnError)
+	configFlag            = servefs.String("config", "", "path of a YAML config file, flags and env vars are overriding its values")
+	addrFlag              = servefs.String("addr", defaults.Server.Addr, "address the server is listening on")
+	chromeWSFlag          = servefs.String("ws", defaults.Scraper.BrowserEndpoint, "websocket url of chrome instance to connect to for site rendering")
+	intervalFlag          = servefs.Int("interval", int(defaults.Watcher.Interval.Seconds()), "interval in seconds between watcher runs")
+	gracePeriodFlag       = servefs.Int("grace", int(defaults.Server.GracePeriod.Seconds()), "shutdown grace period in seconds")
+	backendFlag           = servefs.String("backend", defaults.Storage.Backend, "the storage backend (memory|postgres)")
+	postgresFlag          = servefs.String("pguri", defaults.Storage.PostgresURI, "postgres connection uri")
+	secretKeyFlag         = servefs.String("secretkey", "", "base64 encoded 32 byte key for encrypting credentials of subscriptions at rest")
+	threadsFlag           = servefs.Int("threads", defaults.Watcher.Threads, "number of scrapers running in parallel")
+	batchSizeFlag         = servefs.Int("batchsize", defaults.Watcher.BatchSize, "number of sites scraped by one scraper")
+	timeoutFlag           = servefs.Duration("timeout", defaults.Scraper.Timeout, "timeout of scrape requests")
+	userAgentFlag         = servefs.String("useragent", defaults.Scraper.UserAgent, "user agent sent by the scrapers")
+	domainsFlag           = servefs.String("domains", "", "comma separated list of domains allowed to be scraped, all domains if empty")
+	maxBodySizeFlag       = servefs.Int64("maxbodysize", defaults.Scraper.MaxBodySize, "max body size in bytes read from scraped sites, 0 means default of 1GB")
+	rpsFlag               = servefs.Float64("rps", defaults.Scraper.RequestsPerSecond, "max requests per second of a scraper, 0 means unlimited")
+	hostConcurrencyFlag   = servefs.Int("hostconcurrency", defaults.Scraper.HostLimits.MaxConcurrency, "max concurrent requests per host across all scrapers, 0 means unlimited")
+	hostDelayFlag         = servefs.Duration("hostdelay", defaults.Scraper.HostLimits.MinDelay, "min delay between requests to the same host across all scrapers")
+	robotsFlag            = servefs.Bool("robots", defaults.Scraper.Robots, "obey robots.txt of the scraped sites and reject subscriptions to disallowed sites")
+	subscriberLimitFlag   = servefs.Int("subscriberlimit", defaults.Subscriptions.SubscriberLimit, "max number of subscribers")
+	subscriptionLimitFlag = servefs.Int("subscriptionlimit", defaults.Subscriptions.SubscriptionLimit, "default max number of subscriptions per subscriber, -1 means unlimited")
 )
 
+// serveConfig is loading the config file if given, overriding it with the flags which were set
+// explicitly or via env vars, and validating the result.
+func serveConfig() (*config, error) {
+	cfg := defaultConfig()
+	if *configFlag != "" {
+		var err error
+		if cfg, err = loadConfig(*configFlag); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.applyFlags(rootfs, servefs)
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
 // newServeFunc creates the func which is executed by servecmd.
 func newServeFunc() func(context.Context, []string) error {
 
 	return func(serveCtx context.Context, args []string) error {
-		ctx, cancel := context.WithCancel(serveCtx)
-		logger, err := createLogger(*logLevelFlag)
+		cfg, err := serveConfig()
 		if err != nil {
 			return err
 		}
 
+		logger, levelVar, err := createLevelLogger(cfg.LogLevel)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(serveCtx)
+		defer cancel()
+
 		var archive service.SiteArchive
 		var subscriptionSvc service.SubscriptionSvc
+		var gcStorage storage.GarbageCollector
+		var blobs storage.BlobStorage
+		var blobReferrer storage.BlobReferrer
+		var versions storage.VersionStorage
+		var notifier storage.ChangeNotifier
+		var elector watcher.Elector
+
+		subscriptionSvcOpts := []service.SubscriptionSvcOpt{
+			service.WithLogger(logger),
+			service.WithSubscriberLimit(cfg.Subscriptions.SubscriberLimit),
+			service.WithSubscriptionLimit(cfg.Subscriptions.SubscriptionLimit),
+		}
 
-		switch *backendFlag {
+		// the robots.txt policy is shared by the subscription service and all scrapers, to share its cache
+		var policy *robots.Policy
+		if cfg.Scraper.Robots {
+			policy = robots.New(cfg.Scraper.UserAgent, robots.WithLogger(logger))
+			subscriptionSvcOpts = append(subscriptionSvcOpts, service.WithRobotsChecker(policy))
+		}
+
+		archiveOpts := []service.SiteArchiveOpt{
+			service.WithTextDiffer(service.TextDiffer{MaxSize: cfg.Archive.DiffMaxSize, Timeout: cfg.Archive.DiffTimeout}),
+			service.WithArchiveLogger(logger),
+		}
+		// ParseCompression can't fail, as the configuration was validated already
+		compression, _ := storage.ParseCompression(cfg.Archive.Compression)
+		if cfg.Archive.BlobStorage == fileBlobStorage {
+			blobs = file.NewBlobStorage(cfg.Archive.BlobDir, logger, file.WithCompression(compression))
+			archiveOpts = append(archiveOpts, service.WithBlobStorage(blobs))
+		}
+
+		switch cfg.Storage.Backend {
 		case memoryBackend:
-			archive = service.NewSiteArchive(memory.NewSiteStorage(logger))
-			subscriptionSvc = service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger))
+			// sites and subscriptions are sharing a storage, so that orphan sites can be removed
+			mem := memory.NewStorage(logger)
+			notifier = memory.NewChangeNotifier(logger)
+			versions = mem
+			archiveOpts = append(archiveOpts, service.WithChangeNotifier(notifier), service.WithVersionHistory(versions))
+			archive = service.NewSiteArchive(mem, archiveOpts...)
+			subscriptionSvc = service.NewSubscriptionSvc(mem, subscriptionSvcOpts...)
+			gcStorage, blobReferrer = mem, mem
 		case postgresBackend:
-			storage, err := postgres.New(*postgresFlag, logger)
+			cipher, err := storage.ParseCipher(cfg.Storage.SecretKey)
+			if err != nil {
+				return err
+			}
+			db, err := postgres.New(cfg.Storage.PostgresURI, logger, postgres.WithCipher(cipher))
 			if err != nil {
 				return err
 			}
-			archive = service.NewSiteArchive(storage)
-			subscriptionSvc = service.NewSubscriptionSvc(storage, service.WithLogger(logger))
+			if cfg.Archive.BlobStorage == postgresBackend {
+				blobs = db.BlobStorage(postgres.WithBlobCompression(compression))
+				archiveOpts = append(archiveOpts, service.WithBlobStorage(blobs))
+			}
+			// changes are announced to all instances using the database
+			notifier = db
+			versions = db
+			archiveOpts = append(archiveOpts, service.WithChangeNotifier(notifier), service.WithVersionHistory(versions))
+			archive = service.NewSiteArchive(db, archiveOpts...)
+			subscriptionSvc = service.NewSubscriptionSvc(db, subscriptionSvcOpts...)
+			gcStorage, blobReferrer = db, db
+			if cfg.Watcher.LeaderElection {
+				elector = db.LeaderElector("htracker-watcher")
+			}
 		default:
-			return fmt.Errorf("storage backend %s not supported", *backendFlag)
-		}
-
-		watcherOpts := []watcher.Opt{
-			watcher.WithInterval(time.Duration(*intervalFlag) * time.Second),
-			watcher.WithLogger(logger),
+			return fmt.Errorf("storage backend %s not supported", cfg.Storage.Backend)
 		}
 
-		if *chromeWSFlag != "" {
-			watcherOpts = append(watcherOpts, watcher.WithScraperOpts(scraper.WithBrowserEndpoint(*chromeWSFlag)))
+		// the host limiter is shared by all scrapers, to enforce the limits across all of them
+		limiter := scraper.NewHostLimiter(cfg.Scraper.HostLimits.scraperLimits())
+		// the browser session is shared by all scrapers, they are rendering sites in tabs of the same browser
+		browser := scraper.NewBrowser(cfg.Scraper.BrowserEndpoint, scraper.WithMaxTabs(cfg.Scraper.BrowserTabs),
+			scraper.WithBrowserLogger(logger))
+		defer browser.Close()
+		watcherOpts := append(newWatcherOpts(cfg, limiter, policy, browser), watcher.WithLogger(logger))
+		if elector != nil {
+			watcherOpts = append(watcherOpts, watcher.WithElector(elector))
 		}
 
 		watcher := watcher.NewWatcher(archive, subscriptionSvc, watcherOpts...)
-		router := httptransport.MakeAPIHandler(archive, subscriptionSvc, logger)
+		var apiOpts []httptransport.APIOpt
+		if cfg.Server.ExportRequestOptions {
+			apiOpts = append(apiOpts, httptransport.WithRequestOptionsExport())
+		}
+		router := httptransport.MakeAPIHandler(archive, subscriptionSvc, logger, apiOpts...)
+		router.Get("/api/health", httptransport.MakeHealthHandler(map[string]httptransport.HealthChecker{"browser": browser}))
+		// the changes of all instances are streamed to the clients of this one, sharing one listener of the notifier
+		streams := memory.NewChangeNotifier(logger)
+		router.Get("/api/changes", httptransport.MakeChangesHandler(ctx, streams, logger))
 
 		// the run group will take care of running and shutting down all background components
 		g := run.Group{}
 
 		// add handler for signals to run group, for shutting down all components on SIGINT and SIGTERM
+		// and reloading the configuration on SIGHUP
 		g.Add(func() error {
 			c := make(chan os.Signal, 1)
-			signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-			select {
-			case sig := <-c:
-				return fmt.Errorf("catched signal %v", sig)
-			case <-ctx.Done():
-				return ctx.Err()
+			signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+			defer signal.Stop(c)
+
+			active := cfg
+			for {
+				select {
+				case sig := <-c:
+					if sig == syscall.SIGHUP {
+						active = reloadConfig(active, levelVar, limiter, policy, browser, watcher, logger)
+						continue
+					}
+					return fmt.Errorf("catched signal %v", sig)
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 			}
 		}, func(error) {
 			cancel()
@@ -94,20 +213,61 @@ func newServeFunc() func(context.Context, []string) error {
 		// add watcher to run group
 		g.Add(func() error { return watcher.Start(ctx) }, func(error) { cancel() })
 
+		// add listener of the changes archived by all instances to run group, forwarding them to the streams
+		g.Add(func() error {
+			events, err := notifier.ListenChanges(ctx)
+			if err != nil {
+				return fmt.Errorf("ChangeNotifier.ListenChanges(): %w", err)
+			}
+			for event := range events {
+				logger.Info("site changed", slog.String("url", event.Subscription.URL),
+					slog.String("filter", event.Subscription.Filter), slog.Time("last_updated", event.LastUpdated))
+				// the in-memory notifier can't fail
+				_ = streams.NotifyChange(ctx, event)
+			}
+			return ctx.Err()
+		}, func(error) { cancel() })
+
+		// add garbage collector of orphan subscriptions, sites and blobs to run group
+		var gc *service.GarbageCollector
+		if cfg.Storage.GCInterval > 0 {
+			var gcOpts []service.GarbageCollectorOpt
+			if blobs != nil {
+				gcOpts = append(gcOpts, service.WithBlobCollection(blobs, blobReferrer, cfg.Archive.BlobGCGrace))
+			}
+			gc = service.NewGarbageCollector(gcStorage, cfg.Storage.GCInterval, logger, gcOpts...)
+			g.Add(func() error { return gc.Start(ctx) }, func(error) { cancel() })
+		}
+
+		// add pruner of the versions of sites to run group
+		var pruner *service.Pruner
+		if cfg.Archive.PruneInterval > 0 {
+			retention, err := htracker.ParseRetentionPolicy(cfg.Archive.Retention)
+			if err != nil {
+				return fmt.Errorf("archive.retention: %w", err)
+			}
+			pruner = service.NewPruner(versions, *retention, cfg.Archive.PruneInterval, logger)
+			g.Add(func() error { return pruner.Start(ctx) }, func(error) { cancel() })
+		}
+		// the totals of the jobs are reported by the API, the ones of disabled jobs are left out
+		router.Get("/api/admin/stats", httptransport.MakeStatsHandler(gc, pruner))
+
 		// Instead of ListenAndServe(), which can't be interrupted, we create our own
 		// Server and add it's Serve() method to the run group later.
 		// We set ReadHeaderTimeout to prevent Slowloris attacks.
 		server := http.Server{Handler: router, ReadHeaderTimeout: 5 * time.Second}
 
-		logger.Info("start listening...", slog.String("listen_addr", *addrFlag))
-		ln, err := net.Listen("tcp", *addrFlag)
+		logger.Info("start listening...", slog.String("listen_addr", cfg.Server.Addr))
+		ln, err := net.Listen("tcp", cfg.Server.Addr)
 		if err != nil {
 			logger.Error("failed to start server, exiting", err)
 			return err
 		}
 
-		graceTimeoutCtx, _ := context.WithTimeout(ctx, time.Duration(*gracePeriodFlag)*time.Second)
 		g.Add(func() error { return server.Serve(ln) }, func(error) {
+			// ctx is already canceled at this point, so the grace period needs a fresh context
+			graceTimeoutCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.GracePeriod)
+			defer cancel()
 			if err := server.Shutdown(graceTimeoutCtx); err != nil {
 				logger.Error("graceful shutdown error", err)
 			}
@@ -118,3 +278,66 @@ func newServeFunc() func(context.Context, []string) error {
 		return err
 	}
 }
+
+// newWatcherOpts is returning the watcher options of the given configuration, including the scraper options.
+// The robots.txt policy is only applied if not nil.
+func newWatcherOpts(cfg *config, limiter *scraper.HostLimiter, policy *robots.Policy, browser *scraper.Browser) []watcher.Opt {
+	scraperOpts := []scraper.Opt{
+		scraper.WithHostLimiter(limiter),
+		scraper.WithBrowser(browser),
+		scraper.WithTimeout(cfg.Scraper.Timeout),
+		scraper.WithUserAgent(cfg.Scraper.UserAgent),
+		scraper.WithMaxBodySize(cfg.Scraper.MaxBodySize),
+		scraper.WithRequestsPerSecond(cfg.Scraper.RequestsPerSecond),
+	}
+
+	if len(cfg.Scraper.AllowedDomains) > 0 {
+		scraperOpts = append(scraperOpts, scraper.WithAllowedDomains(cfg.Scraper.AllowedDomains))
+	}
+
+	if policy != nil {
+		scraperOpts = append(scraperOpts, scraper.WithRobots(policy))
+	}
+
+	return []watcher.Opt{
+		watcher.WithInterval(cfg.Watcher.Interval),
+		watcher.WithThreads(cfg.Watcher.Threads),
+		watcher.WithBatchSize(cfg.Watcher.BatchSize),
+		watcher.WithScraperOpts(scraperOpts...),
+	}
+}
+
+// reloadConfig is re-reading the configuration and applying the log level, watcher and scraper
+// settings to the running components. Changes of other settings are only logged, as they require
+// a restart. If the new configuration is invalid, the active one is kept and returned.
+func reloadConfig(active *config, levelVar *slog.LevelVar, limiter *scraper.HostLimiter, policy *robots.Policy,
+	browser *scraper.Browser, w *watcher.Watcher, logger *slog.Logger) *config {
+	logger.Info("reloading configuration")
+
+	cfg, err := serveConfig()
+	if err != nil {
+		logger.Error("failed to reload configuration, keeping active configuration", err)
+		return active
+	}
+
+	if changed := cfg.restartRequired(active); len(changed) > 0 {
+		logger.Warn("changed settings require a restart and are ignored", "settings", strings.Join(changed, ","))
+	}
+
+	// parseLogLevel can't fail, as the configuration was validated already
+	lvl, _ := parseLogLevel(cfg.LogLevel)
+	levelVar.Set(lvl)
+	limiter.SetLimits(cfg.Scraper.HostLimits.scraperLimits())
+	if policy != nil {
+		policy.SetUserAgent(cfg.Scraper.UserAgent)
+	}
+	browser.SetEndpoint(cfg.Scraper.BrowserEndpoint)
+	w.Reconfigure(newWatcherOpts(cfg, limiter, policy, browser)...)
+
+	logger.Info("configuration reloaded", "log_level", cfg.LogLevel)
+
+	// keep the settings which were not applied, to warn again on the next reload
+	cfg.keepRestartSettings(active)
+
+	return cfg
+}
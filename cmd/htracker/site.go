@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"gitlab.com/henri.philipps/htracker/service"
+)
+
+// newSiteCmd is creating the site command with its subcommands for inspecting the site archive.
+func newSiteCmd() *ffcli.Command {
+	return &ffcli.Command{
+		Name:        "site",
+		ShortUsage:  "htracker <flags> site show <flags>",
+		ShortHelp:   "inspect archived sites",
+		Subcommands: []*ffcli.Command{newSiteShowCmd(), newSiteScreenshotCmd()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+func newSiteShowCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("site show", flag.ExitOnError)
+	cf := registerClientFlags(fs).withOutput(fs)
+	sf := registerSubscriptionFlags(fs)
+	showContent := fs.Bool("content", false, "also print the archived content of the site")
+	df := registerDiffFlags(fs)
+
+	return &ffcli.Command{
+		Name:       "show",
+		ShortUsage: "htracker site show -url <url> [<subscription flags>]",
+		ShortHelp:  "show the archived state of a site",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "url"); err != nil {
+				return err
+			}
+			_, archive, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			subscription, err := sf.subscription()
+			if err != nil {
+				return err
+			}
+			site, err := archive.Get(ctx, subscription)
+			if err != nil {
+				return err
+			}
+
+			if !*showContent {
+				site.Content = nil
+			}
+			// the images are written by the screenshot command
+			if site.Screenshot != nil {
+				site.Screenshot.Image, site.Screenshot.Previous = nil, nil
+			}
+
+			rows := [][]string{
+				{"METHOD", site.Subscription.HTTPMethod()},
+				{"URL", site.Subscription.URL},
+				{"FILTER", site.Subscription.Filter},
+				{"CONTENT TYPE", site.Subscription.ContentType},
+				{"BODY", site.Subscription.Body},
+				{"LAST CHECKED", site.LastChecked.Format(time.RFC3339)},
+				{"LAST UPDATED", site.LastUpdated.Format(time.RFC3339)},
+				{"CHECKSUM", site.Checksum},
+				{"STATE", string(site.State)},
+			}
+			if m := site.Metrics; m != nil {
+				rows = append(rows,
+					[]string{"CHANGED", fmt.Sprintf("%.1f%% (+%d/-%d chars, +%d/-%d lines)", m.Changed, m.InsertedChars,
+						m.DeletedChars, m.InsertedLines, m.DeletedLines)},
+					[]string{"SIMILARITY", fmt.Sprintf("%.2f", m.Similarity)})
+			}
+			if site.Screenshot != nil {
+				rows = append(rows, []string{"PIXEL DIFF", fmt.Sprintf("%.1f%%", site.Screenshot.PixelDiff)})
+			}
+			if err := cf.print(site, []string{"FIELD", "VALUE"}, rows); err != nil {
+				return err
+			}
+
+			if *cf.output == outputTable {
+				diff, err := df.render(site.Diff)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(os.Stdout, "\nDIFF:\n%s\n", diff)
+				if *showContent {
+					fmt.Fprintf(os.Stdout, "\nCONTENT:\n%s\n", site.Content)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newSiteScreenshotCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("site screenshot", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	sf := registerSubscriptionFlags(fs)
+	image := fs.String("image", service.ScreenshotAfter, "image of the screenshot, 'after' for the latest screenshot, 'before' for the one before the latest visual change, 'diff' for the changed pixels")
+	out := fs.String("out", "screenshot.png", "path of the written PNG file")
+
+	return &ffcli.Command{
+		Name:       "screenshot",
+		ShortUsage: "htracker site screenshot -url <url> [<subscription flags>] [-image before|after|diff] [-out <file>]",
+		ShortHelp:  "write an archived screenshot of a site to a PNG file",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "url", "out"); err != nil {
+				return err
+			}
+			_, archive, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			subscription, err := sf.subscription()
+			if err != nil {
+				return err
+			}
+			site, err := archive.Get(ctx, subscription)
+			if err != nil {
+				return err
+			}
+
+			png, err := service.ScreenshotImage(site, *image)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(*out, png, 0o644)
+		},
+	}
+}
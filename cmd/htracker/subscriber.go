@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strconv"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"gitlab.com/henri.philipps/htracker/service"
+)
+
+// newSubscriberCmd is creating the subscriber command with its subcommands for managing subscribers.
+func newSubscriberCmd() *ffcli.Command {
+	return &ffcli.Command{
+		Name:        "subscriber",
+		ShortUsage:  "htracker <flags> subscriber add|list|delete <flags>",
+		ShortHelp:   "manage subscribers",
+		Subcommands: []*ffcli.Command{newSubscriberAddCmd(), newSubscriberListCmd(), newSubscriberDeleteCmd()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+func newSubscriberAddCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("subscriber add", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	email := fs.String("email", "", "email of the new subscriber")
+	limit := fs.Int("limit", 0, "maximum number of subscriptions (0: server default, -1: unlimited)")
+
+	return &ffcli.Command{
+		Name:       "add",
+		ShortUsage: "htracker subscriber add -email <email> [-limit <n>]",
+		ShortHelp:  "add a new subscriber",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "email"); err != nil {
+				return err
+			}
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			return svc.AddSubscriber(ctx, &service.Subscriber{Email: *email, SubscriptionLimit: *limit})
+		},
+	}
+}
+
+func newSubscriberListCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("subscriber list", flag.ExitOnError)
+	cf := registerClientFlags(fs).withOutput(fs)
+
+	return &ffcli.Command{
+		Name:       "list",
+		ShortUsage: "htracker subscriber list",
+		ShortHelp:  "list all subscribers",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			subscribers, err := svc.GetSubscribers(ctx)
+			if err != nil {
+				return err
+			}
+
+			rows := make([][]string, len(subscribers))
+			for i, s := range subscribers {
+				rows[i] = []string{s.Email, strconv.Itoa(s.SubscriptionLimit), strconv.Itoa(len(s.Subscriptions))}
+			}
+			return cf.print(subscribers, []string{"EMAIL", "LIMIT", "SUBSCRIPTIONS"}, rows)
+		},
+	}
+}
+
+func newSubscriberDeleteCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("subscriber delete", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	email := fs.String("email", "", "email of the subscriber to be deleted")
+
+	return &ffcli.Command{
+		Name:       "delete",
+		ShortUsage: "htracker subscriber delete -email <email>",
+		ShortHelp:  "delete a subscriber with all its subscriptions",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "email"); err != nil {
+				return err
+			}
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			return svc.DeleteSubscriber(ctx, *email)
+		},
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// subscriptionFlags are the flags describing a subscription.
+type subscriptionFlags struct {
+	url         *string
+	filter      *string
+	contentType *string
+	useChrome   *bool
+	fallback    *bool
+	screenshot  *bool
+	normalize   *string
+	diffMode    *string
+	minChange   *float64
+	minPixel    *float64
+	retention   *string
+	method      *string
+	body        *string
+}
+
+// registerSubscriptionFlags is adding the flags describing a subscription to the given FlagSet.
+func registerSubscriptionFlags(fs *flag.FlagSet) *subscriptionFlags {
+	return &subscriptionFlags{
+		url:         fs.String("url", "", "url of the watched site"),
+		filter:      fs.String("filter", "", "css selector or regexp for filtering the site content"),
+		contentType: fs.String("contenttype", "", "content type of the site, 'feed' for comparing the items of RSS and Atom feeds, 'links' for comparing the links of a sitemap or of the anchors matching the filter"),
+		useChrome:   fs.Bool("chrome", false, "render the site with chrome"),
+		fallback:    fs.Bool("fallback", false, "scrape the site without rendering if chrome is unavailable"),
+		screenshot:  fs.Bool("screenshot", false, "capture a screenshot of the rendered site (of the element matching the filter) and report visual changes"),
+		normalize:   fs.String("normalize", "", "normalization of the content before comparing it, 'whitespace' for collapsing whitespace, 'none' for comparing the content as scraped"),
+		diffMode:    fs.String("diffmode", "", "units compared when diffing the content, 'line' or 'word' instead of characters, e.g. for big pages"),
+		minChange:   fs.Float64("minchange", 0, "min percentage of changed content reported as update, smaller changes are archived silently"),
+		minPixel:    fs.Float64("minpixelchange", 0, "min percentage of changed pixels of the screenshot reported as update, smaller visual changes are archived silently"),
+		retention:   fs.String("retention", "", "versions of the content kept, e.g. 'last=10,within=72h,daily=7,weekly=4' or 'all', the default policy of the server if empty"),
+		method:      fs.String("method", "GET", "http method used for requesting the site (GET|POST)"),
+		body:        fs.String("body", "", "request body, e.g. form fields 'a=1&b=2' (use -header for other content types than forms)"),
+	}
+}
+
+// subscription is returning the subscription described by the flags, or an error if the retention
+// policy is invalid.
+func (sf *subscriptionFlags) subscription() (*htracker.Subscription, error) {
+	var retention *htracker.RetentionPolicy
+	if *sf.retention != "" {
+		var err error
+		if retention, err = htracker.ParseRetentionPolicy(*sf.retention); err != nil {
+			return nil, fmt.Errorf("invalid retention flag: %w", err)
+		}
+	}
+	return &htracker.Subscription{
+		URL:            *sf.url,
+		Filter:         *sf.filter,
+		ContentType:    *sf.contentType,
+		UseChrome:      *sf.useChrome,
+		ChromeFallback: *sf.fallback,
+		Screenshot:     *sf.screenshot,
+		Normalize:      *sf.normalize,
+		DiffMode:       *sf.diffMode,
+		MinChange:      *sf.minChange,
+		MinPixelChange: *sf.minPixel,
+		Retention:      retention,
+		Method:         *sf.method,
+		Body:           *sf.body,
+	}, nil
+}
+
+// requestFlags are the flags describing the request options of a subscription.
+type requestFlags struct {
+	headers   *keyValueFlag
+	cookies   *keyValueFlag
+	basicAuth *string
+	actions   *actionsFlag
+}
+
+// registerRequestFlags is adding the flags describing the request options of a subscription to the given FlagSet.
+func registerRequestFlags(fs *flag.FlagSet) *requestFlags {
+	rf := &requestFlags{
+		headers:   &keyValueFlag{sep: ":"},
+		cookies:   &keyValueFlag{sep: "="},
+		basicAuth: fs.String("basicauth", "", "credentials 'user:password' for sites behind basic authentication"),
+		actions:   &actionsFlag{},
+	}
+	fs.Var(rf.headers, "header", "header 'Name: value' sent with each request, can be repeated")
+	fs.Var(rf.cookies, "cookie", "cookie 'name=value' sent with each request, can be repeated")
+	fs.Var(rf.actions, "action", "chrome action run before capturing the site, can be repeated: "+
+		"wait:<selector>|click:<selector>|type:<selector>=<text>|sleep:<duration>|scroll[:<selector>]")
+	return rf
+}
+
+// requestOptions is returning the request options described by the flags, or nil if none are set.
+func (rf *requestFlags) requestOptions() (*htracker.RequestOptions, error) {
+	if len(rf.headers.values) == 0 && len(rf.cookies.values) == 0 && *rf.basicAuth == "" && len(rf.actions.actions) == 0 {
+		return nil, nil
+	}
+
+	opts := &htracker.RequestOptions{Headers: rf.headers.values, Cookies: rf.cookies.values, Actions: rf.actions.actions}
+	if *rf.basicAuth != "" {
+		user, password, ok := strings.Cut(*rf.basicAuth, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid basic auth credentials, expected 'user:password'")
+		}
+		opts.BasicAuth = &htracker.BasicAuth{Username: user, Password: password}
+	}
+	return opts, nil
+}
+
+// keyValueFlag is a repeatable flag collecting key value pairs separated by sep.
+type keyValueFlag struct {
+	sep    string
+	values map[string]string
+}
+
+func (f *keyValueFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	pairs := make([]string, 0, len(f.values))
+	for k, v := range f.values {
+		pairs = append(pairs, k+f.sep+v)
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func (f *keyValueFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, f.sep)
+	if k = strings.TrimSpace(k); !ok || k == "" {
+		return fmt.Errorf("expected 'key%svalue', got %q", f.sep, value)
+	}
+	if f.values == nil {
+		f.values = map[string]string{}
+	}
+	f.values[k] = strings.TrimSpace(v)
+	return nil
+}
+
+// actionsFlag is a repeatable flag collecting the chrome actions of a script.
+type actionsFlag struct {
+	actions []htracker.ChromeAction
+}
+
+func (f *actionsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	actions := make([]string, len(f.actions))
+	for i, a := range f.actions {
+		actions[i] = string(a.Action)
+	}
+	return strings.Join(actions, ", ")
+}
+
+func (f *actionsFlag) Set(value string) error {
+	kind, arg, _ := strings.Cut(value, ":")
+	a := htracker.ChromeAction{Action: htracker.ActionKind(kind), Selector: arg}
+
+	switch a.Action {
+	case htracker.ActionType:
+		a.Selector, a.Text, _ = strings.Cut(arg, "=")
+	case htracker.ActionSleep:
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return fmt.Errorf("invalid duration of sleep action: %w", err)
+		}
+		a.Selector, a.Duration = "", d
+	}
+
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	f.actions = append(f.actions, a)
+	return nil
+}
+
+// newSubscriptionCmd is creating the subscription command with its subcommands for managing subscriptions.
+func newSubscriptionCmd() *ffcli.Command {
+	return &ffcli.Command{
+		Name:        "subscription",
+		ShortUsage:  "htracker <flags> subscription add|list|remove <flags>",
+		ShortHelp:   "manage subscriptions of subscribers",
+		Subcommands: []*ffcli.Command{newSubscriptionAddCmd(), newSubscriptionListCmd(), newSubscriptionRemoveCmd()},
+		Exec: func(context.Context, []string) error {
+			return flag.ErrHelp
+		},
+	}
+}
+
+func newSubscriptionAddCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("subscription add", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	sf := registerSubscriptionFlags(fs)
+	rf := registerRequestFlags(fs)
+	email := fs.String("email", "", "email of the subscriber")
+	interval := fs.Duration("interval", time.Hour, "interval between checks of the site")
+
+	return &ffcli.Command{
+		Name:       "add",
+		ShortUsage: "htracker subscription add -email <email> -url <url> [<subscription flags>] [<request flags>]",
+		ShortHelp:  "subscribe a subscriber to a site",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "email", "url"); err != nil {
+				return err
+			}
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			subscription, err := sf.subscription()
+			if err != nil {
+				return err
+			}
+			subscription.Interval = *interval
+			if subscription.Request, err = rf.requestOptions(); err != nil {
+				return err
+			}
+			return svc.Subscribe(ctx, *email, subscription)
+		},
+	}
+}
+
+func newSubscriptionListCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("subscription list", flag.ExitOnError)
+	cf := registerClientFlags(fs).withOutput(fs)
+	email := fs.String("email", "", "email of the subscriber")
+
+	return &ffcli.Command{
+		Name:       "list",
+		ShortUsage: "htracker subscription list -email <email>",
+		ShortHelp:  "list the subscriptions of a subscriber",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "email"); err != nil {
+				return err
+			}
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			subscriptions, err := svc.GetSubscriptionsBySubscriber(ctx, *email)
+			if err != nil {
+				return err
+			}
+
+			rows := make([][]string, len(subscriptions))
+			for i, s := range subscriptions {
+				rows[i] = []string{s.HTTPMethod(), s.URL, s.Filter, s.ContentType, strconv.FormatBool(s.UseChrome), s.Interval.String()}
+			}
+			return cf.print(subscriptions, []string{"METHOD", "URL", "FILTER", "CONTENT TYPE", "CHROME", "INTERVAL"}, rows)
+		},
+	}
+}
+
+func newSubscriptionRemoveCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("subscription remove", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	sf := registerSubscriptionFlags(fs)
+	rf := registerRequestFlags(fs)
+	email := fs.String("email", "", "email of the subscriber")
+
+	return &ffcli.Command{
+		Name:       "remove",
+		ShortUsage: "htracker subscription remove -email <email> -url <url> [<subscription flags>] [<request flags>]",
+		ShortHelp:  "unsubscribe a subscriber from a site, the request options must match the subscription",
+		FlagSet:    fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := requireFlags(fs, "email", "url"); err != nil {
+				return err
+			}
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+			subscription, err := sf.subscription()
+			if err != nil {
+				return err
+			}
+			if subscription.Request, err = rf.requestOptions(); err != nil {
+				return err
+			}
+			return svc.Unsubscribe(ctx, *email, subscription)
+		},
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/peterbourgon/ff/v3/ffcli"
+	"gitlab.com/henri.philipps/htracker/service"
+)
+
+const (
+	formatJSON = "json"
+	formatOPML = "opml"
+)
+
+// newExportCmd is creating the export command for dumping subscribers and subscriptions.
+func newExportCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	format := fs.String("format", formatJSON, "export format (json|opml) - opml is exporting the subscriptions of a single subscriber")
+	email := fs.String("email", "", "email of the subscriber to export (opml only)")
+	outFile := fs.String("file", "-", "file to write the export to (- for stdout)")
+	requestOptions := fs.Bool("request-options", false, "include the request options of subscriptions in clear text (json and -pguri only)")
+
+	return &ffcli.Command{
+		Name:       "export",
+		ShortUsage: "htracker <flags> export [-format json|opml] [-email <email>] [-request-options] [-file <file>]",
+		ShortHelp:  "export subscribers and their subscriptions",
+		LongHelp: `The export subcommand is dumping all subscribers with their subscriptions, limits and intervals as JSON,
+which can be restored with the import subcommand. With -format opml, the subscriptions of the subscriber
+given by -email are exported as OPML document.
+
+Request options, which might contain credentials, are left out unless -request-options is given. The
+export is containing them in clear text then and must be stored as safely as the secret key. The subscriptions
+returned by the server api are lacking request options, so -request-options requires -pguri.`,
+		FlagSet: fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if err := cf.parseEnv(); err != nil {
+				return err
+			}
+			if *requestOptions && *cf.pguri == "" {
+				return fmt.Errorf("flag -request-options requires -pguri")
+			}
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+
+			var data []byte
+			switch *format {
+			case formatJSON:
+				dump, err := service.ExportSubscribers(ctx, svc, *requestOptions)
+				if err != nil {
+					return err
+				}
+				if data, err = json.MarshalIndent(dump, "", "  "); err != nil {
+					return err
+				}
+			case formatOPML:
+				if err := requireFlags(fs, "email"); err != nil {
+					return err
+				}
+				if data, err = service.ExportOPML(ctx, svc, *email); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("export format %s not supported", *format)
+			}
+
+			if *outFile == "-" {
+				_, err = os.Stdout.Write(append(data, '\n'))
+				return err
+			}
+			return os.WriteFile(*outFile, append(data, '\n'), 0o600)
+		},
+	}
+}
+
+// newImportCmd is creating the import command for restoring subscribers and subscriptions.
+func newImportCmd() *ffcli.Command {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	cf := registerClientFlags(fs)
+	format := fs.String("format", formatJSON, "import format (json|opml) - opml is importing subscriptions for a single subscriber")
+	email := fs.String("email", "", "email of the subscriber to import the subscriptions for (opml only)")
+	interval := fs.Duration("interval", time.Hour, "interval of imported subscriptions without interval (opml only)")
+
+	return &ffcli.Command{
+		Name:       "import",
+		ShortUsage: "htracker <flags> import [-format json|opml] [-email <email>] <file>",
+		ShortHelp:  "import subscribers and their subscriptions",
+		LongHelp: `The import subcommand is restoring subscribers and subscriptions from a JSON export, skipping
+already existing ones. With -format opml, the subscriber given by -email is subscribed to all sites
+of the OPML document. Use - as file to read from stdin.`,
+		FlagSet: fs,
+		Exec: func(ctx context.Context, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("expected exactly one file, got %d arguments", len(args))
+			}
+
+			var data []byte
+			var err error
+			if args[0] == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			svc, _, closeServices, err := cf.services()
+			if err != nil {
+				return err
+			}
+			defer closeServices()
+
+			switch *format {
+			case formatJSON:
+				dump := &service.Dump{}
+				if err := json.Unmarshal(data, dump); err != nil {
+					return fmt.Errorf("failed to decode export: %w", err)
+				}
+				return service.ImportSubscribers(ctx, svc, dump)
+			case formatOPML:
+				if err := requireFlags(fs, "email"); err != nil {
+					return err
+				}
+				return service.ImportOPML(ctx, svc, *email, data, *interval)
+			default:
+				return fmt.Errorf("import format %s not supported", *format)
+			}
+		},
+	}
+}
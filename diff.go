@@ -0,0 +1,176 @@
+package htracker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DiffOperation is the kind of a DiffOp.
+type DiffOperation string
+
+const (
+	DiffEqual  DiffOperation = "equal"
+	DiffInsert DiffOperation = "insert"
+	DiffDelete DiffOperation = "delete"
+
+	// DiffSummary is a note describing the changes instead of listing them, e.g. if the content
+	// is too big for diffing. It is no part of the old or new text.
+	DiffSummary DiffOperation = "summary"
+)
+
+// Diff modes of subscriptions, defining the units compared when diffing the text of a site.
+const (
+	// DiffModeDefault is comparing characters, merging the changes to semantically meaningful chunks.
+	DiffModeDefault = ""
+
+	// DiffModeLine is comparing lines, which is fast and suitable for big or structured text.
+	DiffModeLine = "line"
+
+	// DiffModeWord is comparing words, separated by whitespace.
+	DiffModeWord = "word"
+)
+
+// DiffOp is a part of the text of a site, which was kept, inserted or deleted.
+type DiffOp struct {
+	Op   DiffOperation
+	Text string
+}
+
+// Diff is the format-neutral representation of the changes of a site, including the unchanged text.
+// It is stored by the archive and rendered in the format requested by clients.
+type Diff []DiffOp
+
+// Empty is returning whether the diff has no insertions, deletions or summary.
+func (d Diff) Empty() bool {
+	for _, op := range d {
+		if op.Op != DiffEqual {
+			return false
+		}
+	}
+	return true
+}
+
+// OldText is returning the text before the change.
+func (d Diff) OldText() string {
+	return d.text(DiffDelete)
+}
+
+// NewText is returning the text after the change.
+func (d Diff) NewText() string {
+	return d.text(DiffInsert)
+}
+
+// text is returning the unchanged text and the text of the given operation.
+func (d Diff) text(op DiffOperation) string {
+	b := strings.Builder{}
+	for _, o := range d {
+		if o.Op == DiffEqual || o.Op == op {
+			b.WriteString(o.Text)
+		}
+	}
+	return b.String()
+}
+
+// appendChangeList is appending a list of changed items to d, with a header like "2 added links:" as
+// unchanged text and the lines of the items as given operation. Empty lists are skipped, lists are
+// separated by an empty line.
+func appendChangeList(d Diff, lines []string, kind, noun string, op DiffOperation) Diff {
+	if len(lines) == 0 {
+		return d
+	}
+	if len(lines) > 1 {
+		noun += "s"
+	}
+
+	header := fmt.Sprintf("%d %s %s:\n", len(lines), kind, noun)
+	if len(d) > 0 {
+		header = "\n" + header
+	}
+	d = append(d, DiffOp{Op: DiffEqual, Text: header})
+	for _, line := range lines {
+		d = append(d, DiffOp{Op: op, Text: line + "\n"})
+	}
+	return d
+}
+
+// diffString is returning the text of all operations of d, both the deleted and inserted text.
+func diffString(d Diff) string {
+	b := strings.Builder{}
+	for _, op := range d {
+		b.WriteString(op.Text)
+	}
+	return b.String()
+}
+
+// UnmarshalJSON is decoding the diff from a list of operations, or from a string holding a diff
+// with ANSI colored insertions and deletions, as stored before diffs were format-neutral.
+func (d *Diff) UnmarshalJSON(data []byte) error {
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*d = ParseANSIDiff(legacy)
+		return nil
+	}
+
+	var ops []DiffOp
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return err
+	}
+	*d = ops
+	return nil
+}
+
+// ANSI escape sequences of insertions and deletions in legacy diffs.
+const (
+	ansiInsert = "\x1b[32m"
+	ansiDelete = "\x1b[31m"
+	ansiReset  = "\x1b[0m"
+)
+
+// ParseANSIDiff is converting a legacy diff, holding only the insertions (green) and deletions (red)
+// marked by ANSI escape sequences, to a Diff. Text outside of escape sequences is unchanged text.
+func ParseANSIDiff(s string) Diff {
+	var d Diff
+	for s != "" {
+		insert, del := strings.Index(s, ansiInsert), strings.Index(s, ansiDelete)
+		start, op := insert, DiffInsert
+		if del >= 0 && (insert < 0 || del < insert) {
+			start, op = del, DiffDelete
+		}
+		if start < 0 {
+			d = append(d, DiffOp{Op: DiffEqual, Text: s})
+			break
+		}
+		if start > 0 {
+			d = append(d, DiffOp{Op: DiffEqual, Text: s[:start]})
+		}
+
+		s = s[start+len(ansiInsert):]
+		end := strings.Index(s, ansiReset)
+		if end < 0 {
+			end = len(s)
+		}
+		d = append(d, DiffOp{Op: op, Text: s[:end]})
+		s = strings.TrimPrefix(s[end:], ansiReset)
+	}
+	return d
+}
+
+// ChangeMetrics is quantifying the changes of a site, to tell a single changed character from a
+// rewritten page. Characters are counted as unicode code points.
+type ChangeMetrics struct {
+	InsertedChars int
+	DeletedChars  int
+
+	// InsertedLines and DeletedLines are the lines of the new and old text with inserted or deleted text.
+	InsertedLines int
+	DeletedLines  int
+
+	// Changed is the percentage of the old text which was changed, between 0 and 100. It is the
+	// bigger one of the inserted and deleted characters relative to the characters of the old text.
+	Changed float64
+
+	// Similarity is the ratio of the unchanged characters to the characters of the old and new
+	// text, between 0 (completely different) and 1 (equal).
+	Similarity float64
+}
@@ -0,0 +1,59 @@
+package htracker
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseANSIDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want Diff
+	}{
+		{name: "empty", s: ""},
+		{name: "insertion", s: "\x1b[32mnew\x1b[0m", want: Diff{{Op: DiffInsert, Text: "new"}}},
+		{name: "changes", s: "a\x1b[31mold\x1b[0m\x1b[32mnew\x1b[0mb",
+			want: Diff{{Op: DiffEqual, Text: "a"}, {Op: DiffDelete, Text: "old"}, {Op: DiffInsert, Text: "new"}, {Op: DiffEqual, Text: "b"}}},
+		{name: "unterminated", s: "\x1b[31mold", want: Diff{{Op: DiffDelete, Text: "old"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseANSIDiff(tt.s); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseANSIDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff_UnmarshalJSON(t *testing.T) {
+	want := Diff{{Op: DiffEqual, Text: "fees: "}, {Op: DiffDelete, Text: "10"}, {Op: DiffInsert, Text: "12"}}
+
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := json.Marshal("fees: \x1b[31m10\x1b[0m\x1b[32m12\x1b[0m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range [][]byte{data, legacy} {
+		var got Diff
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("json.Unmarshal(%s) failed: %v", data, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("json.Unmarshal(%s) = %v, want %v", data, got, want)
+		}
+	}
+
+	if want, got := "fees: 10", want.OldText(); want != got {
+		t.Errorf("Expected old text %q, got %q", want, got)
+	}
+	if want, got := "fees: 12", want.NewText(); want != got {
+		t.Errorf("Expected new text %q, got %q", want, got)
+	}
+}
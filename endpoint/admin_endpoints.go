@@ -0,0 +1,161 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker/service"
+	"golang.org/x/exp/slog"
+)
+
+type AdminEndpoints struct {
+	Export     Endpoint[ExportReq, ExportResp]
+	Import     Endpoint[ImportReq, ImportResp]
+	ExportOPML Endpoint[ExportOPMLReq, ExportOPMLResp]
+	ImportOPML Endpoint[ImportOPMLReq, ImportOPMLResp]
+}
+
+// MakeAdminEndpoints is creating the admin endpoints. The exports are only including the request options
+// of subscriptions, which might contain credentials, if exportRequestOptions is set.
+func MakeAdminEndpoints(svc service.SubscriptionSvc, exportRequestOptions bool, logger *slog.Logger) AdminEndpoints {
+	exportEP := MakeExportEndpoint(svc, exportRequestOptions)
+	exportEP = LoggingMiddleware[ExportReq, ExportResp](logger)(exportEP)
+
+	importEP := MakeImportEndpoint(svc)
+	importEP = LoggingMiddleware[ImportReq, ImportResp](logger)(importEP)
+
+	exportOPMLEP := MakeExportOPMLEndpoint(svc)
+	exportOPMLEP = LoggingMiddleware[ExportOPMLReq, ExportOPMLResp](logger)(exportOPMLEP)
+
+	importOPMLEP := MakeImportOPMLEndpoint(svc)
+	importOPMLEP = LoggingMiddleware[ImportOPMLReq, ImportOPMLResp](logger)(importOPMLEP)
+
+	return AdminEndpoints{
+		Export:     exportEP,
+		Import:     importEP,
+		ExportOPML: exportOPMLEP,
+		ImportOPML: importOPMLEP,
+	}
+}
+
+type ExportReq struct{}
+
+func (req ExportReq) Name() string {
+	return "Export"
+}
+
+func (req ExportReq) Empty() bool {
+	return true
+}
+
+type ExportResp struct {
+	Dump *service.Dump
+	err  error
+}
+
+func (resp ExportResp) Failed() error {
+	return resp.err
+}
+
+func (resp ExportResp) StatusCode() int {
+	return http.StatusOK
+}
+
+func MakeExportEndpoint(svc service.SubscriptionSvc, withRequestOptions bool) Endpoint[ExportReq, ExportResp] {
+	return func(ctx context.Context, req ExportReq) (ExportResp, error) {
+		dump, err := service.ExportSubscribers(ctx, svc, withRequestOptions)
+		return ExportResp{Dump: dump, err: err}, nil
+	}
+}
+
+type ImportReq struct {
+	Dump *service.Dump
+}
+
+func (req ImportReq) Name() string {
+	return "Import"
+}
+
+type ImportResp struct {
+	err error
+}
+
+func (resp ImportResp) Failed() error {
+	return resp.err
+}
+
+func (resp ImportResp) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func MakeImportEndpoint(svc service.SubscriptionSvc) Endpoint[ImportReq, ImportResp] {
+	return func(ctx context.Context, req ImportReq) (ImportResp, error) {
+		if req.Dump == nil {
+			return ImportResp{}, fmt.Errorf("could not find valid dump in request")
+		}
+		err := service.ImportSubscribers(ctx, svc, req.Dump)
+		return ImportResp{err: err}, nil
+	}
+}
+
+type ExportOPMLReq struct {
+	Email string
+}
+
+func (req ExportOPMLReq) Name() string {
+	return "ExportOPML"
+}
+
+type ExportOPMLResp struct {
+	OPML []byte
+	err  error
+}
+
+func (resp ExportOPMLResp) Failed() error {
+	return resp.err
+}
+
+func (resp ExportOPMLResp) StatusCode() int {
+	return http.StatusOK
+}
+
+func MakeExportOPMLEndpoint(svc service.SubscriptionSvc) Endpoint[ExportOPMLReq, ExportOPMLResp] {
+	return func(ctx context.Context, req ExportOPMLReq) (ExportOPMLResp, error) {
+		opml, err := service.ExportOPML(ctx, svc, req.Email)
+		return ExportOPMLResp{OPML: opml, err: err}, nil
+	}
+}
+
+type ImportOPMLReq struct {
+	Email    string
+	OPML     []byte
+	Interval time.Duration
+}
+
+func (req ImportOPMLReq) Name() string {
+	return "ImportOPML"
+}
+
+type ImportOPMLResp struct {
+	err error
+}
+
+func (resp ImportOPMLResp) Failed() error {
+	return resp.err
+}
+
+func (resp ImportOPMLResp) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func MakeImportOPMLEndpoint(svc service.SubscriptionSvc) Endpoint[ImportOPMLReq, ImportOPMLResp] {
+	return func(ctx context.Context, req ImportOPMLReq) (ImportOPMLResp, error) {
+		if len(req.OPML) == 0 {
+			return ImportOPMLResp{}, fmt.Errorf("could not find opml document in request")
+		}
+		err := service.ImportOPML(ctx, svc, req.Email, req.OPML, req.Interval)
+		return ImportOPMLResp{err: err}, nil
+	}
+}
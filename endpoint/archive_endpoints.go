@@ -11,8 +11,10 @@ import (
 )
 
 type ArchiveEndpoints struct {
-	Update Endpoint[UpdateReq, UpdateResp]
-	Get    Endpoint[GetReq, GetResp]
+	Update        Endpoint[UpdateReq, UpdateResp]
+	Get           Endpoint[GetReq, GetResp]
+	GetScreenshot Endpoint[GetScreenshotReq, GetScreenshotResp]
+	GetVersions   Endpoint[GetVersionsReq, GetVersionsResp]
 }
 
 func MakeArchiveEndpoints(svc service.SiteArchive, logger *slog.Logger) ArchiveEndpoints {
@@ -23,9 +25,17 @@ func MakeArchiveEndpoints(svc service.SiteArchive, logger *slog.Logger) ArchiveE
 	getEP := MakeGetEndpoint(svc)
 	getEP = LoggingMiddleware[GetReq, GetResp](logger)(getEP)
 
+	getScreenshotEP := MakeGetScreenshotEndpoint(svc)
+	getScreenshotEP = LoggingMiddleware[GetScreenshotReq, GetScreenshotResp](logger)(getScreenshotEP)
+
+	getVersionsEP := MakeGetVersionsEndpoint(svc)
+	getVersionsEP = LoggingMiddleware[GetVersionsReq, GetVersionsResp](logger)(getVersionsEP)
+
 	return ArchiveEndpoints{
-		Update: updateEP,
-		Get:    getEP,
+		Update:        updateEP,
+		Get:           getEP,
+		GetScreenshot: getScreenshotEP,
+		GetVersions:   getVersionsEP,
 	}
 }
 
@@ -38,7 +48,7 @@ func (req UpdateReq) Name() string {
 }
 
 type UpdateResp struct {
-	Diff string
+	Diff htracker.Diff
 	err  error
 }
 
@@ -62,6 +72,13 @@ func MakeUpdateEndpoint(svc service.SiteArchive) Endpoint[UpdateReq, UpdateResp]
 
 type GetReq struct {
 	Subscription *htracker.Subscription
+
+	// DiffFormat is the format the diff of the site is rendered in, see service.FormatDiff.
+	// The diff is only returned as list of operations if empty.
+	DiffFormat string `json:",omitempty"`
+	// DiffContext is the number of unchanged lines around changes of the rendered diff,
+	// service.DefaultDiffContext if nil.
+	DiffContext *int `json:",omitempty"`
 }
 
 func (req GetReq) Name() string {
@@ -70,7 +87,9 @@ func (req GetReq) Name() string {
 
 type GetResp struct {
 	Site *htracker.Site
-	err  error
+	// FormattedDiff is the diff of the site in the requested format.
+	FormattedDiff string `json:",omitempty"`
+	err           error
 }
 
 func (resp GetResp) Failed() error {
@@ -87,6 +106,100 @@ func MakeGetEndpoint(svc service.SiteArchive) Endpoint[GetReq, GetResp] {
 			return GetResp{}, fmt.Errorf("could not find subscription in request")
 		}
 		site, err := svc.Get(ctx, req.Subscription)
-		return GetResp{Site: site, err: err}, nil
+		if err == nil {
+			// any client of the API is able to get the site, so the credentials of its subscription are left out
+			site = site.Redacted()
+		}
+		if err != nil || req.DiffFormat == "" {
+			return GetResp{Site: site, err: err}, nil
+		}
+
+		diffContext := service.DefaultDiffContext
+		if req.DiffContext != nil {
+			diffContext = *req.DiffContext
+		}
+		diff, err := service.FormatDiff(site.Diff, req.DiffFormat, diffContext)
+		return GetResp{Site: site, FormattedDiff: diff, err: err}, nil
+	}
+}
+
+type GetScreenshotReq struct {
+	Subscription *htracker.Subscription
+
+	// Image is the requested image of the screenshot, see service.ScreenshotImage.
+	Image string
+}
+
+func (req GetScreenshotReq) Name() string {
+	return "sitearchive_GetScreenshot"
+}
+
+type GetScreenshotResp struct {
+	// PNG is the requested image.
+	PNG []byte
+	err error
+}
+
+func (resp GetScreenshotResp) Failed() error {
+	return resp.err
+}
+
+func (resp GetScreenshotResp) StatusCode() int {
+	return http.StatusOK
+}
+
+func MakeGetScreenshotEndpoint(svc service.SiteArchive) Endpoint[GetScreenshotReq, GetScreenshotResp] {
+	return func(ctx context.Context, req GetScreenshotReq) (GetScreenshotResp, error) {
+		if req.Subscription == nil {
+			return GetScreenshotResp{}, fmt.Errorf("could not find subscription in request")
+		}
+		site, err := svc.Get(ctx, req.Subscription)
+		if err != nil {
+			return GetScreenshotResp{err: err}, nil
+		}
+		image, err := service.ScreenshotImage(site, req.Image)
+		return GetScreenshotResp{PNG: image, err: err}, nil
+	}
+}
+
+type GetVersionsReq struct {
+	Subscription *htracker.Subscription
+
+	// Content is including the content of the versions, which is left out if false.
+	Content bool `json:",omitempty"`
+}
+
+func (req GetVersionsReq) Name() string {
+	return "sitearchive_GetVersions"
+}
+
+type GetVersionsResp struct {
+	Versions []*htracker.Version
+	err      error
+}
+
+func (resp GetVersionsResp) Failed() error {
+	return resp.err
+}
+
+func (resp GetVersionsResp) StatusCode() int {
+	return http.StatusOK
+}
+
+func MakeGetVersionsEndpoint(svc service.SiteArchive) Endpoint[GetVersionsReq, GetVersionsResp] {
+	return func(ctx context.Context, req GetVersionsReq) (GetVersionsResp, error) {
+		if req.Subscription == nil {
+			return GetVersionsResp{}, fmt.Errorf("could not find subscription in request")
+		}
+		versions, err := svc.GetVersions(ctx, req.Subscription)
+		if err != nil {
+			return GetVersionsResp{err: err}, nil
+		}
+		if !req.Content {
+			for _, v := range versions {
+				v.Content = nil
+			}
+		}
+		return GetVersionsResp{Versions: versions}, nil
 	}
 }
@@ -2,6 +2,7 @@ package endpoint
 
 import (
 	"context"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -22,13 +23,13 @@ func TestMiddleware(t *testing.T) {
 	content2 := []byte("This is Site2")
 
 	req1 := UpdateReq{
-		Site: &htracker.Site{sub1, time.Now(), time.Now(), content1, service.Checksum(content1), ""},
+		Site: &htracker.Site{Subscription: sub1, LastUpdated: time.Now(), LastChecked: time.Now(), Content: content1, Checksum: service.Checksum(content1)},
 	}
 	req2 := UpdateReq{
-		Site: &htracker.Site{sub2, time.Now(), time.Now(), content2, service.Checksum(content2), ""},
+		Site: &htracker.Site{Subscription: sub2, LastUpdated: time.Now(), LastChecked: time.Now(), Content: content2, Checksum: service.Checksum(content2)},
 	}
 	req3 := UpdateReq{
-		Site: &htracker.Site{sub1, time.Now(), time.Now(), content2, service.Checksum(content2), ""},
+		Site: &htracker.Site{Subscription: sub1, LastUpdated: time.Now(), LastChecked: time.Now(), Content: content2, Checksum: service.Checksum(content2)},
 	}
 	req4 := GetReq{Subscription: sub1}
 	req5 := GetReq{Subscription: sub2}
@@ -97,3 +98,84 @@ func TestMiddleware(t *testing.T) {
 	}
 	t.Logf("Resp: %v", getResp.Site.Checksum)
 }
+
+func TestGetEndpoint_DiffFormat(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	sub := &htracker.Subscription{URL: "http://site1.example/blah"}
+	for _, content := range []string{"fees: 10 EUR\n", "fees: 12 EUR\n"} {
+		site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: []byte(content), Checksum: service.Checksum([]byte(content))}
+		if _, err := svc.Update(ctx, site); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	noContext := 0
+	tests := []struct {
+		name    string
+		req     GetReq
+		want    string
+		wantErr bool
+	}{
+		{name: "no format", req: GetReq{Subscription: sub}},
+		{name: "plain", req: GetReq{Subscription: sub, DiffFormat: service.DiffFormatPlain}, want: "fees: 1[-0-]{+2+} EUR\n"},
+		{name: "unified", req: GetReq{Subscription: sub, DiffFormat: service.DiffFormatUnified, DiffContext: &noContext},
+			want: "--- archived\n+++ current\n@@ -1,1 +1,1 @@\n-fees: 10 EUR\n+fees: 12 EUR\n"},
+		{name: "unknown format", req: GetReq{Subscription: sub, DiffFormat: "pdf"}, wantErr: true},
+	}
+
+	getEp := MakeGetEndpoint(svc)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := getEp(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := resp.Failed(); (err != nil) != tt.wantErr {
+				t.Fatalf("Failed() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if want, got := tt.want, resp.FormattedDiff; want != got {
+				t.Errorf("Expected diff %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestGetScreenshotEndpoint(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	sub := &htracker.Subscription{URL: "http://site1.example/blah", UseChrome: true, Screenshot: true}
+	site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Screenshot: &htracker.Screenshot{Image: []byte("png")}}
+	if _, err := svc.Update(ctx, site); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     GetScreenshotReq
+		want    string
+		wantErr error
+	}{
+		{name: "after", req: GetScreenshotReq{Subscription: sub, Image: service.ScreenshotAfter}, want: "png"},
+		{name: "no visual change", req: GetScreenshotReq{Subscription: sub, Image: service.ScreenshotBefore}, wantErr: htracker.ErrNotExist},
+		{name: "unknown image", req: GetScreenshotReq{Subscription: sub, Image: "gif"}, wantErr: htracker.ErrInvalid},
+		{name: "unknown site", req: GetScreenshotReq{Subscription: &htracker.Subscription{URL: "http://unknown.example"},
+			Image: service.ScreenshotAfter}, wantErr: htracker.ErrNotExist},
+	}
+
+	getScreenshotEp := MakeGetScreenshotEndpoint(svc)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := getScreenshotEp(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := resp.Failed(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Failed() = %v, want %v", err, tt.wantErr)
+			}
+			if want, got := tt.want, string(resp.PNG); want != got {
+				t.Errorf("Expected image %q, got %q", want, got)
+			}
+		})
+	}
+}
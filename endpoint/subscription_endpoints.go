@@ -138,7 +138,7 @@ func (resp GetSubscriptionsBySubscriberResp) StatusCode() int {
 func MakeGetSubscriptionsBySubscriberEndpoint(svc service.SubscriptionSvc) Endpoint[GetSubscriptionsBySubscriberReq, GetSubscriptionsBySubscriberResp] {
 	return func(ctx context.Context, req GetSubscriptionsBySubscriberReq) (GetSubscriptionsBySubscriberResp, error) {
 		subscriptions, err := svc.GetSubscriptionsBySubscriber(ctx, req.Email)
-		return GetSubscriptionsBySubscriberResp{Subscriptions: subscriptions, err: err}, nil
+		return GetSubscriptionsBySubscriberResp{Subscriptions: service.RedactSubscriptions(subscriptions), err: err}, nil
 	}
 }
 
@@ -169,7 +169,7 @@ func MakeGetSubscribersBySubscriptionEndpoint(svc service.SubscriptionSvc) Endpo
 			return GetSubscribersBySubscriptionResp{}, fmt.Errorf("could not find valid subscription in request")
 		}
 		subscribers, err := svc.GetSubscribersBySubscription(ctx, req.Subscription)
-		return GetSubscribersBySubscriptionResp{Subscribers: subscribers, err: err}, nil
+		return GetSubscribersBySubscriptionResp{Subscribers: service.RedactSubscribers(subscribers), err: err}, nil
 	}
 }
 
@@ -199,7 +199,7 @@ func (resp GetSubscribersResp) StatusCode() int {
 func MakeGetSubscribersEndpoint(svc service.SubscriptionSvc) Endpoint[GetSubscribersReq, GetSubscribersResp] {
 	return func(ctx context.Context, req GetSubscribersReq) (GetSubscribersResp, error) {
 		subscribers, err := svc.GetSubscribers(ctx)
-		return GetSubscribersResp{Subscribers: subscribers, err: err}, nil
+		return GetSubscribersResp{Subscribers: service.RedactSubscribers(subscribers), err: err}, nil
 	}
 }
 
@@ -7,3 +7,5 @@ import (
 var ErrNotExist = errors.New("the item could not be found")
 var ErrAlreadyExists = errors.New("the item already exists")
 var ErrLimit = errors.New("limit reached")
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+var ErrInvalid = errors.New("invalid item")
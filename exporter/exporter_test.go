@@ -2,6 +2,7 @@ package exporter
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -30,18 +31,18 @@ func TestExporter_Export(t *testing.T) {
 		subscription       *htracker.Subscription
 		content            []byte
 		checksum           string
-		diffExpected       string
+		diffExpected       htracker.Diff
 		checkDateExpected  time.Time
 		updateDateExpected time.Time
 	}{
 		{name: "add new site1", date: date1, subscription: sub1, content: content1,
-			checksum: service.Checksum(content1), diffExpected: "",
+			checksum: service.Checksum(content1), diffExpected: nil,
 			checkDateExpected: date1, updateDateExpected: date1},
 		{name: "add new site2", date: date1, subscription: sub2, content: content2,
-			checksum: service.Checksum(content2), diffExpected: "",
+			checksum: service.Checksum(content2), diffExpected: nil,
 			checkDateExpected: date1, updateDateExpected: date1},
 		{name: "site1 unchanged", date: date2, subscription: sub1, content: content1,
-			checksum: service.Checksum(content1), diffExpected: "",
+			checksum: service.Checksum(content1), diffExpected: nil,
 			checkDateExpected: date2, updateDateExpected: date1},
 		{name: "update site1", date: date3, subscription: sub3, content: content1Updated,
 			checksum: service.Checksum(content1Updated), diffExpected: service.DiffText(string(content1),
@@ -85,8 +86,8 @@ func TestExporter_Export(t *testing.T) {
 		if want, got := tc.checksum, site.Checksum; want != got {
 			t.Fatalf("%s: Expected checksum %s, got %s", tc.name, want, got)
 		}
-		if want, got := tc.diffExpected, site.Diff; want != got {
-			t.Fatalf("%s: Expected diff %s, got %s", tc.name, want, got)
+		if want, got := tc.diffExpected, site.Diff; !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s: Expected diff %v, got %v", tc.name, want, got)
 		}
 	}
 }
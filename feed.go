@@ -0,0 +1,68 @@
+package htracker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContentTypeFeed is the content type of subscriptions to RSS and Atom feeds. The content of their
+// sites is the list of feed items, which are compared by GUID instead of diffing the XML of the feed.
+const ContentTypeFeed = "feed"
+
+// FeedItem is an entry of a RSS or Atom feed.
+type FeedItem struct {
+	// GUID is identifying the item, the link or title of the item is used if the feed is missing it.
+	GUID      string
+	Title     string
+	Link      string `json:",omitempty"`
+	Published string `json:",omitempty"`
+
+	// Checksum is the checksum of the description or content of the item.
+	Checksum string `json:",omitempty"`
+}
+
+// FeedChanges are the items of a feed which are new, removed or changed since the last scrape.
+type FeedChanges struct {
+	New     []FeedItem
+	Removed []FeedItem
+	Changed []FeedItem
+}
+
+// Empty is returning whether there are no changes.
+func (c *FeedChanges) Empty() bool {
+	return len(c.New) == 0 && len(c.Removed) == 0 && len(c.Changed) == 0
+}
+
+// String is returning the changes as text, listing the items of each kind of change, e.g.
+// "2 new items:\n+ Title (https://site.example/item)\n...".
+func (c *FeedChanges) String() string {
+	return diffString(c.Diff())
+}
+
+// Diff is returning the changes as Diff, new items are insertions and removed items are deletions.
+func (c *FeedChanges) Diff() Diff {
+	d := Diff{}
+
+	write := func(items []FeedItem, kind, prefix string, op DiffOperation) {
+		lines := make([]string, 0, len(items))
+		for _, item := range items {
+			line := fmt.Sprintf("%s %s", prefix, item.Title)
+			if item.Link != "" {
+				line += fmt.Sprintf(" (%s)", item.Link)
+			}
+			lines = append(lines, line)
+		}
+		d = appendChangeList(d, lines, kind, "item", op)
+	}
+
+	write(c.New, "new", "+", DiffInsert)
+	write(c.Changed, "changed", "~", DiffEqual)
+	write(c.Removed, "removed", "-", DiffDelete)
+
+	return d
+}
+
+// IsFeed is returning whether the subscribed site is a RSS or Atom feed.
+func (s *Subscription) IsFeed() bool {
+	return strings.EqualFold(s.ContentType, ContentTypeFeed)
+}
@@ -4,15 +4,21 @@ go 1.19
 
 require (
 	github.com/PuerkitoBio/goquery v1.8.0
+	github.com/chromedp/cdproto v0.0.0-20221126224343-3a0787b8dd28
+	github.com/chromedp/chromedp v0.8.6
 	github.com/geziyor/geziyor v0.0.0-20220429000531-738852f9321d
 	github.com/go-chi/chi v1.5.4
 	github.com/jmoiron/sqlx v1.3.5
+	github.com/ledongthuc/pdf v0.0.0-20220302134840-0c2507a12d80
 	github.com/lib/pq v1.10.7
 	github.com/oklog/run v1.1.0
 	github.com/peterbourgon/ff/v3 v3.3.0
 	github.com/sergi/go-diff v1.2.0
+	github.com/temoto/robotstxt v1.1.2
 	golang.org/x/exp v0.0.0-20221217163422-3c43f8badb15
 	golang.org/x/net v0.4.0
+	golang.org/x/text v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -20,8 +26,6 @@ require (
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
-	github.com/chromedp/cdproto v0.0.0-20221126224343-3a0787b8dd28 // indirect
-	github.com/chromedp/chromedp v0.8.6 // indirect
 	github.com/chromedp/sysutil v1.0.0 // indirect
 	github.com/go-kit/kit v0.12.0 // indirect
 	github.com/gobwas/httphead v0.1.0 // indirect
@@ -35,9 +39,7 @@ require (
 	github.com/prometheus/client_model v0.3.0 // indirect
 	github.com/prometheus/common v0.39.0 // indirect
 	github.com/prometheus/procfs v0.8.0 // indirect
-	github.com/temoto/robotstxt v1.1.2 // indirect
 	golang.org/x/sys v0.3.0 // indirect
-	golang.org/x/text v0.5.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
 	google.golang.org/protobuf v1.28.1 // indirect
 )
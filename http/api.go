@@ -7,12 +7,35 @@ import (
 	"golang.org/x/exp/slog"
 )
 
-func MakeAPIHandler(archivesvc service.SiteArchive, subcriptionsvc service.SubscriptionSvc, logger *slog.Logger) *chi.Mux {
+// APIOpt is an option of the API handler.
+type APIOpt func(*apiOpts)
+
+type apiOpts struct {
+	exportRequestOptions bool
+}
+
+// WithRequestOptionsExport is including the request options of subscriptions in the dumps of
+// GET /api/admin/export. They might contain credentials, which are left out of all responses otherwise.
+func WithRequestOptionsExport() APIOpt {
+	return func(o *apiOpts) {
+		o.exportRequestOptions = true
+	}
+}
+
+func MakeAPIHandler(archivesvc service.SiteArchive, subcriptionsvc service.SubscriptionSvc, logger *slog.Logger, opts ...APIOpt) *chi.Mux {
+	o := &apiOpts{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
 	archiveEndpoints := endpoint.MakeArchiveEndpoints(archivesvc, logger)
 	subscriptionEndpoints := endpoint.MakeSubscriptionEndpoints(subcriptionsvc, logger)
+	adminEndpoints := endpoint.MakeAdminEndpoints(subcriptionsvc, o.exportRequestOptions, logger)
 
 	router := chi.NewRouter()
 	router.Get("/api/site", createJSONHandler(archiveEndpoints.Get))
+	router.Get("/api/site/screenshot", createScreenshotHandler(archiveEndpoints.GetScreenshot))
+	router.Get("/api/site/versions", createJSONHandler(archiveEndpoints.GetVersions))
 	router.Post("/api/subscriber", createJSONHandler(subscriptionEndpoints.AddSubscriber))
 	router.Get("/api/subscriber", createJSONHandler(subscriptionEndpoints.GetSubscribers))
 	router.Get("/api/subscriber/by_subscription", createJSONHandler(subscriptionEndpoints.GetSubscribersBySubscription))
@@ -20,6 +43,10 @@ func MakeAPIHandler(archivesvc service.SiteArchive, subcriptionsvc service.Subsc
 	router.Post("/api/subscription", createJSONHandler(subscriptionEndpoints.Subscribe))
 	router.Get("/api/subscription/by_subscriber", createJSONHandler(subscriptionEndpoints.GetSubscriptionsBySubscriber))
 	router.Delete("/api/subscription", createJSONHandler(subscriptionEndpoints.Unsubscribe))
+	router.Get("/api/subscription/opml", createExportOPMLHandler(adminEndpoints.ExportOPML))
+	router.Post("/api/subscription/opml", createImportOPMLHandler(adminEndpoints.ImportOPML))
+	router.Get("/api/admin/export", createJSONHandler(adminEndpoints.Export))
+	router.Post("/api/admin/import", createJSONHandler(adminEndpoints.Import))
 
 	return router
 }
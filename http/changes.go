@@ -0,0 +1,60 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// MakeChangesHandler is returning a handler streaming the change events of the given notifier as
+// server-sent events of type "change", with the JSON encoded storage.ChangeEvent as data. Each request
+// is listening for changes until it is canceled or ctx is done, so that streams are not blocking a
+// graceful shutdown of the server.
+func MakeChangesHandler(ctx context.Context, notifier storage.ChangeNotifier, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeJSONError(w, http.StatusInternalServerError, "streaming not supported")
+			return
+		}
+
+		listenCtx, cancel := context.WithCancel(req.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-ctx.Done():
+				cancel()
+			case <-listenCtx.Done():
+			}
+		}()
+
+		events, err := notifier.ListenChanges(listenCtx)
+		if err != nil {
+			logger.Error("failed to listen for changes", err)
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		// the channel is closed when listenCtx is canceled
+		for event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.Error("failed to encode change event", err, slog.String("url", event.Subscription.URL))
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: change\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
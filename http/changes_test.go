@@ -0,0 +1,85 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func TestMakeChangesHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notifier := memory.NewChangeNotifier(slog.Default())
+	server := httptest.NewServer(MakeChangesHandler(ctx, notifier, slog.Default()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Expected content type text/event-stream, got %s", got)
+	}
+
+	// the headers are sent after the handler started listening
+	sub := &htracker.Subscription{URL: "http://site1.example", Method: "POST", Body: "q=1"}
+	lastUpdated := time.Now().Round(time.Millisecond)
+	if err := notifier.NotifyChange(ctx, storage.NewChangeEvent(sub, lastUpdated)); err != nil {
+		t.Fatalf("NotifyChange() failed: %v", err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	for _, want := range []string{"event: change", "data: "} {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, want) {
+				t.Fatalf("Expected line %q, got %q", want, line)
+			}
+			if want != "data: " {
+				continue
+			}
+			event := &storage.ChangeEvent{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, want)), event); err != nil {
+				t.Fatalf("Failed to decode change event: %v", err)
+			}
+			if !event.Matches(sub) || !event.LastUpdated.Equal(lastUpdated) {
+				t.Errorf("Expected change event of %v at %v, got %v", sub, lastUpdated, event)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change event received")
+		}
+	}
+
+	// the stream is ended when the server is shutting down
+	cancel()
+	for {
+		select {
+		case _, ok := <-lines:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("stream not ended after cancel")
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/endpoint"
+	"gitlab.com/henri.philipps/htracker/service"
+)
+
+// Client is a client for the htracker JSON API. It is implementing the SubscriptionSvc
+// interface, so it can be used instead of a local service, e.g. by command line tools.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// compile time check of interface implementation.
+var _ service.SubscriptionSvc = &Client{}
+
+// ClientOpt is a functional option for the Client.
+type ClientOpt func(*Client)
+
+// WithHTTPClient is setting the http.Client used for sending requests.
+func WithHTTPClient(httpClient *http.Client) ClientOpt {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// NewClient is returning a new Client for the API of the htracker server listening at baseURL.
+func NewClient(baseURL string, opts ...ClientOpt) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// AddSubscriber is adding a new subscriber.
+func (c *Client) AddSubscriber(ctx context.Context, subscriber *service.Subscriber) error {
+	_, err := doJSONRequest[endpoint.AddSubscriberReq, endpoint.AddSubscriberResp](ctx, c,
+		http.MethodPost, "/api/subscriber", endpoint.AddSubscriberReq{Subscriber: subscriber})
+	return err
+}
+
+// Subscribe is adding a subscription for the given email.
+func (c *Client) Subscribe(ctx context.Context, email string, subscription *htracker.Subscription) error {
+	_, err := doJSONRequest[endpoint.SubscribeReq, endpoint.SubscribeResp](ctx, c,
+		http.MethodPost, "/api/subscription", endpoint.SubscribeReq{Email: email, Subscription: subscription})
+	return err
+}
+
+// GetSubscriptionsBySubscriber returns a list of subscriptions for the given subscriber.
+func (c *Client) GetSubscriptionsBySubscriber(ctx context.Context, email string) ([]*htracker.Subscription, error) {
+	resp, err := doJSONRequest[endpoint.GetSubscriptionsBySubscriberReq, endpoint.GetSubscriptionsBySubscriberResp](ctx, c,
+		http.MethodGet, "/api/subscription/by_subscriber", endpoint.GetSubscriptionsBySubscriberReq{Email: email})
+	return resp.Subscriptions, err
+}
+
+// GetSubscribersBySubscription returns a list of subscribers for a given subscription.
+func (c *Client) GetSubscribersBySubscription(ctx context.Context, subscription *htracker.Subscription) ([]*service.Subscriber, error) {
+	resp, err := doJSONRequest[endpoint.GetSubscribersBySubscriptionReq, endpoint.GetSubscribersBySubscriptionResp](ctx, c,
+		http.MethodGet, "/api/subscriber/by_subscription", endpoint.GetSubscribersBySubscriptionReq{Subscription: subscription})
+	return resp.Subscribers, err
+}
+
+// GetSubscribers returns all existing subscribers.
+func (c *Client) GetSubscribers(ctx context.Context) ([]*service.Subscriber, error) {
+	resp, err := doJSONRequest[endpoint.GetSubscribersReq, endpoint.GetSubscribersResp](ctx, c,
+		http.MethodGet, "/api/subscriber", endpoint.GetSubscribersReq{})
+	return resp.Subscribers, err
+}
+
+// Unsubscribe is unsubscribing a subscriber from watching a site.
+func (c *Client) Unsubscribe(ctx context.Context, email string, subscription *htracker.Subscription) error {
+	_, err := doJSONRequest[endpoint.UnsubscribeReq, endpoint.UnsubscribeResp](ctx, c,
+		http.MethodDelete, "/api/subscription", endpoint.UnsubscribeReq{Email: email, Subscription: subscription})
+	return err
+}
+
+// DeleteSubscriber is removing a subscriber with all it's subscriptions.
+func (c *Client) DeleteSubscriber(ctx context.Context, email string) error {
+	_, err := doJSONRequest[endpoint.DeleteSubscriberReq, endpoint.DeleteSubscriberResp](ctx, c,
+		http.MethodDelete, "/api/subscriber", endpoint.DeleteSubscriberReq{Email: email})
+	return err
+}
+
+// Get is returning the archived state of the site for the given subscription.
+func (c *Client) Get(ctx context.Context, subscription *htracker.Subscription) (*htracker.Site, error) {
+	resp, err := doJSONRequest[endpoint.GetReq, endpoint.GetResp](ctx, c,
+		http.MethodGet, "/api/site", endpoint.GetReq{Subscription: subscription})
+	if err != nil {
+		return &htracker.Site{}, err
+	}
+	return resp.Site, nil
+}
+
+// GetVersions is returning the archived versions of the site for the given subscription, latest first,
+// with their content if content is true.
+func (c *Client) GetVersions(ctx context.Context, subscription *htracker.Subscription, content bool) ([]*htracker.Version, error) {
+	resp, err := doJSONRequest[endpoint.GetVersionsReq, endpoint.GetVersionsResp](ctx, c,
+		http.MethodGet, "/api/site/versions", endpoint.GetVersionsReq{Subscription: subscription, Content: content})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Versions, nil
+}
+
+// doJSONRequest is a generic client for the JSON API. It is encoding the request,
+// sending it to the given path and decoding the response or error.
+func doJSONRequest[Req endpoint.Requester, Resp endpoint.Responder](ctx context.Context, c *Client, method, path string, request Req) (Resp, error) {
+	var response Resp
+
+	var body io.Reader = http.NoBody
+	if emptyer, ok := any(request).(endpoint.Emptyer); !ok || !emptyer.Empty() {
+		buf := &bytes.Buffer{}
+		if err := json.NewEncoder(buf).Encode(request); err != nil {
+			return response, fmt.Errorf("failed to encode %s request: %w", request.Name(), err)
+		}
+		body = buf
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return response, fmt.Errorf("failed to create %s request: %w", request.Name(), err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return response, fmt.Errorf("%s request failed: %w", request.Name(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return response, decodeHTTPJSONError(resp)
+	}
+
+	if resp.StatusCode == http.StatusNoContent {
+		return response, nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response, fmt.Errorf("failed to decode %s response: %w", request.Name(), err)
+	}
+
+	return response, nil
+}
+
+// apiError is an error returned by the API. It is wrapping the domain error
+// matching the status code of the response, if there is any.
+type apiError struct {
+	msg string
+	err error
+}
+
+func (e *apiError) Error() string {
+	return e.msg
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// decodeHTTPJSONError is translating an error response of the API back into domain errors.
+func decodeHTTPJSONError(resp *http.Response) error {
+	errResponse := struct{ Error string }{}
+	if err := json.NewDecoder(resp.Body).Decode(&errResponse); err != nil || errResponse.Error == "" {
+		errResponse.Error = resp.Status
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return &apiError{msg: errResponse.Error, err: htracker.ErrNotExist}
+	case http.StatusConflict:
+		return &apiError{msg: errResponse.Error, err: htracker.ErrAlreadyExists}
+	case http.StatusForbidden:
+		return &apiError{msg: errResponse.Error, err: htracker.ErrRobotsDisallowed}
+	case http.StatusBadRequest:
+		return &apiError{msg: errResponse.Error, err: htracker.ErrInvalid}
+	default:
+		return &apiError{msg: errResponse.Error}
+	}
+}
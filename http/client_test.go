@@ -0,0 +1,266 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(logger))
+	subscriptionSvc := service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger))
+	server := httptest.NewServer(MakeAPIHandler(archive, subscriptionSvc, logger))
+	defer server.Close()
+
+	client := NewClient(server.URL + "/")
+
+	email := "email1@foo.test"
+	sub1 := &htracker.Subscription{URL: "http://site1.example/blah", Filter: "foo", ContentType: "text", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example/blub", Filter: "bar", ContentType: "byte", Interval: time.Minute}
+
+	if err := client.AddSubscriber(ctx, &service.Subscriber{Email: email, SubscriptionLimit: 5}); err != nil {
+		t.Fatalf("client.AddSubscriber() failed: %v", err)
+	}
+	if err := client.AddSubscriber(ctx, &service.Subscriber{Email: email}); !errors.Is(err, htracker.ErrAlreadyExists) {
+		t.Fatalf("client.AddSubscriber() expected ErrAlreadyExists, got %v", err)
+	}
+
+	for _, sub := range []*htracker.Subscription{sub1, sub2} {
+		if err := client.Subscribe(ctx, email, sub); err != nil {
+			t.Fatalf("client.Subscribe() failed: %v", err)
+		}
+	}
+
+	subscribers, err := client.GetSubscribers(ctx)
+	if err != nil {
+		t.Fatalf("client.GetSubscribers() failed: %v", err)
+	}
+	if len(subscribers) != 1 || subscribers[0].Email != email || subscribers[0].SubscriptionLimit != 5 {
+		t.Fatalf("client.GetSubscribers() returned unexpected subscribers: %v", subscribers)
+	}
+
+	subscriptions, err := client.GetSubscriptionsBySubscriber(ctx, email)
+	if err != nil {
+		t.Fatalf("client.GetSubscriptionsBySubscriber() failed: %v", err)
+	}
+	if want, got := 2, len(subscriptions); want != got {
+		t.Fatalf("Expected %d subscriptions, got %d", want, got)
+	}
+	if want, got := sub1.Interval, subscriptions[0].Interval; want != got {
+		t.Errorf("Expected interval %v, got %v", want, got)
+	}
+
+	subscribers, err = client.GetSubscribersBySubscription(ctx, sub2)
+	if err != nil {
+		t.Fatalf("client.GetSubscribersBySubscription() failed: %v", err)
+	}
+	if len(subscribers) != 1 {
+		t.Fatalf("Expected 1 subscriber for %s, got %d", sub2.URL, len(subscribers))
+	}
+
+	if err := client.Unsubscribe(ctx, email, sub1); err != nil {
+		t.Fatalf("client.Unsubscribe() failed: %v", err)
+	}
+	if err := client.Unsubscribe(ctx, email, sub1); !errors.Is(err, htracker.ErrNotExist) {
+		t.Fatalf("client.Unsubscribe() expected ErrNotExist, got %v", err)
+	}
+
+	if _, err := client.Get(ctx, sub2); !errors.Is(err, htracker.ErrNotExist) {
+		t.Fatalf("client.Get() expected ErrNotExist, got %v", err)
+	}
+
+	if err := client.DeleteSubscriber(ctx, email); err != nil {
+		t.Fatalf("client.DeleteSubscriber() failed: %v", err)
+	}
+	if _, err := client.GetSubscriptionsBySubscriber(ctx, email); !errors.Is(err, htracker.ErrNotExist) {
+		t.Fatalf("client.GetSubscriptionsBySubscriber() expected ErrNotExist, got %v", err)
+	}
+}
+
+func TestAPIHandler_RequestOptions(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	// sites and subscriptions are sharing a storage, so that the archived sites are holding the
+	// subscriptions with their request options
+	mem := memory.NewStorage(logger)
+	archive := service.NewSiteArchive(mem)
+	subscriptionSvc := service.NewSubscriptionSvc(mem)
+
+	email := "email1@foo.test"
+	sub := &htracker.Subscription{URL: "http://site1.example/private", Interval: time.Hour, Request: &htracker.RequestOptions{
+		Cookies: map[string]string{"session": "secret1"}, BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret2"}}}
+	if err := subscriptionSvc.AddSubscriber(ctx, &service.Subscriber{Email: email}); err != nil {
+		t.Fatalf("Setup: AddSubscriber() failed: %v", err)
+	}
+	if err := subscriptionSvc.Subscribe(ctx, email, sub); err != nil {
+		t.Fatalf("Setup: Subscribe() failed: %v", err)
+	}
+	if _, err := archive.Update(ctx, &htracker.Site{Subscription: sub, LastChecked: time.Now(), State: htracker.SiteStateOK}); err != nil {
+		t.Fatalf("Setup: archive.Update() failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name       string
+		opts       []APIOpt
+		wantExport bool
+	}{
+		{name: "default"},
+		{name: "export request options", opts: []APIOpt{WithRequestOptionsExport()}, wantExport: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(MakeAPIHandler(archive, subscriptionSvc, logger, tt.opts...))
+			defer server.Close()
+			client := NewClient(server.URL + "/")
+
+			subscribers, err := client.GetSubscribers(ctx)
+			if err != nil || len(subscribers) != 1 || len(subscribers[0].Subscriptions) != 1 {
+				t.Fatalf("client.GetSubscribers() = %v, %v", subscribers, err)
+			}
+			if got := subscribers[0].Subscriptions[0].Request; got != nil {
+				t.Errorf("Expected no request options of subscribers, got %+v", got)
+			}
+
+			subscriptions, err := client.GetSubscriptionsBySubscriber(ctx, email)
+			if err != nil || len(subscriptions) != 1 {
+				t.Fatalf("client.GetSubscriptionsBySubscriber() = %v, %v", subscriptions, err)
+			}
+			if got := subscriptions[0].Request; got != nil {
+				t.Errorf("Expected no request options of subscriptions, got %+v", got)
+			}
+
+			site, err := client.Get(ctx, sub)
+			if err != nil {
+				t.Fatalf("client.Get() failed: %v", err)
+			}
+			if got := site.Subscription.Request; got != nil {
+				t.Errorf("Expected no request options of the archived site, got %+v", got)
+			}
+
+			res, err := http.Get(server.URL + "/api/admin/export")
+			if err != nil {
+				t.Fatalf("GET /api/admin/export failed: %v", err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("Failed to read export: %v", err)
+			}
+			if got := strings.Contains(string(body), "secret2"); got != tt.wantExport {
+				t.Errorf("Expected credentials in export: %v, got %s", tt.wantExport, body)
+			}
+		})
+	}
+
+	// the responses are redacted, not the stored subscriptions
+	subscriptions, err := subscriptionSvc.GetSubscriptionsBySubscriber(ctx, email)
+	if err != nil || len(subscriptions) != 1 || subscriptions[0].Request == nil {
+		t.Errorf("Expected the stored subscription to keep its request options, got %v, %v", subscriptions, err)
+	}
+}
+
+func TestClient_GetVersions(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	sites := memory.NewSiteStorage(logger)
+	archive := service.NewSiteArchive(sites, service.WithVersionHistory(sites))
+	subscriptionSvc := service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger))
+	server := httptest.NewServer(MakeAPIHandler(archive, subscriptionSvc, logger))
+	defer server.Close()
+
+	client := NewClient(server.URL + "/")
+
+	sub := &htracker.Subscription{URL: "http://site1.example/blah", Interval: time.Hour}
+	for i, content := range []string{"version 1", "version 2"} {
+		site := &htracker.Site{Subscription: sub, LastChecked: time.Now().Add(time.Duration(i) * time.Minute), Content: []byte(content),
+			Checksum: service.Checksum([]byte(content)), State: htracker.SiteStateOK}
+		if _, err := archive.Update(ctx, site); err != nil {
+			t.Fatalf("Setup: archive.Update() failed: %v", err)
+		}
+	}
+
+	for _, content := range []bool{false, true} {
+		versions, err := client.GetVersions(ctx, sub, content)
+		if err != nil {
+			t.Fatalf("client.GetVersions() failed: %v", err)
+		}
+		if len(versions) != 2 || versions[0].Checksum != service.Checksum([]byte("version 2")) {
+			t.Fatalf("Expected 2 versions, the latest first, got %v", versions)
+		}
+		if got := string(versions[0].Content); content && got != "version 2" || !content && got != "" {
+			t.Errorf("Expected content only if requested (%v), got %q", content, got)
+		}
+	}
+}
+
+func TestOPMLHandlers(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(logger))
+	subscriptionSvc := service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger))
+	server := httptest.NewServer(MakeAPIHandler(archive, subscriptionSvc, logger))
+	defer server.Close()
+
+	email1 := "email1@foo.test"
+	email2 := "email2@foo.test"
+	sub1 := &htracker.Subscription{URL: "http://site1.example/blah", Filter: "foo", ContentType: "text", Interval: time.Hour}
+
+	for _, email := range []string{email1, email2} {
+		if err := subscriptionSvc.AddSubscriber(ctx, &service.Subscriber{Email: email}); err != nil {
+			t.Fatalf("AddSubscriber() failed: %v", err)
+		}
+	}
+	if err := subscriptionSvc.Subscribe(ctx, email1, sub1); err != nil {
+		t.Fatalf("Subscribe() failed: %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/api/subscription/opml?email=" + email1)
+	if err != nil {
+		t.Fatalf("GET opml failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if want, got := opmlContentType, resp.Header.Get("Content-Type"); want != got {
+		t.Errorf("Expected content type %s, got %s", want, got)
+	}
+
+	resp2, err := http.Post(server.URL+"/api/subscription/opml?email="+email2, opmlContentType, resp.Body)
+	if err != nil {
+		t.Fatalf("POST opml failed: %v", err)
+	}
+	defer resp2.Body.Close()
+	if want, got := http.StatusNoContent, resp2.StatusCode; want != got {
+		t.Fatalf("Expected status %d, got %d", want, got)
+	}
+
+	subscriptions, err := subscriptionSvc.GetSubscriptionsBySubscriber(ctx, email2)
+	if err != nil {
+		t.Fatalf("GetSubscriptionsBySubscriber() failed: %v", err)
+	}
+	if len(subscriptions) != 1 || !subscriptions[0].Equals(sub1) || subscriptions[0].Interval != sub1.Interval {
+		t.Errorf("Expected imported subscriptions [%v], got %v", sub1, subscriptions)
+	}
+
+	resp3, err := http.Get(server.URL + "/api/subscription/opml?email=unknown")
+	if err != nil {
+		t.Fatalf("GET opml failed: %v", err)
+	}
+	defer resp3.Body.Close()
+	if want, got := http.StatusNotFound, resp3.StatusCode; want != got {
+		t.Errorf("Expected status %d for unknown subscriber, got %d", want, got)
+	}
+}
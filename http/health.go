@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// healthCheckTimeout is the max time all components together are given to report their health.
+const healthCheckTimeout = 10 * time.Second
+
+// Health states reported by the health handler.
+const (
+	HealthOK          = "ok"
+	HealthDegraded    = "degraded"
+	HealthUnavailable = "unavailable"
+)
+
+// HealthChecker is a component reporting its health, CheckHealth is returning nil if the
+// component is healthy.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// ComponentHealth is the health state of a single component.
+type ComponentHealth struct {
+	Status string
+	Error  string `json:",omitempty"`
+}
+
+// HealthResponse is the response of the health handler. The Status is HealthDegraded if any of
+// the components is unavailable.
+type HealthResponse struct {
+	Status     string
+	Components map[string]ComponentHealth
+}
+
+// MakeHealthHandler is returning a handler reporting the health of the given components. Unavailable
+// components are degrading the service, but it is still serving requests, so the status code is
+// always 200.
+func MakeHealthHandler(components map[string]HealthChecker) http.HandlerFunc {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+		defer cancel()
+
+		resp := HealthResponse{Status: HealthOK, Components: map[string]ComponentHealth{}}
+		for _, name := range names {
+			if err := components[name].CheckHealth(ctx); err != nil {
+				resp.Status = HealthDegraded
+				resp.Components[name] = ComponentHealth{Status: HealthUnavailable, Error: err.Error()}
+				continue
+			}
+			resp.Components[name] = ComponentHealth{Status: HealthOK}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			panic(err)
+		}
+	}
+}
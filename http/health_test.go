@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type healthFunc func(ctx context.Context) error
+
+func (f healthFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestMakeHealthHandler(t *testing.T) {
+	healthy := healthFunc(func(context.Context) error { return nil })
+	unhealthy := healthFunc(func(context.Context) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name       string
+		components map[string]HealthChecker
+		want       HealthResponse
+	}{
+		{name: "no components", want: HealthResponse{Status: HealthOK, Components: map[string]ComponentHealth{}}},
+		{name: "healthy", components: map[string]HealthChecker{"browser": healthy},
+			want: HealthResponse{Status: HealthOK, Components: map[string]ComponentHealth{"browser": {Status: HealthOK}}}},
+		{name: "degraded", components: map[string]HealthChecker{"browser": unhealthy, "db": healthy},
+			want: HealthResponse{Status: HealthDegraded, Components: map[string]ComponentHealth{
+				"browser": {Status: HealthUnavailable, Error: "connection refused"},
+				"db":      {Status: HealthOK},
+			}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			MakeHealthHandler(tt.components)(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+			if want, got := http.StatusOK, w.Code; want != got {
+				t.Errorf("Expected status code %d, got %d", want, got)
+			}
+			got := HealthResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -17,21 +17,13 @@ func createJSONHandler[Req endpoint.Requester, Resp endpoint.Responder](ep endpo
 		ctx := req.Context()
 		request, err := decodeHTTPJSONRequest[Req](ctx, req)
 		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			errResponse := struct{ Error string }{Error: fmt.Sprintf("request decoder: %s", err.Error())}
-			if err := json.NewEncoder(w).Encode(errResponse); err != nil {
-				panic(err)
-			}
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("request decoder: %s", err.Error()))
 			return
 		}
 
 		response, err := ep(ctx, request)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			errResponse := struct{ Error string }{Error: err.Error()}
-			if err := json.NewEncoder(w).Encode(errResponse); err != nil {
-				panic(err)
-			}
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
@@ -41,6 +33,15 @@ func createJSONHandler[Req endpoint.Requester, Resp endpoint.Responder](ep endpo
 	}
 }
 
+// writeJSONError is writing an error response with the given status code.
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	errResponse := struct{ Error string }{Error: msg}
+	if err := json.NewEncoder(w).Encode(errResponse); err != nil {
+		panic(err)
+	}
+}
+
 // decodeHTTPJSONRequest is a generic decoder for JSON HTTP requests.
 // TODO: Implement input validation in each Requester implementation,
 // right now we only do a basic nil check there.
@@ -68,6 +69,10 @@ func encodeHTTPJSONResponse[Resp endpoint.Responder](ctx context.Context, w http
 			w.WriteHeader(http.StatusNotFound)
 		case errors.Is(err, htracker.ErrAlreadyExists):
 			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, htracker.ErrRobotsDisallowed):
+			w.WriteHeader(http.StatusForbidden)
+		case errors.Is(err, htracker.ErrInvalid):
+			w.WriteHeader(http.StatusBadRequest)
 		default:
 			w.WriteHeader(http.StatusInternalServerError)
 		}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker/endpoint"
+)
+
+const opmlContentType = "text/x-opml; charset=utf-8"
+
+// maxOPMLSize is limiting the size of uploaded OPML documents.
+const maxOPMLSize = 10 << 20
+
+// createExportOPMLHandler is creating a HandlerFunc serving the subscriptions of the subscriber
+// given by the email query parameter as OPML document, so it can be used by feed readers directly.
+func createExportOPMLHandler(ep endpoint.Endpoint[endpoint.ExportOPMLReq, endpoint.ExportOPMLResp]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		response, err := ep(ctx, endpoint.ExportOPMLReq{Email: req.URL.Query().Get("email")})
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if response.Failed() != nil {
+			if err := encodeHTTPJSONResponse(ctx, w, response); err != nil {
+				panic(err)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", opmlContentType)
+		w.WriteHeader(response.StatusCode())
+		if _, err := w.Write(response.OPML); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// createImportOPMLHandler is creating a HandlerFunc subscribing the subscriber given by the email
+// query parameter to all sites of the OPML document in the request body. The optional interval
+// query parameter is used for all subscriptions without an interval attribute.
+func createImportOPMLHandler(ep endpoint.Endpoint[endpoint.ImportOPMLReq, endpoint.ImportOPMLResp]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		request := endpoint.ImportOPMLReq{Email: req.URL.Query().Get("email"), Interval: time.Hour}
+
+		if interval := req.URL.Query().Get("interval"); interval != "" {
+			i, err := time.ParseDuration(interval)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("request decoder: %s", err.Error()))
+				return
+			}
+			request.Interval = i
+		}
+
+		opml, err := io.ReadAll(io.LimitReader(req.Body, maxOPMLSize))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("request decoder: %s", err.Error()))
+			return
+		}
+		request.OPML = opml
+
+		response, err := ep(ctx, request)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := encodeHTTPJSONResponse(ctx, w, response); err != nil {
+			panic(err)
+		}
+	}
+}
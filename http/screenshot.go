@@ -0,0 +1,39 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+
+	"gitlab.com/henri.philipps/htracker/endpoint"
+)
+
+// createScreenshotHandler is creating a HandlerFunc serving an image of the screenshot of the site given
+// by the JSON request as PNG. Errors are returned as JSON.
+func createScreenshotHandler(ep endpoint.Endpoint[endpoint.GetScreenshotReq, endpoint.GetScreenshotResp]) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		request, err := decodeHTTPJSONRequest[endpoint.GetScreenshotReq](ctx, req)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("request decoder: %s", err.Error()))
+			return
+		}
+
+		response, err := ep(ctx, request)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if response.Failed() != nil {
+			if err := encodeHTTPJSONResponse(ctx, w, response); err != nil {
+				panic(err)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(response.StatusCode())
+		if _, err := w.Write(response.PNG); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+)
+
+// GCTotals are the totals of the garbage collector since the start of the instance.
+type GCTotals struct {
+	Runs int
+	storage.GCStats
+}
+
+// PruneTotals are the totals of the pruner since the start of the instance.
+type PruneTotals struct {
+	Runs int
+	// Versions is the number of versions removed, which were not kept by their retention policy.
+	Versions int
+}
+
+// StatsResponse is the response of the stats handler. The totals of disabled jobs are left out.
+type StatsResponse struct {
+	GarbageCollector *GCTotals    `json:",omitempty"`
+	Pruner           *PruneTotals `json:",omitempty"`
+}
+
+// MakeStatsHandler is returning a handler reporting the totals of the maintenance jobs of the instance.
+// Jobs which are disabled are given as nil.
+func MakeStatsHandler(gc *service.GarbageCollector, pruner *service.Pruner) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		resp := StatsResponse{}
+		if gc != nil {
+			runs, total := gc.Stats()
+			resp.GarbageCollector = &GCTotals{Runs: runs, GCStats: total}
+		}
+		if pruner != nil {
+			runs, total := pruner.Stats()
+			resp.Pruner = &PruneTotals{Runs: runs, Versions: total}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			panic(err)
+		}
+	}
+}
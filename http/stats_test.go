@@ -0,0 +1,75 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func TestMakeStatsHandler(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+
+	// an orphan site with an orphan version
+	db := memory.NewStorage(logger)
+	sub := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour}
+	site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Checksum: "1234"}
+	if err := db.Add(ctx, site); err != nil {
+		t.Fatalf("Setup: failed to add site: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.AddVersion(ctx, site); err != nil {
+			t.Fatalf("Setup: failed to add version: %v", err)
+		}
+	}
+
+	gc := service.NewGarbageCollector(db, time.Hour, logger)
+	pruner := service.NewPruner(db, htracker.RetentionPolicy{KeepLast: 1}, time.Hour, logger)
+	if _, err := pruner.Prune(ctx); err != nil {
+		t.Fatalf("Setup: Prune() failed: %v", err)
+	}
+	if _, err := gc.Collect(ctx); err != nil {
+		t.Fatalf("Setup: Collect() failed: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		gc     *service.GarbageCollector
+		pruner *service.Pruner
+		want   StatsResponse
+	}{
+		{name: "disabled", want: StatsResponse{}},
+		{name: "enabled", gc: gc, pruner: pruner, want: StatsResponse{
+			GarbageCollector: &GCTotals{Runs: 1, GCStats: storage.GCStats{Sites: 1}},
+			Pruner:           &PruneTotals{Runs: 1, Versions: 1},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			MakeStatsHandler(tt.gc, tt.pruner)(w, httptest.NewRequest(http.MethodGet, "/api/admin/stats", nil))
+
+			if want, got := http.StatusOK, w.Code; want != got {
+				t.Errorf("Expected status code %d, got %d", want, got)
+			}
+			got := StatsResponse{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package htracker
+
+import "strings"
+
+// ContentTypeLinks is the content type of subscriptions to the links of a site, e.g. for getting notified
+// about new pages. The content of their sites is the sorted list of urls of a sitemap, or of the anchors of
+// a html page matching the filter, which is compared as set instead of diffing the text of the site.
+const ContentTypeLinks = "links"
+
+// LinkChanges are the urls which were added to or removed from a site since the last scrape.
+type LinkChanges struct {
+	Added   []string
+	Removed []string
+}
+
+// Empty is returning whether there are no changes.
+func (c *LinkChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// String is returning the changes as text, e.g. "1 added link:\n+ https://site.example/new\n".
+func (c *LinkChanges) String() string {
+	return diffString(c.Diff())
+}
+
+// Diff is returning the changes as Diff, added links are insertions and removed links are deletions.
+func (c *LinkChanges) Diff() Diff {
+	prefixed := func(links []string, prefix string) []string {
+		lines := make([]string, 0, len(links))
+		for _, link := range links {
+			lines = append(lines, prefix+" "+link)
+		}
+		return lines
+	}
+
+	d := appendChangeList(Diff{}, prefixed(c.Added, "+"), "added", "link", DiffInsert)
+	return appendChangeList(d, prefixed(c.Removed, "-"), "removed", "link", DiffDelete)
+}
+
+// IsLinks is returning whether the links of the subscribed site are watched.
+func (s *Subscription) IsLinks() bool {
+	return strings.EqualFold(s.ContentType, ContentTypeLinks)
+}
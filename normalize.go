@@ -0,0 +1,18 @@
+package htracker
+
+// Normalization modes of the content of a site, which is normalized before computing its checksum and
+// diffing it, so that invisible differences like line endings are not reported as changes. In all modes
+// the content was decoded to UTF-8 before, using the charset of the response header or html meta tag,
+// as filters can't be applied to the raw bytes.
+const (
+	// NormalizeDefault is removing byte order marks, normalizing unicode to NFC and line endings to LF.
+	// Unfiltered html is rendered from the parsed document, which is normalizing its entities and markup.
+	NormalizeDefault = ""
+
+	// NormalizeWhitespace is collapsing whitespace to single spaces as well, trimming lines and
+	// removing empty lines.
+	NormalizeWhitespace = "whitespace"
+
+	// NormalizeNone is keeping the content as scraped and decoded.
+	NormalizeNone = "none"
+)
@@ -0,0 +1,132 @@
+package htracker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is an archived version of the content of a site, see RetentionPolicy.
+type Version struct {
+	// Archived is the time the content was scraped.
+	Archived time.Time
+	Checksum string
+	// Content is empty if the content is stored in a blob storage, referenced by its checksum.
+	Content []byte `json:",omitempty"`
+}
+
+// RetentionPolicy is defining which archived versions of a site are kept, a version is kept if any
+// of the rules is keeping it. The latest version is always kept. The zero value is keeping all versions.
+type RetentionPolicy struct {
+	// KeepLast is the number of latest versions kept.
+	KeepLast int `json:",omitempty"`
+	// KeepWithin is keeping all versions younger than the duration.
+	KeepWithin time.Duration `json:",omitempty"`
+	// KeepDaily is the number of days for which the latest version of the day is kept, starting
+	// with the latest day having a version.
+	KeepDaily int `json:",omitempty"`
+	// KeepWeekly is the number of ISO weeks for which the latest version of the week is kept, starting
+	// with the latest week having a version.
+	KeepWeekly int `json:",omitempty"`
+}
+
+// retentionAll is the string of the zero RetentionPolicy, which is keeping all versions.
+const retentionAll = "all"
+
+// ParseRetentionPolicy is parsing a policy in the format of RetentionPolicy.String, e.g.
+// "last=10,within=72h,daily=7,weekly=4", or "all" for keeping all versions. An error wrapping
+// ErrInvalid is returned if s is no valid policy.
+func ParseRetentionPolicy(s string) (*RetentionPolicy, error) {
+	p := &RetentionPolicy{}
+	if s == retentionAll {
+		return p, nil
+	}
+	if s == "" {
+		return nil, fmt.Errorf("empty retention policy: %w", ErrInvalid)
+	}
+
+	for _, rule := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		var err error
+		switch name {
+		case "last":
+			p.KeepLast, err = strconv.Atoi(value)
+		case "within":
+			p.KeepWithin, err = time.ParseDuration(value)
+		case "daily":
+			p.KeepDaily, err = strconv.Atoi(value)
+		case "weekly":
+			p.KeepWeekly, err = strconv.Atoi(value)
+		default:
+			return nil, fmt.Errorf("retention rule %q not supported, expected last, within, daily or weekly: %w", rule, ErrInvalid)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention rule %q: %v: %w", rule, err, ErrInvalid)
+		}
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// String is returning the policy in the format parsed by ParseRetentionPolicy.
+func (p RetentionPolicy) String() string {
+	var rules []string
+	if p.KeepLast != 0 {
+		rules = append(rules, "last="+strconv.Itoa(p.KeepLast))
+	}
+	if p.KeepWithin != 0 {
+		rules = append(rules, "within="+p.KeepWithin.String())
+	}
+	if p.KeepDaily != 0 {
+		rules = append(rules, "daily="+strconv.Itoa(p.KeepDaily))
+	}
+	if p.KeepWeekly != 0 {
+		rules = append(rules, "weekly="+strconv.Itoa(p.KeepWeekly))
+	}
+	if len(rules) == 0 {
+		return retentionAll
+	}
+	return strings.Join(rules, ",")
+}
+
+// Validate is returning an error wrapping ErrInvalid if any rule of the policy is negative.
+func (p RetentionPolicy) Validate() error {
+	if p.KeepLast < 0 || p.KeepWithin < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 {
+		return fmt.Errorf("retention policy %s must not be negative: %w", p, ErrInvalid)
+	}
+	return nil
+}
+
+// Keep is returning which of the versions archived at the given times are kept at now, given the
+// times latest first. Days and weeks are in UTC.
+func (p RetentionPolicy) Keep(archived []time.Time, now time.Time) []bool {
+	keep := make([]bool, len(archived))
+	if p == (RetentionPolicy{}) {
+		for i := range keep {
+			keep[i] = true
+		}
+		return keep
+	}
+
+	days, weeks := map[string]bool{}, map[string]bool{}
+	for i, t := range archived {
+		t = t.UTC()
+		keep[i] = i == 0 || i < p.KeepLast || now.Sub(t) < p.KeepWithin
+
+		// the first version seen of a day or week is its latest one
+		if day := t.Format("2006-01-02"); !days[day] && len(days) < p.KeepDaily {
+			days[day] = true
+			keep[i] = true
+		}
+		year, week := t.ISOWeek()
+		if w := fmt.Sprintf("%d-%d", year, week); !weeks[w] && len(weeks) < p.KeepWeekly {
+			weeks[w] = true
+			keep[i] = true
+		}
+	}
+	return keep
+}
@@ -0,0 +1,94 @@
+package htracker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    *RetentionPolicy
+		wantErr bool
+	}{
+		{s: "all", want: &RetentionPolicy{}},
+		{s: "last=10", want: &RetentionPolicy{KeepLast: 10}},
+		{s: "last=10,within=72h0m0s,daily=7,weekly=4", want: &RetentionPolicy{KeepLast: 10, KeepWithin: 72 * time.Hour, KeepDaily: 7, KeepWeekly: 4}},
+		{s: "", wantErr: true},
+		{s: "monthly=3", wantErr: true},
+		{s: "last=many", wantErr: true},
+		{s: "within=3d", wantErr: true},
+		{s: "daily=-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseRetentionPolicy(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRetentionPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalid) {
+					t.Errorf("ParseRetentionPolicy() expected ErrInvalid, got %v", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRetentionPolicy() = %+v, want %+v", got, tt.want)
+			}
+			if s := got.String(); s != tt.s {
+				t.Errorf("String() = %q, want %q", s, tt.s)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicy_Keep(t *testing.T) {
+	now := time.Date(2023, 3, 15, 12, 0, 0, 0, time.UTC) // a wednesday
+	// two versions per day for 21 days, latest first
+	var archived []time.Time
+	for i := 0; i < 42; i++ {
+		archived = append(archived, now.Add(-time.Duration(i)*12*time.Hour))
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   []int
+	}{
+		{name: "all", policy: RetentionPolicy{}, want: seq(0, 42)},
+		{name: "latest is always kept", policy: RetentionPolicy{KeepWithin: time.Nanosecond}, want: []int{0}},
+		{name: "last", policy: RetentionPolicy{KeepLast: 3}, want: []int{0, 1, 2}},
+		{name: "within", policy: RetentionPolicy{KeepWithin: 36 * time.Hour}, want: []int{0, 1, 2}},
+		// the versions at 12:00 are the latest of their day
+		{name: "daily", policy: RetentionPolicy{KeepDaily: 3}, want: []int{0, 2, 4}},
+		// the weeks are starting on monday, the 13th and 6th of march and the 27th of february
+		{name: "weekly", policy: RetentionPolicy{KeepWeekly: 3}, want: []int{0, 6, 20}},
+		{name: "combined", policy: RetentionPolicy{KeepLast: 2, KeepDaily: 2, KeepWeekly: 2}, want: []int{0, 1, 2, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for i, keep := range tt.policy.Keep(archived, now) {
+				if keep {
+					got = append(got, i)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Keep() kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// seq is returning the numbers from start up to end, excluding end.
+func seq(start, end int) []int {
+	s := []int{}
+	for i := start; i < end; i++ {
+		s = append(s, i)
+	}
+	return s
+}
@@ -0,0 +1,198 @@
+// Package robots is implementing a robots.txt policy, deciding which urls may be scraped
+// and how long to wait between requests to a host.
+package robots
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+	"golang.org/x/exp/slog"
+)
+
+// maxRobotsSize is the max size of a robots.txt file, content exceeding it is ignored.
+const maxRobotsSize = 500 * 1024
+
+// Policy is fetching robots.txt files and caching them per host.
+type Policy struct {
+	httpClient *http.Client
+	ttl        time.Duration
+	errorTTL   time.Duration
+	logger     *slog.Logger
+
+	mu        sync.Mutex
+	userAgent string
+	cache     map[string]*entry
+}
+
+// entry is a cached robots.txt, ready is closed as soon as it was fetched.
+type entry struct {
+	ready   chan struct{}
+	expires time.Time
+	data    *robotstxt.RobotsData
+	err     error
+}
+
+// Opt is a functional option for the Policy.
+type Opt func(*Policy)
+
+// WithHTTPClient is setting the client used to fetch robots.txt files.
+func WithHTTPClient(client *http.Client) Opt {
+	return func(p *Policy) {
+		p.httpClient = client
+	}
+}
+
+// WithTTL is setting how long robots.txt files are cached.
+func WithTTL(ttl time.Duration) Opt {
+	return func(p *Policy) {
+		p.ttl = ttl
+	}
+}
+
+// WithErrorTTL is setting how long robots.txt files answered with a server error are cached. They are
+// disallowing everything, but the error might be temporary.
+func WithErrorTTL(ttl time.Duration) Opt {
+	return func(p *Policy) {
+		p.errorTTL = ttl
+	}
+}
+
+// WithLogger is setting the logger.
+func WithLogger(logger *slog.Logger) Opt {
+	return func(p *Policy) {
+		p.logger = logger
+	}
+}
+
+// New is returning a new Policy applying the rules for the given user agent.
+func New(userAgent string, opts ...Opt) *Policy {
+	p := &Policy{
+		userAgent:  userAgent,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		ttl:        24 * time.Hour,
+		errorTTL:   5 * time.Minute,
+		logger:     slog.Default(),
+		cache:      map[string]*entry{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Check is returning whether the given url may be scraped and the crawl delay requested for its host.
+// If robots.txt could not be fetched, the url is allowed and the error returned.
+func (p *Policy) Check(ctx context.Context, rawURL string) (allowed bool, crawlDelay time.Duration, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to parse url %s: %w", rawURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false, 0, fmt.Errorf("url scheme %s not supported", u.Scheme)
+	}
+
+	data, err := p.robots(ctx, u.Scheme+"://"+u.Host)
+	if err != nil {
+		return true, 0, err
+	}
+
+	userAgent := p.UserAgent()
+	return data.TestAgent(u.EscapedPath(), userAgent), data.FindGroup(userAgent).CrawlDelay, nil
+}
+
+// UserAgent is returning the user agent the rules are applied for.
+func (p *Policy) UserAgent() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.userAgent
+}
+
+// SetUserAgent is changing the user agent the rules are applied for.
+func (p *Policy) SetUserAgent(userAgent string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.userAgent = userAgent
+}
+
+// Allowed is returning whether the given url may be scraped.
+// If robots.txt could not be fetched, the url is allowed and the error returned.
+func (p *Policy) Allowed(ctx context.Context, rawURL string) (bool, error) {
+	allowed, _, err := p.Check(ctx, rawURL)
+	return allowed, err
+}
+
+// robots is returning the robots.txt of the given origin from the cache, fetching it if necessary.
+// Concurrent calls for the same origin are waiting for a single fetch.
+func (p *Policy) robots(ctx context.Context, origin string) (*robotstxt.RobotsData, error) {
+	p.mu.Lock()
+	e, ok := p.cache[origin]
+	if !ok || time.Now().After(e.expires) {
+		e = &entry{ready: make(chan struct{}), expires: time.Now().Add(p.ttl)}
+		p.cache[origin] = e
+		p.mu.Unlock()
+
+		var status int
+		e.data, status, e.err = p.fetch(ctx, origin)
+		switch {
+		case e.err != nil:
+			// don't cache errors, they might be temporary
+			p.mu.Lock()
+			if p.cache[origin] == e {
+				delete(p.cache, origin)
+			}
+			p.mu.Unlock()
+		case status >= http.StatusInternalServerError:
+			// server errors might be temporary as well, but they are disallowing everything for a while
+			p.mu.Lock()
+			e.expires = time.Now().Add(p.errorTTL)
+			p.mu.Unlock()
+		}
+		close(e.ready)
+	} else {
+		p.mu.Unlock()
+	}
+
+	select {
+	case <-e.ready:
+		return e.data, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch is returning the parsed robots.txt of the given origin and the status code of the response.
+func (p *Policy) fetch(ctx context.Context, origin string) (*robotstxt.RobotsData, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", p.UserAgent())
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch robots.txt of %s: %w", origin, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to read robots.txt of %s: %w", origin, err)
+	}
+
+	// 4xx is allowing everything, 5xx is disallowing everything
+	data, err := robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to parse robots.txt of %s: %w", origin, err)
+	}
+
+	p.logger.Debug("fetched robots.txt", "origin", origin, "status", resp.StatusCode)
+	return data, resp.StatusCode, nil
+}
@@ -0,0 +1,159 @@
+package robots
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const robotsTxt = `
+User-agent: *
+Disallow: /private
+Crawl-delay: 2
+
+User-agent: htracker
+Disallow: /no-htracker
+Allow: /private/public
+Crawl-delay: 1
+`
+
+func TestPolicy_Check(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(&fetches, 1)
+		fmt.Fprint(w, robotsTxt)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		path           string
+		wantAllowed    bool
+		wantCrawlDelay time.Duration
+	}{
+		{name: "allowed", userAgent: "SomeBot/1.0", path: "/public", wantAllowed: true, wantCrawlDelay: 2 * time.Second},
+		{name: "disallowed", userAgent: "SomeBot/1.0", path: "/private/page", wantCrawlDelay: 2 * time.Second},
+		{name: "agent group allowed", userAgent: "HTracker/Geziyor 1.0", path: "/private/public/page", wantAllowed: true, wantCrawlDelay: time.Second},
+		{name: "agent group disallowed", userAgent: "HTracker/Geziyor 1.0", path: "/no-htracker", wantCrawlDelay: time.Second},
+		{name: "agent group ignores other groups", userAgent: "HTracker/Geziyor 1.0", path: "/private", wantAllowed: true, wantCrawlDelay: time.Second},
+	}
+
+	ctx := context.Background()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(tt.userAgent)
+			allowed, crawlDelay, err := p.Check(ctx, server.URL+tt.path)
+			if err != nil {
+				t.Fatalf("Policy.Check() failed: %v", err)
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("Policy.Check() allowed = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if crawlDelay != tt.wantCrawlDelay {
+				t.Errorf("Policy.Check() crawlDelay = %v, want %v", crawlDelay, tt.wantCrawlDelay)
+			}
+		})
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		atomic.StoreInt32(&fetches, 0)
+		p := New("SomeBot/1.0")
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := p.Allowed(ctx, server.URL+"/public"); err != nil {
+					t.Errorf("Policy.Allowed() failed: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if got := atomic.LoadInt32(&fetches); got != 1 {
+			t.Errorf("Expected robots.txt to be fetched once, got %d fetches", got)
+		}
+	})
+}
+
+func TestPolicy_Check_Status(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		wantAllowed bool
+	}{
+		{name: "not found", status: http.StatusNotFound, wantAllowed: true},
+		{name: "forbidden", status: http.StatusForbidden, wantAllowed: true},
+		{name: "server error", status: http.StatusServiceUnavailable, wantAllowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			allowed, err := New("htracker").Allowed(context.Background(), server.URL+"/page")
+			if err != nil {
+				t.Fatalf("Policy.Allowed() failed: %v", err)
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("Policy.Allowed() = %v, want %v", allowed, tt.wantAllowed)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		allowed, err := New("htracker").Allowed(context.Background(), server.URL+"/page")
+		if err == nil || !allowed {
+			t.Errorf("Policy.Allowed() expected to allow with error, got %v, %v", allowed, err)
+		}
+	})
+}
+
+func TestPolicy_Check_ServerErrorExpires(t *testing.T) {
+	// the first fetch is failing with a server error, all later ones are succeeding
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, robotsTxt)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	p := New("SomeBot/1.0", WithErrorTTL(time.Millisecond))
+	for i, want := range []bool{false, true, true} {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		allowed, err := p.Allowed(ctx, server.URL+"/public")
+		if err != nil {
+			t.Fatalf("check %d: Policy.Allowed() failed: %v", i, err)
+		}
+		if allowed != want {
+			t.Errorf("check %d: Policy.Allowed() = %v, want %v", i, allowed, want)
+		}
+	}
+	// the successful fetch is cached for the regular ttl
+	if got := atomic.LoadInt32(&fetches); got != 2 {
+		t.Errorf("Expected robots.txt to be fetched twice, got %d fetches", got)
+	}
+}
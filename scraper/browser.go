@@ -0,0 +1,417 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/geziyor/geziyor/client"
+	"gitlab.com/henri.philipps/htracker"
+	"golang.org/x/exp/slog"
+)
+
+// ErrBrowserUnavailable is returned if the chrome browser used for rendering can't be reached.
+var ErrBrowserUnavailable = errors.New("browser unavailable")
+
+const (
+	// DefaultMaxTabs is the default number of tabs a Browser is rendering sites in concurrently.
+	DefaultMaxTabs = 4
+
+	// defaultRetryAfter is the time a Browser is waiting after a failed connection attempt, before
+	// trying to connect again. Until then rendering fails immediately.
+	defaultRetryAfter = 30 * time.Second
+
+	// healthCheckTimeout is the max time CheckHealth is waiting for the endpoint to accept a connection.
+	healthCheckTimeout = 5 * time.Second
+)
+
+// Browser is a connection to a remote chrome browser used for rendering sites. It can be shared by
+// several scrapers, which are rendering their sites in tabs of the same browser session. The connection
+// is established on first use and re-established if it was lost.
+type Browser struct {
+	logger     *slog.Logger
+	tabs       chan struct{}
+	retryAfter time.Duration
+
+	mu       sync.Mutex
+	endpoint string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	err      error
+	failed   time.Time
+}
+
+// NewBrowser is returning a new Browser connecting to the chrome websocket endpoint.
+func NewBrowser(endpoint string, opts ...BrowserOpt) *Browser {
+	b := &Browser{
+		logger:     slog.Default(),
+		tabs:       make(chan struct{}, DefaultMaxTabs),
+		retryAfter: defaultRetryAfter,
+		endpoint:   endpoint,
+	}
+
+	for _, o := range opts {
+		o(b)
+	}
+
+	return b
+}
+
+// Endpoint is returning the websocket endpoint of the browser.
+func (b *Browser) Endpoint() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.endpoint
+}
+
+// SetEndpoint is changing the websocket endpoint of the browser, closing the current connection if
+// the endpoint changed.
+func (b *Browser) SetEndpoint(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if endpoint == b.endpoint {
+		return
+	}
+	b.endpoint = endpoint
+	b.disconnect()
+	b.err = nil
+}
+
+// Health is returning nil if the browser is available, or the error of the last failed attempt
+// to reach it, wrapping ErrBrowserUnavailable.
+func (b *Browser) Health() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.endpoint == "" {
+		return fmt.Errorf("no endpoint configured: %w", ErrBrowserUnavailable)
+	}
+	return b.err
+}
+
+// CheckHealth is checking whether the endpoint of the browser is accepting connections and updates
+// the health state accordingly. It is returning the new health state (see Health()).
+func (b *Browser) CheckHealth(ctx context.Context) error {
+	endpoint := b.Endpoint()
+	if endpoint == "" {
+		return b.Health()
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return b.fail(nil, fmt.Errorf("invalid endpoint %s: %w", endpoint, err))
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "80")
+		if u.Scheme == "wss" || u.Scheme == "https" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return b.fail(nil, err)
+	}
+	conn.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.err = nil
+
+	return nil
+}
+
+// Close is closing the connection to the browser.
+func (b *Browser) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.disconnect()
+}
+
+// Render is loading the site of req in a new tab of the browser, running the given script once the
+// site is ready and returning the resulting html as response. Each tab has its own browser context, so
+// cookies are not shared between renders. The headers, cookies and basic auth credentials of req are
+// only sent to the origin of the site, the user agent is sent with all requests of the tab.
+// If the browser can't be reached, an error wrapping ErrBrowserUnavailable is returned.
+func (b *Browser) Render(ctx context.Context, req *client.Request, script []htracker.ChromeAction, opts ...RenderOpt) (*client.Response, error) {
+	scriptActions, err := chromeActions(script)
+	if err != nil {
+		return nil, err
+	}
+	o := renderOpts{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	select {
+	case b.tabs <- struct{}{}:
+		defer func() { <-b.tabs }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	browserCtx, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	targetID, dispose, err := newIsolatedTarget(browserCtx)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
+		return nil, b.fail(browserCtx, fmt.Errorf("failed to open tab: %w", err))
+	}
+	defer dispose()
+
+	// the tab is derived from the browser session, it is closed when the request is done or canceled
+	tabCtx, cancel := chromedp.NewContext(browserCtx, chromedp.WithTargetID(targetID))
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-done:
+		}
+	}()
+
+	if err := chromedp.Run(tabCtx); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, ctx.Err()
+		}
+		return nil, b.fail(browserCtx, fmt.Errorf("failed to open tab: %w", err))
+	}
+
+	scope := newOriginScope(req.Request)
+	var body string
+	var res *network.Response
+	actions := []chromedp.Action{
+		network.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			chromedp.ListenTarget(ctx, func(ev interface{}) {
+				if event, ok := ev.(*network.EventResponseReceived); ok && res == nil && event.Type == network.ResourceTypeDocument {
+					res = event.Response
+				}
+			})
+			return nil
+		}),
+	}
+	if ua := req.Header.Get("User-Agent"); ua != "" {
+		actions = append(actions, emulation.SetUserAgentOverride(ua))
+	}
+	actions = append(actions, scope.actions(tabCtx)...)
+	actions = append(actions,
+		chromedp.Navigate(req.URL.String()),
+		chromedp.WaitReady(":root"),
+	)
+	actions = append(actions, scriptActions...)
+	actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+		node, err := dom.GetDocument().Do(ctx)
+		if err != nil {
+			return err
+		}
+		body, err = dom.GetOuterHTML().WithNodeID(node.NodeID).Do(ctx)
+		return err
+	}))
+	if o.screenshot != nil {
+		actions = append(actions, screenshotAction(o.selector, o.screenshot))
+	}
+
+	if err := chromedp.Run(tabCtx, actions...); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to render site: %w", err)
+	}
+
+	resp := &client.Response{
+		Response: &http.Response{Request: req.Request},
+		Body:     []byte(body),
+		Request:  req,
+	}
+	if res != nil {
+		resp.StatusCode = int(res.Status)
+		resp.Proto = res.Protocol
+		resp.Header = client.ConvertMapToHeader(res.Headers)
+	}
+	if resp.HTMLDoc, err = goquery.NewDocumentFromReader(bytes.NewReader(resp.Body)); err != nil {
+		return nil, fmt.Errorf("failed to parse rendered html: %w", err)
+	}
+
+	return resp, nil
+}
+
+// newIsolatedTarget is creating a blank tab in a new browser context of the browser session browserCtx,
+// which is not sharing cookies and caches with other tabs. The returned func is disposing the browser
+// context with its tab.
+func newIsolatedTarget(browserCtx context.Context) (target.ID, func(), error) {
+	c := chromedp.FromContext(browserCtx)
+	if c == nil || c.Browser == nil {
+		return "", nil, errors.New("not connected to a browser")
+	}
+	execCtx := cdp.WithExecutor(browserCtx, c.Browser)
+
+	contextID, err := target.CreateBrowserContext().WithDisposeOnDetach(true).Do(execCtx)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create browser context: %w", err)
+	}
+	dispose := func() {
+		// the browser context is disposed on disconnect as well
+		_ = target.DisposeBrowserContext(contextID).Do(execCtx)
+	}
+
+	targetID, err := target.CreateTarget("about:blank").WithBrowserContextID(contextID).Do(execCtx)
+	if err != nil {
+		dispose()
+		return "", nil, fmt.Errorf("failed to create target: %w", err)
+	}
+
+	return targetID, dispose, nil
+}
+
+// connect is returning the context of the browser session, connecting to the browser if not yet
+// connected or if the connection was lost. After a failed attempt, connecting is retried after
+// retryAfter only, failing immediately until then.
+func (b *Browser) connect() (context.Context, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx != nil && b.ctx.Err() == nil {
+		return b.ctx, nil
+	}
+	if b.endpoint == "" {
+		return nil, fmt.Errorf("no endpoint configured: %w", ErrBrowserUnavailable)
+	}
+	if b.err != nil && time.Since(b.failed) < b.retryAfter {
+		return nil, b.err
+	}
+
+	if b.ctx != nil {
+		b.logger.Warn("lost connection to browser, reconnecting", "endpoint", b.endpoint)
+		b.disconnect()
+	}
+
+	allocCtx, allocCancel := chromedp.NewRemoteAllocator(context.Background(), b.endpoint)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	b.cancel = func() {
+		cancel()
+		allocCancel()
+	}
+
+	// the first run is connecting to the browser, the session lives as long as ctx
+	if err := chromedp.Run(ctx); err != nil {
+		b.disconnect()
+		b.err = fmt.Errorf("failed to connect to %s: %v: %w", b.endpoint, err, ErrBrowserUnavailable)
+		b.failed = time.Now()
+		b.logger.Warn("browser unavailable", "endpoint", b.endpoint, "error", err)
+		return nil, b.err
+	}
+
+	b.ctx = ctx
+	b.err = nil
+	b.logger.Debug("connected to browser", "endpoint", b.endpoint)
+
+	return ctx, nil
+}
+
+// fail is recording err as health state and closing the connection of the session browserCtx, if
+// still active. It is returning err wrapping ErrBrowserUnavailable.
+func (b *Browser) fail(browserCtx context.Context, err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if browserCtx != nil && browserCtx == b.ctx {
+		b.disconnect()
+	}
+	b.err = fmt.Errorf("%v: %w", err, ErrBrowserUnavailable)
+	b.failed = time.Now()
+	b.logger.Warn("browser unavailable", "endpoint", b.endpoint, "error", err)
+
+	return b.err
+}
+
+// disconnect is closing the current browser session, b.mu must be held.
+func (b *Browser) disconnect() {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.ctx = nil
+	b.cancel = nil
+}
+
+// screenshotAction is returning the action capturing a PNG screenshot of the element matching the CSS
+// selector, or of the full page if the selector is empty.
+func screenshotAction(selector string, image *[]byte) chromedp.Action {
+	if selector == "" {
+		// the quality of 100 is selecting the PNG format
+		return chromedp.FullScreenshot(image, 100)
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		tctx, cancel := context.WithTimeout(ctx, defaultActionTimeout)
+		defer cancel()
+		if err := chromedp.Screenshot(selector, image, chromedp.ByQuery).Do(tctx); err != nil {
+			return fmt.Errorf("screenshot of %s failed: %w", selector, err)
+		}
+		return nil
+	})
+}
+
+// renderOpts are the options of a single call of Render.
+type renderOpts struct {
+	selector   string
+	screenshot *[]byte
+}
+
+// RenderOpt is a type representing functional options of Browser.Render.
+type RenderOpt func(*renderOpts)
+
+// WithScreenshot is capturing a PNG screenshot of the rendered site into image, after the script
+// was run. The element matching the CSS selector is captured, the full page if the selector is empty.
+func WithScreenshot(selector string, image *[]byte) RenderOpt {
+	return func(o *renderOpts) {
+		o.selector = selector
+		o.screenshot = image
+	}
+}
+
+// BrowserOpt is a type representing functional Browser options.
+type BrowserOpt func(*Browser)
+
+// WithMaxTabs is limiting the number of tabs rendering sites concurrently.
+func WithMaxTabs(n int) BrowserOpt {
+	return func(b *Browser) {
+		if n > 0 {
+			b.tabs = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithBrowserLogger configures the logger of the Browser.
+func WithBrowserLogger(logger *slog.Logger) BrowserOpt {
+	return func(b *Browser) {
+		b.logger = logger
+	}
+}
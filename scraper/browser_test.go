@@ -0,0 +1,223 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/geziyor/geziyor/client"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+// closedEndpoint is returning a websocket endpoint nobody is listening on.
+func closedEndpoint(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	return "ws://" + addr
+}
+
+func TestBrowser_Unavailable(t *testing.T) {
+	b := NewBrowser(closedEndpoint(t), WithBrowserLogger(slog.Default()))
+	defer b.Close()
+
+	req, err := client.NewRequest(http.MethodGet, "http://site.example", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Render(context.Background(), req, nil); !errors.Is(err, ErrBrowserUnavailable) {
+		t.Fatalf("Expected ErrBrowserUnavailable, got %v", err)
+	}
+	if err := b.Health(); !errors.Is(err, ErrBrowserUnavailable) {
+		t.Errorf("Expected browser to be unhealthy, got %v", err)
+	}
+
+	// connecting is not retried immediately
+	start := time.Now()
+	if _, err := b.Render(context.Background(), req, nil); !errors.Is(err, ErrBrowserUnavailable) {
+		t.Fatalf("Expected ErrBrowserUnavailable, got %v", err)
+	}
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Errorf("Expected render to fail immediately, took %s", d)
+	}
+}
+
+func TestBrowser_CheckHealth(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantErr  bool
+	}{
+		{name: "listening", endpoint: "ws://" + l.Addr().String()},
+		{name: "closed", endpoint: closedEndpoint(t), wantErr: true},
+		{name: "not configured", endpoint: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBrowser(tt.endpoint)
+
+			err := b.CheckHealth(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckHealth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrBrowserUnavailable) {
+				t.Errorf("Expected ErrBrowserUnavailable, got %v", err)
+			}
+			if want, got := err, b.Health(); fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Errorf("Expected health %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestScraper_BrowserUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "plain")
+	}))
+	defer server.Close()
+
+	fallback := &htracker.Subscription{URL: server.URL, UseChrome: true, ChromeFallback: true}
+	noFallback := &htracker.Subscription{URL: server.URL, ContentType: "no fallback", UseChrome: true}
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+	browser := NewBrowser(closedEndpoint(t))
+	defer browser.Close()
+
+	NewScraper([]*htracker.Subscription{fallback, noFallback}, WithExporters([]exporter.Interface{exp}),
+		WithBrowser(browser), WithLogDisabled(true)).Start()
+
+	tests := []struct {
+		name         string
+		subscription *htracker.Subscription
+		wantState    htracker.SiteState
+		wantContent  string
+	}{
+		{name: "fallback", subscription: fallback, wantState: htracker.SiteStateRenderFallback, wantContent: "plain"},
+		{name: "no fallback", subscription: noFallback, wantState: htracker.SiteStateBrowserUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, err := archive.Get(context.Background(), tt.subscription)
+			if err != nil {
+				t.Fatalf("archive.Get() failed: %v", err)
+			}
+			if want, got := tt.wantState, site.State; want != got {
+				t.Errorf("Expected state %s, got %s", want, got)
+			}
+			if want, got := tt.wantContent, string(site.Content); want != got {
+				t.Errorf("Expected content %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestBrowser_Render_OriginScope(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", intTestVarName)
+	}
+
+	// the third party is embedded by the site, it must not receive the credentials of the site
+	var leaked []string
+	var mu sync.Mutex
+	thirdParty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		for _, name := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+			if r.Header.Get(name) != "" {
+				leaked = append(leaked, name)
+			}
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/javascript")
+		fmt.Fprint(w, `document.documentElement.setAttribute("data-tracked", "true")`)
+	}))
+	defer thirdParty.Close()
+	// cookies are not scoped by port, so the third party needs another host
+	thirdPartyURL := strings.Replace(thirdParty.URL, "127.0.0.1", "localhost", 1)
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "password" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "secret" {
+			http.Error(w, "missing cookie", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-Api-Key") != "key" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		fmt.Fprintf(w, `<html><head><script src="%s/track.js"></script></head><body><p id="content">private</p></body></html>`, thirdPartyURL)
+	}))
+	defer site.Close()
+
+	req, err := client.NewRequest(http.MethodGet, site.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Api-Key", "key")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "secret"})
+	req.SetBasicAuth("user", "password")
+
+	b := NewBrowser("ws://localhost:3000")
+	defer b.Close()
+
+	resp, err := b.Render(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if want, got := http.StatusOK, resp.StatusCode; want != got {
+		t.Fatalf("Expected status %d, got %d: %s", want, got, resp.Body)
+	}
+	if !strings.Contains(string(resp.Body), "private") || !strings.Contains(string(resp.Body), `data-tracked="true"`) {
+		t.Errorf("Expected private content with third party script run, got %s", resp.Body)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(leaked) > 0 {
+		t.Errorf("Expected no credentials sent to the third party, got %v", leaked)
+	}
+
+	// the cookie of the render is not visible to other renders
+	plain, err := client.NewRequest(http.MethodGet, site.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain.SetBasicAuth("user", "password")
+	plain.Header.Set("X-Api-Key", "key")
+	resp, err = b.Render(context.Background(), plain, nil)
+	if err != nil {
+		t.Fatalf("Render() failed: %v", err)
+	}
+	if want, got := http.StatusForbidden, resp.StatusCode; want != got {
+		t.Errorf("Expected status %d without cookie, got %d", want, got)
+	}
+}
@@ -0,0 +1,62 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// defaultActionTimeout is the max time a chrome action is waiting for its selector to match.
+const defaultActionTimeout = 30 * time.Second
+
+// chromeActions is returning the chromedp actions executing the given script.
+func chromeActions(script []htracker.ChromeAction) ([]chromedp.Action, error) {
+	actions := make([]chromedp.Action, 0, len(script))
+
+	for _, a := range script {
+		var action chromedp.Action
+		switch a.Action {
+		case htracker.ActionWait:
+			action = chromedp.WaitVisible(a.Selector, chromedp.ByQuery)
+		case htracker.ActionClick:
+			action = chromedp.Click(a.Selector, chromedp.ByQuery)
+		case htracker.ActionType:
+			action = chromedp.SendKeys(a.Selector, a.Text, chromedp.ByQuery)
+		case htracker.ActionSleep:
+			actions = append(actions, chromedp.Sleep(a.Duration))
+			continue
+		case htracker.ActionScroll:
+			if a.Selector == "" {
+				action = chromedp.Evaluate(`window.scrollTo(0, document.body.scrollHeight)`, nil)
+			} else {
+				action = chromedp.ScrollIntoView(a.Selector, chromedp.ByQuery)
+			}
+		default:
+			return nil, fmt.Errorf("chrome action %s not supported: %w", a.Action, htracker.ErrInvalid)
+		}
+
+		timeout := a.Duration
+		if timeout == 0 {
+			timeout = defaultActionTimeout
+		}
+		actions = append(actions, withTimeout(a, action, timeout))
+	}
+
+	return actions, nil
+}
+
+// withTimeout is wrapping the action of a, so that it fails if it is not done within timeout.
+// Otherwise waiting for a selector which is never matching would block forever.
+func withTimeout(a htracker.ChromeAction, action chromedp.Action, timeout time.Duration) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err := action.Do(tctx); err != nil {
+			return fmt.Errorf("chrome action %s %s failed: %w", a.Action, a.Selector, err)
+		}
+		return nil
+	})
+}
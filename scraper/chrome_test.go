@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geziyor/geziyor/client"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func Test_chromeActions(t *testing.T) {
+	script := []htracker.ChromeAction{
+		{Action: htracker.ActionClick, Selector: "button.accept"},
+		{Action: htracker.ActionWait, Selector: "#content", Duration: time.Second},
+		{Action: htracker.ActionType, Selector: "input", Text: "foo"},
+		{Action: htracker.ActionSleep, Duration: time.Millisecond},
+		{Action: htracker.ActionScroll},
+		{Action: htracker.ActionScroll, Selector: "#footer"},
+	}
+
+	actions, err := chromeActions(script)
+	if err != nil {
+		t.Fatalf("chromeActions() failed: %v", err)
+	}
+	if want, got := len(script), len(actions); want != got {
+		t.Errorf("Expected %d actions, got %d", want, got)
+	}
+
+	_, err = chromeActions([]htracker.ChromeAction{{Action: "hover"}})
+	if !errors.Is(err, htracker.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for unsupported action, got %v", err)
+	}
+}
+
+func TestScraper_Actions(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", intTestVarName)
+	}
+
+	// the quotes are rendered by java script, clicking next is loading the second page
+	sub := &htracker.Subscription{URL: "http://quotes.toscrape.com/js/", Filter: "div.quote:first-child span.text", UseChrome: true,
+		Request: &htracker.RequestOptions{Actions: []htracker.ChromeAction{
+			{Action: htracker.ActionScroll, Selector: "li.next > a"},
+			{Action: htracker.ActionClick, Selector: "li.next > a"},
+			{Action: htracker.ActionWait, Selector: "li.previous"},
+		}}}
+	firstPage := &htracker.Subscription{URL: sub.URL, Filter: sub.Filter, ContentType: "first page", UseChrome: true}
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+	NewScraper([]*htracker.Subscription{sub, firstPage}, WithExporters([]exporter.Interface{exp}),
+		WithBrowserEndpoint("ws://localhost:3000")).Start()
+
+	site, err := archive.Get(context.Background(), sub)
+	if err != nil {
+		t.Fatalf("archive.Get() failed: %v", err)
+	}
+	first, err := archive.Get(context.Background(), firstPage)
+	if err != nil {
+		t.Fatalf("archive.Get() failed: %v", err)
+	}
+	if len(site.Content) == 0 || string(site.Content) == string(first.Content) {
+		t.Errorf("Expected content of the second page, got %q", site.Content)
+	}
+}
+
+// actionsPage is a page changing its content by java script when the actions of the script are run.
+const actionsPage = `<html><body>
+<button id="accept" onclick="mark('clicked')">accept</button>
+<input id="query" oninput="mark('typed-' + this.value)">
+<div style="height: 5000px"></div>
+<p id="footer">footer</p>
+<script>
+function mark(id) {
+	if (document.getElementById(id)) return;
+	var p = document.createElement('p');
+	p.id = id;
+	document.body.appendChild(p);
+}
+window.addEventListener('scroll', function() { mark('scrolled') });
+setTimeout(function() { mark('late') }, 300);
+</script>
+</body></html>`
+
+func TestBrowser_Render_Actions(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", intTestVarName)
+	}
+
+	// chrome needs to reach the test server, e.g. running in a container with host networking
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, actionsPage)
+	}))
+	defer server.Close()
+
+	b := NewBrowser("ws://localhost:3000")
+	defer b.Close()
+
+	tests := []struct {
+		name     string
+		script   []htracker.ChromeAction
+		want     []string
+		dontWant []string
+	}{
+		{name: "no actions", dontWant: []string{`id="clicked"`, `id="typed-foo"`, `id="scrolled"`}},
+		{name: "wait", script: []htracker.ChromeAction{{Action: htracker.ActionWait, Selector: "#late"}},
+			want: []string{`id="late"`}},
+		{name: "click", script: []htracker.ChromeAction{{Action: htracker.ActionClick, Selector: "#accept"}},
+			want: []string{`id="clicked"`}, dontWant: []string{`id="typed-foo"`}},
+		{name: "type", script: []htracker.ChromeAction{{Action: htracker.ActionType, Selector: "#query", Text: "foo"}},
+			want: []string{`id="typed-foo"`}, dontWant: []string{`id="clicked"`}},
+		{name: "scroll to bottom", script: []htracker.ChromeAction{{Action: htracker.ActionScroll},
+			{Action: htracker.ActionWait, Selector: "#scrolled"}}, want: []string{`id="scrolled"`}},
+		{name: "scroll into view", script: []htracker.ChromeAction{{Action: htracker.ActionScroll, Selector: "#footer"},
+			{Action: htracker.ActionWait, Selector: "#scrolled"}}, want: []string{`id="scrolled"`}},
+		{name: "sleep", script: []htracker.ChromeAction{{Action: htracker.ActionSleep, Duration: 500 * time.Millisecond}},
+			want: []string{`id="late"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := client.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := b.Render(context.Background(), req, tt.script)
+			if err != nil {
+				t.Fatalf("Render() failed: %v", err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(string(resp.Body), s) {
+					t.Errorf("Expected rendered html to contain %s, got %s", s, resp.Body)
+				}
+			}
+			for _, s := range tt.dontWant {
+				if strings.Contains(string(resp.Body), s) {
+					t.Errorf("Expected rendered html not to contain %s, got %s", s, resp.Body)
+				}
+			}
+		})
+	}
+
+	// an action never matching is failing the render after its timeout
+	req, err := client.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := []htracker.ChromeAction{{Action: htracker.ActionWait, Selector: "#never", Duration: 100 * time.Millisecond}}
+	if _, err := b.Render(context.Background(), req, script); err == nil {
+		t.Error("Expected render to fail waiting for a missing element")
+	}
+}
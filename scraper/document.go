@@ -0,0 +1,286 @@
+package scraper
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/geziyor/geziyor/client"
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html/charset"
+)
+
+// Media types of the documents, which text is extracted before filtering and diffing the content.
+const (
+	mediaTypePDF  = "application/pdf"
+	mediaTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mediaTypeODT  = "application/vnd.oasis.opendocument.text"
+)
+
+// XML namespaces of the text elements of DOCX and ODT documents.
+const (
+	namespaceDOCX = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	namespaceODT  = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// documentMediaType is returning the media type of the document in body, or an empty string if it's not
+// a supported document. The content is sniffed instead of trusting the Content-Type header, as documents
+// are often served as application/octet-stream and chrome is rendering them as html.
+func documentMediaType(body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte("%PDF-")):
+		return mediaTypePDF
+	case !bytes.HasPrefix(body, []byte("PK\x03\x04")):
+		return ""
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return ""
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return mediaTypeDOCX
+		case "mimetype":
+			if mimetype, err := readZipFile(f); err == nil && string(mimetype) == mediaTypeODT {
+				return mediaTypeODT
+			}
+		}
+	}
+
+	return ""
+}
+
+// readBody is returning the body of r, decoded to UTF-8 using the charset of the Content-Type header or
+// of the html meta tag, and the parsed html document if it's html. The text of documents is extracted
+// instead. Responses already holding a html document (rendered by chrome) are returned as is.
+func readBody(r *client.Response) ([]byte, *goquery.Document, error) {
+	if r.HTMLDoc != nil {
+		return r.Body, r.HTMLDoc, nil
+	}
+
+	if mediaType := documentMediaType(r.Body); mediaType != "" {
+		text, err := extractText(mediaType, r.Body)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to extract text of %s: %w", mediaType, err)
+		}
+		return text, nil, nil
+	}
+
+	reader, err := charset.NewReader(bytes.NewReader(r.Body), r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to detect charset: %w", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode body: %w", err)
+	}
+	// the byte order mark is kept by the decoder, but it's breaking the xml parser of feeds and sitemaps
+	body = bytes.TrimPrefix(body, []byte("\ufeff"))
+	if !r.IsHTML() {
+		return body, nil, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse html: %w", err)
+	}
+	return body, doc, nil
+}
+
+// extractText is returning the plain text of the document in body, with one line per paragraph (or
+// per row of text in case of pdf).
+func extractText(mediaType string, body []byte) ([]byte, error) {
+	switch mediaType {
+	case mediaTypePDF:
+		return extractPDFText(body)
+	case mediaTypeDOCX:
+		return extractZippedXMLText(body, "word/document.xml", xmlTextElements{
+			namespace:  namespaceDOCX,
+			text:       map[string]bool{"t": true},
+			paragraphs: map[string]bool{"p": true},
+			breaks:     map[string]string{"tab": "\t", "br": "\n", "cr": "\n"},
+			// the tab stops of a paragraph are tab elements as well
+			ignored: map[string]bool{"tabs": true},
+		})
+	case mediaTypeODT:
+		return extractZippedXMLText(body, "content.xml", xmlTextElements{
+			namespace:  namespaceODT,
+			text:       map[string]bool{"p": true, "h": true},
+			paragraphs: map[string]bool{"p": true, "h": true},
+			breaks:     map[string]string{"tab": "\t", "line-break": "\n", "s": " "},
+		})
+	default:
+		return nil, fmt.Errorf("media type %s not supported", mediaType)
+	}
+}
+
+// extractPDFText is returning the text of the pdf document in body, one line per row of text.
+func extractPDFText(body []byte) (text []byte, err error) {
+	// the pdf reader is panicking on some malformed documents
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to read pdf: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pdf: %w", err)
+	}
+
+	buf := bytes.Buffer{}
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read page %d of pdf: %w", i, err)
+		}
+		// the rows are sorted by position, starting at the top of the page
+		for _, row := range rows {
+			words := make([]string, 0, len(row.Content))
+			for _, t := range row.Content {
+				words = append(words, t.S)
+			}
+			buf.WriteString(strings.TrimSpace(strings.Join(words, "")))
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// xmlTextElements is describing the elements of a XML document holding its text.
+type xmlTextElements struct {
+	namespace string
+	// text is the elements the character data is extracted from, including their children.
+	text map[string]bool
+	// paragraphs is the elements terminated by a newline.
+	paragraphs map[string]bool
+	// breaks is the empty elements replaced by the given string.
+	breaks map[string]string
+	// ignored is the elements which are skipped, including their children.
+	ignored map[string]bool
+}
+
+// extractZippedXMLText is returning the text of the XML document with the given name in the zip archive body.
+func extractZippedXMLText(body []byte, name string, elements xmlTextElements) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		defer rc.Close()
+
+		return extractXMLText(rc, elements)
+	}
+
+	return nil, fmt.Errorf("document is missing %s", name)
+}
+
+// extractXMLText is returning the text of the given elements of the XML document read from r.
+func extractXMLText(r io.Reader, elements xmlTextElements) ([]byte, error) {
+	buf := bytes.Buffer{}
+	dec := xml.NewDecoder(r)
+	depth, ignored := 0, 0
+
+	for {
+		token, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse document: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space != elements.namespace {
+				continue
+			}
+			if elements.ignored[t.Name.Local] {
+				ignored++
+			}
+			if elements.text[t.Name.Local] {
+				depth++
+			}
+			if s, ok := elements.breaks[t.Name.Local]; ok && ignored == 0 {
+				buf.WriteString(strings.Repeat(s, repeatCount(t)))
+			}
+		case xml.EndElement:
+			if t.Name.Space != elements.namespace {
+				continue
+			}
+			if elements.ignored[t.Name.Local] {
+				ignored--
+			}
+			if elements.text[t.Name.Local] {
+				depth--
+			}
+			if elements.paragraphs[t.Name.Local] {
+				buf.WriteByte('\n')
+			}
+		case xml.CharData:
+			if depth > 0 && ignored == 0 {
+				buf.Write(t)
+			}
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// maxRepeatCount is the max number of repetitions of a break element, so that a small document can't
+// expand to an arbitrary amount of spaces.
+const maxRepeatCount = 1000
+
+// repeatCount is returning how often a break element is repeated, which is given by the c attribute
+// for spaces in ODT documents. It is capped at maxRepeatCount.
+func repeatCount(e xml.StartElement) int {
+	for _, attr := range e.Attr {
+		if attr.Name.Local != "c" {
+			continue
+		}
+		c, err := strconv.Atoi(attr.Value)
+		switch {
+		case errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(attr.Value, "-"):
+			return maxRepeatCount
+		case err != nil || c < 1:
+			return 1
+		case c > maxRepeatCount:
+			return maxRepeatCount
+		default:
+			return c
+		}
+	}
+	return 1
+}
+
+// readZipFile is returning the content of f.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,278 @@
+package scraper
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geziyor/geziyor/client"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+// testPDF is returning a pdf document with one page, showing the given lines of text.
+func testPDF(lines ...string) []byte {
+	content := "BT /F1 12 Tf\n"
+	for i, line := range lines {
+		content += fmt.Sprintf("1 0 0 1 72 %d Tm (%s) Tj\n", 720-i*20, line)
+	}
+	content += "ET"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+
+	buf := bytes.NewBufferString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+// testZip is returning a zip archive with the given files.
+func testZip(t *testing.T, files [][2]string) []byte {
+	buf := bytes.Buffer{}
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testDOCX(t *testing.T) []byte {
+	return testZip(t, [][2]string{
+		{"[Content_Types].xml", `<?xml version="1.0"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:tabs><w:tab w:val="left" w:pos="720"/></w:tabs></w:pPr><w:r><w:t>Section 1</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Fees are </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>10 EUR</w:t></w:r><w:r><w:tab/><w:t>per month</w:t></w:r></w:p>
+</w:body></w:document>`},
+	})
+}
+
+func testODT(t *testing.T) []byte {
+	return testODTSpaces(t, "2")
+}
+
+// testODTSpaces is returning an ODT document with a text:s element repeated c times.
+func testODTSpaces(t *testing.T, c string) []byte {
+	return testZip(t, [][2]string{
+		{"mimetype", mediaTypeODT},
+		{"content.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:text>
+<text:h text:outline-level="1">Section 1</text:h>
+<text:p>Fees are <text:span>10 EUR</text:span><text:s text:c="` + c + `"/>per month</text:p>
+</office:text></office:body></office:document-content>`},
+	})
+}
+
+func Test_extractText(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          []byte
+		wantMediaType string
+		want          string
+	}{
+		{name: "pdf", body: testPDF("Section 1", "Fees are 10 EUR per month"), wantMediaType: mediaTypePDF,
+			want: "Section 1\nFees are 10 EUR per month\n"},
+		{name: "docx", body: testDOCX(t), wantMediaType: mediaTypeDOCX, want: "Section 1\nFees are 10 EUR\tper month\n"},
+		{name: "odt", body: testODT(t), wantMediaType: mediaTypeODT, want: "Section 1\nFees are 10 EUR  per month\n"},
+		{name: "odt huge space count", body: testODTSpaces(t, "2147483647000"), wantMediaType: mediaTypeODT,
+			want: "Section 1\nFees are 10 EUR" + strings.Repeat(" ", maxRepeatCount) + "per month\n"},
+		{name: "odt space count above max", body: testODTSpaces(t, "1001"), wantMediaType: mediaTypeODT,
+			want: "Section 1\nFees are 10 EUR" + strings.Repeat(" ", maxRepeatCount) + "per month\n"},
+		{name: "odt negative space count", body: testODTSpaces(t, "-5"), wantMediaType: mediaTypeODT,
+			want: "Section 1\nFees are 10 EUR per month\n"},
+		{name: "odt invalid space count", body: testODTSpaces(t, "many"), wantMediaType: mediaTypeODT,
+			want: "Section 1\nFees are 10 EUR per month\n"},
+		{name: "html", body: []byte("<html><body>Section 1</body></html>")},
+		{name: "other zip", body: testZip(t, [][2]string{{"README", "Section 1"}})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediaType := documentMediaType(tt.body)
+			if mediaType != tt.wantMediaType {
+				t.Fatalf("Expected media type %q, got %q", tt.wantMediaType, mediaType)
+			}
+			if mediaType == "" {
+				return
+			}
+
+			got, err := extractText(mediaType, tt.body)
+			if err != nil {
+				t.Fatalf("extractText() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Expected text %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := extractText(mediaTypePDF, []byte("%PDF-1.4 broken")); err == nil {
+		t.Error("Expected extractText() to fail for broken pdf")
+	}
+}
+
+func TestScraper_Documents(t *testing.T) {
+	documents := map[string][]byte{
+		"/fees.pdf":  testPDF("Section 1", "Fees are 10 EUR per month"),
+		"/fees.docx": testDOCX(t),
+		"/fees.odt":  testODT(t),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(documents[r.URL.Path])
+	}))
+	defer server.Close()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+
+	subscriptions := []*htracker.Subscription{}
+	for path := range documents {
+		subscriptions = append(subscriptions, &htracker.Subscription{URL: server.URL + path, Filter: `Fees are \d+ EUR`})
+	}
+	NewScraper(subscriptions, WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)).Start()
+
+	for _, sub := range subscriptions {
+		site, err := archive.Get(context.Background(), sub)
+		if err != nil {
+			t.Fatalf("archive.Get(%s) failed: %v", sub.URL, err)
+		}
+		if want, got := "Fees are 10 EUR", string(site.Content); want != got {
+			t.Errorf("%s: Expected content %q, got %q", sub.URL, want, got)
+		}
+	}
+}
+
+func Test_readBody_Charset(t *testing.T) {
+	r := &client.Response{
+		Response: &http.Response{Header: http.Header{"Content-Type": []string{"text/html; charset=iso-8859-1"}}},
+		// "Gebühren" encoded in latin-1
+		Body: []byte("<html><body><p>Geb\xfchren</p></body></html>"),
+	}
+
+	body, doc, err := readBody(r)
+	if err != nil {
+		t.Fatalf("readBody() failed: %v", err)
+	}
+	if doc == nil {
+		t.Fatal("Expected html document")
+	}
+	if want, got := "Gebühren", doc.Find("p").Text(); want != got {
+		t.Errorf("Expected text %q, got %q", want, got)
+	}
+	if !strings.Contains(string(body), "Gebühren") {
+		t.Errorf("Expected body to be decoded, got %q", body)
+	}
+}
+
+func TestScraper_Normalize(t *testing.T) {
+	pages := map[string][]byte{
+		"/utf8": []byte("\xef\xbb\xbf<html><body><p>Gebühren:\r\n 10  EUR</p></body></html>"),
+		// latin-1 encoded, declared by the meta tag only
+		"/latin1": []byte("<html><head><meta charset=\"iso-8859-1\"></head><body><p>Geb\xfchren:\n 10 EUR</p></body></html>"),
+		// "ü" as u followed by a combining diaeresis
+		"/decomposed": []byte("<html><body><p>Gebu\u0308hren:\n 10\tEUR</p></body></html>"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+
+	subscriptions := []*htracker.Subscription{}
+	for path := range pages {
+		subscriptions = append(subscriptions, &htracker.Subscription{URL: server.URL + path, Filter: "p", Normalize: htracker.NormalizeWhitespace})
+	}
+	NewScraper(subscriptions, WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)).Start()
+
+	for _, sub := range subscriptions {
+		site, err := archive.Get(context.Background(), sub)
+		if err != nil {
+			t.Fatalf("archive.Get(%s) failed: %v", sub.URL, err)
+		}
+		if want, got := "Gebühren:\n10 EUR", string(site.Content); want != got {
+			t.Errorf("%s: Expected content %q, got %q", sub.URL, want, got)
+		}
+		if want, got := service.Checksum([]byte("Gebühren:\n10 EUR")), site.Checksum; want != got {
+			t.Errorf("%s: Expected checksum %s, got %s", sub.URL, want, got)
+		}
+	}
+}
+
+func TestScraper_Normalize_Entities(t *testing.T) {
+	pages := map[string][]byte{
+		"/named":   []byte("<html><body><p>Terms &amp; Conditions&nbsp;apply</p></body></html>"),
+		"/numeric": []byte("<html><body><p>Terms &#38; Conditions&#160;apply</p></body></html>"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write(pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		mode      string
+		wantEqual bool
+	}{
+		{mode: htracker.NormalizeDefault, wantEqual: true},
+		{mode: htracker.NormalizeNone},
+	} {
+		archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+		exp := exporter.NewExporter(context.Background(), archive)
+
+		subscriptions := []*htracker.Subscription{}
+		for path := range pages {
+			subscriptions = append(subscriptions, &htracker.Subscription{URL: server.URL + path, Normalize: tt.mode})
+		}
+		NewScraper(subscriptions, WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)).Start()
+
+		checksums := map[string]bool{}
+		for _, sub := range subscriptions {
+			site, err := archive.Get(context.Background(), sub)
+			if err != nil {
+				t.Fatalf("mode %q: archive.Get(%s) failed: %v", tt.mode, sub.URL, err)
+			}
+			checksums[site.Checksum] = true
+		}
+		if equal := len(checksums) == 1; equal != tt.wantEqual {
+			t.Errorf("mode %q: Expected equal checksums %v, got %v", tt.mode, tt.wantEqual, equal)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+)
+
+// feed is a RSS 2.0 (items of the channel), RSS 1.0 (items of the RDF document) or Atom (entries) feed.
+type feed struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedItem `xml:"item"`
+	Entries []feedItem `xml:"entry"`
+}
+
+// feedItem is a RSS item or Atom entry.
+type feedItem struct {
+	GUID        string     `xml:"guid"`
+	ID          string     `xml:"id"`
+	Title       string     `xml:"title"`
+	Links       []feedLink `xml:"link"`
+	PubDate     string     `xml:"pubDate"`
+	Date        string     `xml:"date"`
+	Published   string     `xml:"published"`
+	Updated     string     `xml:"updated"`
+	Description string     `xml:"description"`
+	Encoded     string     `xml:"encoded"`
+	Summary     string     `xml:"summary"`
+	Content     string     `xml:"content"`
+}
+
+// feedLink is the link of a RSS item (as text) or Atom entry (as href attribute).
+type feedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// parseFeed is returning the items of the RSS or Atom feed in body, which must be UTF-8 encoded.
+func parseFeed(body []byte) ([]htracker.FeedItem, error) {
+	f := feed{}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	// the body was decoded already, regardless of the encoding declared by the document
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	var items []feedItem
+	switch f.XMLName.Local {
+	case "rss":
+		items = f.Channel.Items
+	case "RDF":
+		items = f.Items
+	case "feed":
+		items = f.Entries
+	default:
+		return nil, fmt.Errorf("failed to parse feed: unknown root element %s", f.XMLName.Local)
+	}
+
+	feedItems := make([]htracker.FeedItem, 0, len(items))
+	for _, item := range items {
+		feedItems = append(feedItems, item.feedItem())
+	}
+
+	return feedItems, nil
+}
+
+// feedItem is returning the item as htracker.FeedItem.
+func (i feedItem) feedItem() htracker.FeedItem {
+	link := i.link()
+	title := strings.TrimSpace(i.Title)
+	content := firstNonEmpty(i.Encoded, i.Content, i.Description, i.Summary)
+
+	item := htracker.FeedItem{
+		GUID:      firstNonEmpty(i.GUID, i.ID, link, title),
+		Title:     title,
+		Link:      link,
+		Published: firstNonEmpty(i.PubDate, i.Published, i.Date, i.Updated),
+	}
+	if content != "" {
+		// markup of the content is often reformatted without changing the item
+		item.Checksum = service.Checksum(service.Normalize([]byte(content), htracker.NormalizeWhitespace))
+	}
+
+	return item
+}
+
+// link is returning the link of the item, preferring the alternate link of Atom entries.
+func (i feedItem) link() string {
+	link := ""
+	for _, l := range i.Links {
+		switch {
+		case l.Href == "":
+			link = firstNonEmpty(link, l.Text)
+		case l.Rel == "" || l.Rel == "alternate":
+			return strings.TrimSpace(l.Href)
+		default:
+			link = firstNonEmpty(link, l.Href)
+		}
+	}
+	return link
+}
+
+// firstNonEmpty is returning the first of the given strings which is not empty after trimming spaces.
+func firstNonEmpty(strs ...string) string {
+	for _, s := range strs {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+const testRSS = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+  <title>News</title>
+  <item>
+    <guid isPermaLink="false">item-2</guid>
+    <title>Second item</title>
+    <link>http://site.example/2</link>
+    <pubDate>Tue, 02 Jan 2024 10:00:00 GMT</pubDate>
+    <description>short</description>
+    <content:encoded><![CDATA[<p>full content</p>]]></content:encoded>
+  </item>
+  <item>
+    <title>First item</title>
+    <link>http://site.example/1</link>
+  </item>
+</channel>
+</rss>`
+
+const testAtom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>News</title>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title type="html">First entry</title>
+    <link rel="edit" href="http://site.example/edit/1"/>
+    <link rel="alternate" href="http://site.example/1"/>
+    <updated>2024-01-01T10:00:00Z</updated>
+    <summary>summary</summary>
+  </entry>
+</feed>`
+
+const testRDF = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="http://site.example/"><title>News</title></channel>
+  <item rdf:about="http://site.example/1">
+    <title>First item</title>
+    <link>http://site.example/1</link>
+    <dc:date>2024-01-01</dc:date>
+  </item>
+</rdf:RDF>`
+
+func Test_parseFeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		feed    string
+		want    []htracker.FeedItem
+		wantErr bool
+	}{
+		{name: "rss", feed: testRSS, want: []htracker.FeedItem{
+			{GUID: "item-2", Title: "Second item", Link: "http://site.example/2", Published: "Tue, 02 Jan 2024 10:00:00 GMT",
+				Checksum: service.Checksum([]byte("<p>full content</p>"))},
+			{GUID: "http://site.example/1", Title: "First item", Link: "http://site.example/1"},
+		}},
+		{name: "atom", feed: testAtom, want: []htracker.FeedItem{
+			{GUID: "urn:uuid:1", Title: "First entry", Link: "http://site.example/1", Published: "2024-01-01T10:00:00Z",
+				Checksum: service.Checksum([]byte("summary"))},
+		}},
+		{name: "rdf", feed: testRDF, want: []htracker.FeedItem{
+			{GUID: "http://site.example/1", Title: "First item", Link: "http://site.example/1", Published: "2024-01-01"},
+		}},
+		{name: "html", feed: "<html><body>no feed</body></html>", wantErr: true},
+		{name: "no xml", feed: "no feed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFeed([]byte(tt.feed))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFeed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFeed() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScraper_Feed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(testRSS))
+	}))
+	defer server.Close()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+	sub := &htracker.Subscription{URL: server.URL, ContentType: htracker.ContentTypeFeed}
+	NewScraper([]*htracker.Subscription{sub}, WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)).Start()
+
+	site, err := archive.Get(context.Background(), sub)
+	if err != nil {
+		t.Fatalf("archive.Get() failed: %v", err)
+	}
+	items := []htracker.FeedItem{}
+	if err := json.Unmarshal(site.Content, &items); err != nil {
+		t.Fatalf("Expected feed items as content, got %q: %v", site.Content, err)
+	}
+	if want, got := 2, len(items); want != got {
+		t.Errorf("Expected %d items, got %d", want, got)
+	}
+}
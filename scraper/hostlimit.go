@@ -0,0 +1,200 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit is limiting the requests sent to a single host.
+type HostLimit struct {
+	// MaxConcurrency is the max number of requests in flight to the host, 0 means unlimited.
+	MaxConcurrency int
+
+	// MinDelay is the min delay between the start of two requests to the host.
+	MinDelay time.Duration
+}
+
+// hostIdleTimeout is the time after which the state of a host without requests is evicted.
+const hostIdleTimeout = 10 * time.Minute
+
+// HostLimiter is enforcing HostLimits across all scrapers sharing it. The default limit
+// can be overridden per domain, where an override is also applied to all subdomains.
+type HostLimiter struct {
+	mu        sync.Mutex
+	limit     HostLimit
+	overrides map[string]HostLimit
+	hosts     map[string]*hostState
+	lastSweep time.Time
+}
+
+// hostState is tracking the requests to a single host.
+type hostState struct {
+	mu         sync.Mutex
+	limit      HostLimit
+	inFlight   int
+	changed    chan struct{}
+	next       time.Time
+	crawlDelay time.Duration
+	lastUsed   time.Time
+}
+
+// NewHostLimiter is returning a new HostLimiter using the given default limit and overrides per domain.
+func NewHostLimiter(limit HostLimit, overrides map[string]HostLimit) *HostLimiter {
+	l := &HostLimiter{hosts: map[string]*hostState{}}
+	l.SetLimits(limit, overrides)
+	return l
+}
+
+// SetLimits is replacing the limits of the HostLimiter. The states of known hosts are kept,
+// so requests already in flight are counted against the new limits and the spacing of
+// requests by the min delay is preserved.
+func (l *HostLimiter) SetLimits(limit HostLimit, overrides map[string]HostLimit) {
+	normalized := make(map[string]HostLimit, len(overrides))
+	for domain, o := range overrides {
+		normalized[strings.ToLower(strings.TrimSuffix(domain, "."))] = o
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+	l.overrides = normalized
+	for host, s := range l.hosts {
+		s.mu.Lock()
+		s.limit = l.lookup(host)
+		// wake up waiting requests, the max concurrency might have been raised
+		s.broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// Limit is returning the limit applied to the given host, which is the override of the
+// most specific matching domain or the default limit.
+func (l *HostLimiter) Limit(host string) HostLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lookup(strings.ToLower(host))
+}
+
+func (l *HostLimiter) lookup(host string) HostLimit {
+	for domain := host; domain != ""; {
+		if o, ok := l.overrides[domain]; ok {
+			return o
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return l.limit
+}
+
+func (l *HostLimiter) state(host string) *hostState {
+	host = strings.ToLower(host)
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > hostIdleTimeout {
+		l.evictIdle(now)
+	}
+
+	s, ok := l.hosts[host]
+	if !ok {
+		s = &hostState{limit: l.lookup(host), changed: make(chan struct{})}
+		l.hosts[host] = s
+	}
+	s.mu.Lock()
+	s.lastUsed = now
+	s.mu.Unlock()
+	return s
+}
+
+// evictIdle is removing the states of hosts without requests in flight, which haven't been
+// used for hostIdleTimeout. l.mu must be held.
+func (l *HostLimiter) evictIdle(now time.Time) {
+	l.lastSweep = now
+	for host, s := range l.hosts {
+		s.mu.Lock()
+		idle := s.inFlight == 0 && now.After(s.next) && now.Sub(s.lastUsed) > hostIdleTimeout
+		s.mu.Unlock()
+		if idle {
+			delete(l.hosts, host)
+		}
+	}
+}
+
+// broadcast is waking up all requests waiting for a change of the state. s.mu must be held.
+func (s *hostState) broadcast() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// SetCrawlDelay is raising the min delay between requests to host to at least the given
+// crawl delay (e.g. requested by robots.txt), if the limit of the host is lower.
+func (l *HostLimiter) SetCrawlDelay(host string, delay time.Duration) {
+	s := l.state(host)
+	s.mu.Lock()
+	s.crawlDelay = delay
+	s.mu.Unlock()
+}
+
+// Acquire is waiting until a request to host is allowed. The returned func needs to be called
+// when the request finished. An error is returned if ctx is done before.
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (release func(), err error) {
+	s := l.state(host)
+
+	s.mu.Lock()
+	for s.limit.MaxConcurrency > 0 && s.inFlight >= s.limit.MaxConcurrency {
+		changed := s.changed
+		s.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		s.mu.Lock()
+	}
+	s.inFlight++
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			s.mu.Lock()
+			s.inFlight--
+			s.lastUsed = time.Now()
+			s.broadcast()
+			s.mu.Unlock()
+		})
+	}
+
+	// reserve the next slot, so that concurrent requests are spaced by the min delay
+	delay := s.limit.MinDelay
+	if s.crawlDelay > delay {
+		delay = s.crawlDelay
+	}
+	now := time.Now()
+	start := s.next
+	if start.Before(now) {
+		start = now
+	}
+	s.next = start.Add(delay)
+	s.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
@@ -0,0 +1,277 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/robots"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func TestHostLimiter_Limit(t *testing.T) {
+	def := HostLimit{MaxConcurrency: 1, MinDelay: time.Second}
+	example := HostLimit{MaxConcurrency: 4}
+	api := HostLimit{MaxConcurrency: 2, MinDelay: time.Millisecond}
+
+	l := NewHostLimiter(def, map[string]HostLimit{"Example.com.": example, "api.example.com": api})
+
+	tests := []struct {
+		host string
+		want HostLimit
+	}{
+		{host: "example.com", want: example},
+		{host: "www.example.com", want: example},
+		{host: "API.example.com", want: api},
+		{host: "v1.api.example.com", want: api},
+		{host: "notexample.com", want: def},
+		{host: "example.org", want: def},
+		{host: "localhost", want: def},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := l.Limit(tt.host); got != tt.want {
+				t.Errorf("HostLimiter.Limit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHostLimiter_Acquire(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("max concurrency", func(t *testing.T) {
+		l := NewHostLimiter(HostLimit{MaxConcurrency: 2}, nil)
+
+		release1, err := l.Acquire(ctx, "a.test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := l.Acquire(ctx, "a.test"); err != nil {
+			t.Fatal(err)
+		}
+		// other hosts are not affected
+		if _, err := l.Acquire(ctx, "b.test"); err != nil {
+			t.Fatal(err)
+		}
+
+		tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if _, err := l.Acquire(tctx, "a.test"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Acquire() expected DeadlineExceeded, got %v", err)
+		}
+
+		release1()
+		if _, err := l.Acquire(ctx, "a.test"); err != nil {
+			t.Fatalf("Acquire() after release failed: %v", err)
+		}
+	})
+
+	t.Run("min delay", func(t *testing.T) {
+		delay := 30 * time.Millisecond
+		l := NewHostLimiter(HostLimit{MinDelay: delay}, nil)
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			release, err := l.Acquire(ctx, "a.test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			release()
+		}
+		if elapsed := time.Since(start); elapsed < 2*delay {
+			t.Errorf("Expected 3 requests to take at least %v, took %v", 2*delay, elapsed)
+		}
+
+		tctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := l.Acquire(tctx, "a.test"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Acquire() expected Canceled, got %v", err)
+		}
+	})
+}
+
+func TestHostLimiter_SetLimits(t *testing.T) {
+	ctx := context.Background()
+	delay := 50 * time.Millisecond
+	l := NewHostLimiter(HostLimit{MaxConcurrency: 1, MinDelay: delay}, nil)
+
+	release, err := l.Acquire(ctx, "a.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reloading the limits must not forget the request in flight
+	l.SetLimits(HostLimit{MaxConcurrency: 1, MinDelay: delay}, nil)
+
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(tctx, "a.test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() after SetLimits expected DeadlineExceeded, got %v", err)
+	}
+
+	// raising the limit is waking up waiting requests
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, "a.test")
+		acquired <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.SetLimits(HostLimit{MaxConcurrency: 2, MinDelay: delay}, nil)
+
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() not woken up after raising MaxConcurrency")
+	}
+	release()
+
+	// the spacing by the min delay reserved before the reload is kept
+	start := time.Now()
+	if _, err := l.Acquire(ctx, "a.test"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < delay/2 {
+		t.Errorf("Expected Acquire() to wait for the min delay, took %v", elapsed)
+	}
+}
+
+func TestHostLimiter_evictIdle(t *testing.T) {
+	ctx := context.Background()
+	l := NewHostLimiter(HostLimit{MaxConcurrency: 1}, nil)
+
+	release, err := l.Acquire(ctx, "busy.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	idleRelease, err := l.Acquire(ctx, "idle.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idleRelease()
+
+	l.mu.Lock()
+	for _, s := range l.hosts {
+		s.lastUsed = s.lastUsed.Add(-2 * hostIdleTimeout)
+	}
+	l.evictIdle(time.Now())
+	_, busy := l.hosts["busy.test"]
+	_, idle := l.hosts["idle.test"]
+	l.mu.Unlock()
+
+	if !busy {
+		t.Error("Expected state of host with request in flight to be kept")
+	}
+	if idle {
+		t.Error("Expected state of idle host to be evicted")
+	}
+}
+
+func TestScraper_HostLimiter(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(w, "content of %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+	limiter := NewHostLimiter(HostLimit{MaxConcurrency: 2}, nil)
+
+	// two scrapers sharing the limiter, like the workers of the watcher
+	batches := [][]*htracker.Subscription{{}, {}}
+	for i := 0; i < 8; i++ {
+		sub := &htracker.Subscription{URL: fmt.Sprintf("%s/%d", server.URL, i)}
+		batches[i%2] = append(batches[i%2], sub)
+	}
+
+	wg := sync.WaitGroup{}
+	for _, batch := range batches {
+		wg.Add(1)
+		go func(batch []*htracker.Subscription) {
+			defer wg.Done()
+			NewScraper(batch, WithExporters([]exporter.Interface{exp}), WithHostLimiter(limiter), WithLogDisabled(true)).Start()
+		}(batch)
+	}
+	wg.Wait()
+
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", max)
+	}
+
+	for _, batch := range batches {
+		for _, sub := range batch {
+			if _, err := archive.Get(context.Background(), sub); err != nil {
+				t.Errorf("archive.Get(%s) failed: %v", sub.URL, err)
+			}
+		}
+	}
+}
+
+func TestScraper_Robots(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprintf(w, "content of %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+
+	allowed := &htracker.Subscription{URL: server.URL + "/public"}
+	disallowed := &htracker.Subscription{URL: server.URL + "/private/page"}
+
+	NewScraper([]*htracker.Subscription{allowed, disallowed}, WithExporters([]exporter.Interface{exp}),
+		WithRobots(robots.New("htracker")), WithLogDisabled(true)).Start()
+
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+
+	tests := []struct {
+		subscription *htracker.Subscription
+		wantState    htracker.SiteState
+	}{
+		{subscription: allowed, wantState: htracker.SiteStateOK},
+		{subscription: disallowed, wantState: htracker.SiteStateBlockedByRobots},
+	}
+	for _, tt := range tests {
+		site, err := archive.Get(context.Background(), tt.subscription)
+		if err != nil {
+			t.Fatalf("archive.Get(%s) failed: %v", tt.subscription.URL, err)
+		}
+		if site.State != tt.wantState {
+			t.Errorf("Expected state %s for %s, got %s", tt.wantState, tt.subscription.URL, site.State)
+		}
+	}
+}
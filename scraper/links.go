@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// defaultLinkSelector is selecting the anchors of a html page, if the subscription has no filter.
+const defaultLinkSelector = "a[href]"
+
+// sitemap is a sitemap (urlset) or sitemap index, the locations are urls of pages or of other sitemaps.
+type sitemap struct {
+	XMLName   xml.Name
+	Locations []string `xml:"url>loc"`
+	Sitemaps  []string `xml:"sitemap>loc"`
+}
+
+// parseLinks is returning the sorted and deduplicated links of a sitemap in body, or of the anchors of the
+// html document doc matching the selector. Relative links are resolved against base, links to other
+// schemes than http and https are skipped.
+func parseLinks(body []byte, doc *goquery.Document, base *url.URL, selector string) ([]string, error) {
+	var links []string
+
+	if doc != nil {
+		if selector == "" {
+			selector = defaultLinkSelector
+		}
+		// the selector can match the anchors or elements containing them
+		selection := doc.Find(selector)
+		selection.Filter("a[href]").AddSelection(selection.Find("a[href]")).Each(func(_ int, s *goquery.Selection) {
+			href, _ := s.Attr("href")
+			links = append(links, href)
+		})
+	} else {
+		var err error
+		if links, err = parseSitemap(body); err != nil {
+			return nil, err
+		}
+	}
+
+	set := make(map[string]bool, len(links))
+	for _, link := range links {
+		u, err := base.Parse(strings.TrimSpace(link))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		u.Fragment = ""
+		set[u.String()] = true
+	}
+
+	urls := make([]string, 0, len(set))
+	for u := range set {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+
+	return urls, nil
+}
+
+// parseSitemap is returning the locations of the sitemap or sitemap index in body, which can be gzipped.
+func parseSitemap(body []byte) ([]string, error) {
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzipped sitemap: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to read gzipped sitemap: %w", err)
+		}
+	}
+
+	s := sitemap{}
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	// the body was decoded already, regardless of the encoding declared by the document
+	dec.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %w", err)
+	}
+
+	switch s.XMLName.Local {
+	case "urlset":
+		return s.Locations, nil
+	case "sitemapindex":
+		return s.Sitemaps, nil
+	default:
+		return nil, fmt.Errorf("failed to parse sitemap: unknown root element %s", s.XMLName.Local)
+	}
+}
@@ -0,0 +1,133 @@
+package scraper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+const testSitemap = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://site.example/b</loc><lastmod>2023-01-02</lastmod></url>
+  <url><loc> https://site.example/a </loc></url>
+  <url><loc>https://site.example/b</loc></url>
+</urlset>`
+
+const testSitemapIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://site.example/sitemap1.xml</loc></sitemap>
+</sitemapindex>`
+
+const testLinksHTML = `<html><body>
+<nav><a href="/about">About</a></nav>
+<ul class="news">
+  <li><a href="/news/2">News 2</a></li>
+  <li><a href="news/1#top">News 1</a></li>
+  <li><a href="https://other.example/news">Other</a></li>
+  <li><a href="mailto:news@site.example">Mail</a></li>
+</ul>
+</body></html>`
+
+func gzipped(t *testing.T, s string) []byte {
+	buf := bytes.Buffer{}
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func Test_parseLinks(t *testing.T) {
+	base, err := url.Parse("https://site.example/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testLinksHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		body     []byte
+		doc      *goquery.Document
+		selector string
+		want     []string
+		wantErr  bool
+	}{
+		{name: "sitemap", body: []byte(testSitemap), want: []string{"https://site.example/a", "https://site.example/b"}},
+		{name: "gzipped sitemap", body: gzipped(t, testSitemap), want: []string{"https://site.example/a", "https://site.example/b"}},
+		{name: "sitemap index", body: []byte(testSitemapIndex), want: []string{"https://site.example/sitemap1.xml"}},
+		{name: "no sitemap", body: []byte("<rss></rss>"), wantErr: true},
+		{name: "all anchors", doc: doc, want: []string{"https://other.example/news", "https://site.example/about",
+			"https://site.example/news/1", "https://site.example/news/2"}},
+		{name: "anchors in selection", doc: doc, selector: "ul.news", want: []string{"https://other.example/news",
+			"https://site.example/news/1", "https://site.example/news/2"}},
+		{name: "selected anchors", doc: doc, selector: `a[href^="/news"]`, want: []string{"https://site.example/news/2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLinks(tt.body, tt.doc, base, tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLinks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLinks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScraper_Links(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sitemap.xml" {
+			w.Header().Set("Content-Type", "application/xml")
+			w.Write([]byte(testSitemap))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testLinksHTML))
+	}))
+	defer server.Close()
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+	sitemap := &htracker.Subscription{URL: server.URL + "/sitemap.xml", ContentType: htracker.ContentTypeLinks}
+	news := &htracker.Subscription{URL: server.URL + "/news/", Filter: "ul.news a", ContentType: htracker.ContentTypeLinks}
+	NewScraper([]*htracker.Subscription{sitemap, news}, WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)).Start()
+
+	tests := []struct {
+		subscription *htracker.Subscription
+		want         string
+	}{
+		{subscription: sitemap, want: "https://site.example/a\nhttps://site.example/b"},
+		{subscription: news, want: server.URL + "/news/2\n" + server.URL + "/news/news/1\nhttps://other.example/news"},
+	}
+
+	for _, tt := range tests {
+		site, err := archive.Get(context.Background(), tt.subscription)
+		if err != nil {
+			t.Fatalf("archive.Get(%s) failed: %v", tt.subscription.URL, err)
+		}
+		if want, got := tt.want, string(site.Content); want != got {
+			t.Errorf("%s: Expected content %q, got %q", tt.subscription.URL, want, got)
+		}
+	}
+}
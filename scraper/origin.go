@@ -0,0 +1,133 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// maxAuthAttempts is the max number of auth challenges answered with the credentials during a render,
+// rejected credentials would be retried forever otherwise.
+const maxAuthAttempts = 3
+
+// originScope are the request options of a site rendered with chrome, which must only be sent to the
+// origin of the site and not to third parties embedded in the site, like CDNs or trackers.
+type originScope struct {
+	origin   string
+	headers  http.Header
+	cookies  []*http.Cookie
+	username string
+	password string
+	auth     bool
+}
+
+// newOriginScope is returning the scope of the headers, cookies and basic auth credentials of req.
+// The user agent is not scoped, it is sent to all origins.
+func newOriginScope(req *http.Request) *originScope {
+	s := &originScope{origin: origin(req.URL), headers: req.Header.Clone(), cookies: req.Cookies()}
+	s.username, s.password, s.auth = req.BasicAuth()
+
+	// cookies and credentials are set in the browser, which is scoping them to the origin itself
+	for _, name := range []string{"Cookie", "Authorization", "User-Agent"} {
+		s.headers.Del(name)
+	}
+	return s
+}
+
+// origin is returning the origin of u, e.g. https://example.com:8443.
+func origin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// matches is returning whether rawURL has the origin of the scope.
+func (s *originScope) matches(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && origin(u) == s.origin
+}
+
+// actions is returning the chrome actions applying the scope to the tab tabCtx, which must run
+// before navigating to the site.
+func (s *originScope) actions(tabCtx context.Context) []chromedp.Action {
+	var actions []chromedp.Action
+
+	if len(s.cookies) > 0 {
+		cookies := make([]*network.CookieParam, len(s.cookies))
+		for i, c := range s.cookies {
+			// without domain, the cookie is a host-only cookie of the host of the url
+			cookies[i] = &network.CookieParam{Name: c.Name, Value: c.Value, URL: s.origin + "/", Path: "/"}
+		}
+		actions = append(actions, network.SetCookies(cookies))
+	}
+
+	if len(s.headers) == 0 && !s.auth {
+		return actions
+	}
+
+	var authAttempts int32
+	listen := chromedp.ActionFunc(func(context.Context) error {
+		chromedp.ListenTarget(tabCtx, func(ev interface{}) {
+			switch ev := ev.(type) {
+			case *fetch.EventRequestPaused:
+				go s.continueRequest(tabCtx, ev)
+			case *fetch.EventAuthRequired:
+				provide := s.auth && s.matches(ev.AuthChallenge.Origin) && atomic.AddInt32(&authAttempts, 1) <= maxAuthAttempts
+				go s.continueWithAuth(tabCtx, ev, provide)
+			}
+		})
+		return nil
+	})
+	enable := fetch.Enable().WithPatterns([]*fetch.RequestPattern{{URLPattern: "*"}}).WithHandleAuthRequests(s.auth)
+
+	return append(actions, listen, enable)
+}
+
+// continueRequest is continuing the paused request, adding the headers of the scope if it is sent to its origin.
+func (s *originScope) continueRequest(tabCtx context.Context, ev *fetch.EventRequestPaused) {
+	ctx := cdp.WithExecutor(tabCtx, chromedp.FromContext(tabCtx).Target)
+
+	continueReq := fetch.ContinueRequest(ev.RequestID)
+	if len(s.headers) > 0 && s.matches(ev.Request.URL) {
+		continueReq = continueReq.WithHeaders(s.mergeHeaders(ev.Request.Headers))
+	}
+	// fails if the tab was closed in the meantime, the request is gone then anyway
+	_ = continueReq.Do(ctx)
+}
+
+// continueWithAuth is answering the auth challenge, with the credentials of the scope if provide is true.
+func (s *originScope) continueWithAuth(tabCtx context.Context, ev *fetch.EventAuthRequired, provide bool) {
+	ctx := cdp.WithExecutor(tabCtx, chromedp.FromContext(tabCtx).Target)
+
+	response := &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseCancelAuth}
+	if provide {
+		response = &fetch.AuthChallengeResponse{Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+			Username: s.username, Password: s.password}
+	}
+	_ = fetch.ContinueWithAuth(ev.RequestID, response).Do(ctx)
+}
+
+// mergeHeaders is returning the headers of a request with the headers of the scope added, replacing
+// headers of the same name.
+func (s *originScope) mergeHeaders(headers network.Headers) []*fetch.HeaderEntry {
+	entries := make([]*fetch.HeaderEntry, 0, len(headers)+len(s.headers))
+	for name, value := range headers {
+		if _, ok := s.headers[http.CanonicalHeaderKey(name)]; ok {
+			continue
+		}
+		if v, ok := value.(string); ok {
+			entries = append(entries, &fetch.HeaderEntry{Name: name, Value: v})
+		}
+	}
+	for name, values := range s.headers {
+		for _, v := range values {
+			entries = append(entries, &fetch.HeaderEntry{Name: name, Value: v})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"net/http"
+	"sort"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func Test_newOriginScope(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://Site.example:8443/private", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("User-Agent", "htracker")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "secret"})
+	req.SetBasicAuth("user", "password")
+
+	s := newOriginScope(req)
+
+	if want, got := "https://site.example:8443", s.origin; want != got {
+		t.Errorf("Expected origin %s, got %s", want, got)
+	}
+	if want, got := 1, len(s.headers); want != got || s.headers.Get("X-Api-Key") != "key" {
+		t.Errorf("Expected only the X-Api-Key header to be scoped, got %v", s.headers)
+	}
+	if len(s.cookies) != 1 || s.cookies[0].Name != "session" || s.cookies[0].Value != "secret" {
+		t.Errorf("Expected session cookie, got %v", s.cookies)
+	}
+	if !s.auth || s.username != "user" || s.password != "password" {
+		t.Errorf("Expected basic auth credentials, got %v %s %s", s.auth, s.username, s.password)
+	}
+	if req.Header.Get("Authorization") == "" {
+		t.Error("Expected headers of the request to be kept")
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://site.example:8443/other?q=1", want: true},
+		{url: "https://SITE.example:8443", want: true},
+		{url: "https://site.example/private", want: false},
+		{url: "http://site.example:8443/private", want: false},
+		{url: "https://cdn.site.example:8443/private", want: false},
+		{url: "https://tracker.example/pixel.gif", want: false},
+		{url: "://invalid", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := s.matches(tt.url); got != tt.want {
+				t.Errorf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_originScope_mergeHeaders(t *testing.T) {
+	s := &originScope{headers: http.Header{"X-Api-Key": {"key"}, "Accept-Language": {"de"}}}
+
+	entries := s.mergeHeaders(network.Headers{"accept-language": "en", "Referer": "https://site.example/"})
+
+	got := make([]string, len(entries))
+	for i, e := range entries {
+		got[i] = e.Name + ": " + e.Value
+	}
+	sort.Strings(got)
+	want := []string{"Accept-Language: de", "Referer: https://site.example/", "X-Api-Key: key"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected headers %v, got %v", want, got)
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			t.Errorf("Expected headers %v, got %v", want, got)
+			break
+		}
+	}
+}
@@ -1,8 +1,15 @@
 package scraper
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/geziyor/geziyor"
@@ -10,10 +17,17 @@ import (
 	"github.com/geziyor/geziyor/export"
 	"gitlab.com/henri.philipps/htracker"
 	"gitlab.com/henri.philipps/htracker/exporter"
+	"gitlab.com/henri.philipps/htracker/robots"
 	"gitlab.com/henri.philipps/htracker/service"
 	"golang.org/x/exp/slog"
 )
 
+// DefaultUserAgent is the user agent sent by scrapers if not configured otherwise.
+const DefaultUserAgent = "HTracker/Geziyor 1.0"
+
+// defaultRenderTimeout is the max time for rendering a site, if the scraper has no timeout.
+const defaultRenderTimeout = 3 * time.Minute
+
 // Scraper is used to scrape web sites.
 type Scraper struct {
 	*geziyor.Geziyor
@@ -21,6 +35,21 @@ type Scraper struct {
 	Subscriptions []*htracker.Subscription
 	Logger        *slog.Logger
 
+	// Context is canceling requests waiting for the HostLimiter.
+	Context context.Context
+
+	// HostLimiter is limiting the requests per host, it can be shared by several scrapers.
+	// If nil, requests are not limited per host.
+	HostLimiter *HostLimiter
+
+	// Browser is rendering the sites of subscriptions using chrome, it can be shared by several
+	// scrapers. If nil, a Browser connecting to BrowserEndpoint is used by this scraper only.
+	Browser    *Browser
+	ownBrowser bool
+
+	// Robots is the robots.txt policy applied to all requests. If nil, robots.txt is ignored.
+	Robots *robots.Policy
+
 	/*** Geziyor Opts ***/
 
 	// AllowedDomains is domains that are allowed to make requests
@@ -35,6 +64,9 @@ type Scraper struct {
 	// For extracting data
 	Exporters []export.Exporter
 
+	// Disable logging of geziyor by setting this true
+	LogDisabled bool
+
 	// Max body reading size in bytes. Default: 1GB
 	MaxBodySize int64
 
@@ -50,8 +82,12 @@ type Scraper struct {
 }
 
 // newParseFunc is returning a new parser func, setup to parse the site content for the given subscription.
-// and send the results as siteArchive to the Exports channel.
-func newParseFunc(subscription *htracker.Subscription, logger *slog.Logger) func(*geziyor.Geziyor, *client.Response) {
+// and send the results with the given state as siteArchive to the Exports channel. The text of documents
+// (pdf, docx and odt) is extracted, so that the filter is applied to the text and diffs are readable.
+// The content of feeds is the JSON encoded list of their items. The screenshot is exported with the site
+// if not nil.
+func newParseFunc(subscription *htracker.Subscription, state htracker.SiteState, screenshot []byte,
+	logger *slog.Logger) func(*geziyor.Geziyor, *client.Response) {
 	return func(g *geziyor.Geziyor, r *client.Response) {
 		var content []byte
 
@@ -60,25 +96,64 @@ func newParseFunc(subscription *htracker.Subscription, logger *slog.Logger) func
 			return
 		}
 
+		body, doc, err := readBody(r)
+		if err != nil {
+			logger.Error("ParseFunc failed to read content", err, slog.String("site", subscription.URL))
+			return
+		}
+
 		switch {
+		case subscription.IsFeed():
+			items, err := parseFeed(body)
+			if err != nil {
+				logger.Error("ParseFunc failed to parse feed", err, slog.String("site", subscription.URL))
+				return
+			}
+			// the items are compared by the archive, so they are stored instead of the xml
+			if content, err = json.Marshal(items); err != nil {
+				logger.Error("ParseFunc failed to encode feed items", err, slog.String("site", subscription.URL))
+				return
+			}
+		case subscription.IsLinks():
+			links, err := parseLinks(body, doc, r.Request.URL, subscription.Filter)
+			if err != nil {
+				logger.Error("ParseFunc failed to parse links", err, slog.String("site", subscription.URL))
+				return
+			}
+			// the links are compared as set by the archive, one url per line
+			content = []byte(strings.Join(links, "\n"))
+		case subscription.Filter == "" && doc != nil && subscription.Normalize != htracker.NormalizeNone:
+			// rendering the parsed document is normalizing the html, e.g. entities like &#38; and &amp;
+			html, err := doc.Html()
+			if err != nil {
+				logger.Error("ParseFunc failed to render html", err, slog.String("site", subscription.URL))
+				return
+			}
+			content = []byte(html)
 		case subscription.Filter == "":
-			content = r.Body
-		case r.HTMLDoc != nil:
-			content = []byte(r.HTMLDoc.Find(subscription.Filter).Text())
+			content = body
+		case doc != nil:
+			content = []byte(doc.Find(subscription.Filter).Text())
 		default:
 			exp, err := regexp.Compile(subscription.Filter)
 			if err != nil {
 				logger.Error("ParseFunc failed to compile regexp", err, slog.String("regexp", subscription.Filter), slog.String("site", subscription.URL))
 				return
 			}
-			content = exp.Find(r.Body)
+			content = exp.Find(body)
 		}
 
+		content = service.Normalize(content, subscription.Normalize)
+
 		sa := &htracker.Site{
 			Subscription: subscription,
 			LastChecked:  time.Now(),
 			Content:      content,
 			Checksum:     service.Checksum(content),
+			State:        state,
+		}
+		if screenshot != nil {
+			sa.Screenshot = &htracker.Screenshot{Image: screenshot}
 		}
 
 		g.Exports <- sa
@@ -91,17 +166,28 @@ func NewScraper(subscriptions []*htracker.Subscription, opts ...Opt) *Scraper {
 	scraper := &Scraper{
 		Subscriptions: subscriptions,
 		Logger:        slog.Default(),
-		UserAgent:     "HTracker/Geziyor 1.0",
+		Context:       context.Background(),
+		UserAgent:     DefaultUserAgent,
 	}
 
 	for _, o := range opts {
 		o(scraper)
 	}
 
+	// all requests are sent through the HostLimiter, which is not limiting anything if none was given
+	if scraper.HostLimiter == nil {
+		scraper.HostLimiter = NewHostLimiter(HostLimit{}, nil)
+	}
+
+	if scraper.Browser == nil && scraper.BrowserEndpoint != "" {
+		scraper.Browser = NewBrowser(scraper.BrowserEndpoint, WithBrowserLogger(scraper.Logger))
+		scraper.ownBrowser = true
+	}
+
 	gcfg := geziyor.Options{
 		AllowedDomains:    scraper.AllowedDomains,
-		BrowserEndpoint:   scraper.BrowserEndpoint,
 		Exporters:         scraper.Exporters,
+		LogDisabled:       scraper.LogDisabled,
 		MaxBodySize:       scraper.MaxBodySize,
 		RequestsPerSecond: scraper.RequestsPerSecond,
 		Timeout:           scraper.Timeout,
@@ -109,23 +195,178 @@ func NewScraper(subscriptions []*htracker.Subscription, opts ...Opt) *Scraper {
 
 		// we do our own deduplication in the watcher
 		URLRevisitEnabled: true,
+
+		// the robots middleware of geziyor is silently dropping requests, we apply our own policy
+		RobotsTxtDisabled: true,
+
+		// decoding the charset would break binary documents, the body is decoded by the parser (see readBody())
+		CharsetDetectDisabled: true,
+		ParseHTMLDisabled:     true,
 	}
 
-	gcfg.StartRequestsFunc = func(g *geziyor.Geziyor) {
-		for _, subscription := range scraper.Subscriptions {
-			if subscription.UseChrome {
-				// using external chrome browser for rendering java script
-				g.GetRendered(subscription.URL, newParseFunc(subscription, scraper.Logger))
-			} else {
-				// directly scrape the plain web site content without rendering JS
-				g.Get(subscription.URL, newParseFunc(subscription, scraper.Logger))
+	gcfg.StartRequestsFunc = scraper.startRequests
+
+	scraper.Geziyor = geziyor.NewGeziyor(&gcfg)
+
+	return scraper
+}
+
+// Start is scraping the sites of all subscriptions and returns when all of them are done.
+func (s *Scraper) Start() {
+	s.Geziyor.Start()
+
+	if s.ownBrowser {
+		s.Browser.Close()
+	}
+}
+
+// startRequests is sending the requests for all subscriptions concurrently, each one checked
+// against the robots.txt policy and waiting for the HostLimiter before being sent. It returns when
+// all requests finished.
+func (s *Scraper) startRequests(g *geziyor.Geziyor) {
+	wg := sync.WaitGroup{}
+
+	for _, subscription := range s.Subscriptions {
+		req, err := s.newRequest(subscription)
+		if err != nil {
+			s.Logger.Error("failed to create request", err, slog.String("site", subscription.URL))
+			continue
+		}
+		// the request is sent synchronously, so that the host is released only after it finished
+		req.Synchronized = true
+
+		wg.Add(1)
+		go func(subscription *htracker.Subscription) {
+			defer wg.Done()
+
+			if s.Robots != nil {
+				allowed, crawlDelay, err := s.Robots.Check(s.Context, subscription.URL)
+				switch {
+				case err != nil:
+					s.Logger.Warn("failed to check robots.txt", "site", subscription.URL, "error", err)
+				case !allowed:
+					s.Logger.Info("scraping site disallowed by robots.txt", "site", subscription.URL)
+					g.Exports <- &htracker.Site{
+						Subscription: subscription,
+						LastChecked:  time.Now(),
+						State:        htracker.SiteStateBlockedByRobots,
+					}
+					return
+				case crawlDelay > 0:
+					s.HostLimiter.SetCrawlDelay(req.URL.Hostname(), crawlDelay)
+				}
 			}
+
+			release, err := s.HostLimiter.Acquire(s.Context, req.URL.Hostname())
+			if err != nil {
+				s.Logger.Warn("request canceled while waiting for host limiter", "site", subscription.URL, "error", err)
+				return
+			}
+			defer release()
+
+			s.do(g, subscription, req)
+		}(subscription)
+	}
+
+	wg.Wait()
+}
+
+// do is sending req for the given subscription and passing the response to the parser. If requested by
+// the subscription, the site is rendered with the Browser. If the browser is unavailable, the site is
+// scraped without rendering if the subscription allows it, or exported with SiteStateBrowserUnavailable.
+func (s *Scraper) do(g *geziyor.Geziyor, subscription *htracker.Subscription, req *client.Request) {
+	if !subscription.UseChrome {
+		g.Do(req, newParseFunc(subscription, htracker.SiteStateOK, nil, s.Logger))
+		return
+	}
+
+	err := fmt.Errorf("no browser configured: %w", ErrBrowserUnavailable)
+	if s.Browser != nil {
+		var resp *client.Response
+		var screenshot []byte
+		if resp, err = s.render(subscription, req, &screenshot); err == nil {
+			newParseFunc(subscription, htracker.SiteStateOK, screenshot, s.Logger)(g, resp)
+			return
 		}
 	}
 
-	scraper.Geziyor = geziyor.NewGeziyor(&gcfg)
+	switch {
+	case !errors.Is(err, ErrBrowserUnavailable):
+		s.Logger.Error("failed to render site", err, slog.String("site", subscription.URL))
+	case subscription.ChromeFallback:
+		s.Logger.Info("browser unavailable, scraping site without rendering", "site", subscription.URL, "error", err)
+		g.Do(req, newParseFunc(subscription, htracker.SiteStateRenderFallback, nil, s.Logger))
+	default:
+		s.Logger.Warn("browser unavailable, site not scraped", "site", subscription.URL, "error", err)
+		g.Exports <- &htracker.Site{
+			Subscription: subscription,
+			LastChecked:  time.Now(),
+			State:        htracker.SiteStateBrowserUnavailable,
+		}
+	}
+}
 
-	return scraper
+// render is rendering the site of the given subscription with the Browser, running the chrome actions
+// of its request options. If requested by the subscription, a screenshot is captured into screenshot.
+// The rendering is canceled after the timeout of the scraper.
+func (s *Scraper) render(subscription *htracker.Subscription, req *client.Request, screenshot *[]byte) (*client.Response, error) {
+	ctx := req.Context()
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = defaultRenderTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", s.UserAgent)
+	}
+
+	var script []htracker.ChromeAction
+	if subscription.Request != nil {
+		script = subscription.Request.Actions
+	}
+
+	var opts []RenderOpt
+	if subscription.Screenshot {
+		opts = append(opts, WithScreenshot(subscription.Filter, screenshot))
+	}
+
+	return s.Browser.Render(ctx, req, script, opts...)
+}
+
+// newRequest is returning the request for scraping the site of the given subscription, using its
+// method and body, with the request options of the subscription applied. When the site is rendered, chrome
+// is sending the headers, cookies and basic auth credentials to the origin of the site only.
+func (s *Scraper) newRequest(subscription *htracker.Subscription) (*client.Request, error) {
+	var body io.Reader
+	if subscription.Body != "" {
+		body = strings.NewReader(subscription.Body)
+	}
+
+	req, err := client.NewRequest(subscription.HTTPMethod(), subscription.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		// can be overridden by the headers of the request options
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Request = req.Request.WithContext(s.Context)
+
+	if opts := subscription.Request; opts != nil {
+		for name, value := range opts.Headers {
+			req.Header.Set(name, value)
+		}
+		for name, value := range opts.Cookies {
+			req.AddCookie(&http.Cookie{Name: name, Value: value})
+		}
+		if opts.BasicAuth != nil {
+			req.SetBasicAuth(opts.BasicAuth.Username, opts.BasicAuth.Password)
+		}
+	}
+
+	return req, nil
 }
 
 // Opt is a type representing functional Scraper options.
@@ -139,13 +380,21 @@ func WithAllowedDomains(domains []string) Opt {
 }
 
 // WithBrowserEndpoint is configuring the endpoint for connecting to a chrome
-// browser instance for rendering the web site.
+// browser instance for rendering the web site. It is ignored if a Browser is set.
 func WithBrowserEndpoint(endpoint string) Opt {
 	return func(s *Scraper) {
 		s.BrowserEndpoint = endpoint
 	}
 }
 
+// WithBrowser is rendering sites with the given Browser, which can be shared by several
+// scrapers to reuse the browser session.
+func WithBrowser(browser *Browser) Opt {
+	return func(s *Scraper) {
+		s.Browser = browser
+	}
+}
+
 // WithTimeout is setting the client timeout of the scraper.
 func WithTimeout(timeout time.Duration) Opt {
 	return func(s *Scraper) {
@@ -153,6 +402,50 @@ func WithTimeout(timeout time.Duration) Opt {
 	}
 }
 
+// WithUserAgent is setting the user agent sent with each request.
+func WithUserAgent(userAgent string) Opt {
+	return func(s *Scraper) {
+		s.UserAgent = userAgent
+	}
+}
+
+// WithMaxBodySize is limiting the number of bytes read from a response body.
+func WithMaxBodySize(size int64) Opt {
+	return func(s *Scraper) {
+		s.MaxBodySize = size
+	}
+}
+
+// WithRequestsPerSecond is limiting the number of requests per second of a scraper.
+func WithRequestsPerSecond(rps float64) Opt {
+	return func(s *Scraper) {
+		s.RequestsPerSecond = rps
+	}
+}
+
+// WithHostLimiter is limiting the requests per host with the given HostLimiter, which can be shared
+// by several scrapers to enforce the limits across all of them.
+func WithHostLimiter(limiter *HostLimiter) Opt {
+	return func(s *Scraper) {
+		s.HostLimiter = limiter
+	}
+}
+
+// WithRobots is applying the given robots.txt policy to all requests. Disallowed sites are exported
+// with SiteStateBlockedByRobots and the crawl delay is enforced with the HostLimiter.
+func WithRobots(policy *robots.Policy) Opt {
+	return func(s *Scraper) {
+		s.Robots = policy
+	}
+}
+
+// WithContext is setting the context used for canceling requests waiting for the HostLimiter.
+func WithContext(ctx context.Context) Opt {
+	return func(s *Scraper) {
+		s.Context = ctx
+	}
+}
+
 // WithExporters is adding exporters to export the scraped content (e.g. into a DB).
 func WithExporters(exporters []exporter.Interface) Opt {
 	return func(s *Scraper) {
@@ -160,6 +453,13 @@ func WithExporters(exporters []exporter.Interface) Opt {
 	}
 }
 
+// WithLogDisabled is disabling the internal logging of geziyor.
+func WithLogDisabled(disabled bool) Opt {
+	return func(s *Scraper) {
+		s.LogDisabled = disabled
+	}
+}
+
 // WithLogger configures the Logger.
 func WithLogger(logger *slog.Logger) Opt {
 	return func(s *Scraper) {
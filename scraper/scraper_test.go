@@ -2,6 +2,9 @@ package scraper
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"testing"
@@ -69,7 +72,7 @@ func TestScraper(t *testing.T) {
 			t.Errorf("Expected checksum not to be empty")
 		}
 
-		if site.Diff != "" {
+		if !site.Diff.Empty() {
 			t.Errorf("Expected diff to be empty")
 		}
 	}
@@ -124,3 +127,118 @@ func TestGetRenderedWithFilter(t *testing.T) {
 
 	s.Start()
 }
+
+func TestScraper_RequestOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		consent, err := r.Cookie("consent")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "lang=%s consent=%s", r.Header.Get("Accept-Language"), consent.Value)
+	}))
+	defer server.Close()
+
+	requestOpts := &htracker.RequestOptions{
+		Headers:   map[string]string{"Accept-Language": "de"},
+		Cookies:   map[string]string{"consent": "yes"},
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"},
+	}
+
+	tests := []struct {
+		name string
+		opts []Opt
+	}{
+		{name: "plain"},
+		{name: "host limiter", opts: []Opt{WithHostLimiter(NewHostLimiter(HostLimit{}, nil))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+			exp := exporter.NewExporter(context.Background(), archive)
+			sub := &htracker.Subscription{URL: server.URL, Request: requestOpts}
+
+			opts := append([]Opt{WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)}, tt.opts...)
+			NewScraper([]*htracker.Subscription{sub}, opts...).Start()
+
+			site, err := archive.Get(context.Background(), sub)
+			if err != nil {
+				t.Fatalf("archive.Get() failed: %v", err)
+			}
+			if want, got := "lang=de consent=yes", string(site.Content); want != got {
+				t.Errorf("Expected content %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestScraper_newRequest_Rendered(t *testing.T) {
+	sub := &htracker.Subscription{URL: "http://site.example", UseChrome: true, Request: &htracker.RequestOptions{
+		Headers:   map[string]string{"Accept-Language": "de"},
+		Cookies:   map[string]string{"consent": "yes"},
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"},
+	}}
+
+	req, err := NewScraper([]*htracker.Subscription{sub}).newRequest(sub)
+	if err != nil {
+		t.Fatalf("newRequest() failed: %v", err)
+	}
+	// the request options are passed to chrome as headers, Render is scoping them to the origin of the site
+	headers := client.ConvertHeaderToMap(req.Header)
+	for name, want := range map[string]string{"Accept-Language": "de", "Cookie": "consent=yes", "Authorization": "Basic dXNlcjpzZWNyZXQ="} {
+		if got := headers[name]; got != want {
+			t.Errorf("Expected header %s = %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestScraper_Post(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "%s %s q=%s", r.Method, r.Header.Get("Content-Type"), r.PostForm.Get("q"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		subscription *htracker.Subscription
+		want         string
+	}{
+		{name: "get", subscription: &htracker.Subscription{URL: server.URL}, want: "GET  q="},
+		{name: "post form", subscription: &htracker.Subscription{URL: server.URL, Method: "POST", Body: "q=foo"},
+			want: "POST application/x-www-form-urlencoded q=foo"},
+		{name: "post json", subscription: &htracker.Subscription{URL: server.URL, Method: "POST", Body: `{"q":"foo"}`,
+			Request: &htracker.RequestOptions{Headers: map[string]string{"Content-Type": "application/json"}}},
+			want: "POST application/json q="},
+	}
+
+	archive := service.NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	exp := exporter.NewExporter(context.Background(), archive)
+
+	subscriptions := []*htracker.Subscription{}
+	for _, tt := range tests {
+		subscriptions = append(subscriptions, tt.subscription)
+	}
+	NewScraper(subscriptions, WithExporters([]exporter.Interface{exp}), WithLogDisabled(true)).Start()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site, err := archive.Get(context.Background(), tt.subscription)
+			if err != nil {
+				t.Fatalf("archive.Get() failed: %v", err)
+			}
+			if got := string(site.Content); got != tt.want {
+				t.Errorf("Expected content %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package htracker
+
+// Screenshot is the latest PNG screenshot of a site rendered with chrome, see Subscription.Screenshot.
+type Screenshot struct {
+	Image []byte
+
+	// Previous is the screenshot before the latest visual change of the site, nil if the site
+	// didn't change visually yet.
+	Previous []byte `json:",omitempty"`
+
+	// PixelDiff is the percentage of the pixels which changed between Previous and Image, between 0 and 100.
+	PixelDiff float64
+}
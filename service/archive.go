@@ -1,78 +1,234 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"crypto/sha256"
-	"errors"
 	"fmt"
-	"strings"
-	"unicode"
 
-	"github.com/sergi/go-diff/diffmatchpatch"
 	"gitlab.com/henri.philipps/htracker"
 	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
 )
 
 // SiteArchive is an interface for a service that can store the state of scraped web sites (content, checksum etc).
 type SiteArchive interface {
-	Update(context.Context, *htracker.Site) (diff string, err error)
+	Update(context.Context, *htracker.Site) (diff htracker.Diff, err error)
 	Get(context.Context, *htracker.Subscription) (*htracker.Site, error)
+	// GetVersions is returning the archived versions of the content of the site, latest first.
+	GetVersions(context.Context, *htracker.Subscription) ([]*htracker.Version, error)
 }
 
 // NewSiteArchive is returning a new SiteArchive using the given storage backend.
-func NewSiteArchive(storage storage.SiteStorage) *siteArchive {
-	return &siteArchive{storage: storage}
+func NewSiteArchive(storage storage.SiteStorage, opts ...SiteArchiveOpt) *siteArchive {
+	archive := &siteArchive{storage: storage, differ: DefaultTextDiffer, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(archive)
+	}
+	return archive
+}
+
+// SiteArchiveOpt is representing functional options for the SiteArchive.
+type SiteArchiveOpt func(*siteArchive)
+
+// WithTextDiffer is configuring the budget for diffing the text of sites.
+func WithTextDiffer(differ TextDiffer) SiteArchiveOpt {
+	return func(archive *siteArchive) {
+		archive.differ = differ
+	}
+}
+
+// WithBlobStorage is storing the content of sites in the given BlobStorage instead of the site storage,
+// so that identical contents are stored once. Sites archived before are keeping their content until updated.
+func WithBlobStorage(blobs storage.BlobStorage) SiteArchiveOpt {
+	return func(archive *siteArchive) {
+		archive.blobs = blobs
+	}
+}
+
+// WithChangeNotifier is sending a change event to the given ChangeNotifier for every change reported by Update.
+// Failed notifications are logged, as the change is archived already.
+func WithChangeNotifier(notifier storage.ChangeNotifier) SiteArchiveOpt {
+	return func(archive *siteArchive) {
+		archive.notifier = notifier
+	}
+}
+
+// WithVersionHistory is keeping the versions of the content of sites in the given VersionStorage, a
+// version is added whenever the archived content changes.
+func WithVersionHistory(versions storage.VersionStorage) SiteArchiveOpt {
+	return func(archive *siteArchive) {
+		archive.versions = versions
+	}
+}
+
+// WithArchiveLogger is setting the logger of the SiteArchive, slog.Default() if not set.
+func WithArchiveLogger(logger *slog.Logger) SiteArchiveOpt {
+	return func(archive *siteArchive) {
+		archive.logger = logger
+	}
 }
 
 // siteArchive is implementing SiteArchive.
 type siteArchive struct {
-	storage storage.SiteStorage
+	storage  storage.SiteStorage
+	blobs    storage.BlobStorage
+	notifier storage.ChangeNotifier
+	versions storage.VersionStorage
+	differ   TextDiffer
+	logger   *slog.Logger
 }
 
-// Update is updating the archive with the results of the latest scrape of a site.
-func (archive *siteArchive) Update(ctx context.Context, site *htracker.Site) (diff string, err error) {
-	archivedSite, err := archive.storage.Get(ctx, site.Subscription)
-	if err != nil {
-		if errors.Is(err, htracker.ErrNotExist) {
+// Update is updating the archive with the results of the latest scrape of a site. The archived site is read
+// and written atomically, so that concurrent updates of the same site are applied one after the other.
+func (archive *siteArchive) Update(ctx context.Context, site *htracker.Site) (diff htracker.Diff, err error) {
+	// version is the stored site, if its content is a new version
+	var version *htracker.Site
+	err = archive.storage.Upsert(ctx, site.Subscription, func(archivedSite *htracker.Site) (*htracker.Site, error) {
+		// the update might be retried, so the scraped site is left unchanged
+		current := copySite(site)
+		archivedChecksum := ""
+		var stored *htracker.Site
+		if archivedSite == nil {
 			// site not found in archive - create new entry
-			if err := archive.storage.Add(ctx, site); err != nil {
-				return "", fmt.Errorf("ArchiveStorage.Add(): %w", err)
+			current.LastUpdated = current.LastChecked
+			current.Diff = nil
+			stored, diff = current, nil
+		} else {
+			if err := archive.loadContent(ctx, archivedSite); err != nil {
+				return nil, err
 			}
-			return "", nil
+			archivedChecksum = archivedSite.Checksum
+			stored, diff = archive.merge(archivedSite, current)
+		}
+
+		stored, err := archive.putContent(ctx, stored)
+		version = nil
+		if err == nil && stored.Checksum != "" && stored.Checksum != archivedChecksum {
+			version = stored
+		}
+		return stored, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SiteStorage.Upsert(): %w", err)
+	}
+
+	if archive.versions != nil && version != nil {
+		// the site is archived already, a missing version is only shortening its history
+		if err := archive.versions.AddVersion(ctx, version); err != nil {
+			archive.logger.Error("failed to add version of site", err, slog.String("url", site.Subscription.URL))
 		}
-		return "", fmt.Errorf("ArchiveStorage.Find(): %w", err)
 	}
 
+	if archive.notifier != nil && !diff.Empty() {
+		// the change is archived already, other instances are only missing the event
+		if err := archive.notifier.NotifyChange(ctx, storage.NewChangeEvent(site.Subscription, site.LastChecked)); err != nil {
+			archive.logger.Error("failed to notify change of site", err, slog.String("url", site.Subscription.URL))
+		}
+	}
+	return diff, nil
+}
+
+// merge is returning the site to be archived, given the archived site and the results of the latest
+// scrape, and the diff to be reported. Both given sites might be changed.
+func (archive *siteArchive) merge(archivedSite, site *htracker.Site) (*htracker.Site, htracker.Diff) {
+	// The site was not scraped, we just record the state and keep the content of the last scrape.
+	if !site.State.Scraped() {
+		archivedSite.State = site.State
+		archivedSite.LastChecked = site.LastChecked
+		return archivedSite, nil
+	}
+
+	// The site was never scraped successfully before, so there is nothing to compare with. The same
+	// is true if the site was rendered for the last scrape only, or for the current scrape only.
+	if archivedSite.Checksum == "" || renderFallbackChanged(archivedSite.State, site.State) {
+		site.LastUpdated = site.LastChecked
+		return site, nil
+	}
+
+	var diff htracker.Diff
 	// content changed
 	if archivedSite.Checksum != site.Checksum {
-		diff = DiffText(string(archivedSite.Content), string(site.Content))
 		// The diff function is ignoring whitespace changes as sometimes
 		// whitespace is rendered randomly. So it can happen that we see
 		// a changed checksum, but no diff. In this case we treat the
-		// site as not changed.
-		if diff != "" {
-			site.Diff = diff
-			site.LastUpdated = site.LastChecked
-			if err := archive.storage.Update(ctx, site); err != nil {
-				return diff, fmt.Errorf("ArchiveStorage.Update() - %w", err)
-			}
-			return diff, nil
+		// site as not changed. The same is true for feeds with reordered items.
+		diff = archive.diffContent(archivedSite, site)
+	}
+	// layout changed
+	visualChange := compareScreenshots(archivedSite, site)
+
+	if diff.Empty() && !visualChange {
+		// content unchanged
+		archivedSite.LastChecked = site.LastChecked
+		archivedSite.State = site.State
+		archivedSite.Screenshot = site.Screenshot
+		return archivedSite, nil
+	}
+
+	// Changes below the thresholds of the subscription are not reported as update of the site.
+	report := false
+	if !diff.Empty() {
+		site.Metrics = MeasureChanges(diff, archivedSite.Content, site.Content)
+		report = site.Metrics.Changed >= site.Subscription.MinChange
+	}
+	if visualChange {
+		diff = append(diff, htracker.DiffOp{Op: htracker.DiffSummary,
+			Text: fmt.Sprintf("The screenshot changed by %.1f%% of the pixels.\n", site.Screenshot.PixelDiff)})
+		report = report || site.Screenshot.PixelDiff >= site.Subscription.MinPixelChange
+	}
+	site.Diff = diff
+
+	if !report {
+		// The archived content and screenshot are kept as baseline, so that small changes are
+		// adding up until they are reaching the thresholds. The metrics are the ones of the
+		// changes since the baseline.
+		archivedSite.LastChecked = site.LastChecked
+		archivedSite.State = site.State
+		archivedSite.Metrics = site.Metrics
+		return archivedSite, nil
+	}
+
+	site.LastUpdated = site.LastChecked
+	return site, diff
+}
+
+// copySite is returning a copy of site, which can be changed without changing site.
+func copySite(site *htracker.Site) *htracker.Site {
+	c := *site
+	if site.Screenshot != nil {
+		screenshot := *site.Screenshot
+		c.Screenshot = &screenshot
+	}
+	return &c
+}
+
+// diffContent is returning the diff of the content of the archived and the current site. Feeds are
+// compared by their items, links as set, other sites by their text in the diff mode of the subscription.
+func (archive *siteArchive) diffContent(archived, current *htracker.Site) htracker.Diff {
+	if current.Subscription.IsFeed() {
+		changes, err := DiffFeed(archived.Content, current.Content)
+		// the archived content is no list of feed items, if the subscription was stored before
+		// feeds were supported
+		if err == nil {
+			return changes.Diff()
 		}
 	}
 
-	// content unchanged
-	archivedSite.LastChecked = site.LastChecked
-	if err := archive.storage.Update(ctx, archivedSite); err != nil {
-		return "", fmt.Errorf("ArchiveStorage.Update() - %w", err)
+	if current.Subscription.IsLinks() {
+		return DiffLinks(archived.Content, current.Content).Diff()
 	}
 
-	return "", nil
+	return archive.differ.Diff(string(archived.Content), string(current.Content), current.Subscription.DiffMode)
+}
+
+// renderFallbackChanged is returning whether exactly one of the given states is SiteStateRenderFallback.
+func renderFallbackChanged(archived, current htracker.SiteState) bool {
+	return (archived == htracker.SiteStateRenderFallback) != (current == htracker.SiteStateRenderFallback)
 }
 
 // Get is returning metadata, checksum and content of a site in the DB identified by URL, filter and contentType.
 func (archive *siteArchive) Get(ctx context.Context, subscription *htracker.Subscription) (*htracker.Site, error) {
-	content, err := archive.storage.Get(ctx, subscription)
+	content, err := archive.get(ctx, subscription)
 	if err != nil {
 		return &htracker.Site{}, fmt.Errorf("ArchiveStorage.Get(): %w", err)
 	}
@@ -80,52 +236,77 @@ func (archive *siteArchive) Get(ctx context.Context, subscription *htracker.Subs
 	return content, nil
 }
 
-// DiffPrintAsText is a helper function for formatting a diff as text.
-func DiffPrintAsText(diffs []diffmatchpatch.Diff) string {
-	var buff bytes.Buffer
-	for _, diff := range diffs {
-		text := diff.Text
-
-		switch diff.Type {
-		case diffmatchpatch.DiffInsert:
-			_, _ = buff.WriteString("\x1b[32m")
-			_, _ = buff.WriteString(text)
-			_, _ = buff.WriteString("\x1b[0m")
-		case diffmatchpatch.DiffDelete:
-			_, _ = buff.WriteString("\x1b[31m")
-			_, _ = buff.WriteString(text)
-			_, _ = buff.WriteString("\x1b[0m")
-		case diffmatchpatch.DiffEqual:
+// GetVersions is returning the archived versions of the content of the site, latest first. Without
+// version history, there are no versions.
+func (archive *siteArchive) GetVersions(ctx context.Context, subscription *htracker.Subscription) ([]*htracker.Version, error) {
+	if archive.versions == nil {
+		return []*htracker.Version{}, nil
+	}
+
+	versions, err := archive.versions.GetVersions(ctx, subscription)
+	if err != nil {
+		return nil, fmt.Errorf("VersionStorage.GetVersions(): %w", err)
+	}
+	for _, v := range versions {
+		// the content of versions is stored like the content of sites
+		site := &htracker.Site{Content: v.Content, Checksum: v.Checksum}
+		if err := archive.loadContent(ctx, site); err != nil {
+			return nil, err
 		}
+		v.Content = site.Content
 	}
+	return versions, nil
+}
 
-	return buff.String()
+// get is returning the archived site of the subscription, loading its content from the blob storage
+// if it is not stored inline.
+func (archive *siteArchive) get(ctx context.Context, subscription *htracker.Subscription) (*htracker.Site, error) {
+	site, err := archive.storage.Get(ctx, subscription)
+	if err != nil {
+		return site, err
+	}
+	return site, archive.loadContent(ctx, site)
 }
 
-// stripStringsBuilder is stripping whitespace from the given string.
-func stripStringsBuilder(str string) string {
-	var builder strings.Builder
-	builder.Grow(len(str))
-	for _, rune := range str {
-		if !unicode.IsSpace(rune) {
-			builder.WriteRune(rune)
-		}
+// loadContent is loading the content of site from the blob storage, if it is not stored inline.
+func (archive *siteArchive) loadContent(ctx context.Context, site *htracker.Site) error {
+	if archive.blobs == nil || len(site.Content) > 0 || site.Checksum == "" || site.Checksum == emptyChecksum {
+		return nil
+	}
+
+	content, err := archive.blobs.Get(ctx, site.Checksum)
+	if err != nil {
+		return fmt.Errorf("BlobStorage.Get(): %w", err)
 	}
-	return builder.String()
+	site.Content = content
+	return nil
 }
 
-// DiffText is a helper function for comparing the content of sites.
-// We try to ignore whitespace changes, as sometimes whitespace seems to be rendered randomly.
-func DiffText(str1, str2 string) string {
-	if stripStringsBuilder(str1) == stripStringsBuilder(str2) {
-		return ""
+// putContent is storing the content of site in the blob storage and returning a copy of site without
+// content, which is referencing the blob by its checksum. Without blob storage, site is returned as is.
+func (archive *siteArchive) putContent(ctx context.Context, site *htracker.Site) (*htracker.Site, error) {
+	if archive.blobs == nil || site.Checksum == "" {
+		return site, nil
 	}
 
-	dmp := diffmatchpatch.New()
-	diffs := dmp.DiffMain(str1, str2, false)
-	return DiffPrintAsText(dmp.DiffCleanupSemantic(diffs))
+	if err := archive.blobs.Put(ctx, site.Checksum, site.Content); err != nil {
+		return nil, fmt.Errorf("BlobStorage.Put(): %w", err)
+	}
+	// empty instead of nil, as the content can't be NULL in all storages
+	stored := *site
+	stored.Content = []byte{}
+	return &stored, nil
+}
+
+// DiffText is a helper function for comparing the content of sites, comparing characters within the
+// default budget. We try to ignore whitespace changes, as sometimes whitespace seems to be rendered randomly.
+func DiffText(str1, str2 string) htracker.Diff {
+	return DefaultTextDiffer.Diff(str1, str2, htracker.DiffModeDefault)
 }
 
+// emptyChecksum is the checksum of empty content, which doesn't need to be loaded from the blob storage.
+var emptyChecksum = Checksum(nil)
+
 // Checksum is calclating a checksum of the given data.
 func Checksum(data []byte) string {
 	return fmt.Sprintf("%x", sha256.Sum256(data))
@@ -3,10 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
 	"gitlab.com/henri.philipps/htracker/storage/memory"
 	"golang.org/x/exp/slog"
 )
@@ -33,18 +37,18 @@ func Test_ArchiveService_Update(t *testing.T) {
 		subscription       *htracker.Subscription
 		content            []byte
 		checksum           string
-		diffExpected       string
+		diffExpected       htracker.Diff
 		checkDateExpected  time.Time
 		updateDateExpected time.Time
 	}{
 		{name: "add new site1", date: date1, subscription: sub1, content: content1,
-			checksum: Checksum(content1), diffExpected: "",
+			checksum: Checksum(content1), diffExpected: nil,
 			checkDateExpected: date1, updateDateExpected: date1},
 		{name: "add new site2", date: date1, subscription: sub2, content: content2,
-			checksum: Checksum(content2), diffExpected: "",
+			checksum: Checksum(content2), diffExpected: nil,
 			checkDateExpected: date1, updateDateExpected: date1},
 		{name: "site1 unchanged", date: date2, subscription: sub1, content: content1,
-			checksum: Checksum(content1), diffExpected: "",
+			checksum: Checksum(content1), diffExpected: nil,
 			checkDateExpected: date2, updateDateExpected: date1},
 		{name: "update site1", date: date2, subscription: sub1, content: content1Updated,
 			checksum: Checksum(content1Updated), diffExpected: DiffText(string(content1), string(content1Updated)),
@@ -52,13 +56,13 @@ func Test_ArchiveService_Update(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		diff, err := svc.Update(ctx, &htracker.Site{tc.subscription, tc.date, tc.date, tc.content, tc.checksum, ""})
+		diff, err := svc.Update(ctx, &htracker.Site{Subscription: tc.subscription, LastUpdated: tc.date, LastChecked: tc.date, Content: tc.content, Checksum: tc.checksum})
 		if err != nil {
 			t.Fatalf("%s: archivesvc.Update() failed: %v", tc.name, err)
 		}
 
-		if want, got := tc.diffExpected, diff; want != got {
-			t.Fatalf("%s: Expected diff %s, got %s", tc.name, tc.diffExpected, diff)
+		if want, got := tc.diffExpected, diff; !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s: Expected diff %v, got %v", tc.name, tc.diffExpected, diff)
 		}
 
 		site, err := svc.Get(ctx, tc.subscription)
@@ -78,8 +82,8 @@ func Test_ArchiveService_Update(t *testing.T) {
 		if want, got := tc.checksum, site.Checksum; want != got {
 			t.Fatalf("%s: Expected checksum %s, got %s", tc.name, want, got)
 		}
-		if want, got := tc.diffExpected, diff; want != got {
-			t.Fatalf("%s: Expected diff %s, got %s", tc.name, want, got)
+		if want, got := tc.diffExpected, diff; !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s: Expected diff %v, got %v", tc.name, want, got)
 		}
 	}
 
@@ -88,3 +92,436 @@ func Test_ArchiveService_Update(t *testing.T) {
 		t.Fatalf("svc.Get(): Expected ErrNotExist error, got %v", err)
 	}
 }
+
+func Test_ArchiveService_Update_Blocked(t *testing.T) {
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	ctx := context.Background()
+
+	sub1 := &htracker.Subscription{URL: "http://site1.example/blah"}
+	sub2 := &htracker.Subscription{URL: "http://site1.example/blub"}
+	content1 := []byte("This is Site1")
+	content1Updated := []byte("This is Site1 updated")
+
+	date1 := time.Now()
+	date2 := date1.Add(time.Second)
+	date3 := date2.Add(time.Second)
+	date4 := date3.Add(time.Second)
+	date5 := date4.Add(time.Second)
+
+	ok := func(sub *htracker.Subscription, content []byte, date time.Time) *htracker.Site {
+		return &htracker.Site{Subscription: sub, LastChecked: date, Content: content, Checksum: Checksum(content), State: htracker.SiteStateOK}
+	}
+	blocked := func(sub *htracker.Subscription, date time.Time) *htracker.Site {
+		return &htracker.Site{Subscription: sub, LastChecked: date, State: htracker.SiteStateBlockedByRobots}
+	}
+	unavailable := func(sub *htracker.Subscription, date time.Time) *htracker.Site {
+		return &htracker.Site{Subscription: sub, LastChecked: date, State: htracker.SiteStateBrowserUnavailable}
+	}
+	// content scraped without rendering is not compared with rendered content
+	fallback := func(sub *htracker.Subscription, content []byte, date time.Time) *htracker.Site {
+		site := ok(sub, content, date)
+		site.State = htracker.SiteStateRenderFallback
+		return site
+	}
+
+	steps := []struct {
+		name        string
+		site        *htracker.Site
+		wantDiff    bool
+		wantState   htracker.SiteState
+		wantContent []byte
+		wantUpdated time.Time
+	}{
+		{name: "add site1", site: ok(sub1, content1, date1),
+			wantState: htracker.SiteStateOK, wantContent: content1, wantUpdated: date1},
+		{name: "site1 blocked", site: blocked(sub1, date2),
+			wantState: htracker.SiteStateBlockedByRobots, wantContent: content1, wantUpdated: date1},
+		{name: "site1 unblocked and changed", site: ok(sub1, content1Updated, date3), wantDiff: true,
+			wantState: htracker.SiteStateOK, wantContent: content1Updated, wantUpdated: date3},
+		{name: "add blocked site2", site: blocked(sub2, date1),
+			wantState: htracker.SiteStateBlockedByRobots, wantUpdated: date1},
+		{name: "first scrape of site2", site: ok(sub2, content1, date2),
+			wantState: htracker.SiteStateOK, wantContent: content1, wantUpdated: date2},
+		{name: "browser of site2 unavailable", site: unavailable(sub2, date3),
+			wantState: htracker.SiteStateBrowserUnavailable, wantContent: content1, wantUpdated: date2},
+		{name: "site2 scraped without rendering", site: fallback(sub2, content1Updated, date4),
+			wantState: htracker.SiteStateRenderFallback, wantContent: content1Updated, wantUpdated: date4},
+		{name: "site2 rendered again", site: ok(sub2, content1, date5),
+			wantState: htracker.SiteStateOK, wantContent: content1, wantUpdated: date5},
+	}
+
+	for _, step := range steps {
+		diff, err := svc.Update(ctx, step.site)
+		if err != nil {
+			t.Fatalf("%s: archivesvc.Update() failed: %v", step.name, err)
+		}
+		if diff.Empty() == step.wantDiff {
+			t.Errorf("%s: Expected diff %v, got %q", step.name, step.wantDiff, diff)
+		}
+
+		site, err := svc.Get(ctx, step.site.Subscription)
+		if err != nil {
+			t.Fatalf("%s: archivesvc.Get() failed: %v", step.name, err)
+		}
+		if want, got := step.wantState, site.State; want != got {
+			t.Errorf("%s: Expected state %s, got %s", step.name, want, got)
+		}
+		if want, got := string(step.wantContent), string(site.Content); want != got {
+			t.Errorf("%s: Expected content %q, got %q", step.name, want, got)
+		}
+		if want, got := step.wantUpdated, site.LastUpdated; !want.Equal(got) {
+			t.Errorf("%s: Expected lastUpdated %s, got %s", step.name, want, got)
+		}
+		if want, got := step.site.LastChecked, site.LastChecked; !want.Equal(got) {
+			t.Errorf("%s: Expected lastChecked %s, got %s", step.name, want, got)
+		}
+	}
+}
+
+func Test_ArchiveService_Update_DiffMode(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example", DiffMode: htracker.DiffModeLine}
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()), WithTextDiffer(TextDiffer{MaxSize: 64}))
+
+	steps := []struct {
+		content string
+		want    htracker.Diff
+	}{
+		{content: "a\nb\n"},
+		{content: "a\nc\n", want: htracker.Diff{{Op: htracker.DiffEqual, Text: "a\n"}, {Op: htracker.DiffDelete, Text: "b\n"},
+			{Op: htracker.DiffInsert, Text: "c\n"}}},
+		{content: strings.Repeat("d\n", 40), want: htracker.Diff{{Op: htracker.DiffSummary,
+			Text: "The content changed from 4 to 80 bytes (2 to 40 lines), it was not diffed as exceeding the max diff size of 64 bytes.\n"}}},
+	}
+
+	for i, step := range steps {
+		site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: []byte(step.content),
+			Checksum: Checksum([]byte(step.content)), State: htracker.SiteStateOK}
+		diff, err := svc.Update(ctx, site)
+		if err != nil {
+			t.Fatalf("step %d: archivesvc.Update() failed: %v", i, err)
+		}
+		if !reflect.DeepEqual(diff, step.want) {
+			t.Errorf("step %d: Expected diff %v, got %v", i, step.want, diff)
+		}
+	}
+}
+
+func Test_ArchiveService_Update_MinChange(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example", MinChange: 8}
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	start := time.Now()
+
+	steps := []struct {
+		content      string
+		wantChanged  bool
+		wantArchived string
+	}{
+		{content: "The price is 100 EUR.\n", wantArchived: "The price is 100 EUR.\n"},
+		// 1 of 22 chars changed, the archived content is kept as baseline
+		{content: "The price is 109 EUR.\n", wantArchived: "The price is 100 EUR.\n"},
+		// 1 of 22 chars changed since the last scrape, but 2 since the baseline
+		{content: "The price is 199 EUR.\n", wantChanged: true, wantArchived: "The price is 199 EUR.\n"},
+		{content: "The price is 150 EUR, hurry!\n", wantChanged: true, wantArchived: "The price is 150 EUR, hurry!\n"},
+	}
+
+	updated := start
+	for i, step := range steps {
+		checked := start.Add(time.Duration(i) * time.Minute)
+		site := &htracker.Site{Subscription: sub, LastChecked: checked, Content: []byte(step.content),
+			Checksum: Checksum([]byte(step.content)), State: htracker.SiteStateOK}
+		diff, err := svc.Update(ctx, site)
+		if err != nil {
+			t.Fatalf("step %d: archivesvc.Update() failed: %v", i, err)
+		}
+		if !diff.Empty() != step.wantChanged {
+			t.Errorf("step %d: Expected changed %v, got diff %v", i, step.wantChanged, diff)
+		}
+
+		archived, err := svc.Get(ctx, sub)
+		if err != nil {
+			t.Fatalf("step %d: archivesvc.Get() failed: %v", i, err)
+		}
+		if string(archived.Content) != step.wantArchived {
+			t.Errorf("step %d: Expected archived content %q, got %q", i, step.wantArchived, archived.Content)
+		}
+		if !archived.LastChecked.Equal(checked) {
+			t.Errorf("step %d: Expected last checked %v, got %v", i, checked, archived.LastChecked)
+		}
+		if i > 0 && archived.Metrics == nil {
+			t.Fatalf("step %d: Expected change metrics", i)
+		}
+		if step.wantChanged {
+			updated = checked
+			if archived.Metrics.Changed < sub.MinChange {
+				t.Errorf("step %d: Expected changed content of at least %v%%, got %v%%", i, sub.MinChange, archived.Metrics.Changed)
+			}
+		}
+		if !archived.LastUpdated.Equal(updated) {
+			t.Errorf("step %d: Expected last updated %v, got %v", i, updated, archived.LastUpdated)
+		}
+	}
+}
+
+// blobMap is a BlobStorage for tests.
+type blobMap map[string]*blobEntry
+
+// blobEntry is a blob of a blobMap.
+type blobEntry struct {
+	content []byte
+	lastPut time.Time
+}
+
+func (b blobMap) Put(ctx context.Context, checksum string, content []byte) error {
+	if entry, ok := b[checksum]; ok {
+		entry.lastPut = time.Now()
+		return nil
+	}
+	b[checksum] = &blobEntry{content: content, lastPut: time.Now()}
+	return nil
+}
+
+func (b blobMap) Get(ctx context.Context, checksum string) ([]byte, error) {
+	entry, ok := b[checksum]
+	if !ok {
+		return nil, htracker.ErrNotExist
+	}
+	return entry.content, nil
+}
+
+func (b blobMap) Delete(ctx context.Context, checksum string) error {
+	delete(b, checksum)
+	return nil
+}
+
+func (b blobMap) List(ctx context.Context, before time.Time) ([]string, error) {
+	checksums := []string{}
+	for checksum, entry := range b {
+		if entry.lastPut.Before(before) {
+			checksums = append(checksums, checksum)
+		}
+	}
+	return checksums, nil
+}
+
+func (b blobMap) Expire(ctx context.Context, checksum string, before time.Time) (bool, error) {
+	entry, ok := b[checksum]
+	if !ok || !entry.lastPut.Before(before) {
+		return false, nil
+	}
+	delete(b, checksum)
+	return true, nil
+}
+
+func Test_ArchiveService_Update_BlobStorage(t *testing.T) {
+	ctx := context.Background()
+	sub1 := &htracker.Subscription{URL: "http://site1.example"}
+	sub2 := &htracker.Subscription{URL: "http://site2.example"}
+	sites := memory.NewSiteStorage(slog.Default())
+	blobs := blobMap{}
+	svc := NewSiteArchive(sites, WithBlobStorage(blobs))
+
+	steps := []struct {
+		subscription *htracker.Subscription
+		content      string
+		wantChanged  bool
+		wantBlobs    int
+	}{
+		{subscription: sub1, content: "This is Site1\n", wantBlobs: 1},
+		// identical content is stored once
+		{subscription: sub2, content: "This is Site1\n", wantBlobs: 1},
+		{subscription: sub1, content: "This is Site1 updated\n", wantChanged: true, wantBlobs: 2},
+		{subscription: sub1, content: "", wantChanged: true, wantBlobs: 3},
+	}
+
+	for i, step := range steps {
+		site := &htracker.Site{Subscription: step.subscription, LastChecked: time.Now(), Content: []byte(step.content),
+			Checksum: Checksum([]byte(step.content)), State: htracker.SiteStateOK}
+		diff, err := svc.Update(ctx, site)
+		if err != nil {
+			t.Fatalf("step %d: archivesvc.Update() failed: %v", i, err)
+		}
+		if !diff.Empty() != step.wantChanged {
+			t.Errorf("step %d: Expected changed %v, got diff %v", i, step.wantChanged, diff)
+		}
+		if len(blobs) != step.wantBlobs {
+			t.Errorf("step %d: Expected %d blobs, got %d", i, step.wantBlobs, len(blobs))
+		}
+
+		stored, err := sites.Get(ctx, step.subscription)
+		if err != nil {
+			t.Fatalf("step %d: storage.Get() failed: %v", i, err)
+		}
+		if len(stored.Content) != 0 {
+			t.Errorf("step %d: Expected no content in site storage, got %q", i, stored.Content)
+		}
+
+		archived, err := svc.Get(ctx, step.subscription)
+		if err != nil {
+			t.Fatalf("step %d: archivesvc.Get() failed: %v", i, err)
+		}
+		if string(archived.Content) != step.content {
+			t.Errorf("step %d: Expected archived content %q, got %q", i, step.content, archived.Content)
+		}
+	}
+}
+
+func Test_ArchiveService_Update_VersionHistory(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example"}
+	sites := memory.NewSiteStorage(slog.Default())
+	svc := NewSiteArchive(sites, WithBlobStorage(blobMap{}), WithVersionHistory(sites))
+	start := time.Now()
+
+	steps := []struct {
+		content string
+		state   htracker.SiteState
+	}{
+		{content: "Version 1\n", state: htracker.SiteStateOK},
+		// unchanged content is no new version
+		{content: "Version 1\n", state: htracker.SiteStateOK},
+		{content: "Version 2\n", state: htracker.SiteStateOK},
+		// the content of sites not scraped is not archived
+		{content: "", state: htracker.SiteStateBlockedByRobots},
+		{content: "Version 3\n", state: htracker.SiteStateOK},
+	}
+	for i, step := range steps {
+		site := &htracker.Site{Subscription: sub, LastChecked: start.Add(time.Duration(i) * time.Minute), Content: []byte(step.content),
+			Checksum: Checksum([]byte(step.content)), State: step.state}
+		if _, err := svc.Update(ctx, site); err != nil {
+			t.Fatalf("step %d: archivesvc.Update() failed: %v", i, err)
+		}
+	}
+
+	versions, err := svc.GetVersions(ctx, sub)
+	if err != nil {
+		t.Fatalf("archivesvc.GetVersions() failed: %v", err)
+	}
+	want := []*htracker.Version{
+		{Archived: start.Add(4 * time.Minute), Checksum: Checksum([]byte("Version 3\n")), Content: []byte("Version 3\n")},
+		{Archived: start.Add(2 * time.Minute), Checksum: Checksum([]byte("Version 2\n")), Content: []byte("Version 2\n")},
+		{Archived: start, Checksum: Checksum([]byte("Version 1\n")), Content: []byte("Version 1\n")},
+	}
+	if !reflect.DeepEqual(versions, want) {
+		t.Errorf("archivesvc.GetVersions() = %v, want %v", versions, want)
+	}
+
+	// without version history, there are no versions
+	versions, err = NewSiteArchive(sites).GetVersions(ctx, sub)
+	if err != nil || len(versions) != 0 {
+		t.Errorf("Expected no versions without version history, got %v, %v", versions, err)
+	}
+}
+
+func Test_ArchiveService_Update_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example"}
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+
+	// the same site scraped concurrently by several scrapers, every update is a change of the content
+	const updates = 20
+	errs := make(chan error, updates)
+	diffs := make(chan htracker.Diff, updates)
+	for i := 0; i < updates; i++ {
+		content := []byte(fmt.Sprintf("content %d\n", i))
+		go func() {
+			site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: content,
+				Checksum: Checksum(content), State: htracker.SiteStateOK}
+			diff, err := svc.Update(ctx, site)
+			errs <- err
+			diffs <- diff
+		}()
+	}
+
+	changes := 0
+	for i := 0; i < updates; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("archivesvc.Update() failed: %v", err)
+		}
+		if !(<-diffs).Empty() {
+			changes++
+		}
+	}
+	// the first update is adding the site, all others are changing it
+	if changes != updates-1 {
+		t.Errorf("Expected %d changes, got %d", updates-1, changes)
+	}
+}
+
+func Test_ArchiveService_Update_ChangeNotifier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := &htracker.Subscription{URL: "http://site.example", MinChange: 50}
+	notifier := memory.NewChangeNotifier(slog.Default())
+	events, err := notifier.ListenChanges(ctx)
+	if err != nil {
+		t.Fatalf("ListenChanges() failed: %v", err)
+	}
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()), WithChangeNotifier(notifier))
+
+	steps := []struct {
+		content   string
+		wantEvent bool
+	}{
+		{content: "The price is 100 EUR.\n"},
+		{content: "The price is 100 EUR.\n"},
+		// below the threshold
+		{content: "The price is 101 EUR.\n"},
+		{content: "Sold out!\n", wantEvent: true},
+	}
+
+	for i, step := range steps {
+		checked := time.Now()
+		site := &htracker.Site{Subscription: sub, LastChecked: checked, Content: []byte(step.content),
+			Checksum: Checksum([]byte(step.content)), State: htracker.SiteStateOK}
+		if _, err := svc.Update(ctx, site); err != nil {
+			t.Fatalf("step %d: archivesvc.Update() failed: %v", i, err)
+		}
+
+		select {
+		case event := <-events:
+			if !step.wantEvent {
+				t.Errorf("step %d: unexpected change event %v", i, event)
+				continue
+			}
+			if event.Subscription.URL != sub.URL || !event.LastUpdated.Equal(checked) {
+				t.Errorf("step %d: Expected change event of %s at %v, got %v", i, sub.URL, checked, event)
+			}
+		default:
+			if step.wantEvent {
+				t.Errorf("step %d: Expected change event", i)
+			}
+		}
+	}
+}
+
+// failingNotifier is a ChangeNotifier failing to send events.
+type failingNotifier struct{}
+
+func (failingNotifier) NotifyChange(ctx context.Context, event *storage.ChangeEvent) error {
+	return errors.New("notification failed")
+}
+
+func (failingNotifier) ListenChanges(ctx context.Context) (<-chan *storage.ChangeEvent, error) {
+	return nil, errors.New("listening failed")
+}
+
+func Test_ArchiveService_Update_ChangeNotifier_Failed(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example"}
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()), WithChangeNotifier(failingNotifier{}))
+
+	for i, content := range []string{"The price is 100 EUR.\n", "Sold out!\n"} {
+		site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: []byte(content),
+			Checksum: Checksum([]byte(content)), State: htracker.SiteStateOK}
+		diff, err := svc.Update(ctx, site)
+		// the change is archived and reported, even if other instances are not notified
+		if err != nil {
+			t.Fatalf("step %d: archivesvc.Update() failed: %v", i, err)
+		}
+		if i > 0 && diff.Empty() {
+			t.Errorf("step %d: Expected diff of changed site", i)
+		}
+	}
+}
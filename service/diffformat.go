@@ -0,0 +1,293 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// Formats of rendered diffs, see FormatDiff.
+const (
+	// DiffFormatUnified is a unified diff of the lines of the old and new text.
+	DiffFormatUnified = "unified"
+
+	// DiffFormatHTML is the changed lines as preformatted html, marking insertions with <ins>
+	// and deletions with <del>.
+	DiffFormatHTML = "html"
+
+	// DiffFormatJSON is the list of operations of the diff as JSON, always including all unchanged text.
+	DiffFormatJSON = "json"
+
+	// DiffFormatPlain is the changed lines as text, marking insertions with {+...+} and deletions with [-...-].
+	DiffFormatPlain = "plain"
+
+	// DiffFormatANSI is the changed lines as text, coloring insertions green and deletions red for terminals.
+	DiffFormatANSI = "ansi"
+)
+
+// DefaultDiffContext is the default number of unchanged lines shown around changed lines.
+const DefaultDiffContext = 3
+
+// DiffFormats is returning the supported diff formats.
+func DiffFormats() []string {
+	return []string{DiffFormatUnified, DiffFormatHTML, DiffFormatJSON, DiffFormatPlain, DiffFormatANSI}
+}
+
+// FormatDiff is rendering d in the given format, showing context unchanged lines around changed lines.
+// A negative context is showing all lines. An error wrapping htracker.ErrInvalid is returned for unknown
+// formats.
+func FormatDiff(d htracker.Diff, format string, context int) (string, error) {
+	switch format {
+	case DiffFormatUnified:
+		return formatUnified(d, context), nil
+	case DiffFormatHTML:
+		return formatInline(d, context, htmlMarkup), nil
+	case DiffFormatJSON:
+		if d == nil {
+			d = htracker.Diff{}
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode diff: %w", err)
+		}
+		return string(data), nil
+	case DiffFormatPlain:
+		return formatInline(d, context, plainMarkup), nil
+	case DiffFormatANSI:
+		return formatInline(d, context, ansiMarkup), nil
+	default:
+		return "", fmt.Errorf("diff format %s not supported, expected one of %s: %w", format,
+			strings.Join(DiffFormats(), ", "), htracker.ErrInvalid)
+	}
+}
+
+// inlineMarkup is marking insertions and deletions within the changed lines of a diff.
+type inlineMarkup struct {
+	insertStart, insertEnd string
+	deleteStart, deleteEnd string
+	escape                 func(string) string
+	// gap is separating lines which are not adjacent.
+	gap        string
+	begin, end string
+}
+
+var (
+	plainMarkup = inlineMarkup{insertStart: "{+", insertEnd: "+}", deleteStart: "[-", deleteEnd: "-]",
+		escape: func(s string) string { return s }, gap: "..."}
+	htmlMarkup = inlineMarkup{insertStart: "<ins>", insertEnd: "</ins>", deleteStart: "<del>", deleteEnd: "</del>",
+		escape: html.EscapeString, gap: "…", begin: `<pre class="diff">`, end: "</pre>\n"}
+	ansiMarkup = inlineMarkup{insertStart: "\x1b[32m", insertEnd: "\x1b[0m", deleteStart: "\x1b[31m", deleteEnd: "\x1b[0m",
+		escape: func(s string) string { return s }, gap: "..."}
+)
+
+// diffSegment is a part of a line of a diff.
+type diffSegment struct {
+	op   htracker.DiffOperation
+	text string
+}
+
+// diffLine is a line of a diff, it is changed if it has inserted or deleted text, including a line break.
+type diffLine struct {
+	segments []diffSegment
+	changed  bool
+}
+
+// splitDiffLines is splitting the operations of d into lines.
+func splitDiffLines(d htracker.Diff) []diffLine {
+	lines := []diffLine{{}}
+	for _, op := range d {
+		parts := strings.Split(op.Text, "\n")
+		for i, part := range parts {
+			line := &lines[len(lines)-1]
+			lineBreak := i < len(parts)-1
+			if op.Op != htracker.DiffEqual && (part != "" || lineBreak) {
+				line.changed = true
+			}
+			if part != "" {
+				line.segments = append(line.segments, diffSegment{op: op.Op, text: part})
+			}
+			if lineBreak {
+				lines = append(lines, diffLine{})
+			}
+		}
+	}
+
+	// the text is usually terminated by a line break
+	if last := lines[len(lines)-1]; len(lines) > 1 && len(last.segments) == 0 && !last.changed {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// visibleLines is returning which lines are shown, given the changed lines and the number of context
+// lines shown around them. All lines are shown for a negative context.
+func visibleLines(changed []bool, context int) []bool {
+	visible := make([]bool, len(changed))
+	for i, c := range changed {
+		if context < 0 {
+			visible[i] = true
+			continue
+		}
+		if !c {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(visible) {
+				visible[j] = true
+			}
+		}
+	}
+	return visible
+}
+
+// formatInline is rendering the changed lines of d and their context, marking insertions and
+// deletions within the lines.
+func formatInline(d htracker.Diff, context int, m inlineMarkup) string {
+	if d.Empty() {
+		return ""
+	}
+
+	lines := splitDiffLines(d)
+	changed := make([]bool, len(lines))
+	for i, line := range lines {
+		changed[i] = line.changed
+	}
+	visible := visibleLines(changed, context)
+
+	b := strings.Builder{}
+	b.WriteString(m.begin)
+	last := -1
+	for i, line := range lines {
+		if !visible[i] {
+			continue
+		}
+		if i > last+1 {
+			b.WriteString(m.gap + "\n")
+		}
+		for _, s := range line.segments {
+			switch s.op {
+			case htracker.DiffInsert:
+				b.WriteString(m.insertStart + m.escape(s.text) + m.insertEnd)
+			case htracker.DiffDelete:
+				b.WriteString(m.deleteStart + m.escape(s.text) + m.deleteEnd)
+			default:
+				b.WriteString(m.escape(s.text))
+			}
+		}
+		b.WriteString("\n")
+		last = i
+	}
+	if last < len(lines)-1 {
+		b.WriteString(m.gap + "\n")
+	}
+	b.WriteString(m.end)
+
+	return b.String()
+}
+
+// unifiedLine is a line of a unified diff, prefixed by ' ', '+' or '-'.
+type unifiedLine struct {
+	prefix byte
+	text   string
+}
+
+// formatUnified is rendering d as unified diff of the lines of the old and new text.
+func formatUnified(d htracker.Diff, context int) string {
+	if d.Empty() {
+		return ""
+	}
+
+	diffs, ok := diffTokens(diffmatchpatch.New(), splitLines(d.OldText()), splitLines(d.NewText()), maxDiffTokens)
+	if !ok {
+		// texts with too many distinct lines are shown as replaced
+		diffs = []diffmatchpatch.Diff{{Type: diffmatchpatch.DiffDelete, Text: d.OldText()},
+			{Type: diffmatchpatch.DiffInsert, Text: d.NewText()}}
+	}
+
+	lines := []unifiedLine{}
+	for _, diff := range diffs {
+		prefix := byte(' ')
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			prefix = '+'
+		case diffmatchpatch.DiffDelete:
+			prefix = '-'
+		}
+		for _, text := range strings.SplitAfter(diff.Text, "\n") {
+			if text != "" {
+				lines = append(lines, unifiedLine{prefix: prefix, text: strings.TrimSuffix(text, "\n")})
+			}
+		}
+	}
+
+	b := strings.Builder{}
+	hasChanges := false
+	changed := make([]bool, len(lines))
+	for i, line := range lines {
+		changed[i] = line.prefix != ' '
+		hasChanges = hasChanges || changed[i]
+	}
+	visible := visibleLines(changed, context)
+
+	// summaries are no part of the text, they are preceding the hunks
+	for _, op := range d {
+		if op.Op == htracker.DiffSummary {
+			b.WriteString(op.Text)
+		}
+	}
+	if !hasChanges {
+		return b.String()
+	}
+
+	b.WriteString("--- archived\n+++ current\n")
+	oldLine, newLine := 1, 1
+	for i := 0; i < len(lines); {
+		if !visible[i] {
+			if lines[i].prefix != '+' {
+				oldLine++
+			}
+			if lines[i].prefix != '-' {
+				newLine++
+			}
+			i++
+			continue
+		}
+
+		// the hunk is the run of visible lines
+		end := i
+		oldCount, newCount := 0, 0
+		for ; end < len(lines) && visible[end]; end++ {
+			if lines[end].prefix != '+' {
+				oldCount++
+			}
+			if lines[end].prefix != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldLine, oldCount), hunkRange(newLine, newCount))
+		for _, line := range lines[i:end] {
+			b.WriteByte(line.prefix)
+			b.WriteString(line.text)
+			b.WriteByte('\n')
+		}
+
+		oldLine += oldCount
+		newLine += newCount
+		i = end
+	}
+
+	return b.String()
+}
+
+// hunkRange is returning the range of a hunk of a unified diff, the line before the hunk is given
+// as start for empty ranges.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
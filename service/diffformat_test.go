@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestFormatDiff(t *testing.T) {
+	old := "line 1\nline 2\nfees: 10 EUR\nline 4\nline 5\nline 6\n<b>bold</b>\n"
+	current := "line 1\nline 2\nfees: 12 EUR\nline 4\nline 5\nline 6\n<b>bolder</b>\n"
+	diff := DiffText(old, current)
+
+	tests := []struct {
+		name    string
+		format  string
+		context int
+		want    string
+		wantErr bool
+	}{
+		{name: "unified", format: DiffFormatUnified, context: 1,
+			want: "--- archived\n+++ current\n@@ -2,3 +2,3 @@\n line 2\n-fees: 10 EUR\n+fees: 12 EUR\n line 4\n" +
+				"@@ -6,2 +6,2 @@\n line 6\n-<b>bold</b>\n+<b>bolder</b>\n"},
+		{name: "unified with all lines", format: DiffFormatUnified, context: -1,
+			want: "--- archived\n+++ current\n@@ -1,7 +1,7 @@\n line 1\n line 2\n-fees: 10 EUR\n+fees: 12 EUR\n line 4\n line 5\n line 6\n" +
+				"-<b>bold</b>\n+<b>bolder</b>\n"},
+		{name: "plain", format: DiffFormatPlain, context: 0, want: "...\nfees: 1[-0-]{+2+} EUR\n...\n<b>bold{+er+}</b>\n"},
+		{name: "plain with context", format: DiffFormatPlain, context: 1,
+			want: "...\nline 2\nfees: 1[-0-]{+2+} EUR\nline 4\n...\nline 6\n<b>bold{+er+}</b>\n"},
+		{name: "html", format: DiffFormatHTML, context: 0,
+			want: "<pre class=\"diff\">…\nfees: 1<del>0</del><ins>2</ins> EUR\n…\n&lt;b&gt;bold<ins>er</ins>&lt;/b&gt;\n</pre>\n"},
+		{name: "ansi", format: DiffFormatANSI, context: 0,
+			want: "...\nfees: 1\x1b[31m0\x1b[0m\x1b[32m2\x1b[0m EUR\n...\n<b>bold\x1b[32mer\x1b[0m</b>\n"},
+		{name: "json", format: DiffFormatJSON, context: 0,
+			want: `[{"Op":"equal","Text":"line 1\nline 2\nfees: 1"},{"Op":"delete","Text":"0"},{"Op":"insert","Text":"2"},` +
+				`{"Op":"equal","Text":" EUR\nline 4\nline 5\nline 6\n\u003cb\u003ebold"},{"Op":"insert","Text":"er"},{"Op":"equal","Text":"\u003c/b\u003e\n"}]`},
+		{name: "unknown", format: "pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FormatDiff(diff, tt.format, tt.context)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FormatDiff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, htracker.ErrInvalid) {
+				t.Errorf("Expected ErrInvalid, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FormatDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDiff_Empty(t *testing.T) {
+	for _, format := range DiffFormats() {
+		got, err := FormatDiff(nil, format, DefaultDiffContext)
+		if err != nil {
+			t.Fatalf("FormatDiff(%s) failed: %v", format, err)
+		}
+		if want := map[string]string{DiffFormatJSON: "[]"}[format]; got != want {
+			t.Errorf("FormatDiff(%s) = %q, want %q", format, got, want)
+		}
+	}
+}
+
+func TestFormatDiff_Summary(t *testing.T) {
+	summary := "The content changed from 34 to 33 bytes.\n"
+	diff := htracker.Diff{{Op: htracker.DiffSummary, Text: summary}}
+
+	for _, format := range []string{DiffFormatUnified, DiffFormatPlain, DiffFormatANSI} {
+		got, err := FormatDiff(diff, format, DefaultDiffContext)
+		if err != nil {
+			t.Fatalf("FormatDiff(%s) failed: %v", format, err)
+		}
+		if got != summary {
+			t.Errorf("FormatDiff(%s) = %q, want %q", format, got, summary)
+		}
+	}
+}
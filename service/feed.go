@@ -0,0 +1,45 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// DiffFeed is comparing the items of a feed by GUID, given the JSON encoded items of two scrapes
+// of the feed. Items with the same GUID are changed, if their title, link or content changed.
+func DiffFeed(content1, content2 []byte) (*htracker.FeedChanges, error) {
+	var items1, items2 []htracker.FeedItem
+	if err := json.Unmarshal(content1, &items1); err != nil {
+		return nil, fmt.Errorf("failed to decode feed items: %w", err)
+	}
+	if err := json.Unmarshal(content2, &items2); err != nil {
+		return nil, fmt.Errorf("failed to decode feed items: %w", err)
+	}
+
+	old := make(map[string]htracker.FeedItem, len(items1))
+	for _, item := range items1 {
+		old[item.GUID] = item
+	}
+
+	changes := &htracker.FeedChanges{}
+	current := make(map[string]bool, len(items2))
+	for _, item := range items2 {
+		current[item.GUID] = true
+		oldItem, ok := old[item.GUID]
+		switch {
+		case !ok:
+			changes.New = append(changes.New, item)
+		case oldItem.Title != item.Title || oldItem.Link != item.Link || oldItem.Checksum != item.Checksum:
+			changes.Changed = append(changes.Changed, item)
+		}
+	}
+	for _, item := range items1 {
+		if !current[item.GUID] {
+			changes.Removed = append(changes.Removed, item)
+		}
+	}
+
+	return changes, nil
+}
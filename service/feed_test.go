@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func feedContent(t *testing.T, items ...htracker.FeedItem) []byte {
+	data, err := json.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDiffFeed(t *testing.T) {
+	item1 := htracker.FeedItem{GUID: "1", Title: "Item 1", Link: "http://site.example/1"}
+	item2 := htracker.FeedItem{GUID: "2", Title: "Item 2", Link: "http://site.example/2", Checksum: "a"}
+	item2Changed := htracker.FeedItem{GUID: "2", Title: "Item 2", Link: "http://site.example/2", Checksum: "b"}
+	item3 := htracker.FeedItem{GUID: "3", Title: "Item 3"}
+
+	tests := []struct {
+		name       string
+		old, new   []byte
+		want       *htracker.FeedChanges
+		wantString string
+		wantErr    bool
+	}{
+		{name: "unchanged", old: feedContent(t, item1, item2), new: feedContent(t, item1, item2), want: &htracker.FeedChanges{}},
+		{name: "reordered", old: feedContent(t, item1, item2), new: feedContent(t, item2, item1), want: &htracker.FeedChanges{}},
+		{name: "changes", old: feedContent(t, item1, item2), new: feedContent(t, item3, item2Changed),
+			want:       &htracker.FeedChanges{New: []htracker.FeedItem{item3}, Changed: []htracker.FeedItem{item2Changed}, Removed: []htracker.FeedItem{item1}},
+			wantString: "1 new item:\n+ Item 3\n\n1 changed item:\n~ Item 2 (http://site.example/2)\n\n1 removed item:\n- Item 1 (http://site.example/1)\n"},
+		{name: "no feed", old: []byte("<rss></rss>"), new: feedContent(t, item1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DiffFeed(tt.old, tt.new)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DiffFeed() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffFeed() = %+v, want %+v", got, tt.want)
+			}
+			if want, got := tt.wantString, got.String(); want != got {
+				t.Errorf("Expected changes %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func Test_ArchiveService_Update_Feed(t *testing.T) {
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example/feed.xml", ContentType: htracker.ContentTypeFeed}
+
+	item1 := htracker.FeedItem{GUID: "1", Title: "Item 1"}
+	item2 := htracker.FeedItem{GUID: "2", Title: "Item 2"}
+	date := time.Now()
+
+	steps := []struct {
+		name     string
+		content  []byte
+		wantDiff string
+	}{
+		{name: "first scrape", content: feedContent(t, item1)},
+		{name: "new item", content: feedContent(t, item2, item1), wantDiff: "1 new item:\n{++ Item 2+}\n"},
+		{name: "reordered", content: feedContent(t, item1, item2)},
+	}
+
+	for i, step := range steps {
+		site := &htracker.Site{Subscription: sub, LastChecked: date.Add(time.Duration(i) * time.Second), Content: step.content,
+			Checksum: Checksum(step.content), State: htracker.SiteStateOK}
+		diff, err := svc.Update(ctx, site)
+		if err != nil {
+			t.Fatalf("%s: archivesvc.Update() failed: %v", step.name, err)
+		}
+		got, err := FormatDiff(diff, DiffFormatPlain, -1)
+		if err != nil {
+			t.Fatalf("%s: FormatDiff() failed: %v", step.name, err)
+		}
+		if got != step.wantDiff {
+			t.Errorf("%s: Expected diff %q, got %q", step.name, step.wantDiff, got)
+		}
+	}
+}
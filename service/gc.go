@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// GarbageCollector is removing the subscriptions and archived sites nobody is subscribed to anymore
+// in regular intervals, keeping count of what was reclaimed.
+type GarbageCollector struct {
+	storage  storage.GarbageCollector
+	interval time.Duration
+	logger   *slog.Logger
+
+	// blobs are the contents of the sites of sites, which are removed once unreferenced for the grace period
+	blobs storage.BlobStorage
+	sites storage.BlobReferrer
+	grace time.Duration
+
+	// mu is guarding the totals below
+	mu    sync.Mutex
+	runs  int
+	total storage.GCStats
+}
+
+// GarbageCollectorOpt is a functional option for the GarbageCollector.
+type GarbageCollectorOpt func(*GarbageCollector)
+
+// WithBlobCollection is removing the blobs not referenced by any archived site of sites. Blobs put within
+// the grace period are kept, as the sites referencing them might not be archived yet.
+func WithBlobCollection(blobs storage.BlobStorage, sites storage.BlobReferrer, grace time.Duration) GarbageCollectorOpt {
+	return func(gc *GarbageCollector) {
+		gc.blobs = blobs
+		gc.sites = sites
+		gc.grace = grace
+	}
+}
+
+// NewGarbageCollector is returning a new GarbageCollector cleaning up the given storage in the given interval.
+func NewGarbageCollector(storage storage.GarbageCollector, interval time.Duration, logger *slog.Logger,
+	opts ...GarbageCollectorOpt) *GarbageCollector {
+	gc := &GarbageCollector{storage: storage, interval: interval, logger: logger}
+	for _, opt := range opts {
+		opt(gc)
+	}
+	return gc
+}
+
+// Start is collecting garbage in regular intervals, starting after the first interval. It can be
+// stopped by canceling the given context. Failed runs are logged and retried in the next interval.
+func (gc *GarbageCollector) Start(ctx context.Context) error {
+	gc.logger.Info("Garbage collector started", "interval", gc.interval)
+
+	ticker := time.NewTicker(gc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// the error is logged by Collect already
+			_, _ = gc.Collect(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Collect is running one garbage collection and returning what was reclaimed. Blobs are collected
+// after the sites, so that the blobs of the removed sites are reclaimed in the same run.
+func (gc *GarbageCollector) Collect(ctx context.Context) (storage.GCStats, error) {
+	stats, err := gc.storage.CollectGarbage(ctx)
+	if err != nil {
+		gc.logger.Error("garbage collection failed", err)
+		return stats, err
+	}
+
+	// the orphans removed before a failure of the blob collection are counted anyway
+	var blobErr error
+	if gc.blobs != nil {
+		stats.Blobs, blobErr = storage.CollectBlobs(ctx, gc.blobs, gc.sites, time.Now().Add(-gc.grace))
+	}
+
+	gc.mu.Lock()
+	gc.runs++
+	gc.total.Subscriptions += stats.Subscriptions
+	gc.total.Sites += stats.Sites
+	gc.total.Blobs += stats.Blobs
+	runs, total := gc.runs, gc.total
+	gc.mu.Unlock()
+
+	gc.logger.Info("garbage collected", slog.Int("subscriptions", stats.Subscriptions), slog.Int("sites", stats.Sites),
+		slog.Int("blobs", stats.Blobs), slog.Int("runs", runs), slog.Int("total_subscriptions", total.Subscriptions),
+		slog.Int("total_sites", total.Sites), slog.Int("total_blobs", total.Blobs))
+	if blobErr != nil {
+		gc.logger.Error("blob garbage collection failed", blobErr)
+		return stats, blobErr
+	}
+	return stats, nil
+}
+
+// Stats is returning the number of runs and the total of what was reclaimed since the start.
+func (gc *GarbageCollector) Stats() (runs int, total storage.GCStats) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	return gc.runs, gc.total
+}
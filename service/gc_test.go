@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+// gcStub is a GarbageCollector storage returning the given results of consecutive runs.
+type gcStub struct {
+	results []storage.GCStats
+	err     error
+}
+
+func (s *gcStub) CollectGarbage(ctx context.Context) (storage.GCStats, error) {
+	if s.err != nil {
+		return storage.GCStats{}, s.err
+	}
+	stats := s.results[0]
+	s.results = s.results[1:]
+	return stats, nil
+}
+
+func TestGarbageCollector_Collect(t *testing.T) {
+	ctx := context.Background()
+	stub := &gcStub{results: []storage.GCStats{{Subscriptions: 1, Sites: 2}, {Sites: 3}}}
+	gc := NewGarbageCollector(stub, time.Hour, slog.Default())
+
+	for i, want := range stub.results {
+		stats, err := gc.Collect(ctx)
+		if err != nil {
+			t.Fatalf("run %d: Collect() failed: %v", i, err)
+		}
+		if stats != want {
+			t.Errorf("run %d: Expected stats %+v, got %+v", i, want, stats)
+		}
+	}
+
+	// failed runs are not counted
+	stub.err = errors.New("connection lost")
+	if _, err := gc.Collect(ctx); err == nil {
+		t.Errorf("Expected Collect() to fail")
+	}
+
+	runs, total := gc.Stats()
+	if want := (storage.GCStats{Subscriptions: 1, Sites: 5}); runs != 2 || total != want {
+		t.Errorf("Expected 2 runs with total %+v, got %d runs with total %+v", want, runs, total)
+	}
+}
+
+func TestGarbageCollector_Collect_Blobs(t *testing.T) {
+	ctx := context.Background()
+	sub1 := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example", Interval: time.Hour}
+	mem := memory.NewStorage(slog.Default())
+	blobs := blobMap{}
+	archive := NewSiteArchive(mem, WithBlobStorage(blobs))
+	gc := NewGarbageCollector(mem, time.Hour, slog.Default(), WithBlobCollection(blobs, mem, time.Hour))
+
+	if err := mem.AddSubscriber(ctx, &storage.Subscriber{Email: "email1"}); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+	for _, sub := range []*htracker.Subscription{sub1, sub2} {
+		if err := mem.AddSubscription(ctx, "email1", sub); err != nil {
+			t.Fatalf("Setup: failed to add subscription: %v", err)
+		}
+		content := []byte("This is " + sub.URL)
+		site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: content, Checksum: Checksum(content),
+			State: htracker.SiteStateOK}
+		if _, err := archive.Update(ctx, site); err != nil {
+			t.Fatalf("Setup: archivesvc.Update() failed: %v", err)
+		}
+	}
+	// the blob of a site being archived is not referenced yet
+	pending := []byte("This is pending")
+	if err := blobs.Put(ctx, Checksum(pending), pending); err != nil {
+		t.Fatalf("Setup: Put() failed: %v", err)
+	}
+	if err := mem.RemoveSubscription(ctx, "email1", sub2); err != nil {
+		t.Fatalf("Setup: failed to remove subscription: %v", err)
+	}
+
+	// all blobs are within the grace period
+	stats, err := gc.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect() failed: %v", err)
+	}
+	if stats.Blobs != 0 || len(blobs) != 3 {
+		t.Errorf("Expected no blobs collected within the grace period, got %d and %d left", stats.Blobs, len(blobs))
+	}
+
+	for _, entry := range blobs {
+		entry.lastPut = time.Now().Add(-2 * time.Hour)
+	}
+	stats, err = gc.Collect(ctx)
+	if err != nil {
+		t.Fatalf("Collect() failed: %v", err)
+	}
+	if stats.Blobs != 2 {
+		t.Errorf("Expected 2 unreferenced blobs collected, got %d", stats.Blobs)
+	}
+	if _, err := archive.Get(ctx, sub1); err != nil {
+		t.Errorf("Expected content of subscribed site to be kept, got %v", err)
+	}
+
+	if _, total := gc.Stats(); total.Blobs != 2 {
+		t.Errorf("Expected total of 2 blobs, got %d", total.Blobs)
+	}
+}
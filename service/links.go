@@ -0,0 +1,45 @@
+package service
+
+import (
+	"strings"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// DiffLinks is comparing the links of two scrapes of a site as sets, given the content of the scrapes
+// with one url per line. The changes are in the order of the content.
+func DiffLinks(content1, content2 []byte) *htracker.LinkChanges {
+	links1, links2 := splitLinks(content1), splitLinks(content2)
+
+	old := make(map[string]bool, len(links1))
+	for _, link := range links1 {
+		old[link] = true
+	}
+
+	changes := &htracker.LinkChanges{}
+	current := make(map[string]bool, len(links2))
+	for _, link := range links2 {
+		current[link] = true
+		if !old[link] {
+			changes.Added = append(changes.Added, link)
+		}
+	}
+	for _, link := range links1 {
+		if !current[link] {
+			changes.Removed = append(changes.Removed, link)
+		}
+	}
+
+	return changes
+}
+
+// splitLinks is returning the non-empty lines of content.
+func splitLinks(content []byte) []string {
+	var links []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			links = append(links, line)
+		}
+	}
+	return links
+}
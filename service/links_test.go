@@ -0,0 +1,38 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestDiffLinks(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, new   string
+		want       *htracker.LinkChanges
+		wantString string
+	}{
+		{name: "unchanged", old: "http://site.example/1\nhttp://site.example/2", new: "http://site.example/1\nhttp://site.example/2",
+			want: &htracker.LinkChanges{}},
+		{name: "first scrape", old: "", new: "http://site.example/1",
+			want:       &htracker.LinkChanges{Added: []string{"http://site.example/1"}},
+			wantString: "1 added link:\n+ http://site.example/1\n"},
+		{name: "changes", old: "http://site.example/1\nhttp://site.example/2", new: "http://site.example/2\nhttp://site.example/3\nhttp://site.example/4\n",
+			want:       &htracker.LinkChanges{Added: []string{"http://site.example/3", "http://site.example/4"}, Removed: []string{"http://site.example/1"}},
+			wantString: "2 added links:\n+ http://site.example/3\n+ http://site.example/4\n\n1 removed link:\n- http://site.example/1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffLinks([]byte(tt.old), []byte(tt.new))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffLinks() = %+v, want %+v", got, tt.want)
+			}
+			if want, got := tt.wantString, got.String(); want != got {
+				t.Errorf("Expected changes %q, got %q", want, got)
+			}
+		})
+	}
+}
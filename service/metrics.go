@@ -0,0 +1,93 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// MeasureChanges is returning the metrics of the changes of d between the archived and the current
+// content. Diffs with a summary are counted as completely changed content, as the changes are unknown.
+func MeasureChanges(d htracker.Diff, archived, current []byte) *htracker.ChangeMetrics {
+	m := &htracker.ChangeMetrics{}
+	oldChars, newChars := utf8.RuneCount(archived), utf8.RuneCount(current)
+
+	summarized := false
+	for _, op := range d {
+		summarized = summarized || op.Op == htracker.DiffSummary
+	}
+	if summarized {
+		m.InsertedChars, m.DeletedChars = newChars, oldChars
+		m.InsertedLines, m.DeletedLines = len(splitLines(string(current))), len(splitLines(string(archived)))
+	} else {
+		countChanges(d, m)
+	}
+
+	switch {
+	case oldChars > 0:
+		m.Changed = math.Min(100, 100*float64(maxInt(m.InsertedChars, m.DeletedChars))/float64(oldChars))
+	case m.InsertedChars > 0:
+		m.Changed = 100
+	}
+
+	m.Similarity = 1
+	if total := oldChars + newChars; total > 0 {
+		m.Similarity = math.Max(0, 1-float64(m.InsertedChars+m.DeletedChars)/float64(total))
+	}
+
+	return m
+}
+
+// countChanges is counting the inserted and deleted characters and lines of d. A line is counted
+// once, no matter how many changes it has, a changed line break is counted for its line.
+func countChanges(d htracker.Diff, m *htracker.ChangeMetrics) {
+	oldLine, newLine := 0, 0
+	lastDeleted, lastInserted := -1, -1
+	for _, op := range d {
+		parts := strings.Split(op.Text, "\n")
+		for i, part := range parts {
+			lineBreak := i < len(parts)-1
+			changed := part != "" || lineBreak
+
+			switch op.Op {
+			case htracker.DiffInsert:
+				m.InsertedChars += utf8.RuneCountInString(part)
+				if changed && lastInserted != newLine {
+					m.InsertedLines++
+					lastInserted = newLine
+				}
+			case htracker.DiffDelete:
+				m.DeletedChars += utf8.RuneCountInString(part)
+				if changed && lastDeleted != oldLine {
+					m.DeletedLines++
+					lastDeleted = oldLine
+				}
+			}
+			if !lineBreak {
+				continue
+			}
+
+			switch op.Op {
+			case htracker.DiffInsert:
+				m.InsertedChars++
+				newLine++
+			case htracker.DiffDelete:
+				m.DeletedChars++
+				oldLine++
+			default:
+				oldLine++
+				newLine++
+			}
+		}
+	}
+}
+
+// maxInt is returning the bigger one of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
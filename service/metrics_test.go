@@ -0,0 +1,68 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestMeasureChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     htracker.Diff
+		archived string
+		current  string
+		want     *htracker.ChangeMetrics
+	}{
+		{
+			name:     "single char",
+			diff:     DiffText("fees: 10 EUR\nopen: Mon\n", "fees: 12 EUR\nopen: Mon\n"),
+			archived: "fees: 10 EUR\nopen: Mon\n",
+			current:  "fees: 12 EUR\nopen: Mon\n",
+			want: &htracker.ChangeMetrics{InsertedChars: 1, DeletedChars: 1, InsertedLines: 1, DeletedLines: 1, Changed: 100.0 / 23,
+				Similarity: 1 - 2.0/46},
+		},
+		{
+			name:     "inserted line",
+			diff:     DefaultTextDiffer.Diff("a\nb\n", "a\nx\nb\n", htracker.DiffModeLine),
+			archived: "a\nb\n",
+			current:  "a\nx\nb\n",
+			want:     &htracker.ChangeMetrics{InsertedChars: 2, InsertedLines: 1, Changed: 50, Similarity: 1 - 2.0/10},
+		},
+		{
+			name:     "rewritten",
+			diff:     DefaultTextDiffer.Diff("ab\n", "cdef\ngh\n", htracker.DiffModeLine),
+			archived: "ab\n",
+			current:  "cdef\ngh\n",
+			want:     &htracker.ChangeMetrics{InsertedChars: 8, DeletedChars: 3, InsertedLines: 2, DeletedLines: 1, Changed: 100},
+		},
+		{
+			name:     "unicode",
+			diff:     DiffText("grün", "grün!"),
+			archived: "grün",
+			current:  "grün!",
+			want:     &htracker.ChangeMetrics{InsertedChars: 1, InsertedLines: 1, Changed: 25, Similarity: 1 - 1.0/9},
+		},
+		{
+			name:    "first content",
+			diff:    htracker.Diff{{Op: htracker.DiffInsert, Text: "a"}},
+			current: "a",
+			want:    &htracker.ChangeMetrics{InsertedChars: 1, InsertedLines: 1, Changed: 100},
+		},
+		{
+			name:     "summary",
+			diff:     diffSummary("a\nb\n", "c\n", "testing"),
+			archived: "a\nb\n",
+			current:  "c\n",
+			want:     &htracker.ChangeMetrics{InsertedChars: 2, DeletedChars: 4, InsertedLines: 1, DeletedLines: 2, Changed: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MeasureChanges(tt.diff, []byte(tt.archived), []byte(tt.current)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MeasureChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+
+	"gitlab.com/henri.philipps/htracker"
+	"golang.org/x/text/unicode/norm"
+)
+
+// bom is the UTF-8 encoded byte order mark.
+var bom = []byte("\xef\xbb\xbf")
+
+// Normalize is returning the content normalized according to mode, see htracker.NormalizeDefault
+// and the other modes. The content must be UTF-8 encoded. Unknown modes are treated as the default.
+func Normalize(content []byte, mode string) []byte {
+	if mode == htracker.NormalizeNone {
+		return content
+	}
+
+	content = bytes.TrimPrefix(content, bom)
+	content = norm.NFC.Bytes(content)
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	content = bytes.ReplaceAll(content, []byte("\r"), []byte("\n"))
+
+	if mode != htracker.NormalizeWhitespace {
+		return content
+	}
+
+	lines := strings.Split(string(content), "\n")
+	collapsed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		// strings.Fields is splitting at unicode whitespace, including non-breaking spaces
+		if fields := strings.Fields(line); len(fields) > 0 {
+			collapsed = append(collapsed, strings.Join(fields, " "))
+		}
+	}
+
+	return []byte(strings.Join(collapsed, "\n"))
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"testing"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mode    string
+		want    string
+	}{
+		{name: "bom", content: "\xef\xbb\xbfHello", want: "Hello"},
+		{name: "line endings", content: "a\r\nb\rc\n", want: "a\nb\nc\n"},
+		// "é" as e followed by a combining acute accent
+		{name: "nfc", content: "Cafe\u0301", want: "Caf\u00e9"},
+		{name: "whitespace kept", content: " a  b \n\n c", want: " a  b \n\n c"},
+		{name: "whitespace collapsed", content: " a  b \r\n\n\t c\n", mode: htracker.NormalizeWhitespace, want: "a b\nc"},
+		{name: "none", content: "\xef\xbb\xbfa\r\n", mode: htracker.NormalizeNone, want: "\xef\xbb\xbfa\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(Normalize([]byte(tt.content), tt.mode)); got != tt.want {
+				t.Errorf("Normalize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// Pruner is removing the archived versions of sites not kept by their retention policy in regular
+// intervals, keeping count of what was removed.
+type Pruner struct {
+	storage  storage.VersionStorage
+	policy   htracker.RetentionPolicy
+	interval time.Duration
+	logger   *slog.Logger
+
+	// mu is guarding the totals below
+	mu    sync.Mutex
+	runs  int
+	total int
+}
+
+// NewPruner is returning a new Pruner pruning the versions of the given storage in the given interval.
+// The given policy is applied to the sites of subscriptions without retention policy.
+func NewPruner(storage storage.VersionStorage, policy htracker.RetentionPolicy, interval time.Duration, logger *slog.Logger) *Pruner {
+	return &Pruner{storage: storage, policy: policy, interval: interval, logger: logger}
+}
+
+// Start is pruning in regular intervals, starting after the first interval. It can be stopped by
+// canceling the given context. Failed runs are logged and retried in the next interval.
+func (p *Pruner) Start(ctx context.Context) error {
+	p.logger.Info("Pruner started", "interval", p.interval, "policy", p.policy.String())
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// the error is logged by Prune already
+			_, _ = p.Prune(ctx)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Prune is running one pruning and returning the number of removed versions.
+func (p *Pruner) Prune(ctx context.Context) (int, error) {
+	pruned, err := p.storage.PruneVersions(ctx, p.policy)
+	if err != nil {
+		p.logger.Error("pruning versions failed", err, slog.Int("versions", pruned))
+		return pruned, err
+	}
+
+	p.mu.Lock()
+	p.runs++
+	p.total += pruned
+	runs, total := p.runs, p.total
+	p.mu.Unlock()
+
+	p.logger.Info("versions pruned", slog.Int("versions", pruned), slog.Int("runs", runs), slog.Int("total_versions", total))
+	return pruned, nil
+}
+
+// Stats is returning the number of runs and the total of removed versions since the start.
+func (p *Pruner) Stats() (runs int, total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.runs, p.total
+}
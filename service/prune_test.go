@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func TestPruner_Prune(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site.example", Interval: time.Hour}
+	db := memory.NewSiteStorage(slog.Default())
+	archive := NewSiteArchive(db, WithVersionHistory(db))
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		content := []byte{byte('a' + i)}
+		site := &htracker.Site{Subscription: sub, LastChecked: start.Add(time.Duration(i) * time.Minute), Content: content,
+			Checksum: Checksum(content), State: htracker.SiteStateOK}
+		if _, err := archive.Update(ctx, site); err != nil {
+			t.Fatalf("Setup: archivesvc.Update() failed: %v", err)
+		}
+	}
+
+	pruner := NewPruner(db, htracker.RetentionPolicy{KeepLast: 2}, time.Hour, slog.Default())
+	for i, want := range []int{3, 0} {
+		pruned, err := pruner.Prune(ctx)
+		if err != nil {
+			t.Fatalf("run %d: Prune() failed: %v", i, err)
+		}
+		if pruned != want {
+			t.Errorf("run %d: Expected %d pruned versions, got %d", i, want, pruned)
+		}
+	}
+	if runs, total := pruner.Stats(); runs != 2 || total != 3 {
+		t.Errorf("Expected 2 runs pruning 3 versions, got %d runs pruning %d versions", runs, total)
+	}
+
+	versions, err := archive.GetVersions(ctx, sub)
+	if err != nil {
+		t.Fatalf("archivesvc.GetVersions() failed: %v", err)
+	}
+	if len(versions) != 2 || string(versions[0].Content) != "e" || string(versions[1].Content) != "d" {
+		t.Errorf("Expected the latest 2 versions to be kept, got %v", versions)
+	}
+}
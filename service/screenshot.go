@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// Images of a screenshot, see ScreenshotImage.
+const (
+	// ScreenshotBefore is the screenshot before the latest visual change.
+	ScreenshotBefore = "before"
+
+	// ScreenshotAfter is the latest screenshot.
+	ScreenshotAfter = "after"
+
+	// ScreenshotDiff is the image highlighting the pixels changed by the latest visual change.
+	ScreenshotDiff = "diff"
+)
+
+// ScreenshotImage is returning the PNG image of the given kind of the screenshot of site, see ScreenshotBefore and
+// the other images. An error wrapping htracker.ErrNotExist is returned if the site has no such image,
+// an error wrapping htracker.ErrInvalid for unknown images.
+func ScreenshotImage(site *htracker.Site, kind string) ([]byte, error) {
+	switch kind {
+	case ScreenshotBefore, ScreenshotAfter, ScreenshotDiff:
+	default:
+		return nil, fmt.Errorf("screenshot image %s not supported, expected one of %s, %s, %s: %w", kind,
+			ScreenshotBefore, ScreenshotAfter, ScreenshotDiff, htracker.ErrInvalid)
+	}
+
+	if site.Screenshot == nil {
+		return nil, fmt.Errorf("site %s has no screenshot: %w", site.Subscription.URL, htracker.ErrNotExist)
+	}
+	if kind == ScreenshotAfter {
+		return site.Screenshot.Image, nil
+	}
+	if site.Screenshot.Previous == nil {
+		return nil, fmt.Errorf("site %s didn't change visually yet: %w", site.Subscription.URL, htracker.ErrNotExist)
+	}
+	if kind == ScreenshotBefore {
+		return site.Screenshot.Previous, nil
+	}
+	return DiffImage(site.Screenshot.Previous, site.Screenshot.Image)
+}
+
+// PixelDiff is returning the percentage of the pixels differing between the PNG images before and
+// after, between 0 and 100. Images of different size are compared on the area of the bigger one,
+// pixels outside of the smaller image are counted as changed.
+func PixelDiff(before, after []byte) (float64, error) {
+	img1, img2, err := decodeImages(before, after)
+	if err != nil {
+		return 0, err
+	}
+
+	bounds := diffBounds(img1, img2)
+	total := bounds.Dx() * bounds.Dy()
+	if total == 0 {
+		return 0, nil
+	}
+
+	changed := 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if pixelChanged(img1, img2, x, y) {
+				changed++
+			}
+		}
+	}
+
+	return 100 * float64(changed) / float64(total), nil
+}
+
+// DiffImage is returning a PNG image highlighting the differences between the PNG images before and
+// after. Changed pixels are red, unchanged pixels are shown pale gray.
+func DiffImage(before, after []byte) ([]byte, error) {
+	img1, img2, err := decodeImages(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := diffBounds(img1, img2)
+	diff := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if pixelChanged(img1, img2, x, y) {
+				diff.Set(x, y, color.RGBA{R: 0xff, A: 0xff})
+				continue
+			}
+			gray := color.GrayModel.Convert(img2.At(x, y)).(color.Gray)
+			diff.Set(x, y, color.Gray{Y: 0xff - (0xff-gray.Y)/3})
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, diff); err != nil {
+		return nil, fmt.Errorf("failed to encode diff image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeImages is decoding the PNG images before and after.
+func decodeImages(before, after []byte) (image.Image, image.Image, error) {
+	img1, err := png.Decode(bytes.NewReader(before))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode previous screenshot: %w", err)
+	}
+	img2, err := png.Decode(bytes.NewReader(after))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode screenshot: %w", err)
+	}
+	return img1, img2, nil
+}
+
+// diffBounds is returning the area covering both images.
+func diffBounds(img1, img2 image.Image) image.Rectangle {
+	return img1.Bounds().Union(img2.Bounds())
+}
+
+// pixelChanged is returning whether the pixel at x, y differs between the images, or is part of one image only.
+func pixelChanged(img1, img2 image.Image, x, y int) bool {
+	p := image.Pt(x, y)
+	in1, in2 := p.In(img1.Bounds()), p.In(img2.Bounds())
+	if !in1 || !in2 {
+		return true
+	}
+
+	r1, g1, b1, a1 := img1.At(x, y).RGBA()
+	r2, g2, b2, a2 := img2.At(x, y).RGBA()
+	return r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2
+}
+
+// compareScreenshots is comparing the screenshot of the current site with the archived one and
+// returning whether the site changed visually. In this case the archived image becomes the previous
+// one of the current screenshot. Otherwise the archived screenshot is kept, including the previous
+// image of the latest visual change. Screenshots which can't be decoded are counted as completely changed.
+func compareScreenshots(archived, current *htracker.Site) bool {
+	switch {
+	case !current.Subscription.Screenshot:
+		return false
+	case current.Screenshot == nil:
+		// the site wasn't rendered, e.g. as chrome was unavailable
+		current.Screenshot = archived.Screenshot
+		return false
+	case archived.Screenshot == nil:
+		// the first screenshot has nothing to compare with
+		return false
+	}
+
+	pixelDiff, err := PixelDiff(archived.Screenshot.Image, current.Screenshot.Image)
+	if err != nil {
+		pixelDiff = 100
+	}
+	if pixelDiff == 0 {
+		current.Screenshot = archived.Screenshot
+		return false
+	}
+
+	current.Screenshot.Previous = archived.Screenshot.Image
+	current.Screenshot.PixelDiff = pixelDiff
+	return true
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+// testPNG is returning a white PNG image of the given size with the given black pixels.
+func testPNG(t *testing.T, width, height int, black ...image.Point) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	for _, p := range black {
+		img.Set(p.X, p.Y, color.Black)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("png.Encode() failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestPixelDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []byte
+		after   []byte
+		want    float64
+		wantErr bool
+	}{
+		{name: "equal", before: testPNG(t, 4, 5), after: testPNG(t, 4, 5)},
+		{name: "one pixel", before: testPNG(t, 4, 5), after: testPNG(t, 4, 5, image.Pt(1, 1)), want: 5},
+		{name: "moved pixel", before: testPNG(t, 4, 5, image.Pt(0, 0)), after: testPNG(t, 4, 5, image.Pt(1, 1)), want: 10},
+		{name: "taller", before: testPNG(t, 4, 4), after: testPNG(t, 4, 5), want: 20},
+		{name: "no png", before: []byte("foo"), after: testPNG(t, 4, 5), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PixelDiff(tt.before, tt.after)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PixelDiff() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PixelDiff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffImage(t *testing.T) {
+	data, err := DiffImage(testPNG(t, 2, 2), testPNG(t, 2, 3, image.Pt(1, 0)))
+	if err != nil {
+		t.Fatalf("DiffImage() failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() failed: %v", err)
+	}
+
+	if img.Bounds() != image.Rect(0, 0, 2, 3) {
+		t.Errorf("Expected bounds of the bigger image, got %v", img.Bounds())
+	}
+	red := color.RGBA{R: 0xff, A: 0xff}
+	for _, p := range []image.Point{{1, 0}, {0, 2}, {1, 2}} {
+		if c := color.RGBAModel.Convert(img.At(p.X, p.Y)); c != red {
+			t.Errorf("Expected changed pixel %v to be red, got %v", p, c)
+		}
+	}
+	if c := color.RGBAModel.Convert(img.At(0, 0)); c == red {
+		t.Errorf("Expected unchanged pixel to be gray, got %v", c)
+	}
+}
+
+func TestScreenshotImage(t *testing.T) {
+	before, after := testPNG(t, 2, 2), testPNG(t, 2, 2, image.Pt(0, 0))
+	sub := &htracker.Subscription{URL: "http://site.example", UseChrome: true, Screenshot: true}
+	changed := &htracker.Site{Subscription: sub, Screenshot: &htracker.Screenshot{Image: after, Previous: before, PixelDiff: 25}}
+	unchanged := &htracker.Site{Subscription: sub, Screenshot: &htracker.Screenshot{Image: before}}
+
+	tests := []struct {
+		name    string
+		site    *htracker.Site
+		kind    string
+		want    []byte
+		wantErr error
+	}{
+		{name: "after", site: changed, kind: ScreenshotAfter, want: after},
+		{name: "before", site: changed, kind: ScreenshotBefore, want: before},
+		{name: "no visual change", site: unchanged, kind: ScreenshotBefore, wantErr: htracker.ErrNotExist},
+		{name: "no diff", site: unchanged, kind: ScreenshotDiff, wantErr: htracker.ErrNotExist},
+		{name: "no screenshot", site: &htracker.Site{Subscription: sub}, kind: ScreenshotAfter, wantErr: htracker.ErrNotExist},
+		{name: "unknown image", site: changed, kind: "side-by-side", wantErr: htracker.ErrInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ScreenshotImage(tt.site, tt.kind)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ScreenshotImage() error = %v, want %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("ScreenshotImage() returned unexpected image")
+			}
+		})
+	}
+
+	if _, err := ScreenshotImage(changed, ScreenshotDiff); err != nil {
+		t.Errorf("ScreenshotImage() failed for diff: %v", err)
+	}
+}
+
+func Test_ArchiveService_Update_Screenshot(t *testing.T) {
+	ctx := context.Background()
+	// the min change of the content is not applied to visual changes
+	sub := &htracker.Subscription{URL: "http://site.example", UseChrome: true, Screenshot: true, MinChange: 50, MinPixelChange: 10}
+	svc := NewSiteArchive(memory.NewSiteStorage(slog.Default()))
+	content := []byte("same text")
+
+	shot1, shot2 := testPNG(t, 4, 5), testPNG(t, 4, 5, image.Pt(0, 0))
+	shot3, shot4 := testPNG(t, 4, 5, image.Pt(0, 0), image.Pt(1, 1)), testPNG(t, 4, 5, image.Pt(0, 0), image.Pt(1, 1), image.Pt(2, 2))
+	steps := []struct {
+		name         string
+		screenshot   []byte
+		wantChanged  bool
+		wantArchived []byte
+		wantPrevious []byte
+		wantDiff     float64
+	}{
+		{name: "first screenshot", screenshot: shot1, wantArchived: shot1},
+		{name: "unchanged", screenshot: shot1, wantArchived: shot1},
+		// 1 of 20 pixels is below the min pixel change, the archived screenshot is kept as baseline
+		{name: "small change", screenshot: shot2, wantArchived: shot1},
+		{name: "not rendered", wantArchived: shot1},
+		// 1 pixel changed since the last scrape, but 2 since the baseline
+		{name: "visual change", screenshot: shot3, wantChanged: true, wantArchived: shot3, wantPrevious: shot1, wantDiff: 10},
+		{name: "small change after visual change", screenshot: shot4, wantArchived: shot3, wantPrevious: shot1, wantDiff: 10},
+	}
+
+	for _, step := range steps {
+		site := &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: content, Checksum: Checksum(content),
+			State: htracker.SiteStateOK}
+		if step.screenshot != nil {
+			site.Screenshot = &htracker.Screenshot{Image: step.screenshot}
+		}
+		diff, err := svc.Update(ctx, site)
+		if err != nil {
+			t.Fatalf("%s: archivesvc.Update() failed: %v", step.name, err)
+		}
+		if !diff.Empty() != step.wantChanged {
+			t.Errorf("%s: Expected changed %v, got diff %v", step.name, step.wantChanged, diff)
+		}
+
+		archived, err := svc.Get(ctx, sub)
+		if err != nil {
+			t.Fatalf("%s: archivesvc.Get() failed: %v", step.name, err)
+		}
+		if archived.Screenshot == nil {
+			t.Fatalf("%s: Expected archived screenshot", step.name)
+		}
+		if !bytes.Equal(archived.Screenshot.Image, step.wantArchived) {
+			t.Errorf("%s: Expected another archived screenshot", step.name)
+		}
+		if !bytes.Equal(archived.Screenshot.Previous, step.wantPrevious) || archived.Screenshot.PixelDiff != step.wantDiff {
+			t.Errorf("%s: Expected pixel diff %v to the previous screenshot, got %v", step.name, step.wantDiff, archived.Screenshot.PixelDiff)
+		}
+	}
+}
@@ -21,6 +21,11 @@ type SubscriptionSvc interface {
 	DeleteSubscriber(ctx context.Context, email string) error
 }
 
+// RobotsChecker is checking if a url may be scraped according to its robots.txt.
+type RobotsChecker interface {
+	Allowed(ctx context.Context, url string) (bool, error)
+}
+
 // Subscriber is describing a user holding subscriptions to sites.
 type Subscriber struct {
 	Email             string
@@ -28,12 +33,38 @@ type Subscriber struct {
 	SubscriptionLimit int
 }
 
+// RedactSubscriptions is returning redacted copies of the subscriptions, see htracker.Subscription.Redacted.
+func RedactSubscriptions(subscriptions []*htracker.Subscription) []*htracker.Subscription {
+	if subscriptions == nil {
+		return nil
+	}
+	redacted := make([]*htracker.Subscription, len(subscriptions))
+	for i, s := range subscriptions {
+		redacted[i] = s.Redacted()
+	}
+	return redacted
+}
+
+// RedactSubscribers is returning copies of the subscribers with redacted subscriptions, see
+// htracker.Subscription.Redacted.
+func RedactSubscribers(subscribers []*Subscriber) []*Subscriber {
+	if subscribers == nil {
+		return nil
+	}
+	redacted := make([]*Subscriber, len(subscribers))
+	for i, s := range subscribers {
+		redacted[i] = &Subscriber{Email: s.Email, Subscriptions: RedactSubscriptions(s.Subscriptions), SubscriptionLimit: s.SubscriptionLimit}
+	}
+	return redacted
+}
+
 // subscriptionSvc is implementing the SubscriptionSvc interface.
 type subscriptionSvc struct {
 	storage           storage.SubscriptionStorage
 	logger            slog.Logger
 	subscriptionLimit int
 	subscriberLimit   int
+	robots            RobotsChecker
 }
 
 // compile time check of interface implementation.
@@ -41,7 +72,7 @@ var _ SubscriptionSvc = &subscriptionSvc{}
 
 // NewSubscriptionSvc is returning a new SubscriptionService using the given storage backend.
 func NewSubscriptionSvc(storage storage.SubscriptionStorage, opts ...SubscriptionSvcOpt) *subscriptionSvc {
-	svc := &subscriptionSvc{storage: storage, subscriptionLimit: 100, subscriberLimit: 100}
+	svc := &subscriptionSvc{storage: storage, logger: *slog.Default(), subscriptionLimit: 100, subscriberLimit: 100}
 	for _, opt := range opts {
 		opt(svc)
 	}
@@ -72,6 +103,13 @@ func WithSubscriberLimit(limit int) SubscriptionSvcOpt {
 	}
 }
 
+// WithRobotsChecker is rejecting subscriptions to urls disallowed by their robots.txt.
+func WithRobotsChecker(robots RobotsChecker) SubscriptionSvcOpt {
+	return func(svc *subscriptionSvc) {
+		svc.robots = robots
+	}
+}
+
 // AddSubscriber is adding a new subscriber.
 // A SubscriptionLimit of -1 means unlimited subscriptions.
 func (svc *subscriptionSvc) AddSubscriber(ctx context.Context, subscriber *Subscriber) error {
@@ -99,7 +137,13 @@ func (svc *subscriptionSvc) AddSubscriber(ctx context.Context, subscriber *Subsc
 
 // Subscribe is adding a subscription for the given email and will return
 // an error if the subscription already exists or we hit the subscription limit.
+// Invalid subscriptions are rejected with ErrInvalid.
+// If a RobotsChecker is configured, urls disallowed by robots.txt are rejected with ErrRobotsDisallowed.
 func (svc *subscriptionSvc) Subscribe(ctx context.Context, email string, subscription *htracker.Subscription) error {
+	if err := subscription.Validate(); err != nil {
+		return fmt.Errorf("can't subscribe to %s: %w", subscription.URL, err)
+	}
+
 	subscriber, err := svc.storage.GetSubscriber(ctx, email)
 	if err != nil {
 		return fmt.Errorf("storage.GetSubscriber(): %w", err)
@@ -109,6 +153,17 @@ func (svc *subscriptionSvc) Subscribe(ctx context.Context, email string, subscri
 		return fmt.Errorf("can't add new subscription - reached %d subscriptions: %w", subscriber.SubscriptionLimit, htracker.ErrLimit)
 	}
 
+	if svc.robots != nil {
+		allowed, err := svc.robots.Allowed(ctx, subscription.URL)
+		switch {
+		case err != nil:
+			// robots.txt not being available is not preventing a subscription
+			svc.logger.Warn("failed to check robots.txt", "url", subscription.URL, "error", err)
+		case !allowed:
+			return fmt.Errorf("can't subscribe to %s: %w", subscription.URL, htracker.ErrRobotsDisallowed)
+		}
+	}
+
 	err = svc.storage.AddSubscription(ctx, email, subscription)
 	if err != nil {
 		return fmt.Errorf("storage.AddSubscription(): %w", err)
@@ -136,7 +191,7 @@ func (svc *subscriptionSvc) GetSubscribersBySubscription(ctx context.Context, su
 
 	// TODO: should we avoid this transformation? factor out Subscriber type?
 	for _, s := range storSubscribers {
-		subscribers = append(subscribers, &Subscriber{Email: s.Email, Subscriptions: s.Subscriptions})
+		subscribers = append(subscribers, &Subscriber{Email: s.Email, Subscriptions: s.Subscriptions, SubscriptionLimit: s.SubscriptionLimit})
 	}
 
 	return subscribers, nil
@@ -151,7 +206,7 @@ func (svc *subscriptionSvc) GetSubscribers(ctx context.Context) (subscribers []*
 
 	// TODO: should we avoid this transformation? factor out Subscriber type?
 	for _, s := range storSubscribers {
-		subscribers = append(subscribers, &Subscriber{Email: s.Email, Subscriptions: s.Subscriptions})
+		subscribers = append(subscribers, &Subscriber{Email: s.Email, Subscriptions: s.Subscriptions, SubscriptionLimit: s.SubscriptionLimit})
 	}
 
 	return subscribers, nil
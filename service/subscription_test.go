@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -99,6 +100,90 @@ func TestSubscriptionSvc_Subscribe(t *testing.T) {
 	}
 }
 
+// robotsCheckerFunc is a RobotsChecker for testing.
+type robotsCheckerFunc func(url string) (bool, error)
+
+func (f robotsCheckerFunc) Allowed(ctx context.Context, url string) (bool, error) {
+	return f(url)
+}
+
+func TestSubscriptionSvc_Subscribe_Robots(t *testing.T) {
+	ctx := context.Background()
+	email := "email1@foo.test"
+
+	robots := robotsCheckerFunc(func(url string) (bool, error) {
+		switch url {
+		case "http://site1.example/disallowed":
+			return false, nil
+		case "http://unreachable.example/":
+			return true, errors.New("connection refused")
+		}
+		return true, nil
+	})
+
+	svc := NewSubscriptionSvc(memory.NewSubscriptionStorage(slog.Default()), WithRobotsChecker(robots))
+	if err := svc.AddSubscriber(ctx, &Subscriber{Email: email}); err != nil {
+		t.Fatalf("svc.AddSubscriber() failed: %v", err)
+	}
+
+	tests := []struct {
+		url     string
+		wantErr error
+	}{
+		{url: "http://site1.example/allowed"},
+		{url: "http://site1.example/disallowed", wantErr: htracker.ErrRobotsDisallowed},
+		{url: "http://unreachable.example/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := svc.Subscribe(ctx, email, &htracker.Subscription{URL: tt.url})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("svc.Subscribe() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscriptionSvc_Subscribe_Request(t *testing.T) {
+	ctx := context.Background()
+	email := "email1@foo.test"
+
+	svc := NewSubscriptionSvc(memory.NewSubscriptionStorage(slog.Default()))
+	if err := svc.AddSubscriber(ctx, &Subscriber{Email: email}); err != nil {
+		t.Fatalf("svc.AddSubscriber() failed: %v", err)
+	}
+
+	url := "http://site1.example/search"
+	tests := []struct {
+		name         string
+		subscription *htracker.Subscription
+		wantErr      error
+	}{
+		{name: "get", subscription: &htracker.Subscription{URL: url}},
+		{name: "post", subscription: &htracker.Subscription{URL: url, Method: "POST", Body: "q=foo"}},
+		{name: "post other body", subscription: &htracker.Subscription{URL: url, Method: "POST", Body: "q=bar"}},
+		{name: "post again", subscription: &htracker.Subscription{URL: url, Method: "post", Body: "q=foo"}, wantErr: htracker.ErrAlreadyExists},
+		{name: "get with body", subscription: &htracker.Subscription{URL: url, Body: "q=foo"}, wantErr: htracker.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.Subscribe(ctx, email, tt.subscription); !errors.Is(err, tt.wantErr) {
+				t.Errorf("svc.Subscribe() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	subscriptions, err := svc.GetSubscriptionsBySubscriber(ctx, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 3, len(subscriptions); want != got {
+		t.Errorf("Expected %d subscriptions, got %d", want, got)
+	}
+}
+
 func TestSubscriptionSvc_Unsubscribe(t *testing.T) {
 	type args struct {
 		email        string
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// Defaults of the budget for diffing the text of a site.
+const (
+	DefaultDiffMaxSize = 1 << 20
+	DefaultDiffTimeout = time.Second
+)
+
+// DefaultTextDiffer is diffing texts within the default budget.
+var DefaultTextDiffer = TextDiffer{MaxSize: DefaultDiffMaxSize, Timeout: DefaultDiffTimeout}
+
+// TextDiffer is diffing texts within a budget, so that huge or very different texts are not blocking
+// the archive. The changes are summarized instead of diffed if the budget is exceeded.
+type TextDiffer struct {
+	// MaxSize is the max size of each text in bytes, 0 means unlimited.
+	MaxSize int
+	// Timeout is the max duration of diffing, 0 means unlimited.
+	Timeout time.Duration
+}
+
+// Diff is comparing the texts in the given mode, see htracker.DiffModeDefault and the other modes. Changes
+// of whitespace only are ignored, as sometimes whitespace seems to be rendered randomly.
+func (td TextDiffer) Diff(str1, str2, mode string) htracker.Diff {
+	if stripStringsBuilder(str1) == stripStringsBuilder(str2) {
+		return nil
+	}
+
+	if td.MaxSize > 0 && (len(str1) > td.MaxSize || len(str2) > td.MaxSize) {
+		return diffSummary(str1, str2, fmt.Sprintf("exceeding the max diff size of %d bytes", td.MaxSize))
+	}
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = td.Timeout
+	start := time.Now()
+
+	var diffs []diffmatchpatch.Diff
+	ok := true
+	switch mode {
+	case htracker.DiffModeLine:
+		diffs, ok = diffTokens(dmp, splitLines(str1), splitLines(str2), maxDiffTokens)
+	case htracker.DiffModeWord:
+		// texts with too many distinct words are diffed by line
+		if diffs, ok = diffTokens(dmp, splitWords(str1), splitWords(str2), maxDiffTokens); !ok {
+			diffs, ok = diffTokens(dmp, splitLines(str1), splitLines(str2), maxDiffTokens)
+		}
+	default:
+		diffs = dmp.DiffCleanupSemantic(dmp.DiffMain(str1, str2, false))
+	}
+	if !ok {
+		return diffSummary(str1, str2, fmt.Sprintf("exceeding the max number of %d distinct lines", maxDiffTokens))
+	}
+
+	// diffmatchpatch is returning a valid, but not minimal diff on timeouts
+	if td.Timeout > 0 && time.Since(start) >= td.Timeout {
+		return diffSummary(str1, str2, fmt.Sprintf("diffing took longer than %s", td.Timeout))
+	}
+
+	return diffOps(diffs)
+}
+
+// diffSummary is returning a Diff summarizing the change of the texts, as they weren't diffed for the given reason.
+func diffSummary(str1, str2, reason string) htracker.Diff {
+	return htracker.Diff{{Op: htracker.DiffSummary, Text: fmt.Sprintf(
+		"The content changed from %d to %d bytes (%d to %d lines), it was not diffed as %s.\n",
+		len(str1), len(str2), len(splitLines(str1)), len(splitLines(str2)), reason)}}
+}
+
+// diffOps is converting the diffs of diffmatchpatch to a Diff.
+func diffOps(diffs []diffmatchpatch.Diff) htracker.Diff {
+	d := make(htracker.Diff, 0, len(diffs))
+	for _, diff := range diffs {
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			d = append(d, htracker.DiffOp{Op: htracker.DiffInsert, Text: diff.Text})
+		case diffmatchpatch.DiffDelete:
+			d = append(d, htracker.DiffOp{Op: htracker.DiffDelete, Text: diff.Text})
+		case diffmatchpatch.DiffEqual:
+			d = append(d, htracker.DiffOp{Op: htracker.DiffEqual, Text: diff.Text})
+		}
+	}
+	return d
+}
+
+// stripStringsBuilder is stripping whitespace from the given string.
+func stripStringsBuilder(str string) string {
+	var builder strings.Builder
+	builder.Grow(len(str))
+	for _, rune := range str {
+		if !unicode.IsSpace(rune) {
+			builder.WriteRune(rune)
+		}
+	}
+	return builder.String()
+}
+
+// splitLines is splitting the text into lines, keeping the line breaks.
+func splitLines(str string) []string {
+	lines := strings.SplitAfter(str, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords is splitting the text into words and the whitespace between them.
+func splitWords(str string) []string {
+	words := []string{}
+	start, space := 0, false
+	for i, r := range str {
+		if i > start && unicode.IsSpace(r) != space {
+			words = append(words, str[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(str) {
+		words = append(words, str[start:])
+	}
+	return words
+}
+
+// maxDiffTokens is the max number of distinct tokens diffed by diffTokens, the number of valid runes
+// without the NUL rune and the surrogates.
+const maxDiffTokens = unicode.MaxRune - 0x800
+
+// diffTokens is comparing two lists of tokens, e.g. lines, by diffing them as strings of runes with one
+// rune per distinct token. The line mode of diffmatchpatch is broken, as it is diffing lists of indexes.
+// It is returning false if the lists are containing more than limit distinct tokens, which must not
+// exceed maxDiffTokens.
+func diffTokens(dmp *diffmatchpatch.DiffMatchPatch, tokens1, tokens2 []string, limit int) ([]diffmatchpatch.Diff, bool) {
+	runes := map[string]rune{}
+	encode := func(list []string) ([]rune, bool) {
+		encoded := make([]rune, 0, len(list))
+		for _, token := range list {
+			r, ok := runes[token]
+			if !ok {
+				if len(runes) >= limit {
+					return nil, false
+				}
+				r = rune(len(runes) + 1)
+				// surrogates are no valid runes
+				if r >= 0xd800 {
+					r += 0x800
+				}
+				runes[token] = r
+			}
+			encoded = append(encoded, r)
+		}
+		return encoded, true
+	}
+	runes1, ok1 := encode(tokens1)
+	runes2, ok2 := encode(tokens2)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	index := make(map[rune]string, len(runes))
+	for token, r := range runes {
+		index[r] = token
+	}
+
+	diffs := dmp.DiffMainRunes(runes1, runes2, false)
+	for i, diff := range diffs {
+		b := strings.Builder{}
+		for _, r := range diff.Text {
+			b.WriteString(index[r])
+		}
+		diffs[i].Text = b.String()
+	}
+	return diffs, true
+}
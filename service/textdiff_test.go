@@ -0,0 +1,95 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestTextDiffer_Diff(t *testing.T) {
+	old := "Fees\nThe fee is 10 EUR per month.\n"
+	current := "Fees\nThe fee is 12 EUR per year.\n"
+
+	tests := []struct {
+		name   string
+		differ TextDiffer
+		str1   string
+		str2   string
+		mode   string
+		want   htracker.Diff
+	}{
+		{name: "whitespace only", differ: DefaultTextDiffer, str1: old, str2: strings.ReplaceAll(old, " ", "\t")},
+		{name: "characters", differ: DefaultTextDiffer, str1: old, str2: current, want: htracker.Diff{
+			{Op: htracker.DiffEqual, Text: "Fees\nThe fee is 1"}, {Op: htracker.DiffDelete, Text: "0"}, {Op: htracker.DiffInsert, Text: "2"},
+			{Op: htracker.DiffEqual, Text: " EUR per "}, {Op: htracker.DiffDelete, Text: "month"}, {Op: htracker.DiffInsert, Text: "year"},
+			{Op: htracker.DiffEqual, Text: ".\n"}}},
+		{name: "lines", differ: DefaultTextDiffer, str1: old, str2: current, mode: htracker.DiffModeLine, want: htracker.Diff{
+			{Op: htracker.DiffEqual, Text: "Fees\n"}, {Op: htracker.DiffDelete, Text: "The fee is 10 EUR per month.\n"},
+			{Op: htracker.DiffInsert, Text: "The fee is 12 EUR per year.\n"}}},
+		{name: "words", differ: DefaultTextDiffer, str1: old, str2: current, mode: htracker.DiffModeWord, want: htracker.Diff{
+			{Op: htracker.DiffEqual, Text: "Fees\nThe fee is "}, {Op: htracker.DiffDelete, Text: "10"}, {Op: htracker.DiffInsert, Text: "12"},
+			{Op: htracker.DiffEqual, Text: " EUR per "}, {Op: htracker.DiffDelete, Text: "month."}, {Op: htracker.DiffInsert, Text: "year."},
+			{Op: htracker.DiffEqual, Text: "\n"}}},
+		{name: "too big", differ: TextDiffer{MaxSize: 32}, str1: old, str2: current, mode: htracker.DiffModeLine, want: htracker.Diff{
+			{Op: htracker.DiffSummary, Text: "The content changed from 34 to 33 bytes (2 to 2 lines), it was not diffed as exceeding the max diff size of 32 bytes.\n"}}},
+		{name: "unlimited", differ: TextDiffer{}, str1: "a", str2: "b", want: htracker.Diff{
+			{Op: htracker.DiffDelete, Text: "a"}, {Op: htracker.DiffInsert, Text: "b"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.differ.Diff(tt.str1, tt.str2, tt.mode); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextDiffer_Diff_Timeout(t *testing.T) {
+	// texts without common lines are expensive to diff by characters
+	b1, b2 := strings.Builder{}, strings.Builder{}
+	for i := 0; i < 20000; i++ {
+		b1.WriteString(strings.Repeat(string(rune('a'+i%26)), i%7+1) + " ")
+		b2.WriteString(strings.Repeat(string(rune('z'-i%25)), i%5+1) + " ")
+	}
+
+	differ := TextDiffer{Timeout: time.Millisecond}
+	start := time.Now()
+	got := differ.Diff(b1.String(), b2.String(), htracker.DiffModeDefault)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Expected diffing to be interrupted, took %s", d)
+	}
+	if len(got) != 1 || got[0].Op != htracker.DiffSummary {
+		t.Fatalf("Expected a summary, got %d operations", len(got))
+	}
+	if !strings.Contains(got[0].Text, "diffing took longer than 1ms") {
+		t.Errorf("Expected summary to name the timeout, got %q", got[0].Text)
+	}
+}
+
+func Test_splitWords(t *testing.T) {
+	want := []string{"Fees:", " ", "10", " ", "EUR", "\n\n", "per", " ", "month"}
+	if got := splitWords("Fees: 10 EUR\n\nper month"); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitWords() = %q, want %q", got, want)
+	}
+}
+
+func Test_diffTokens_Limit(t *testing.T) {
+	dmp := diffmatchpatch.New()
+	tokens1, tokens2 := []string{"a", "b", "a"}, []string{"a", "c"}
+
+	if _, ok := diffTokens(dmp, tokens1, tokens2, 2); ok {
+		t.Error("Expected 3 distinct tokens to exceed the limit of 2")
+	}
+	diffs, ok := diffTokens(dmp, tokens1, tokens2, 3)
+	if !ok {
+		t.Fatal("Expected 3 distinct tokens to be within the limit of 3")
+	}
+	if got := diffOps(diffs); got.OldText() != "aba" || got.NewText() != "ac" {
+		t.Errorf("Expected the diff of the tokens, got %#v", got)
+	}
+}
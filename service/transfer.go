@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// dumpVersion is the version of the Dump format.
+const dumpVersion = 1
+
+// Dump is holding all subscribers with their subscriptions, for migrating between instances or backends.
+type Dump struct {
+	Version     int
+	Created     time.Time
+	Subscribers []*Subscriber
+}
+
+// ExportSubscribers is creating a Dump of all subscribers of the given SubscriptionSvc. Request options
+// are only included if withRequestOptions is set, as they might contain credentials. Such a dump is
+// containing the credentials in clear text and must be stored as safely as the secret key of the storage.
+func ExportSubscribers(ctx context.Context, svc SubscriptionSvc, withRequestOptions bool) (*Dump, error) {
+	subscribers, err := svc.GetSubscribers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SubscriptionSvc.GetSubscribers(): %w", err)
+	}
+
+	if subscribers == nil {
+		subscribers = []*Subscriber{}
+	}
+	if !withRequestOptions {
+		subscribers = RedactSubscribers(subscribers)
+	}
+
+	return &Dump{Version: dumpVersion, Created: time.Now(), Subscribers: subscribers}, nil
+}
+
+// ImportSubscribers is restoring the subscribers of a Dump with all their subscriptions.
+// Subscribers and subscriptions already existing are skipped, so an import can be repeated.
+func ImportSubscribers(ctx context.Context, svc SubscriptionSvc, dump *Dump) error {
+	if dump.Version != dumpVersion {
+		return fmt.Errorf("dump version %d not supported", dump.Version)
+	}
+
+	for _, subscriber := range dump.Subscribers {
+		err := svc.AddSubscriber(ctx, &Subscriber{Email: subscriber.Email, SubscriptionLimit: subscriber.SubscriptionLimit})
+		if err != nil && !errors.Is(err, htracker.ErrAlreadyExists) {
+			return fmt.Errorf("failed to import subscriber %s: %w", subscriber.Email, err)
+		}
+
+		if err := subscribeAll(ctx, svc, subscriber.Email, subscriber.Subscriptions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subscribeAll is subscribing email to all given subscriptions, skipping existing ones.
+func subscribeAll(ctx context.Context, svc SubscriptionSvc, email string, subscriptions []*htracker.Subscription) error {
+	for _, subscription := range subscriptions {
+		err := svc.Subscribe(ctx, email, subscription)
+		if err != nil && !errors.Is(err, htracker.ErrAlreadyExists) {
+			return fmt.Errorf("failed to import subscription of %s to %s: %w", email, subscription.URL, err)
+		}
+	}
+	return nil
+}
+
+// opml is a minimal OPML 2.0 document, see http://opml.org/spec2.opml.
+// The subscription details not covered by OPML are stored in additional
+// attributes of the outline elements.
+type opml struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Title   string    `xml:"head>title"`
+	Created string    `xml:"head>dateCreated,omitempty"`
+	Body    []outline `xml:"body>outline"`
+}
+
+type outline struct {
+	Text        string    `xml:"text,attr"`
+	Type        string    `xml:"type,attr,omitempty"`
+	URL         string    `xml:"url,attr,omitempty"`
+	XMLURL      string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL     string    `xml:"htmlUrl,attr,omitempty"`
+	Filter      string    `xml:"filter,attr,omitempty"`
+	ContentType string    `xml:"contentType,attr,omitempty"`
+	UseChrome   string    `xml:"useChrome,attr,omitempty"`
+	Fallback    string    `xml:"chromeFallback,attr,omitempty"`
+	Screenshot  string    `xml:"screenshot,attr,omitempty"`
+	Normalize   string    `xml:"normalize,attr,omitempty"`
+	DiffMode    string    `xml:"diffMode,attr,omitempty"`
+	MinChange   string    `xml:"minChange,attr,omitempty"`
+	MinPixel    string    `xml:"minPixelChange,attr,omitempty"`
+	Retention   string    `xml:"retention,attr,omitempty"`
+	Method      string    `xml:"method,attr,omitempty"`
+	Body        string    `xml:"body,attr,omitempty"`
+	Interval    string    `xml:"interval,attr,omitempty"`
+	Outlines    []outline `xml:"outline"`
+}
+
+// MarshalOPML is encoding the given subscriptions as OPML document. Request options are not
+// included, as they might contain credentials.
+func MarshalOPML(title string, subscriptions []*htracker.Subscription) ([]byte, error) {
+	doc := opml{Version: "2.0", Title: title, Created: time.Now().Format(time.RFC1123Z)}
+
+	for _, s := range subscriptions {
+		o := outline{Text: s.URL, Type: "link", URL: s.URL, HTMLURL: s.URL, Filter: s.Filter, ContentType: s.ContentType, Body: s.Body,
+			Normalize: s.Normalize, DiffMode: s.DiffMode}
+		if s.HTTPMethod() != http.MethodGet {
+			o.Method = s.HTTPMethod()
+		}
+		if s.UseChrome {
+			o.UseChrome = strconv.FormatBool(s.UseChrome)
+		}
+		if s.ChromeFallback {
+			o.Fallback = strconv.FormatBool(s.ChromeFallback)
+		}
+		if s.Screenshot {
+			o.Screenshot = strconv.FormatBool(s.Screenshot)
+		}
+		if s.MinChange != 0 {
+			o.MinChange = strconv.FormatFloat(s.MinChange, 'f', -1, 64)
+		}
+		if s.MinPixelChange != 0 {
+			o.MinPixel = strconv.FormatFloat(s.MinPixelChange, 'f', -1, 64)
+		}
+		if s.Retention != nil {
+			o.Retention = s.Retention.String()
+		}
+		if s.Interval != 0 {
+			o.Interval = s.Interval.String()
+		}
+		doc.Body = append(doc.Body, o)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode opml: %w", err)
+	}
+
+	return append([]byte(xml.Header), data...), nil
+}
+
+// UnmarshalOPML is decoding the subscriptions of an OPML document. Nested outlines
+// (e.g. folders of feed readers) are flattened. Feed urls (xmlUrl) are preferred
+// over other urls and subscribed as feeds, unless the outline has a contentType
+// attribute. Outlines without any url are skipped. The given interval is used for
+// subscriptions without an interval attribute.
+func UnmarshalOPML(data []byte, interval time.Duration) ([]*htracker.Subscription, error) {
+	doc := opml{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode opml: %w", err)
+	}
+
+	subscriptions := []*htracker.Subscription{}
+	var walk func([]outline) error
+	walk = func(outlines []outline) error {
+		for _, o := range outlines {
+			if err := walk(o.Outlines); err != nil {
+				return err
+			}
+
+			url := firstNonEmpty(o.XMLURL, o.URL, o.HTMLURL)
+			if url == "" {
+				continue
+			}
+
+			subscription := &htracker.Subscription{URL: url, Filter: o.Filter, ContentType: o.ContentType, Method: o.Method, Body: o.Body,
+				Normalize: o.Normalize, DiffMode: o.DiffMode, Interval: interval}
+			if o.XMLURL != "" && o.ContentType == "" {
+				subscription.ContentType = htracker.ContentTypeFeed
+			}
+			if o.UseChrome != "" {
+				useChrome, err := strconv.ParseBool(o.UseChrome)
+				if err != nil {
+					return fmt.Errorf("invalid useChrome attribute for %s: %w", url, err)
+				}
+				subscription.UseChrome = useChrome
+			}
+			if o.Fallback != "" {
+				fallback, err := strconv.ParseBool(o.Fallback)
+				if err != nil {
+					return fmt.Errorf("invalid chromeFallback attribute for %s: %w", url, err)
+				}
+				subscription.ChromeFallback = fallback
+			}
+			if o.Screenshot != "" {
+				screenshot, err := strconv.ParseBool(o.Screenshot)
+				if err != nil {
+					return fmt.Errorf("invalid screenshot attribute for %s: %w", url, err)
+				}
+				subscription.Screenshot = screenshot
+			}
+			if o.MinChange != "" {
+				minChange, err := strconv.ParseFloat(o.MinChange, 64)
+				if err != nil {
+					return fmt.Errorf("invalid minChange attribute for %s: %w", url, err)
+				}
+				subscription.MinChange = minChange
+			}
+			if o.MinPixel != "" {
+				minPixelChange, err := strconv.ParseFloat(o.MinPixel, 64)
+				if err != nil {
+					return fmt.Errorf("invalid minPixelChange attribute for %s: %w", url, err)
+				}
+				subscription.MinPixelChange = minPixelChange
+			}
+			if o.Retention != "" {
+				retention, err := htracker.ParseRetentionPolicy(o.Retention)
+				if err != nil {
+					return fmt.Errorf("invalid retention attribute for %s: %w", url, err)
+				}
+				subscription.Retention = retention
+			}
+			if o.Interval != "" {
+				i, err := time.ParseDuration(o.Interval)
+				if err != nil {
+					return fmt.Errorf("invalid interval attribute for %s: %w", url, err)
+				}
+				subscription.Interval = i
+			}
+			subscriptions = append(subscriptions, subscription)
+		}
+		return nil
+	}
+
+	if err := walk(doc.Body); err != nil {
+		return nil, err
+	}
+
+	return subscriptions, nil
+}
+
+// ExportOPML is exporting the subscriptions of the given subscriber as OPML document.
+func ExportOPML(ctx context.Context, svc SubscriptionSvc, email string) ([]byte, error) {
+	subscriptions, err := svc.GetSubscriptionsBySubscriber(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("SubscriptionSvc.GetSubscriptionsBySubscriber(): %w", err)
+	}
+
+	return MarshalOPML("htracker subscriptions of "+email, subscriptions)
+}
+
+// ImportOPML is subscribing the given subscriber to all sites of an OPML document,
+// skipping existing subscriptions. The subscriber needs to exist already.
+func ImportOPML(ctx context.Context, svc SubscriptionSvc, email string, data []byte, interval time.Duration) error {
+	subscriptions, err := UnmarshalOPML(data, interval)
+	if err != nil {
+		return err
+	}
+
+	return subscribeAll(ctx, svc, email, subscriptions)
+}
+
+func firstNonEmpty(strs ...string) string {
+	for _, s := range strs {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"golang.org/x/exp/slog"
+)
+
+func TestExportImportSubscribers(t *testing.T) {
+	ctx := context.Background()
+
+	sub1 := &htracker.Subscription{URL: "http://site1.example/blah", Filter: "foo", ContentType: "text", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example/blub", Filter: "bar", ContentType: "byte", UseChrome: true, Interval: time.Minute}
+	sub3 := &htracker.Subscription{URL: "http://site3.example", Interval: time.Hour, Request: &htracker.RequestOptions{
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"}}}
+
+	email1 := "email1@foo.test"
+	email2 := "email2@foo.test"
+
+	src := NewSubscriptionSvc(memory.NewSubscriptionStorage(slog.Default()))
+	if err := src.AddSubscriber(ctx, &Subscriber{Email: email1, SubscriptionLimit: 5}); err != nil {
+		t.Fatalf("AddSubscriber() failed: %v", err)
+	}
+	if err := src.AddSubscriber(ctx, &Subscriber{Email: email2, SubscriptionLimit: -1}); err != nil {
+		t.Fatalf("AddSubscriber() failed: %v", err)
+	}
+	for _, s := range []*htracker.Subscription{sub1, sub2} {
+		if err := src.Subscribe(ctx, email1, s); err != nil {
+			t.Fatalf("Subscribe() failed: %v", err)
+		}
+	}
+	for _, s := range []*htracker.Subscription{sub2, sub3} {
+		if err := src.Subscribe(ctx, email2, s); err != nil {
+			t.Fatalf("Subscribe() failed: %v", err)
+		}
+	}
+
+	// the request options are only exported on request
+	redacted, err := ExportSubscribers(ctx, src, false)
+	if err != nil {
+		t.Fatalf("ExportSubscribers() failed: %v", err)
+	}
+	for _, subscriber := range redacted.Subscribers {
+		for _, s := range subscriber.Subscriptions {
+			if s.Request != nil {
+				t.Errorf("Expected request options of %s to be left out, got %+v", s.URL, s.Request)
+			}
+		}
+	}
+	if sub3.Request == nil {
+		t.Errorf("Expected the request options of the exported subscription to be kept")
+	}
+
+	dump, err := ExportSubscribers(ctx, src, true)
+	if err != nil {
+		t.Fatalf("ExportSubscribers() failed: %v", err)
+	}
+
+	dst := NewSubscriptionSvc(memory.NewSubscriptionStorage(slog.Default()))
+	// importing twice should not fail, as existing items are skipped
+	for i := 0; i < 2; i++ {
+		if err := ImportSubscribers(ctx, dst, dump); err != nil {
+			t.Fatalf("ImportSubscribers() failed: %v", err)
+		}
+	}
+
+	got, err := dst.GetSubscribers(ctx)
+	if err != nil {
+		t.Fatalf("GetSubscribers() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, dump.Subscribers) {
+		t.Errorf("Expected imported subscribers %v, got %v", dump.Subscribers, got)
+	}
+
+	if err := ImportSubscribers(ctx, dst, &Dump{Version: 99}); err == nil {
+		t.Errorf("Expected ImportSubscribers() to fail for unknown dump version")
+	}
+}
+
+func TestOPML(t *testing.T) {
+	sub1 := &htracker.Subscription{URL: "http://site1.example/blah", Filter: "foo", ContentType: "text", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example/blub", UseChrome: true, ChromeFallback: true, Screenshot: true, Normalize: htracker.NormalizeWhitespace,
+		DiffMode: htracker.DiffModeLine, MinChange: 2.5, MinPixelChange: 1.5, Interval: time.Minute,
+		Retention: &htracker.RetentionPolicy{KeepLast: 5, KeepWithin: 48 * time.Hour, KeepDaily: 7, KeepWeekly: 4}}
+	sub4 := &htracker.Subscription{URL: "http://site4.example/", Retention: &htracker.RetentionPolicy{}, Interval: time.Hour}
+	sub3 := &htracker.Subscription{URL: "http://site2.example/search", Method: "POST", Body: "q=foo&page=1", Interval: time.Minute}
+
+	data, err := MarshalOPML("test", []*htracker.Subscription{sub1, sub2, sub3, sub4})
+	if err != nil {
+		t.Fatalf("MarshalOPML() failed: %v", err)
+	}
+
+	got, err := UnmarshalOPML(data, time.Hour)
+	if err != nil {
+		t.Fatalf("UnmarshalOPML() failed: %v", err)
+	}
+	if want := []*htracker.Subscription{sub1, sub2, sub3, sub4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalOPML() = %v, want %v", got, want)
+	}
+
+	// a typical export of a feed reader with folders
+	feedReaderOPML := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Feeds</title></head>
+  <body>
+    <outline text="News" title="News">
+      <outline type="rss" text="Site 1" xmlUrl="http://site1.example/feed.xml" htmlUrl="http://site1.example/"/>
+      <outline type="rss" text="Site 2" xmlUrl="http://site2.example/rss"/>
+    </outline>
+    <outline text="Empty folder"/>
+  </body>
+</opml>`
+
+	got, err = UnmarshalOPML([]byte(feedReaderOPML), time.Hour)
+	if err != nil {
+		t.Fatalf("UnmarshalOPML() failed: %v", err)
+	}
+	want := []*htracker.Subscription{
+		{URL: "http://site1.example/feed.xml", ContentType: htracker.ContentTypeFeed, Interval: time.Hour},
+		{URL: "http://site2.example/rss", ContentType: htracker.ContentTypeFeed, Interval: time.Hour},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalOPML() = %v, want %v", got, want)
+	}
+
+	if _, err := UnmarshalOPML([]byte("no xml"), time.Hour); err == nil {
+		t.Errorf("Expected UnmarshalOPML() to fail for invalid document")
+	}
+	invalidRetention := `<opml version="2.0"><body><outline url="http://site1.example/" retention="monthly=3"/></body></opml>`
+	if _, err := UnmarshalOPML([]byte(invalidRetention), time.Hour); err == nil {
+		t.Errorf("Expected UnmarshalOPML() to fail for invalid retention attribute")
+	}
+}
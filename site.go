@@ -2,6 +2,30 @@ package htracker
 
 import "time"
 
+// SiteState is describing the outcome of the last scrape of a site.
+type SiteState string
+
+const (
+	// SiteStateOK means the site was scraped successfully.
+	SiteStateOK SiteState = "ok"
+
+	// SiteStateBlockedByRobots means scraping the site is disallowed by its robots.txt.
+	// Content, checksum and diff of the last successful scrape are kept.
+	SiteStateBlockedByRobots SiteState = "blocked_by_robots"
+
+	// SiteStateBrowserUnavailable means the site needs to be rendered, but chrome was unavailable.
+	// Content, checksum and diff of the last successful scrape are kept.
+	SiteStateBrowserUnavailable SiteState = "browser_unavailable"
+
+	// SiteStateRenderFallback means chrome was unavailable and the site was scraped without rendering instead.
+	SiteStateRenderFallback SiteState = "render_fallback"
+)
+
+// Scraped is returning whether the site was scraped, so that content and checksum are valid.
+func (s SiteState) Scraped() bool {
+	return s != SiteStateBlockedByRobots && s != SiteStateBrowserUnavailable
+}
+
 // Site is holding content and metadata of a subscribed site.
 type Site struct {
 	Subscription *Subscription
@@ -9,5 +33,21 @@ type Site struct {
 	LastChecked  time.Time
 	Content      []byte
 	Checksum     string
-	Diff         string
+	Diff         Diff
+	State        SiteState
+
+	// Metrics are quantifying the changes of the diff, nil if the content didn't change yet.
+	Metrics *ChangeMetrics `json:",omitempty"`
+
+	// Screenshot is the screenshot of sites rendered with chrome, if requested by the subscription.
+	Screenshot *Screenshot `json:",omitempty"`
+}
+
+// Redacted is returning a copy of the site with a redacted subscription, see Subscription.Redacted.
+func (s *Site) Redacted() *Site {
+	redacted := *s
+	if s.Subscription != nil {
+		redacted.Subscription = s.Subscription.Redacted()
+	}
+	return &redacted
 }
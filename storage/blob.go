@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// BlobStorage is an interface describing a content addressed storage for the content of sites, keyed by
+// its SHA-256 checksum (see Site.Checksum). Identical contents are stored once.
+type BlobStorage interface {
+	// Put is storing content under checksum. If the checksum is stored already, only the time of its
+	// last put is updated, so that it is not collected as garbage before the site referencing it is archived.
+	Put(ctx context.Context, checksum string, content []byte) error
+	// Get is returning the content stored under checksum or an error wrapping ErrNotExist.
+	Get(ctx context.Context, checksum string) ([]byte, error)
+	// Delete is removing the content stored under checksum, it is a no-op if not stored.
+	Delete(ctx context.Context, checksum string) error
+	// List is returning the checksums of the blobs last put before the given time.
+	List(ctx context.Context, before time.Time) ([]string, error)
+	// Expire is removing the content stored under checksum if it was last put before the given time,
+	// returning whether it was removed.
+	Expire(ctx context.Context, checksum string, before time.Time) (bool, error)
+}
+
+// ValidateChecksum is returning an error wrapping ErrInvalid if checksum is no hex encoded SHA-256
+// checksum, so that it can be used as key of blobs, e.g. as file name.
+func ValidateChecksum(checksum string) error {
+	if len(checksum) != 64 {
+		return fmt.Errorf("checksum %q is no SHA-256 checksum: %w", checksum, htracker.ErrInvalid)
+	}
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return fmt.Errorf("checksum %q is no SHA-256 checksum: %w", checksum, htracker.ErrInvalid)
+	}
+	return nil
+}
+
+// Compression is the compression of blobs. Only gzip is supported, as the standard library has no zstd.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+)
+
+// ParseCompression is returning the Compression named by s, CompressionNone if empty. An error
+// wrapping ErrInvalid is returned for unknown compressions.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case "", CompressionNone:
+		return CompressionNone, nil
+	case CompressionGzip:
+		return c, nil
+	default:
+		return "", fmt.Errorf("compression %s not supported, expected %s or %s (zstd is not supported): %w", s,
+			CompressionNone, CompressionGzip, htracker.ErrInvalid)
+	}
+}
+
+// Compress is returning data compressed with c.
+func (c Compression) Compress(data []byte) ([]byte, error) {
+	switch c {
+	case "", CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		buf := &bytes.Buffer{}
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("compression %s not supported: %w", c, htracker.ErrInvalid)
+	}
+}
+
+// Decompress is returning data decompressed with c.
+func (c Compression) Decompress(data []byte) ([]byte, error) {
+	switch c {
+	case "", CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("gunzip: %w", err)
+		}
+		defer r.Close()
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("gunzip: %w", err)
+		}
+		return content, nil
+	default:
+		return nil, fmt.Errorf("compression %s not supported: %w", c, htracker.ErrInvalid)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestValidateChecksum(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum string
+		wantErr  bool
+	}{
+		{name: "valid", checksum: strings.Repeat("ab", 32)},
+		{name: "empty", checksum: "", wantErr: true},
+		{name: "short", checksum: "abcd", wantErr: true},
+		{name: "no hex", checksum: strings.Repeat("zz", 32), wantErr: true},
+		{name: "path", checksum: "../" + strings.Repeat("a", 61), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateChecksum(tt.checksum)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateChecksum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, htracker.ErrInvalid) {
+				t.Errorf("Expected error wrapping ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	content := []byte(strings.Repeat("This is Site1 ä😎\n", 100))
+
+	tests := []struct {
+		name    string
+		s       string
+		want    Compression
+		wantErr bool
+	}{
+		{name: "default", s: "", want: CompressionNone},
+		{name: "none", s: "none", want: CompressionNone},
+		{name: "gzip", s: "gzip", want: CompressionGzip},
+		{name: "unknown", s: "zip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCompression(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCompression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, htracker.ErrInvalid) {
+					t.Errorf("Expected error wrapping ErrInvalid, got %v", err)
+				}
+				return
+			}
+			if c != tt.want {
+				t.Fatalf("ParseCompression() = %s, want %s", c, tt.want)
+			}
+
+			data, err := c.Compress(content)
+			if err != nil {
+				t.Fatalf("Compress() failed: %v", err)
+			}
+			if c == CompressionGzip && len(data) >= len(content) {
+				t.Errorf("Expected compressed data to be smaller than %d bytes, got %d", len(content), len(data))
+			}
+			got, err := c.Decompress(data)
+			if err != nil {
+				t.Fatalf("Decompress() failed: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("Decompress() = %q, want %q", got, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// ChangeEvent is announcing a change of an archived site, which was reported to its subscribers.
+type ChangeEvent struct {
+	// Subscription is identifying the changed site together with BodyChecksum, it has neither body nor
+	// request options, as they might be big or contain credentials.
+	Subscription *htracker.Subscription
+	// BodyChecksum is the SHA256 checksum of the body of the subscription, empty if it has none.
+	BodyChecksum string `json:",omitempty"`
+	LastUpdated  time.Time
+}
+
+// Matches is returning whether the event is announcing a change of the site of subscription. The
+// request options of subscription are ignored.
+func (e *ChangeEvent) Matches(subscription *htracker.Subscription) bool {
+	s := *subscription
+	s.Body, s.Request = "", nil
+	return e.Subscription.Equals(&s) && e.BodyChecksum == bodyChecksum(subscription.Body)
+}
+
+// ChangeNotifier is an interface describing a broker of change events. Depending on the implementation,
+// the events are received by the listeners of all instances sharing the storage.
+type ChangeNotifier interface {
+	// NotifyChange is sending the event to all listeners.
+	NotifyChange(context.Context, *ChangeEvent) error
+	// ListenChanges is returning a channel receiving the change events until ctx is canceled,
+	// the channel is closed then.
+	ListenChanges(context.Context) (<-chan *ChangeEvent, error)
+}
+
+// NewChangeEvent is returning the event of a change of the site of subscription.
+func NewChangeEvent(subscription *htracker.Subscription, lastUpdated time.Time) *ChangeEvent {
+	s := *subscription
+	s.Body, s.Request = "", nil
+	return &ChangeEvent{Subscription: &s, BodyChecksum: bodyChecksum(subscription.Body), LastUpdated: lastUpdated}
+}
+
+// bodyChecksum is returning the hex encoded SHA256 checksum of body, or "" if it is empty.
+func bodyChecksum(body string) string {
+	if body == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
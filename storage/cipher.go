@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// KeySize is the size in bytes of the keys used for encrypting secrets at rest (AES-256).
+const KeySize = 32
+
+// ErrNoCipher is returned when secrets should be persisted, but no encryption key is configured.
+var ErrNoCipher = errors.New("no encryption key configured for storing secrets")
+
+// Cipher is encrypting secrets, like the credentials of subscriptions, before they are persisted.
+// It is using AES-GCM, storing the random nonce in front of the ciphertext.
+type Cipher struct {
+	aead cipher.AEAD
+	// hashKey is the key of Hash, derived from the encryption key.
+	hashKey []byte
+}
+
+// NewCipher is returning a new Cipher using the given key of KeySize bytes.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes.NewCipher(): %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher.NewGCM(): %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("htracker hash key"))
+
+	return &Cipher{aead: aead, hashKey: mac.Sum(nil)}, nil
+}
+
+// ParseCipher is returning a new Cipher using the base64 encoded key, e.g. created with
+// "openssl rand -base64 32". An empty key is returning a nil Cipher.
+func ParseCipher(key string) (*Cipher, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+	}
+	return NewCipher(raw)
+}
+
+// Encrypt is returning the base64 encoded ciphertext of plaintext.
+func (c *Cipher) Encrypt(plaintext []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to create nonce: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(c.aead.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+// Decrypt is returning the plaintext of a ciphertext returned by Encrypt.
+func (c *Cipher) Decrypt(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	if len(data) < c.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, data := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt ciphertext: %w", err)
+	}
+	return plaintext, nil
+}
+
+// Hash is returning the hex encoded HMAC-SHA256 of data, for identifying secrets without storing
+// them in clear text. Equal data is always returning the same hash, as long as the key is the same.
+func (c *Cipher) Hash(data []byte) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Variant is returning the hash of the settings and request options of the subscription, which is
+// distinguishing subscriptions of the same site with different settings or requested with different
+// options, or an empty string for the default settings without request options. Request options are
+// hashed with the key of c, ErrNoCipher is returned if c is nil then.
+func Variant(c *Cipher, s *htracker.Subscription) (string, error) {
+	variant := struct {
+		Settings htracker.SubscriptionSettings
+		Request  *htracker.RequestOptions `json:",omitempty"`
+	}{s.Settings(), s.Request}
+
+	if variant.Settings == (htracker.SubscriptionSettings{}) && s.Request == nil {
+		return "", nil
+	}
+	if s.Request != nil && c == nil {
+		return "", ErrNoCipher
+	}
+
+	data, err := json.Marshal(variant)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode subscription settings: %w", err)
+	}
+	if s.Request == nil {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return c.Hash(data), nil
+}
+
+// EncryptRequestOptions is returning the encrypted JSON encoding of the given request options,
+// or an empty string if there are none. ErrNoCipher is returned if c is nil.
+func EncryptRequestOptions(c *Cipher, opts *htracker.RequestOptions) (string, error) {
+	if opts == nil {
+		return "", nil
+	}
+	if c == nil {
+		return "", ErrNoCipher
+	}
+
+	data, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request options: %w", err)
+	}
+	return c.Encrypt(data)
+}
+
+// DecryptRequestOptions is returning the request options encrypted by EncryptRequestOptions,
+// or nil if ciphertext is empty. ErrNoCipher is returned if c is nil.
+func DecryptRequestOptions(c *Cipher, ciphertext string) (*htracker.RequestOptions, error) {
+	if ciphertext == "" {
+		return nil, nil
+	}
+	if c == nil {
+		return nil, ErrNoCipher
+	}
+
+	data, err := c.Decrypt(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &htracker.RequestOptions{}
+	if err := json.Unmarshal(data, opts); err != nil {
+		return nil, fmt.Errorf("failed to decode request options: %w", err)
+	}
+	return opts, nil
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+func TestParseCipher(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))
+
+	tests := []struct {
+		name    string
+		key     string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "valid key", key: key},
+		{name: "empty key", key: "", wantNil: true},
+		{name: "invalid base64", key: "not base64!", wantErr: true},
+		{name: "short key", key: base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCipher(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCipher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (c == nil) != tt.wantNil {
+				t.Errorf("ParseCipher() = %v, wantNil %v", c, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestCipher_RequestOptions(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{1}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCipher(bytes.Repeat([]byte{2}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &htracker.RequestOptions{
+		Headers:   map[string]string{"Accept-Language": "de"},
+		Cookies:   map[string]string{"consent": "yes"},
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"},
+	}
+
+	ciphertext, err := EncryptRequestOptions(c, opts)
+	if err != nil {
+		t.Fatalf("EncryptRequestOptions() failed: %v", err)
+	}
+	if strings.Contains(ciphertext, "secret") {
+		t.Errorf("Expected password to be encrypted, got %s", ciphertext)
+	}
+
+	got, err := DecryptRequestOptions(c, ciphertext)
+	if err != nil {
+		t.Fatalf("DecryptRequestOptions() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, opts) {
+		t.Errorf("DecryptRequestOptions() = %v, want %v", got, opts)
+	}
+
+	if _, err := DecryptRequestOptions(other, ciphertext); err == nil {
+		t.Error("Expected decryption with a different key to fail")
+	}
+
+	if _, err := EncryptRequestOptions(nil, opts); !errors.Is(err, ErrNoCipher) {
+		t.Errorf("Expected ErrNoCipher without cipher, got %v", err)
+	}
+
+	if ciphertext, err := EncryptRequestOptions(nil, nil); ciphertext != "" || err != nil {
+		t.Errorf("Expected no ciphertext without request options, got %q, %v", ciphertext, err)
+	}
+	if opts, err := DecryptRequestOptions(nil, ""); opts != nil || err != nil {
+		t.Errorf("Expected no request options without ciphertext, got %v, %v", opts, err)
+	}
+}
+
+func TestVariant(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{1}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCipher(bytes.Repeat([]byte{2}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain := &htracker.Subscription{URL: "http://site1.example"}
+	auth := &htracker.Subscription{URL: plain.URL, Request: &htracker.RequestOptions{
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"}}}
+	sameAuth := &htracker.Subscription{URL: plain.URL, Request: &htracker.RequestOptions{
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"}}}
+	otherAuth := &htracker.Subscription{URL: plain.URL, Request: &htracker.RequestOptions{
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "other"}}}
+
+	variant := func(c *Cipher, s *htracker.Subscription) string {
+		t.Helper()
+		v, err := Variant(c, s)
+		if err != nil {
+			t.Fatalf("Variant() failed: %v", err)
+		}
+		return v
+	}
+
+	if got := variant(c, plain); got != "" {
+		t.Errorf("Expected empty variant without request options, got %q", got)
+	}
+	if got := variant(nil, plain); got != "" {
+		t.Errorf("Expected empty variant without request options and cipher, got %q", got)
+	}
+	v := variant(c, auth)
+	if v == "" || strings.Contains(v, "secret") {
+		t.Errorf("Expected hash of request options, got %q", v)
+	}
+	if got := variant(c, sameAuth); got != v {
+		t.Errorf("Expected same variant for same request options, got %q and %q", v, got)
+	}
+	if got := variant(c, otherAuth); got == v {
+		t.Errorf("Expected different variant for different credentials, got %q", got)
+	}
+	if got := variant(other, auth); got == v {
+		t.Errorf("Expected different variant with a different key, got %q", got)
+	}
+	settings := &htracker.Subscription{URL: plain.URL, UseChrome: true, Screenshot: true}
+	v = variant(nil, settings)
+	if v == "" {
+		t.Error("Expected variant of non-default settings")
+	}
+	if got := variant(c, settings); got != v {
+		t.Errorf("Expected variant of settings independent of the cipher, got %q and %q", v, got)
+	}
+	if got := variant(nil, &htracker.Subscription{URL: plain.URL, UseChrome: true}); got == v {
+		t.Errorf("Expected different variant for different settings, got %q", got)
+	}
+	if got := variant(nil, &htracker.Subscription{URL: plain.URL, UseChrome: true, Screenshot: true, Interval: time.Hour}); got != v {
+		t.Errorf("Expected variant independent of the interval, got %q and %q", v, got)
+	}
+
+	if _, err := Variant(nil, auth); !errors.Is(err, ErrNoCipher) {
+		t.Errorf("Expected ErrNoCipher without cipher, got %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// blobExtensions are the file extensions of blobs per compression.
+var blobExtensions = map[storage.Compression]string{
+	storage.CompressionNone: "",
+	storage.CompressionGzip: ".gz",
+}
+
+// blobDir is a BlobStorage keeping each blob in a file named by its checksum, with an extension
+// per compression. The files are spread over subdirectories named by the first two characters of
+// the checksum, to keep directories small.
+type blobDir struct {
+	dir         string
+	compression storage.Compression
+	logger      *slog.Logger
+}
+
+// compile time check of interface implementation.
+var _ storage.BlobStorage = &blobDir{}
+
+// BlobOpt is a functional option for the BlobStorage.
+type BlobOpt func(*blobDir)
+
+// WithCompression is setting the compression of blobs written, blobs are read in any compression.
+func WithCompression(c storage.Compression) BlobOpt {
+	return func(b *blobDir) {
+		b.compression = c
+	}
+}
+
+// NewBlobStorage is returning a new BlobStorage keeping the blobs in files below dir. The directory
+// is created on the first write if it doesn't exist yet.
+func NewBlobStorage(dir string, logger *slog.Logger, opts ...BlobOpt) *blobDir {
+	b := &blobDir{dir: dir, compression: storage.CompressionNone, logger: logger}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Put is storing content under checksum, if it is not stored yet in any compression. Otherwise the
+// modification time of the file is updated, which is the time of the last put.
+func (b *blobDir) Put(ctx context.Context, checksum string, content []byte) error {
+	if err := storage.ValidateChecksum(checksum); err != nil {
+		return err
+	}
+	path, _, err := b.find(checksum)
+	if err != nil && !errors.Is(err, htracker.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		now := time.Now()
+		err = os.Chtimes(path, now, now)
+		if err == nil {
+			return nil
+		}
+		// the blob is written again if it was removed in the meantime
+		if !errors.Is(err, fs.ErrNotExist) {
+			b.logger.Error("failed to touch blob file", err, slog.String("path", path))
+			return fmt.Errorf("failed to write blob: %w", err)
+		}
+	}
+
+	data, err := b.compression.Compress(content)
+	if err != nil {
+		return err
+	}
+
+	path = b.path(checksum, b.compression)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		b.logger.Error("failed to create blob directory", err, slog.String("path", path))
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	// the blob is written to a temporary file first, so that readers never see partial blobs
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		b.logger.Error("failed to create temporary blob file", err, slog.String("path", path))
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		b.logger.Error("failed to write temporary blob file", err, slog.String("path", tmp.Name()))
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		b.logger.Error("failed to rename blob file", err, slog.String("path", path))
+		return fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	return nil
+}
+
+// Get is returning the content stored under checksum or ErrNotExist if not found.
+func (b *blobDir) Get(ctx context.Context, checksum string) ([]byte, error) {
+	if err := storage.ValidateChecksum(checksum); err != nil {
+		return nil, err
+	}
+	path, compression, err := b.find(checksum)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.logger.Error("failed to read blob file", err, slog.String("path", path))
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	content, err := compression.Decompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress blob %s: %w", checksum, err)
+	}
+
+	return content, nil
+}
+
+// Delete is removing the content stored under checksum in any compression.
+func (b *blobDir) Delete(ctx context.Context, checksum string) error {
+	if err := storage.ValidateChecksum(checksum); err != nil {
+		return err
+	}
+
+	for compression := range blobExtensions {
+		path := b.path(checksum, compression)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			b.logger.Error("failed to remove blob file", err, slog.String("path", path))
+			return fmt.Errorf("failed to delete blob: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// List is returning the checksums of the blobs with a file modified before the given time. Other
+// files, e.g. temporary files of interrupted writes, are ignored.
+func (b *blobDir) List(ctx context.Context, before time.Time) ([]string, error) {
+	checksums := []string{}
+
+	err := filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// the directory is created on the first write
+			if path == b.dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return ctx.Err()
+		}
+
+		checksum, ok := b.checksum(d.Name())
+		if !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(before) {
+			checksums = append(checksums, checksum)
+		}
+		return nil
+	})
+	if err != nil {
+		b.logger.Error("failed to list blob files", err, slog.String("dir", b.dir))
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	return checksums, nil
+}
+
+// Expire is removing the blob stored under checksum in any compression, if its file was modified
+// before the given time.
+func (b *blobDir) Expire(ctx context.Context, checksum string, before time.Time) (bool, error) {
+	if err := storage.ValidateChecksum(checksum); err != nil {
+		return false, err
+	}
+	path, _, err := b.find(checksum)
+	if errors.Is(err, htracker.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		b.logger.Error("failed to stat blob file", err, slog.String("path", path))
+		return false, fmt.Errorf("failed to expire blob: %w", err)
+	}
+	if !info.ModTime().Before(before) {
+		return false, nil
+	}
+
+	return true, b.Delete(ctx, checksum)
+}
+
+// checksum is returning the checksum of the blob stored in the file with the given name, false if
+// the file is no blob.
+func (b *blobDir) checksum(name string) (string, bool) {
+	for _, ext := range blobExtensions {
+		checksum := strings.TrimSuffix(name, ext)
+		if len(checksum)+len(ext) == len(name) && storage.ValidateChecksum(checksum) == nil {
+			return checksum, true
+		}
+	}
+	return "", false
+}
+
+// find is returning the path and compression of the file of the blob stored under checksum, or ErrNotExist.
+func (b *blobDir) find(checksum string) (string, storage.Compression, error) {
+	for compression := range blobExtensions {
+		path := b.path(checksum, compression)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, compression, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			b.logger.Error("failed to stat blob file", err, slog.String("path", path))
+			return "", "", fmt.Errorf("failed to find blob: %w", err)
+		}
+	}
+
+	return "", "", fmt.Errorf("blob %s: %w", checksum, htracker.ErrNotExist)
+}
+
+// path is returning the path of the file of the blob stored under checksum with the given compression.
+func (b *blobDir) path(checksum string, compression storage.Compression) string {
+	return filepath.Join(b.dir, checksum[:2], checksum+blobExtensions[compression])
+}
@@ -0,0 +1,148 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_blobDir(t *testing.T) {
+	ctx := context.Background()
+	content1 := []byte("This is Site1")
+	content2 := []byte("This is Site2 ä😎")
+	checksum1, checksum2 := service.Checksum(content1), service.Checksum(content2)
+
+	for _, compression := range []storage.Compression{storage.CompressionNone, storage.CompressionGzip} {
+		t.Run(string(compression), func(t *testing.T) {
+			dir := t.TempDir()
+			blobs := NewBlobStorage(dir, slog.Default(), WithCompression(compression))
+
+			if _, err := blobs.Get(ctx, checksum1); !errors.Is(err, htracker.ErrNotExist) {
+				t.Errorf("Expected ErrNotExist for missing blob, got %v", err)
+			}
+
+			for _, put := range []struct {
+				checksum string
+				content  []byte
+			}{{checksum1, content1}, {checksum2, content2}, {checksum1, content1}} {
+				if err := blobs.Put(ctx, put.checksum, put.content); err != nil {
+					t.Fatalf("Put() failed: %v", err)
+				}
+			}
+
+			got, err := blobs.Get(ctx, checksum2)
+			if err != nil {
+				t.Fatalf("Get() failed: %v", err)
+			}
+			if !bytes.Equal(got, content2) {
+				t.Errorf("Get() = %q, want %q", got, content2)
+			}
+
+			// blobs written with another compression are still readable
+			other := NewBlobStorage(dir, slog.Default())
+			got, err = other.Get(ctx, checksum1)
+			if err != nil {
+				t.Fatalf("Get() with other compression failed: %v", err)
+			}
+			if !bytes.Equal(got, content1) {
+				t.Errorf("Get() with other compression = %q, want %q", got, content1)
+			}
+
+			if err := blobs.Delete(ctx, checksum1); err != nil {
+				t.Fatalf("Delete() failed: %v", err)
+			}
+			if _, err := blobs.Get(ctx, checksum1); !errors.Is(err, htracker.ErrNotExist) {
+				t.Errorf("Expected ErrNotExist for deleted blob, got %v", err)
+			}
+			if err := blobs.Delete(ctx, checksum1); err != nil {
+				t.Errorf("Delete() of missing blob failed: %v", err)
+			}
+		})
+	}
+}
+
+func Test_blobDir_InvalidChecksum(t *testing.T) {
+	ctx := context.Background()
+	blobs := NewBlobStorage(t.TempDir(), slog.Default())
+
+	if err := blobs.Put(ctx, "../../etc/passwd", []byte("foo")); !errors.Is(err, htracker.ErrInvalid) {
+		t.Errorf("Put(): expected ErrInvalid, got %v", err)
+	}
+	if _, err := blobs.Get(ctx, "../../etc/passwd"); !errors.Is(err, htracker.ErrInvalid) {
+		t.Errorf("Get(): expected ErrInvalid, got %v", err)
+	}
+	if err := blobs.Delete(ctx, "../../etc/passwd"); !errors.Is(err, htracker.ErrInvalid) {
+		t.Errorf("Delete(): expected ErrInvalid, got %v", err)
+	}
+}
+
+func Test_blobDir_Expire(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	blobs := NewBlobStorage(dir, slog.Default(), WithCompression(storage.CompressionGzip))
+	content1, content2 := []byte("This is Site1"), []byte("This is Site2")
+	checksum1, checksum2 := service.Checksum(content1), service.Checksum(content2)
+
+	if got, err := blobs.List(ctx, time.Now()); err != nil || len(got) != 0 {
+		t.Errorf("List() of missing dir = %v, %v, want no blobs", got, err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, put := range []struct {
+		checksum string
+		content  []byte
+	}{{checksum1, content1}, {checksum2, content2}} {
+		if err := blobs.Put(ctx, put.checksum, put.content); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+		if err := os.Chtimes(blobs.path(put.checksum, storage.CompressionGzip), old, old); err != nil {
+			t.Fatalf("Setup: failed to set time of blob: %v", err)
+		}
+	}
+	// a temporary file of an interrupted write is no blob
+	if err := os.WriteFile(filepath.Join(dir, checksum1[:2], checksum1+".gz.123"), nil, 0o644); err != nil {
+		t.Fatalf("Setup: failed to write temporary file: %v", err)
+	}
+	// a put of a stored blob is updating the time of its last put
+	if err := blobs.Put(ctx, checksum2, content2); err != nil {
+		t.Fatalf("Put() failed: %v", err)
+	}
+
+	before := time.Now().Add(-time.Hour)
+	got, err := blobs.List(ctx, before)
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	if want := []string{checksum1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		checksum    string
+		wantExpired bool
+	}{{checksum1, true}, {checksum2, false}, {checksum1, false}} {
+		expired, err := blobs.Expire(ctx, tt.checksum, before)
+		if err != nil {
+			t.Fatalf("Expire() failed: %v", err)
+		}
+		if expired != tt.wantExpired {
+			t.Errorf("Expire(%s) = %v, want %v", tt.checksum, expired, tt.wantExpired)
+		}
+	}
+	if _, err := blobs.Get(ctx, checksum1); !errors.Is(err, htracker.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for expired blob, got %v", err)
+	}
+	if _, err := blobs.Get(ctx, checksum2); err != nil {
+		t.Errorf("Get() of blob put again failed: %v", err)
+	}
+}
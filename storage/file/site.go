@@ -0,0 +1,212 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// siteFile is a SiteStorage keeping the site archive in a local JSON file - mainly for one-shot checks
+// where running a database is not worth it. The file is read and written on every call.
+type siteFile struct {
+	path   string
+	logger *slog.Logger
+	mu     sync.Mutex
+}
+
+// compile time check of interface implementation.
+var _ storage.SiteStorage = &siteFile{}
+
+// NewSiteStorage returns a new SiteStorage persisting the site archive in the file at path.
+// The file is created on the first write if it doesn't exist yet.
+func NewSiteStorage(path string, logger *slog.Logger) *siteFile {
+	return &siteFile{path: path, logger: logger}
+}
+
+// Get is returning the site for the given subscription or ErrNotExist if not found.
+func (f *siteFile) Get(ctx context.Context, subscription *htracker.Subscription) (*htracker.Site, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sites, err := f.load()
+	if err != nil {
+		return &htracker.Site{}, err
+	}
+
+	for _, site := range sites {
+		if sameSite(subscription, site.Subscription) {
+			return site, nil
+		}
+	}
+
+	return &htracker.Site{}, htracker.ErrNotExist
+}
+
+// Add is adding a new site to the archive.
+func (f *siteFile) Add(ctx context.Context, site *htracker.Site) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sites, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sites {
+		if sameSite(site.Subscription, s.Subscription) {
+			return htracker.ErrAlreadyExists
+		}
+	}
+
+	sites = append(sites, &htracker.Site{
+		Subscription: withoutRequestOptions(site.Subscription),
+		LastUpdated:  site.LastChecked,
+		LastChecked:  site.LastChecked,
+		Content:      site.Content,
+		Checksum:     site.Checksum,
+		Diff:         nil,
+		State:        site.State,
+		Screenshot:   site.Screenshot,
+	})
+
+	return f.save(sites)
+}
+
+// Update is updating a site in the site archive if found.
+func (f *siteFile) Update(ctx context.Context, site *htracker.Site) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sites, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	for i, s := range sites {
+		if sameSite(site.Subscription, s.Subscription) {
+			updated := *site
+			updated.Subscription = withoutRequestOptions(site.Subscription)
+			sites[i] = &updated
+			return f.save(sites)
+		}
+	}
+
+	return htracker.ErrNotExist
+}
+
+// Upsert is atomically adding or updating the site of the subscription with the site returned by update.
+// The file is locked against other calls of the storage, but not against other processes.
+func (f *siteFile) Upsert(ctx context.Context, subscription *htracker.Subscription, update storage.UpdateFunc) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sites, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for ; i < len(sites); i++ {
+		if sameSite(subscription, sites[i].Subscription) {
+			break
+		}
+	}
+
+	var archived *htracker.Site
+	if i < len(sites) {
+		archived = sites[i]
+	}
+	site, err := update(archived)
+	if err != nil || site == nil {
+		return err
+	}
+
+	updated := *site
+	updated.Subscription = withoutRequestOptions(site.Subscription)
+	if archived == nil {
+		sites = append(sites, &updated)
+	} else {
+		sites[i] = &updated
+	}
+	return f.save(sites)
+}
+
+// sameSite is returning whether the subscriptions are equal, ignoring their request options, which
+// are not stored. The file is meant for one-shot checks, which are not sharing the archive.
+func sameSite(s1, s2 *htracker.Subscription) bool {
+	return withoutRequestOptions(s1).Equals(withoutRequestOptions(s2))
+}
+
+// withoutRequestOptions is returning a copy of the subscription without its request options,
+// so that credentials are not written to the state file in plain text. They are not needed
+// for identifying the site.
+func withoutRequestOptions(subscription *htracker.Subscription) *htracker.Subscription {
+	if subscription.Request == nil {
+		return subscription
+	}
+	s := *subscription
+	s.Request = nil
+	return &s
+}
+
+// load is reading all sites from the file. A missing file is treated as empty archive.
+func (f *siteFile) load() ([]*htracker.Site, error) {
+	sites := []*htracker.Site{}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return sites, nil
+		}
+		f.logger.Error("failed to read state file", err, slog.String("path", f.path))
+		return sites, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &sites); err != nil {
+		f.logger.Error("failed to decode state file", err, slog.String("path", f.path))
+		return sites, fmt.Errorf("failed to decode state file %s: %w", f.path, err)
+	}
+
+	return sites, nil
+}
+
+// save is writing all sites to the file. We write to a temporary file first
+// and rename it afterwards, to not end up with a truncated state file.
+func (f *siteFile) save(sites []*htracker.Site) error {
+	data, err := json.MarshalIndent(sites, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		f.logger.Error("failed to create temporary state file", err, slog.String("path", f.path))
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		f.logger.Error("failed to write temporary state file", err, slog.String("path", tmp.Name()))
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		f.logger.Error("failed to replace state file", err, slog.String("path", f.path))
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
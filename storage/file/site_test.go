@@ -0,0 +1,137 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+	"golang.org/x/exp/slog"
+)
+
+func Test_siteFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	date1 := time.Now().Round(0)
+	date2 := date1.Add(time.Second)
+
+	sub1 := &htracker.Subscription{URL: "http://site1.example/blah", Filter: "foo", ContentType: "text", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example/blub", Filter: "bar", ContentType: "byte", Interval: time.Minute}
+
+	content1 := []byte("This is Site1")
+	content1Updated := []byte("This is Site1 updated")
+
+	db := NewSiteStorage(path, slog.Default())
+
+	if _, err := db.Get(ctx, sub1); !errors.Is(err, htracker.ErrNotExist) {
+		t.Fatalf("Get() on empty storage: expected ErrNotExist, got %v", err)
+	}
+
+	site1 := &htracker.Site{Subscription: sub1, LastUpdated: date1, LastChecked: date1, Content: content1, Checksum: service.Checksum(content1)}
+	if err := db.Add(ctx, site1); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := db.Add(ctx, site1); !errors.Is(err, htracker.ErrAlreadyExists) {
+		t.Fatalf("Add() of existing site: expected ErrAlreadyExists, got %v", err)
+	}
+
+	site1Updated := &htracker.Site{Subscription: sub1, LastUpdated: date2, LastChecked: date2,
+		Content: content1Updated, Checksum: service.Checksum(content1Updated),
+		Diff: htracker.Diff{{Op: htracker.DiffInsert, Text: "diff"}}}
+	if err := db.Update(ctx, site1Updated); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if err := db.Update(ctx, &htracker.Site{Subscription: sub2}); !errors.Is(err, htracker.ErrNotExist) {
+		t.Fatalf("Update() of unknown site: expected ErrNotExist, got %v", err)
+	}
+
+	// a new instance should see the state written by the first one
+	got, err := NewSiteStorage(path, slog.Default()).Get(ctx, sub1)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if want, got := string(content1Updated), string(got.Content); want != got {
+		t.Errorf("Expected content %s, got %s", want, got)
+	}
+	if want, got := site1Updated.Checksum, got.Checksum; want != got {
+		t.Errorf("Expected checksum %s, got %s", want, got)
+	}
+	if want, got := site1Updated.Diff, got.Diff; !reflect.DeepEqual(want, got) {
+		t.Errorf("Expected diff %v, got %v", want, got)
+	}
+	if !got.LastChecked.Equal(date2) {
+		t.Errorf("Expected lastChecked %v, got %v", date2, got.LastChecked)
+	}
+}
+
+func Test_siteFile_RequestOptions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	db := NewSiteStorage(path, slog.Default())
+
+	sub := &htracker.Subscription{URL: "http://site1.example/private",
+		Request: &htracker.RequestOptions{BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"}}}
+
+	if err := db.Add(ctx, &htracker.Site{Subscription: sub, Content: []byte("content")}); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := db.Update(ctx, &htracker.Site{Subscription: sub, Content: []byte("updated")}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("secret")) {
+		t.Errorf("Expected credentials not to be written to the state file, got %s", data)
+	}
+	if sub.Request == nil {
+		t.Error("Expected request options of the given subscription to be kept")
+	}
+
+	if _, err := db.Get(ctx, sub); err != nil {
+		t.Errorf("Get() failed: %v", err)
+	}
+}
+
+func Test_siteFile_Upsert(t *testing.T) {
+	ctx := context.Background()
+	f := NewSiteStorage(filepath.Join(t.TempDir(), "state.json"), slog.Default())
+	sub := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour,
+		Request: &htracker.RequestOptions{Headers: map[string]string{"Authorization": "secret"}}}
+
+	for i, want := range []string{"1", "12", "123"} {
+		err := f.Upsert(ctx, sub, func(archived *htracker.Site) (*htracker.Site, error) {
+			if (archived == nil) != (i == 0) {
+				t.Errorf("step %d: unexpected archived site %v", i, archived)
+			}
+			if archived == nil {
+				return &htracker.Site{Subscription: sub, Content: []byte("1")}, nil
+			}
+			archived.Content = append(archived.Content, want[len(want)-1])
+			return archived, nil
+		})
+		if err != nil {
+			t.Fatalf("step %d: Upsert() failed: %v", i, err)
+		}
+
+		site, err := f.Get(ctx, sub)
+		if err != nil {
+			t.Fatalf("step %d: Get() failed: %v", i, err)
+		}
+		if string(site.Content) != want {
+			t.Errorf("step %d: Expected content %q, got %q", i, want, site.Content)
+		}
+		if site.Subscription.Request != nil {
+			t.Errorf("step %d: Expected request options not to be stored", i)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// GCStats is holding the number of orphans reclaimed by a garbage collection.
+type GCStats struct {
+	// Subscriptions is the number of subscriptions removed, which had no subscriber anymore.
+	Subscriptions int
+	// Sites is the number of archived sites removed, which had no subscription anymore.
+	Sites int
+	// Blobs is the number of blobs removed, which were not referenced by any archived site anymore.
+	Blobs int
+}
+
+// GarbageCollector is an interface describing a storage which can remove the subscriptions and archived
+// sites nobody is subscribed to anymore.
+type GarbageCollector interface {
+	CollectGarbage(context.Context) (GCStats, error)
+}
+
+// BlobReferrer is an interface describing a storage of archived sites, which are referencing their
+// content in a BlobStorage by its checksum.
+type BlobReferrer interface {
+	// BlobReferences is returning the checksums of the contents of all archived sites.
+	BlobReferences(context.Context) (map[string]bool, error)
+}
+
+// CollectBlobs is removing the blobs not referenced by any archived site of sites and returning their
+// number. Blobs put after the given time are kept, as the sites referencing them might not be archived yet.
+func CollectBlobs(ctx context.Context, blobs BlobStorage, sites BlobReferrer, before time.Time) (int, error) {
+	// the blobs are listed before the references, so that a blob put and referenced in between is kept
+	checksums, err := blobs.List(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("BlobStorage.List(): %w", err)
+	}
+	if len(checksums) == 0 {
+		return 0, nil
+	}
+
+	referenced, err := sites.BlobReferences(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("BlobReferrer.BlobReferences(): %w", err)
+	}
+
+	removed := 0
+	for _, checksum := range checksums {
+		if referenced[checksum] {
+			continue
+		}
+		// the blob is kept if it was put again in the meantime
+		ok, err := blobs.Expire(ctx, checksum, before)
+		if err != nil {
+			return removed, fmt.Errorf("BlobStorage.Expire(): %w", err)
+		}
+		if ok {
+			removed++
+		}
+	}
+
+	return removed, nil
+}
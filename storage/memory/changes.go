@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// changeBufferSize is the number of events buffered per listener, before events are dropped for slow listeners.
+const changeBufferSize = 64
+
+// changeBroker is an in-memory ChangeNotifier, delivering events to the listeners of the same process.
+type changeBroker struct {
+	logger    *slog.Logger
+	mu        sync.Mutex
+	listeners map[chan *storage.ChangeEvent]bool
+}
+
+// compile time check of interface implementation.
+var _ storage.ChangeNotifier = &changeBroker{}
+
+// NewChangeNotifier is returning a new in-memory ChangeNotifier.
+func NewChangeNotifier(logger *slog.Logger) *changeBroker {
+	return &changeBroker{logger: logger, listeners: map[chan *storage.ChangeEvent]bool{}}
+}
+
+// NotifyChange is sending the event to all listeners. Listeners not keeping up are missing the event.
+func (b *changeBroker) NotifyChange(ctx context.Context, event *storage.ChangeEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for listener := range b.listeners {
+		select {
+		case listener <- event:
+		default:
+			b.logger.Warn("change listener not keeping up, dropping event", slog.String("url", event.Subscription.URL))
+		}
+	}
+	return nil
+}
+
+// ListenChanges is returning a channel receiving the change events until ctx is canceled.
+func (b *changeBroker) ListenChanges(ctx context.Context) (<-chan *storage.ChangeEvent, error) {
+	events := make(chan *storage.ChangeEvent, changeBufferSize)
+
+	b.mu.Lock()
+	b.listeners[events] = true
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.listeners, events)
+		b.mu.Unlock()
+		close(events)
+	}()
+
+	return events, nil
+}
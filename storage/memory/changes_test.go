@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_changeBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	broker := NewChangeNotifier(slog.Default())
+
+	listener1, err := broker.ListenChanges(ctx)
+	if err != nil {
+		t.Fatalf("ListenChanges() failed: %v", err)
+	}
+	ctx2, cancel2 := context.WithCancel(ctx)
+	listener2, err := broker.ListenChanges(ctx2)
+	if err != nil {
+		t.Fatalf("ListenChanges() failed: %v", err)
+	}
+
+	sub := &htracker.Subscription{URL: "http://site1.example", Method: "POST", Body: "q=1",
+		Request: &htracker.RequestOptions{Headers: map[string]string{"Authorization": "secret"}}}
+	event := storage.NewChangeEvent(sub, time.Now())
+	if event.Subscription.Request != nil || event.Subscription.Body != "" {
+		t.Errorf("Expected change event without body and request options, got %v", event.Subscription)
+	}
+	if !event.Matches(sub) {
+		t.Errorf("Expected change event to match its subscription")
+	}
+	other := &htracker.Subscription{URL: "http://site1.example", Method: "POST", Body: "q=2"}
+	if event.Matches(other) {
+		t.Errorf("Expected change event not to match subscription with other body")
+	}
+	if err := broker.NotifyChange(ctx, event); err != nil {
+		t.Fatalf("NotifyChange() failed: %v", err)
+	}
+
+	for i, listener := range []<-chan *storage.ChangeEvent{listener1, listener2} {
+		select {
+		case got := <-listener:
+			if got != event {
+				t.Errorf("listener %d: Expected event %v, got %v", i, event, got)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("listener %d: no event received", i)
+		}
+	}
+
+	// the channel of a canceled listener is closed
+	cancel2()
+	select {
+	case _, ok := <-listener2:
+		if ok {
+			t.Errorf("Expected channel of canceled listener to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("channel of canceled listener not closed")
+	}
+}
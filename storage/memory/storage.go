@@ -14,8 +14,10 @@ import (
 type memDB struct {
 	archive     []*htracker.Site
 	subscribers []*storage.Subscriber
-	logger      *slog.Logger
-	mu          sync.Mutex
+	// versions are the versions of the archived sites by the key of their subscription, latest first
+	versions map[string][]*htracker.Version
+	logger   *slog.Logger
+	mu       sync.Mutex
 }
 
 // NewSiteStorage returns a new in-memory site content storage which can be used by a SiteArchive service.
@@ -23,6 +25,12 @@ func NewSiteStorage(logger *slog.Logger) *memDB {
 	return &memDB{logger: logger}
 }
 
+// NewStorage returns a new in-memory storage which can be used by both a SiteArchive and a SubscriptionSvc.
+// Other than with separate storages, archived sites are removed with the last subscription of their site.
+func NewStorage(logger *slog.Logger) *memDB {
+	return &memDB{logger: logger}
+}
+
 // NewSubscriptionStorage returns a new in-memory SubscriptionStorage which can be used by a SubscriptionSvc.
 func NewSubscriptionStorage(logger *slog.Logger) *memDB {
 	return &memDB{logger: logger}
@@ -64,7 +72,9 @@ func (db *memDB) Add(ctx context.Context, site *htracker.Site) error {
 		LastChecked:  site.LastChecked,
 		Content:      site.Content,
 		Checksum:     site.Checksum,
-		Diff:         "",
+		Diff:         nil,
+		State:        site.State,
+		Screenshot:   site.Screenshot,
 	})
 
 	return nil
@@ -81,8 +91,11 @@ func (db *memDB) Update(ctx context.Context, site *htracker.Site) error {
 			asite.LastChecked = site.LastChecked
 			asite.LastUpdated = site.LastUpdated
 			asite.Diff = site.Diff
+			asite.Metrics = site.Metrics
+			asite.Screenshot = site.Screenshot
 			asite.Content = site.Content
 			asite.Checksum = site.Checksum
+			asite.State = site.State
 
 			return nil
 		}
@@ -90,6 +103,35 @@ func (db *memDB) Update(ctx context.Context, site *htracker.Site) error {
 	return htracker.ErrNotExist
 }
 
+// Upsert is atomically adding or updating the site of the subscription with the site returned by update.
+// The archive is locked while update is called.
+func (db *memDB) Upsert(ctx context.Context, subscription *htracker.Subscription, update storage.UpdateFunc) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i, asite := range db.archive {
+		if subscription.Equals(asite.Subscription) {
+			// update is getting a copy, so that the archive is unchanged if it fails
+			archived := *asite
+			site, err := update(&archived)
+			if err != nil || site == nil {
+				return err
+			}
+			updated := *site
+			db.archive[i] = &updated
+			return nil
+		}
+	}
+
+	site, err := update(nil)
+	if err != nil || site == nil {
+		return err
+	}
+	added := *site
+	db.archive = append(db.archive, &added)
+	return nil
+}
+
 /*** Implementation of SubscriptionStorage interface ***/
 
 // compile time check of interface implementation.
@@ -205,6 +247,7 @@ func (db *memDB) RemoveSubscription(ctx context.Context, email string, subscript
 					// remove element i from list
 					subscriber.Subscriptions[i] = subscriber.Subscriptions[len(subscriber.Subscriptions)-1]
 					subscriber.Subscriptions = subscriber.Subscriptions[:len(subscriber.Subscriptions)-1]
+					db.removeOrphanSites()
 					return nil
 				}
 			}
@@ -227,8 +270,77 @@ func (db *memDB) RemoveSubscriber(ctx context.Context, email string) error {
 			// remove element i from list
 			db.subscribers[i] = db.subscribers[len(db.subscribers)-1]
 			db.subscribers = db.subscribers[:len(db.subscribers)-1]
+			db.removeOrphanSites()
 			return nil
 		}
 	}
 	return htracker.ErrNotExist
 }
+
+/*** Implementation of GarbageCollector interface ***/
+
+// compile time check of interface implementation.
+var _ storage.GarbageCollector = &memDB{}
+
+// CollectGarbage is removing the archived sites nobody is subscribed to anymore. Subscriptions are
+// stored with their subscribers, so there are no orphan subscriptions. It must only be used with a
+// storage created by NewStorage, as the archive of a site storage has no subscriptions at all.
+func (db *memDB) CollectGarbage(ctx context.Context) (storage.GCStats, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return storage.GCStats{Sites: db.removeOrphanSites()}, nil
+}
+
+// compile time check of interface implementation.
+var _ storage.BlobReferrer = &memDB{}
+
+// BlobReferences is returning the checksums of the contents of all archived sites and their versions.
+func (db *memDB) BlobReferences(ctx context.Context) (map[string]bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	references := make(map[string]bool, len(db.archive))
+	for _, site := range db.archive {
+		references[site.Checksum] = true
+	}
+	for _, versions := range db.versions {
+		for _, v := range versions {
+			references[v.Checksum] = true
+		}
+	}
+	return references, nil
+}
+
+// removeOrphanSites is removing the archived sites nobody is subscribed to and returning their number.
+// The caller must hold the lock.
+func (db *memDB) removeOrphanSites() int {
+	archive := db.archive[:0]
+	for _, site := range db.archive {
+		if db.subscribed(site.Subscription) {
+			archive = append(archive, site)
+			continue
+		}
+		delete(db.versions, site.Subscription.Key())
+	}
+
+	removed := len(db.archive) - len(archive)
+	// clear the tail, so that the removed sites can be garbage collected
+	for i := len(archive); i < len(db.archive); i++ {
+		db.archive[i] = nil
+	}
+	db.archive = archive
+	return removed
+}
+
+// subscribed is returning whether any subscriber is subscribed to subscription. The caller must hold the lock.
+func (db *memDB) subscribed(subscription *htracker.Subscription) bool {
+	for _, subscriber := range db.subscribers {
+		for _, s := range subscriber.Subscriptions {
+			if s.Equals(subscription) {
+				return true
+			}
+		}
+	}
+	return false
+}
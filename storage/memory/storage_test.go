@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 	"time"
@@ -193,3 +194,126 @@ func Test_memDB_AddSubscriber(t *testing.T) {
 		})
 	}
 }
+
+func Test_memDB_RemoveOrphanSites(t *testing.T) {
+	ctx := context.Background()
+	sub1 := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example", Interval: time.Hour}
+	db := NewStorage(slog.Default())
+
+	for _, email := range []string{"email1", "email2"} {
+		if err := db.AddSubscriber(ctx, &storage.Subscriber{Email: email}); err != nil {
+			t.Fatalf("Setup: failed to add subscriber: %v", err)
+		}
+		if err := db.AddSubscription(ctx, email, sub1); err != nil {
+			t.Fatalf("Setup: failed to add subscription: %v", err)
+		}
+	}
+	if err := db.AddSubscription(ctx, "email1", sub2); err != nil {
+		t.Fatalf("Setup: failed to add subscription: %v", err)
+	}
+	for _, sub := range []*htracker.Subscription{sub1, sub2} {
+		if err := db.Add(ctx, &htracker.Site{Subscription: sub, LastChecked: time.Now()}); err != nil {
+			t.Fatalf("Setup: failed to add site: %v", err)
+		}
+	}
+
+	steps := []struct {
+		name      string
+		remove    func() error
+		wantSites []*htracker.Subscription
+	}{
+		{name: "site still subscribed", remove: func() error { return db.RemoveSubscription(ctx, "email2", sub1) },
+			wantSites: []*htracker.Subscription{sub1, sub2}},
+		{name: "last subscription", remove: func() error { return db.RemoveSubscription(ctx, "email1", sub2) },
+			wantSites: []*htracker.Subscription{sub1}},
+		{name: "last subscriber", remove: func() error { return db.RemoveSubscriber(ctx, "email1") },
+			wantSites: []*htracker.Subscription{}},
+	}
+
+	for _, step := range steps {
+		if err := step.remove(); err != nil {
+			t.Fatalf("%s: remove failed: %v", step.name, err)
+		}
+		gotSites := []*htracker.Subscription{}
+		for _, site := range db.archive {
+			gotSites = append(gotSites, site.Subscription)
+		}
+		if !reflect.DeepEqual(gotSites, step.wantSites) {
+			t.Errorf("%s: Expected sites %v, got %v", step.name, step.wantSites, gotSites)
+		}
+	}
+}
+
+func Test_memDB_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	sub1 := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example", Interval: time.Hour}
+	sub3 := &htracker.Subscription{URL: "http://site1.example", UseChrome: true, Interval: time.Hour}
+
+	db := &memDB{
+		logger:      slog.Default(),
+		subscribers: []*storage.Subscriber{{Email: "email1", Subscriptions: []*htracker.Subscription{sub1}}},
+		archive:     []*htracker.Site{{Subscription: sub1}, {Subscription: sub2}, {Subscription: sub3}},
+	}
+
+	stats, err := db.CollectGarbage(ctx)
+	if err != nil {
+		t.Fatalf("CollectGarbage() failed: %v", err)
+	}
+	if want := (storage.GCStats{Sites: 2}); stats != want {
+		t.Errorf("Expected stats %+v, got %+v", want, stats)
+	}
+	if len(db.archive) != 1 || db.archive[0].Subscription != sub1 {
+		t.Errorf("Expected only the site of sub1 to be kept, got %v", db.archive)
+	}
+}
+
+func Test_memDB_Upsert(t *testing.T) {
+	ctx := context.Background()
+	sub := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour}
+	db := NewSiteStorage(slog.Default())
+
+	// every upsert is appending to the content of the site, concurrent upserts must not lose an append
+	const upserts = 50
+	errs := make(chan error, upserts)
+	for i := 0; i < upserts; i++ {
+		go func() {
+			errs <- db.Upsert(ctx, sub, func(archived *htracker.Site) (*htracker.Site, error) {
+				if archived == nil {
+					return &htracker.Site{Subscription: sub, Content: []byte("x")}, nil
+				}
+				archived.Content = append(archived.Content[:len(archived.Content):len(archived.Content)], 'x')
+				return archived, nil
+			})
+		}()
+	}
+	for i := 0; i < upserts; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Upsert() failed: %v", err)
+		}
+	}
+
+	site, err := db.Get(ctx, sub)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if len(site.Content) != upserts {
+		t.Errorf("Expected content of %d bytes, got %d", upserts, len(site.Content))
+	}
+
+	// failed and empty updates are leaving the site unchanged
+	wantErr := errors.New("failed")
+	if err := db.Upsert(ctx, sub, func(archived *htracker.Site) (*htracker.Site, error) {
+		archived.Content = nil
+		return archived, wantErr
+	}); !errors.Is(err, wantErr) {
+		t.Errorf("Expected Upsert() to return the error of the update, got %v", err)
+	}
+	if err := db.Upsert(ctx, sub, func(archived *htracker.Site) (*htracker.Site, error) { return nil, nil }); err != nil {
+		t.Errorf("Upsert() failed: %v", err)
+	}
+	if site, _ := db.Get(ctx, sub); len(site.Content) != upserts {
+		t.Errorf("Expected unchanged content of %d bytes, got %d", upserts, len(site.Content))
+	}
+}
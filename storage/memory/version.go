@@ -0,0 +1,82 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+)
+
+/*** Implementation of VersionStorage interface ***/
+
+// compile time check of interface implementation.
+var _ storage.VersionStorage = &memDB{}
+
+// AddVersion is adding the content of the archived site as version scraped at site.LastChecked.
+func (db *memDB) AddVersion(ctx context.Context, site *htracker.Site) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.versions == nil {
+		db.versions = map[string][]*htracker.Version{}
+	}
+	key := site.Subscription.Key()
+	versions := append(db.versions[key], &htracker.Version{Archived: site.LastChecked, Checksum: site.Checksum,
+		Content: site.Content})
+	sort.SliceStable(versions, func(i, j int) bool { return versions[i].Archived.After(versions[j].Archived) })
+	db.versions[key] = versions
+	return nil
+}
+
+// GetVersions is returning the versions of the site of the subscription, latest first. The versions
+// are copies, so that changing them is not changing the history.
+func (db *memDB) GetVersions(ctx context.Context, subscription *htracker.Subscription) ([]*htracker.Version, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	versions := make([]*htracker.Version, len(db.versions[subscription.Key()]))
+	for i, v := range db.versions[subscription.Key()] {
+		version := *v
+		versions[i] = &version
+	}
+	return versions, nil
+}
+
+// PruneVersions is removing the versions not kept by the retention policy of the subscription of their
+// site, or by the given default policy if it has none.
+func (db *memDB) PruneVersions(ctx context.Context, defaultPolicy htracker.RetentionPolicy) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+	pruned := 0
+	for _, site := range db.archive {
+		key := site.Subscription.Key()
+		versions := db.versions[key]
+		if len(versions) == 0 {
+			continue
+		}
+
+		policy := defaultPolicy
+		if site.Subscription.Retention != nil {
+			policy = *site.Subscription.Retention
+		}
+		archived := make([]time.Time, len(versions))
+		for i, v := range versions {
+			archived[i] = v.Archived
+		}
+
+		kept := []*htracker.Version{}
+		for i, keep := range policy.Keep(archived, now) {
+			if keep {
+				kept = append(kept, versions[i])
+			}
+		}
+		pruned += len(versions) - len(kept)
+		db.versions[key] = kept
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_memDB_Versions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	sub1 := &htracker.Subscription{URL: "http://site1.example", Interval: time.Hour}
+	sub2 := &htracker.Subscription{URL: "http://site2.example", Interval: time.Hour,
+		Retention: &htracker.RetentionPolicy{KeepLast: 1}}
+	db := NewStorage(slog.Default())
+
+	if err := db.AddSubscriber(ctx, &storage.Subscriber{Email: "email1"}); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+	for _, sub := range []*htracker.Subscription{sub1, sub2} {
+		if err := db.AddSubscription(ctx, "email1", sub); err != nil {
+			t.Fatalf("Setup: failed to add subscription: %v", err)
+		}
+		if err := db.Add(ctx, &htracker.Site{Subscription: sub, LastChecked: now}); err != nil {
+			t.Fatalf("Setup: failed to add site: %v", err)
+		}
+		// added out of order, the versions must be returned latest first
+		for _, age := range []time.Duration{2 * time.Hour, 0, time.Hour} {
+			site := &htracker.Site{Subscription: sub, LastChecked: now.Add(-age), Checksum: age.String(), Content: []byte(age.String())}
+			if err := db.AddVersion(ctx, site); err != nil {
+				t.Fatalf("AddVersion() failed: %v", err)
+			}
+		}
+	}
+
+	got, err := db.GetVersions(ctx, sub1)
+	if err != nil {
+		t.Fatalf("GetVersions() failed: %v", err)
+	}
+	want := []*htracker.Version{
+		{Archived: now, Checksum: "0s", Content: []byte("0s")},
+		{Archived: now.Add(-time.Hour), Checksum: "1h0m0s", Content: []byte("1h0m0s")},
+		{Archived: now.Add(-2 * time.Hour), Checksum: "2h0m0s", Content: []byte("2h0m0s")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetVersions() = %v, want %v", got, want)
+	}
+
+	// sub1 is pruned by the default policy, sub2 by its own one
+	pruned, err := db.PruneVersions(ctx, htracker.RetentionPolicy{KeepWithin: 90 * time.Minute})
+	if err != nil {
+		t.Fatalf("PruneVersions() failed: %v", err)
+	}
+	if pruned != 3 {
+		t.Errorf("Expected 3 pruned versions, got %d", pruned)
+	}
+	for _, tt := range []struct {
+		sub  *htracker.Subscription
+		want int
+	}{{sub1, 2}, {sub2, 1}} {
+		if got, _ := db.GetVersions(ctx, tt.sub); len(got) != tt.want {
+			t.Errorf("Expected %d versions of %s after pruning, got %d", tt.want, tt.sub.URL, len(got))
+		}
+	}
+
+	refs, err := db.BlobReferences(ctx)
+	if err != nil {
+		t.Fatalf("BlobReferences() failed: %v", err)
+	}
+	if !refs["1h0m0s"] || refs["2h0m0s"] {
+		t.Errorf("Expected the checksums of the kept versions to be referenced only, got %v", refs)
+	}
+
+	if err := db.RemoveSubscription(ctx, "email1", sub1); err != nil {
+		t.Fatalf("RemoveSubscription() failed: %v", err)
+	}
+	if got, _ := db.GetVersions(ctx, sub1); len(got) != 0 {
+		t.Errorf("Expected the versions of the removed site to be removed, got %v", got)
+	}
+}
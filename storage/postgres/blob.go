@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// blob is a row of the blobs table.
+type blob struct {
+	Checksum    string
+	Compression string
+	Data        []byte
+	LastPut     time.Time `db:"last_put"`
+}
+
+// blobTable is a BlobStorage keeping the blobs in the blobs table, so that big contents are not
+// bloating the sites table.
+type blobTable struct {
+	db          *db
+	compression storage.Compression
+}
+
+// compile time check of interface implementation.
+var _ storage.BlobStorage = &blobTable{}
+
+// BlobOpt is a functional option for the BlobStorage.
+type BlobOpt func(*blobTable)
+
+// WithBlobCompression is setting the compression of blobs written, blobs are read in any compression.
+func WithBlobCompression(c storage.Compression) BlobOpt {
+	return func(b *blobTable) {
+		b.compression = c
+	}
+}
+
+// BlobStorage is returning a BlobStorage using the connection of db.
+func (db *db) BlobStorage(opts ...BlobOpt) *blobTable {
+	b := &blobTable{db: db, compression: storage.CompressionNone}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Put is storing content under checksum, if it is not stored yet. Otherwise the time of its last put is updated.
+func (b *blobTable) Put(ctx context.Context, checksum string, content []byte) error {
+	if err := storage.ValidateChecksum(checksum); err != nil {
+		return err
+	}
+
+	// the content is only compressed and sent if not stored yet, as it might be big
+	res, err := b.db.conn.ExecContext(ctx, "UPDATE blobs SET last_put = now() WHERE checksum = $1", checksum)
+	var touched int64
+	if err == nil {
+		touched, err = res.RowsAffected()
+	}
+	if err != nil {
+		b.db.logger.Error("query failed", err, slog.String("method", "PutBlob"), slog.String("checksum", checksum))
+		return wrapError(err)
+	}
+	if touched > 0 {
+		return nil
+	}
+
+	data, err := b.compression.Compress(content)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = []byte{}
+	}
+
+	_, err = b.db.conn.ExecContext(ctx, `INSERT INTO blobs (checksum, compression, data) VALUES ($1, $2, $3)
+		ON CONFLICT (checksum) DO UPDATE SET last_put = now()`, checksum, b.compression, data)
+	if err != nil {
+		b.db.logger.Error("query failed", err, slog.String("method", "PutBlob"), slog.String("checksum", checksum))
+		return wrapError(err)
+	}
+	return nil
+}
+
+// Get is returning the content stored under checksum or ErrNotExist if not found.
+func (b *blobTable) Get(ctx context.Context, checksum string) ([]byte, error) {
+	row := &blob{}
+	if err := b.db.conn.GetContext(ctx, row, "SELECT * FROM blobs WHERE checksum = $1", checksum); err != nil {
+		b.db.logger.Error("query failed", err, slog.String("method", "GetBlob"), slog.String("checksum", checksum))
+		return nil, wrapError(err)
+	}
+
+	return storage.Compression(row.Compression).Decompress(row.Data)
+}
+
+// Delete is removing the content stored under checksum, if stored.
+func (b *blobTable) Delete(ctx context.Context, checksum string) error {
+	if _, err := b.db.conn.ExecContext(ctx, "DELETE FROM blobs WHERE checksum = $1", checksum); err != nil {
+		b.db.logger.Error("query failed", err, slog.String("method", "DeleteBlob"), slog.String("checksum", checksum))
+		return wrapError(err)
+	}
+	return nil
+}
+
+// List is returning the checksums of the blobs last put before the given time.
+func (b *blobTable) List(ctx context.Context, before time.Time) ([]string, error) {
+	checksums := []string{}
+	if err := b.db.conn.SelectContext(ctx, &checksums, "SELECT checksum FROM blobs WHERE last_put < $1", before); err != nil {
+		b.db.logger.Error("query failed", err, slog.String("method", "ListBlobs"))
+		return nil, wrapError(err)
+	}
+	return checksums, nil
+}
+
+// Expire is removing the content stored under checksum, if it was last put before the given time.
+func (b *blobTable) Expire(ctx context.Context, checksum string, before time.Time) (bool, error) {
+	res, err := b.db.conn.ExecContext(ctx, "DELETE FROM blobs WHERE checksum = $1 AND last_put < $2", checksum, before)
+	var deleted int64
+	if err == nil {
+		deleted, err = res.RowsAffected()
+	}
+	if err != nil {
+		b.db.logger.Error("query failed", err, slog.String("method", "ExpireBlob"), slog.String("checksum", checksum))
+		return false, wrapError(err)
+	}
+	return deleted > 0, nil
+}
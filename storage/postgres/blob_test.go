@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_blobTable(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	content := []byte("blob content ä😎")
+	checksum := service.Checksum(content)
+	gzipped := db.BlobStorage(WithBlobCompression(storage.CompressionGzip))
+	plain := db.BlobStorage()
+
+	for _, b := range []*blobTable{gzipped, plain} {
+		if err := b.Put(ctx, checksum, content); err != nil {
+			t.Fatalf("Put() failed: %v", err)
+		}
+	}
+	// the blob is stored gzipped by the first Put and readable in any compression
+	got, err := plain.Get(ctx, checksum)
+	if err != nil {
+		t.Fatalf("Get() failed: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Errorf("Get() = %q, want %q", got, content)
+	}
+
+	if err := plain.Put(ctx, service.Checksum(nil), nil); err != nil {
+		t.Errorf("Put() of empty content failed: %v", err)
+	}
+
+	// the blob was put just now
+	if expired, err := plain.Expire(ctx, checksum, time.Now().Add(-time.Hour)); err != nil || expired {
+		t.Errorf("Expire() of recently put blob = %v, %v, want false", expired, err)
+	}
+	listed, err := plain.List(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("List() failed: %v", err)
+	}
+	found := false
+	for _, c := range listed {
+		found = found || c == checksum
+	}
+	if !found {
+		t.Errorf("Expected List() to return %s, got %v", checksum, listed)
+	}
+
+	if err := plain.Delete(ctx, checksum); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := plain.Get(ctx, checksum); !errors.Is(err, htracker.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist for deleted blob, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// changeChannel is the channel of the notifications of changed sites.
+const changeChannel = "htracker_changes"
+
+// maxNotifyPayload is the max size of the payload of a notification, as limited by postgres.
+const maxNotifyPayload = 7999
+
+// changeBufferSize is the number of events buffered for a listener.
+const changeBufferSize = 64
+
+// compile time check of interface implementation.
+var _ storage.ChangeNotifier = &db{}
+
+// NotifyChange is sending the event to the listeners of all instances using the database. An error wrapping
+// ErrInvalid is returned if the event is too big to be sent, e.g. because of a huge filter.
+func (db *db) NotifyChange(ctx context.Context, event *storage.ChangeEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode change event: %w", err)
+	}
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("change event of %s exceeds the max size of %d bytes: %w", event.Subscription.URL,
+			maxNotifyPayload, htracker.ErrInvalid)
+	}
+
+	if _, err := db.conn.ExecContext(ctx, "SELECT pg_notify($1, $2)", changeChannel, string(payload)); err != nil {
+		db.logger.Error("query failed", err, slog.String("method", "NotifyChange"), slog.String("url", event.Subscription.URL))
+		return wrapError(err)
+	}
+	return nil
+}
+
+// ListenChanges is returning a channel receiving the change events of all instances until ctx is canceled.
+// The listener is using its own connection, which is reestablished if lost. Events sent while the
+// connection is lost are missed.
+func (db *db) ListenChanges(ctx context.Context) (<-chan *storage.ChangeEvent, error) {
+	logger := db.logger.With(slog.String("method", "ListenChanges"))
+
+	listener := pq.NewListener(db.uri, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Error("change listener connection failed", err, slog.Int("event", int(ev)))
+		}
+	})
+	if err := listener.Listen(changeChannel); err != nil {
+		logger.Error("failed to listen for changes", err)
+		listener.Close()
+		return nil, err
+	}
+
+	events := make(chan *storage.ChangeEvent, changeBufferSize)
+	go func() {
+		defer close(events)
+		defer listener.Close()
+
+		for {
+			select {
+			case n := <-listener.Notify:
+				// nil is sent after the connection was reestablished
+				if n == nil {
+					logger.Warn("change listener reconnected, changes might have been missed")
+					continue
+				}
+				event := &storage.ChangeEvent{}
+				err := json.Unmarshal([]byte(n.Extra), event)
+				if err == nil && event.Subscription == nil {
+					err = fmt.Errorf("change event without subscription: %w", htracker.ErrInvalid)
+				}
+				if err != nil {
+					logger.Error("failed to decode change event", err, slog.String("payload", n.Extra))
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
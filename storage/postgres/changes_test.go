@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_db_ListenChanges(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// two instances sharing the database
+	db1, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+	db2, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	events, err := db2.ListenChanges(ctx)
+	if err != nil {
+		t.Fatalf("db.ListenChanges() failed: %v", err)
+	}
+	// the listener is connecting asynchronously
+	time.Sleep(500 * time.Millisecond)
+
+	lastUpdated := time.Now().Round(time.Millisecond)
+	sub := &htracker.Subscription{URL: "changesite1", Filter: "filter1", Method: "POST", Body: "q=1"}
+	if err := db1.NotifyChange(ctx, storage.NewChangeEvent(sub, lastUpdated)); err != nil {
+		t.Fatalf("db.NotifyChange() failed: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if !event.Matches(sub) || !event.LastUpdated.Equal(lastUpdated) {
+			t.Errorf("Expected change event of %v at %v, got %v at %v", sub, lastUpdated, event.Subscription, event.LastUpdated)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no change event received")
+	}
+
+	huge := &htracker.Subscription{URL: "changesite2", Filter: strings.Repeat("x", maxNotifyPayload)}
+	if err := db1.NotifyChange(ctx, storage.NewChangeEvent(huge, lastUpdated)); !errors.Is(err, htracker.ErrInvalid) {
+		t.Errorf("Expected ErrInvalid for huge change event, got %v", err)
+	}
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("Expected channel of canceled listener to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("channel of canceled listener not closed")
+	}
+}
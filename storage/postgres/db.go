@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"errors"
@@ -13,6 +14,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
 	"golang.org/x/exp/slog"
 )
 
@@ -20,24 +22,52 @@ const stylePostgres = "postgres"
 const driverPostgres = "postgres"
 
 type db struct {
+	// uri is needed for the separate connection of change listeners
+	uri    string
 	conn   *sqlx.DB
 	logger *slog.Logger
+	cipher *storage.Cipher
 }
 
-func New(uri string, logger *slog.Logger) (*db, error) {
+// Opt is a functional option for the postgres storage.
+type Opt func(*db)
+
+// WithCipher is setting the cipher used to encrypt the request options of subscriptions at rest.
+// Without a cipher, subscriptions with request options can't be stored.
+func WithCipher(c *storage.Cipher) Opt {
+	return func(db *db) {
+		db.cipher = c
+	}
+}
+
+func New(uri string, logger *slog.Logger, opts ...Opt) (*db, error) {
 	db := &db{}
+	for _, opt := range opts {
+		opt(db)
+	}
 	conn, err := sqlx.Open(driverPostgres, uri)
 	if err != nil {
 		return db, err
 	}
 	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return db, err
 	}
 	db.conn = conn
+	db.uri = uri
 	db.logger = logger.With(slog.String("driver", driverPostgres))
+	if err := db.updateVariants(context.Background()); err != nil {
+		conn.Close()
+		return db, err
+	}
 	return db, nil
 }
 
+// Close is closing the connections to the database.
+func (db *db) Close() error {
+	return db.conn.Close()
+}
+
 // wrapError is translating some postgres errors into domain errors.
 func wrapError(err error) error {
 	switch e := err.(type) {
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// compile time check of interface implementation.
+var _ storage.GarbageCollector = &db{}
+
+// CollectGarbage is removing the subscriptions without subscribers and the archived sites without
+// subscriptions in one transaction, e.g. left over by removals before orphans were cleaned up.
+func (db *db) CollectGarbage(ctx context.Context) (storage.GCStats, error) {
+	logger := db.logger.With(slog.String("method", "CollectGarbage"))
+	stats := storage.GCStats{}
+
+	tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		logger.Error("failed to begin a transaction", err)
+		return stats, err
+	}
+
+	// subscriptions are deleted first, as their sites are becoming orphans then
+	for _, del := range []struct {
+		query string
+		count *int
+	}{
+		{query: deleteOrphanSubscriptionsQuery, count: &stats.Subscriptions},
+		{query: deleteOrphanSitesQuery, count: &stats.Sites},
+	} {
+		res, err := tx.ExecContext(ctx, del.query)
+		if err == nil {
+			var count int64
+			count, err = res.RowsAffected()
+			*del.count = int(count)
+		}
+		if err != nil {
+			logger.Error("query failed, rolling back transaction", err)
+			if err := tx.Rollback(); err != nil {
+				logger.Error("rollback failed", err)
+			}
+			return storage.GCStats{}, wrapError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit the transaction", err)
+		return storage.GCStats{}, err
+	}
+
+	return stats, nil
+}
+
+// compile time check of interface implementation.
+var _ storage.BlobReferrer = &db{}
+
+// BlobReferences is returning the checksums of the contents of all archived sites and their versions.
+func (db *db) BlobReferences(ctx context.Context) (map[string]bool, error) {
+	checksums := []string{}
+	if err := db.conn.SelectContext(ctx, &checksums, "SELECT checksum FROM sites UNION SELECT checksum FROM site_versions"); err != nil {
+		db.logger.Error("query failed", err, slog.String("method", "BlobReferences"))
+		return nil, wrapError(err)
+	}
+
+	references := make(map[string]bool, len(checksums))
+	for _, checksum := range checksums {
+		references[checksum] = true
+	}
+	return references, nil
+}
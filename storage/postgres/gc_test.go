@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_db_CollectGarbage(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber := &storage.Subscriber{Email: "gcemail1"}
+	subscribed := &htracker.Subscription{URL: "gcsite1", Interval: time.Hour}
+	orphan := &htracker.Subscription{URL: "gcsite2", Interval: time.Hour}
+	if err := db.AddSubscriber(ctx, subscriber); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+	if err := db.AddSubscription(ctx, subscriber.Email, subscribed); err != nil {
+		t.Fatalf("Setup: failed to add subscription: %v", err)
+	}
+	for _, sub := range []*htracker.Subscription{subscribed, orphan} {
+		if err := db.Add(ctx, &htracker.Site{Subscription: sub, LastChecked: time.Now(), Checksum: "1234"}); err != nil {
+			t.Fatalf("Setup: failed to add site: %v", err)
+		}
+	}
+
+	stats, err := db.CollectGarbage(ctx)
+	if err != nil {
+		t.Fatalf("db.CollectGarbage() failed: %v", err)
+	}
+	// other tests might have left orphans too
+	if stats.Sites < 1 {
+		t.Errorf("Expected at least 1 site to be removed, got %+v", stats)
+	}
+	if _, err := db.Get(ctx, orphan); !errors.Is(err, htracker.ErrNotExist) {
+		t.Errorf("Expected orphan site to be removed, got %v", err)
+	}
+	if _, err := db.Get(ctx, subscribed); err != nil {
+		t.Errorf("Expected subscribed site to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// leaderLock is electing a leader of the instances using the database with a session level advisory lock.
+// The leader is holding the lock on a connection reserved for it, as long as the connection is alive.
+type leaderLock struct {
+	db     *db
+	name   string
+	key    int64
+	logger *slog.Logger
+
+	// mu is guarding conn, which is holding the lock if not nil
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// LeaderElector is returning an elector of the leader of the instances electing a leader with the same name.
+func (db *db) LeaderElector(name string) *leaderLock {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return &leaderLock{db: db, name: name, key: int64(h.Sum64()),
+		logger: db.logger.With(slog.String("method", "LeaderElector"), slog.String("name", name))}
+}
+
+// Elect is trying to acquire the lock of the leader, if not held yet, and returning whether it is held.
+func (l *leaderLock) Elect(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		// the lock is released by postgres with the session
+		l.logger.Warn("connection of leader lost, the lock was released")
+		l.discard()
+	}
+
+	conn, err := l.db.conn.Conn(ctx)
+	if err != nil {
+		l.logger.Error("failed to reserve a connection", err)
+		return false, wrapError(err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked); err != nil {
+		l.logger.Error("query failed", err)
+		conn.Close()
+		return false, wrapError(err)
+	}
+	if !locked {
+		conn.Close()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// Resign is releasing the lock of the leader, if held.
+func (l *leaderLock) Resign() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+
+	var unlocked bool
+	err := l.conn.QueryRowContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key).Scan(&unlocked)
+	if err == nil && !unlocked {
+		err = fmt.Errorf("leader lock %s was not held", l.name)
+	}
+	if err != nil {
+		// the connection must not be reused while holding the lock
+		l.logger.Error("failed to release the lock, closing the connection", err)
+		l.discard()
+		return wrapError(err)
+	}
+
+	err = l.conn.Close()
+	l.conn = nil
+	return err
+}
+
+// discard is closing the reserved connection instead of returning it to the pool, which is releasing
+// the lock if still held. The caller must hold mu.
+func (l *leaderLock) discard() {
+	// returning ErrBadConn is making database/sql close the connection
+	_ = l.conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	l.conn.Close()
+	l.conn = nil
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker/service"
+	"gitlab.com/henri.philipps/htracker/storage/memory"
+	"gitlab.com/henri.philipps/htracker/watcher"
+	"golang.org/x/exp/slog"
+)
+
+func Test_leaderLock(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db1, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+	db2, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+	l1, l2 := db1.LeaderElector("leadertest1"), db2.LeaderElector("leadertest1")
+	defer l1.Resign()
+	defer l2.Resign()
+
+	steps := []struct {
+		name       string
+		elector    *leaderLock
+		resign     bool
+		wantLeader bool
+	}{
+		{name: "first is elected", elector: l1, wantLeader: true},
+		{name: "second is not elected", elector: l2},
+		{name: "first stays leader", elector: l1, wantLeader: true},
+		{name: "first resigns", elector: l1, resign: true},
+		{name: "second takes over", elector: l2, wantLeader: true},
+		{name: "first is not elected again", elector: l1},
+	}
+
+	for _, step := range steps {
+		if step.resign {
+			if err := step.elector.Resign(); err != nil {
+				t.Fatalf("%s: Resign() failed: %v", step.name, err)
+			}
+			continue
+		}
+		leader, err := step.elector.Elect(ctx)
+		if err != nil {
+			t.Fatalf("%s: Elect() failed: %v", step.name, err)
+		}
+		if leader != step.wantLeader {
+			t.Errorf("%s: Expected leader %v, got %v", step.name, step.wantLeader, leader)
+		}
+	}
+}
+
+// countingSubscriptionSvc is counting the calls of GetSubscribers(), which is called once per scrape run of a watcher.
+type countingSubscriptionSvc struct {
+	service.SubscriptionSvc
+	runs atomic.Int32
+}
+
+func (svc *countingSubscriptionSvc) GetSubscribers(ctx context.Context) ([]*service.Subscriber, error) {
+	svc.runs.Add(1)
+	return svc.SubscriptionSvc.GetSubscribers(ctx)
+}
+
+func Test_leaderLock_Watchers(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	logger := slog.Default()
+	type replica struct {
+		svc    *countingSubscriptionSvc
+		cancel context.CancelFunc
+		done   chan error
+	}
+
+	// two replicas with their own connections to the same database
+	replicas := []*replica{}
+	for i := 0; i < 2; i++ {
+		db, err := New(URIfromEnvVars(), logger)
+		if err != nil {
+			t.Fatalf("Failed to open DB connection: %v", err)
+		}
+		svc := &countingSubscriptionSvc{SubscriptionSvc: service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger))}
+		w := watcher.NewWatcher(service.NewSiteArchive(memory.NewSiteStorage(logger)), svc, watcher.WithLogger(logger),
+			watcher.WithInterval(10*time.Millisecond), watcher.WithElector(db.LeaderElector("leadertest2")),
+			watcher.WithElectionInterval(10*time.Millisecond))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := &replica{svc: svc, cancel: cancel, done: make(chan error)}
+		go func() { r.done <- w.Start(ctx) }()
+		replicas = append(replicas, r)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	runs1, runs2 := replicas[0].svc.runs.Load(), replicas[1].svc.runs.Load()
+	if (runs1 > 0) == (runs2 > 0) {
+		t.Fatalf("Expected exactly one watcher to scrape, got %d and %d runs", runs1, runs2)
+	}
+
+	// the other watcher is taking over if the leader stopped
+	leader, standby := replicas[0], replicas[1]
+	if runs2 > 0 {
+		leader, standby = replicas[1], replicas[0]
+	}
+	leader.cancel()
+	if err := <-leader.done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watcher.Start() expected context.Canceled, got %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if standby.svc.runs.Load() == 0 {
+		t.Errorf("Expected the standby watcher to take over")
+	}
+}
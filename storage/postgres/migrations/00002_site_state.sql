@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS state text NOT NULL DEFAULT 'ok';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sites DROP COLUMN IF EXISTS state;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS method text NOT NULL DEFAULT 'GET';
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS body text NOT NULL DEFAULT '';
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_url_filter_content_type_key;
+-- the body is hashed, as it might exceed the max size of an index entry
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_site_key ON subscriptions (url, filter, content_type, method, md5(body));
+
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS method text NOT NULL DEFAULT 'GET';
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS body text NOT NULL DEFAULT '';
+ALTER TABLE sites DROP CONSTRAINT IF EXISTS sites_pkey;
+CREATE UNIQUE INDEX IF NOT EXISTS sites_site_key ON sites (url, filter, content_type, method, md5(body));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS sites_site_key;
+DELETE FROM sites WHERE method <> 'GET' OR body <> '';
+ALTER TABLE sites ADD PRIMARY KEY (url, filter, content_type);
+ALTER TABLE sites DROP COLUMN IF EXISTS body;
+ALTER TABLE sites DROP COLUMN IF EXISTS method;
+
+DROP INDEX IF EXISTS subscriptions_site_key;
+DELETE FROM subscriptions WHERE method <> 'GET' OR body <> '';
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_url_filter_content_type_key UNIQUE (url, filter, content_type);
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS body;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS method;
+-- +goose StatementEnd
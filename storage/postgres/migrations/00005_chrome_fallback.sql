@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS chrome_fallback boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS chrome_fallback;
+-- +goose StatementEnd
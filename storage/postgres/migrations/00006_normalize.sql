@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS normalize text NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS normalize;
+-- +goose StatementEnd
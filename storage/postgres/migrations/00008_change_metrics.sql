@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS metrics text NOT NULL DEFAULT '';
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS min_change double precision NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sites DROP COLUMN IF EXISTS metrics;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS min_change;
+-- +goose StatementEnd
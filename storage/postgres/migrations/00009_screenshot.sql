@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS screenshot bytea NOT NULL DEFAULT '';
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS previous_screenshot bytea NOT NULL DEFAULT '';
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS pixel_diff double precision NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS screenshot boolean NOT NULL DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sites DROP COLUMN IF EXISTS screenshot;
+ALTER TABLE sites DROP COLUMN IF EXISTS previous_screenshot;
+ALTER TABLE sites DROP COLUMN IF EXISTS pixel_diff;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS screenshot;
+-- +goose StatementEnd
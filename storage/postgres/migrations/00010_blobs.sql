@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS blobs
+    (
+        checksum text NOT NULL,
+        compression text NOT NULL,
+        data bytea NOT NULL,
+        PRIMARY KEY(checksum)
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS blobs;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the id is referencing the site of versions, which are removed with the site
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS id bigint GENERATED BY DEFAULT AS IDENTITY;
+CREATE UNIQUE INDEX IF NOT EXISTS sites_id_key ON sites (id);
+
+CREATE TABLE IF NOT EXISTS site_versions
+    (
+        id bigint GENERATED BY DEFAULT AS IDENTITY,
+        site_id bigint NOT NULL,
+        archived timestamp with time zone NOT NULL,
+        checksum text NOT NULL,
+        content text NOT NULL,
+        PRIMARY KEY(id),
+        FOREIGN KEY(site_id) REFERENCES sites(id) ON DELETE CASCADE
+    );
+CREATE INDEX IF NOT EXISTS site_versions_site_id_archived ON site_versions (site_id, archived DESC);
+
+-- the retention policy of the subscription, see htracker.ParseRetentionPolicy, empty for the default policy
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS retention text NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS retention;
+DROP TABLE IF EXISTS site_versions;
+DROP INDEX IF EXISTS sites_id_key;
+ALTER TABLE sites DROP COLUMN IF EXISTS id;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the variant is a hash of the settings and request options, so subscriptions of the same site requested with
+-- different options (e.g. credentials) are stored separately. It is NULL until computed on startup, as
+-- the request options are encrypted.
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS variant text;
+UPDATE subscriptions SET variant = '' WHERE request_options = '';
+DROP INDEX IF EXISTS subscriptions_site_key;
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_site_key ON subscriptions (url, filter, content_type, method, md5(body), variant);
+
+ALTER TABLE sites ADD COLUMN IF NOT EXISTS variant text;
+UPDATE sites st SET variant = '' WHERE NOT EXISTS (
+    SELECT FROM subscriptions s
+    WHERE s.url = st.url AND s.filter = st.filter AND s.content_type = st.content_type
+        AND s.method = st.method AND md5(s.body) = md5(st.body) AND s.variant IS NULL
+);
+DROP INDEX IF EXISTS sites_site_key;
+CREATE UNIQUE INDEX IF NOT EXISTS sites_site_key ON sites (url, filter, content_type, method, md5(body), variant);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS sites_site_key;
+-- keep a single variant of each site
+DELETE FROM sites a USING sites b
+    WHERE a.url = b.url AND a.filter = b.filter AND a.content_type = b.content_type
+        AND a.method = b.method AND md5(a.body) = md5(b.body) AND a.ctid > b.ctid;
+CREATE UNIQUE INDEX IF NOT EXISTS sites_site_key ON sites (url, filter, content_type, method, md5(body));
+ALTER TABLE sites DROP COLUMN IF EXISTS variant;
+
+DROP INDEX IF EXISTS subscriptions_site_key;
+DELETE FROM subscriptions a USING subscriptions b
+    WHERE a.url = b.url AND a.filter = b.filter AND a.content_type = b.content_type
+        AND a.method = b.method AND md5(a.body) = md5(b.body) AND a.id > b.id;
+CREATE UNIQUE INDEX IF NOT EXISTS subscriptions_site_key ON subscriptions (url, filter, content_type, method, md5(body));
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS variant;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS min_pixel_change double precision NOT NULL DEFAULT 0;
+-- visual changes were compared with the min change of the content before, the variants are updated on startup
+UPDATE subscriptions SET min_pixel_change = min_change WHERE screenshot;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS min_pixel_change;
+-- +goose StatementEnd
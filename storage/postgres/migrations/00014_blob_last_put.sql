@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the time of the last put is keeping blobs from being collected as garbage before their sites are archived
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS last_put timestamptz NOT NULL DEFAULT now();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE blobs DROP COLUMN IF EXISTS last_put;
+-- +goose StatementEnd
@@ -2,53 +2,122 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
 	"golang.org/x/exp/slog"
 )
 
 type site struct {
+	ID          int64
 	URL         string
 	Filter      string
 	ContentType string    `db:"content_type"`
 	LastUpdated time.Time `db:"last_updated"`
 	LastChecked time.Time `db:"last_checked"`
 	Content     []byte
-	Diff        string
+	Diff        DiffValuer
 	Checksum    string
+	State       string
+	Method      string
+	Body        string
+	Variant     sql.NullString
+	Metrics     MetricsValuer
+	// Screenshot, PreviousScreenshot and PixelDiff are the fields of the screenshot, which is nil
+	// if the image is empty.
+	Screenshot         []byte
+	PreviousScreenshot []byte  `db:"previous_screenshot"`
+	PixelDiff          float64 `db:"pixel_diff"`
 }
 
+// selectSiteQuery is selecting the site of a subscription, given the arguments of siteKey.
+const selectSiteQuery = "SELECT * FROM sites WHERE url=$1 AND filter=$2 AND content_type=$3 AND method=$4 AND md5(body)=md5($5) AND variant=$6"
+
 func (db *db) Get(ctx context.Context, subscription *htracker.Subscription) (*htracker.Site, error) {
 	site := &site{}
 
-	err := db.conn.GetContext(ctx, site, "SELECT * FROM sites WHERE url=$1 AND filter=$2 AND content_type=$3",
-		subscription.URL, subscription.Filter, subscription.ContentType)
+	key, err := db.siteKey(subscription)
+	if err != nil {
+		db.logger.Error("failed to compute variant of subscription", err, slog.String("method", "Get"), slog.String("url", subscription.URL))
+		return &htracker.Site{}, err
+	}
+
+	err = db.conn.GetContext(ctx, site, selectSiteQuery, key...)
 	if err != nil {
 		db.logger.Error("query failed", err, slog.String("method", "Get"), slog.String("url", subscription.URL),
 			slog.String("filter", subscription.Filter), slog.String("content_type", subscription.ContentType))
 		return &htracker.Site{}, wrapError(err)
 	}
 
+	return site.site(), nil
+}
+
+// siteKey is returning the arguments identifying the site of subscription in queries.
+func (db *db) siteKey(subscription *htracker.Subscription) ([]interface{}, error) {
+	variant, err := storage.Variant(db.cipher, subscription)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{subscription.URL, subscription.Filter, subscription.ContentType, subscription.HTTPMethod(), subscription.Body,
+		variant}, nil
+}
+
+// site is converting the row s into a site.
+func (s *site) site() *htracker.Site {
 	return &htracker.Site{
-		Subscription: &htracker.Subscription{URL: site.URL, Filter: site.Filter, ContentType: site.ContentType},
-		LastUpdated:  site.LastUpdated,
-		LastChecked:  site.LastChecked,
-		Content:      site.Content,
-		Diff:         site.Diff,
-		Checksum:     site.Checksum,
-	}, nil
+		Subscription: &htracker.Subscription{URL: s.URL, Filter: s.Filter, ContentType: s.ContentType,
+			Method: s.Method, Body: s.Body},
+		LastUpdated: s.LastUpdated,
+		LastChecked: s.LastChecked,
+		Content:     s.Content,
+		Diff:        htracker.Diff(s.Diff),
+		Checksum:    s.Checksum,
+		State:       htracker.SiteState(s.State),
+		Metrics:     s.Metrics.ChangeMetrics,
+		Screenshot:  s.screenshot(),
+	}
 }
 
-func (db *db) Add(ctx context.Context, s *htracker.Site) error {
-	query := `
-	INSERT INTO sites
-	(url, filter, content_type, last_updated, last_checked, content, diff, checksum)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+// screenshot is returning the screenshot of the site, nil if there is no image.
+func (s *site) screenshot() *htracker.Screenshot {
+	if len(s.Screenshot) == 0 {
+		return nil
+	}
+	screenshot := &htracker.Screenshot{Image: s.Screenshot, PixelDiff: s.PixelDiff}
+	if len(s.PreviousScreenshot) > 0 {
+		screenshot.Previous = s.PreviousScreenshot
+	}
+	return screenshot
+}
+
+// screenshotColumns is returning the values of the screenshot columns of s.
+func screenshotColumns(s *htracker.Site) (image, previous []byte, pixelDiff float64) {
+	if s.Screenshot == nil {
+		return []byte{}, []byte{}, 0
+	}
+	previous = s.Screenshot.Previous
+	if previous == nil {
+		previous = []byte{}
+	}
+	return s.Screenshot.Image, previous, s.Screenshot.PixelDiff
+}
 
-	_, err := db.conn.ExecContext(ctx, query, s.Subscription.URL, s.Subscription.Filter,
-		s.Subscription.ContentType, s.LastUpdated, s.LastChecked, s.Content, s.Diff, s.Checksum)
+func (db *db) Add(ctx context.Context, s *htracker.Site) error {
+	key, err := db.siteKey(s.Subscription)
 	if err != nil {
+		db.logger.Error("failed to compute variant of subscription", err, slog.String("method", "Add"), slog.String("url", s.Subscription.URL))
+		return err
+	}
+
+	if _, err := insertSite(ctx, db.conn, s, key, ""); err != nil {
 		db.logger.Error("query failed", err, slog.String("method", "Add"), slog.String("url", s.Subscription.URL),
 			slog.String("filter", s.Subscription.Filter), slog.String("content_type", s.Subscription.ContentType))
 		return wrapError(err)
@@ -57,13 +126,13 @@ func (db *db) Add(ctx context.Context, s *htracker.Site) error {
 }
 
 func (db *db) Update(ctx context.Context, s *htracker.Site) error {
-	query := `
-	UPDATE sites SET
-	last_updated = $1, last_checked = $2, content = $3, diff = $4, checksum = $5
-	WHERE url = $6 AND filter = $7 AND content_type = $8`
+	key, err := db.siteKey(s.Subscription)
+	if err != nil {
+		db.logger.Error("failed to compute variant of subscription", err, slog.String("method", "Update"), slog.String("url", s.Subscription.URL))
+		return err
+	}
 
-	res, err := db.conn.ExecContext(ctx, query, s.LastUpdated, s.LastChecked,
-		s.Content, s.Diff, s.Checksum, s.Subscription.URL, s.Subscription.Filter, s.Subscription.ContentType)
+	res, err := updateSite(ctx, db.conn, s, key)
 	if err != nil {
 		db.logger.Error("query failed", err, slog.String("method", "Update"), slog.String("url", s.Subscription.URL),
 			slog.String("filter", s.Subscription.Filter), slog.String("content_type", s.Subscription.ContentType))
@@ -80,3 +149,214 @@ func (db *db) Update(ctx context.Context, s *htracker.Site) error {
 
 	return nil
 }
+
+// maxUpsertAttempts is the number of attempts of Upsert, which is retried if the site was inserted concurrently.
+const maxUpsertAttempts = 3
+
+// Upsert is atomically storing the site returned by update, which is called with the archived site of
+// subscription or nil. The archived site is locked until the site is stored, so concurrent upserts of the
+// same site are applied one after the other.
+func (db *db) Upsert(ctx context.Context, subscription *htracker.Subscription, update storage.UpdateFunc) error {
+	logger := db.logger.With(slog.String("method", "Upsert"), slog.String("url", subscription.URL),
+		slog.String("filter", subscription.Filter), slog.String("content_type", subscription.ContentType))
+
+	for attempt := 1; ; attempt++ {
+		err := db.upsert(ctx, subscription, update, logger)
+		if !errors.Is(err, errConcurrentInsert) {
+			return err
+		}
+		if attempt == maxUpsertAttempts {
+			return fmt.Errorf("site was inserted concurrently %d times: %w", attempt, htracker.ErrAlreadyExists)
+		}
+		logger.Debug("site was inserted concurrently, retrying", slog.Int("attempt", attempt))
+	}
+}
+
+// errConcurrentInsert is returned by upsert if the site was inserted concurrently.
+var errConcurrentInsert = errors.New("site was inserted concurrently")
+
+// upsert is one attempt of Upsert in a transaction. It is returning errConcurrentInsert if the site
+// didn't exist yet, but was inserted concurrently. Sites which don't exist yet can't be locked.
+func (db *db) upsert(ctx context.Context, subscription *htracker.Subscription, update storage.UpdateFunc, logger *slog.Logger) error {
+	key, err := db.siteKey(subscription)
+	if err != nil {
+		logger.Error("failed to compute variant of subscription", err)
+		return err
+	}
+
+	tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		logger.Error("failed to begin a transaction", err)
+		return err
+	}
+	// rolling back is a no-op after commit
+	defer func() { _ = tx.Rollback() }()
+
+	var archived *htracker.Site
+	row := &site{}
+	err = tx.GetContext(ctx, row, selectSiteQuery+" FOR UPDATE", key...)
+	switch {
+	case err == nil:
+		archived = row.site()
+	case errors.Is(err, sql.ErrNoRows):
+	default:
+		logger.Error("query failed", err)
+		return wrapError(err)
+	}
+
+	s, err := update(archived)
+	if err != nil || s == nil {
+		return err
+	}
+
+	// the site is stored with the key of subscription, which is identifying the archived site
+	var res sql.Result
+	if archived == nil {
+		res, err = insertSite(ctx, tx, s, key, "ON CONFLICT DO NOTHING")
+	} else {
+		res, err = updateSite(ctx, tx, s, key)
+	}
+	if err != nil {
+		logger.Error("query failed", err)
+		return wrapError(err)
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return errConcurrentInsert
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("failed to commit the transaction", err)
+		return err
+	}
+	return nil
+}
+
+// insertSite is inserting s with the given siteKey, the given conflict clause is appended to the query.
+func insertSite(ctx context.Context, e sqlx.ExecerContext, s *htracker.Site, key []interface{}, conflict string) (sql.Result, error) {
+	query := `
+	INSERT INTO sites
+	(url, filter, content_type, method, body, variant, last_updated, last_checked, content, diff, checksum, state, metrics,
+	screenshot, previous_screenshot, pixel_diff)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) ` + conflict
+
+	screenshot, previous, pixelDiff := screenshotColumns(s)
+	args := append(append([]interface{}{}, key...), s.LastUpdated, s.LastChecked, s.Content, DiffValuer(s.Diff), s.Checksum, siteState(s),
+		MetricsValuer{s.Metrics}, screenshot, previous, pixelDiff)
+	return e.ExecContext(ctx, query, args...)
+}
+
+// updateSite is updating the stored site of s with the given siteKey.
+func updateSite(ctx context.Context, e sqlx.ExecerContext, s *htracker.Site, key []interface{}) (sql.Result, error) {
+	query := `
+	UPDATE sites SET
+	last_updated = $7, last_checked = $8, content = $9, diff = $10, checksum = $11, state = $12, metrics = $13,
+	screenshot = $14, previous_screenshot = $15, pixel_diff = $16
+	WHERE url = $1 AND filter = $2 AND content_type = $3 AND method = $4 AND md5(body) = md5($5) AND variant = $6`
+
+	screenshot, previous, pixelDiff := screenshotColumns(s)
+	args := append(append([]interface{}{}, key...), s.LastUpdated, s.LastChecked, s.Content, DiffValuer(s.Diff), s.Checksum, siteState(s),
+		MetricsValuer{s.Metrics}, screenshot, previous, pixelDiff)
+	return e.ExecContext(ctx, query, args...)
+}
+
+// DiffValuer is a wrapper for htracker.Diff, implementing Scan() and Value(), to be able to store
+// the operations of the diff as JSON in the diff column. Diffs stored before are ANSI colored text.
+type DiffValuer htracker.Diff
+
+// Scan implements Scanner.
+func (dv *DiffValuer) Scan(src interface{}) error {
+	var s string
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		s = string(src)
+	case string:
+		s = src
+	default:
+		return fmt.Errorf("diff column was not text; type %T", src)
+	}
+
+	if s == "" {
+		*dv = nil
+		return nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		*dv = DiffValuer(htracker.ParseANSIDiff(s))
+		return nil
+	}
+
+	var d htracker.Diff
+	if err := json.Unmarshal([]byte(s), &d); err != nil {
+		return fmt.Errorf("failed to decode diff: %w", err)
+	}
+	*dv = DiffValuer(d)
+	return nil
+}
+
+// Value implements Valuer.
+func (dv DiffValuer) Value() (driver.Value, error) {
+	if len(dv) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(htracker.Diff(dv))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode diff: %w", err)
+	}
+	return string(data), nil
+}
+
+// MetricsValuer is a wrapper for htracker.ChangeMetrics, implementing Scan() and Value(), to be able
+// to store the metrics as JSON in the metrics column. An empty column is nil metrics.
+type MetricsValuer struct {
+	*htracker.ChangeMetrics
+}
+
+// Scan implements Scanner.
+func (mv *MetricsValuer) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("metrics column was not text; type %T", src)
+	}
+
+	if len(data) == 0 {
+		mv.ChangeMetrics = nil
+		return nil
+	}
+
+	m := &htracker.ChangeMetrics{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("failed to decode change metrics: %w", err)
+	}
+	mv.ChangeMetrics = m
+	return nil
+}
+
+// Value implements Valuer.
+func (mv MetricsValuer) Value() (driver.Value, error) {
+	if mv.ChangeMetrics == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(mv.ChangeMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode change metrics: %w", err)
+	}
+	return string(data), nil
+}
+
+// siteState is returning the state of the site, defaulting to SiteStateOK.
+func siteState(s *htracker.Site) string {
+	if s.State == "" {
+		return string(htracker.SiteStateOK)
+	}
+	return string(s.State)
+}
@@ -26,7 +26,7 @@ func TestAdd(t *testing.T) {
 		LastChecked:  date1,
 		Content:      []byte("content1ä😎"),
 		Checksum:     "1234",
-		Diff:         "diff1ä",
+		Diff:         htracker.Diff{{Op: htracker.DiffInsert, Text: "diff1ä"}},
 	}
 	site2 := &htracker.Site{
 		Subscription: sub2,
@@ -34,7 +34,7 @@ func TestAdd(t *testing.T) {
 		LastChecked:  date2,
 		Content:      []byte(""),
 		Checksum:     "5678",
-		Diff:         "",
+		Diff:         nil,
 	}
 
 	tests := []struct {
@@ -86,7 +86,7 @@ func TestAdd(t *testing.T) {
 				if tt.wantSite.Checksum != got.Checksum {
 					t.Errorf("postgres.Add() checksum = %v, want %v", got.Checksum, tt.wantSite.Checksum)
 				}
-				if tt.wantSite.Diff != got.Diff {
+				if !reflect.DeepEqual(tt.wantSite.Diff, got.Diff) {
 					t.Errorf("postgres.Add() diff = %v, want %v", got.Diff, tt.wantSite.Diff)
 				}
 			}
@@ -109,7 +109,7 @@ func TestUpdate(t *testing.T) {
 		LastChecked:  date1,
 		Content:      []byte("content1ä😎"),
 		Checksum:     "1234",
-		Diff:         "diff1ä",
+		Diff:         htracker.Diff{{Op: htracker.DiffInsert, Text: "diff1ä"}},
 	}
 	site1Updated := &htracker.Site{
 		Subscription: sub1,
@@ -117,7 +117,7 @@ func TestUpdate(t *testing.T) {
 		LastChecked:  date1,
 		Content:      []byte("content1_updated"),
 		Checksum:     "12345",
-		Diff:         "diff1",
+		Diff:         htracker.Diff{{Op: htracker.DiffInsert, Text: "diff1"}},
 	}
 	site2 := &htracker.Site{
 		Subscription: sub2,
@@ -125,7 +125,7 @@ func TestUpdate(t *testing.T) {
 		LastChecked:  date2,
 		Content:      []byte(""),
 		Checksum:     "5678",
-		Diff:         "",
+		Diff:         nil,
 	}
 	site2Updated := &htracker.Site{
 		Subscription: sub2,
@@ -133,7 +133,7 @@ func TestUpdate(t *testing.T) {
 		LastChecked:  date2,
 		Content:      []byte("content2_updated"),
 		Checksum:     "56789",
-		Diff:         "content2_updated",
+		Diff:         htracker.Diff{{Op: htracker.DiffInsert, Text: "content2_updated"}},
 	}
 
 	tests := []struct {
@@ -193,10 +193,129 @@ func TestUpdate(t *testing.T) {
 				if tt.wantSite.Checksum != got.Checksum {
 					t.Errorf("postgres.Add() checksum = %v, want %v", got.Checksum, tt.wantSite.Checksum)
 				}
-				if tt.wantSite.Diff != got.Diff {
+				if !reflect.DeepEqual(tt.wantSite.Diff, got.Diff) {
 					t.Errorf("postgres.Add() diff = %v, want %v", got.Diff, tt.wantSite.Diff)
 				}
 			}
 		})
 	}
 }
+
+func Test_DiffValuer_Scan(t *testing.T) {
+	diff := htracker.Diff{{Op: htracker.DiffEqual, Text: "a"}, {Op: htracker.DiffDelete, Text: "b"}, {Op: htracker.DiffInsert, Text: "c"}}
+
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    htracker.Diff
+		wantErr bool
+	}{
+		{name: "null", src: nil},
+		{name: "empty", src: ""},
+		{name: "json", src: []byte(`[{"Op":"equal","Text":"a"},{"Op":"delete","Text":"b"},{"Op":"insert","Text":"c"}]`), want: diff},
+		{name: "legacy ansi", src: "a\x1b[31mb\x1b[0m\x1b[32mc\x1b[0m", want: diff},
+		{name: "broken json", src: "[{", wantErr: true},
+		{name: "no text", src: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dv := DiffValuer{}
+			if err := dv.Scan(tt.src); (err != nil) != tt.wantErr {
+				t.Fatalf("DiffValuer.Scan error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(tt.want, htracker.Diff(dv)) {
+				t.Errorf("Want diff %v, got %v", tt.want, dv)
+			}
+		})
+	}
+
+	// the diff is stored as json and scanned again
+	value, err := DiffValuer(diff).Value()
+	if err != nil {
+		t.Fatalf("DiffValuer.Value failed: %v", err)
+	}
+	dv := DiffValuer{}
+	if err := dv.Scan(value); err != nil || !reflect.DeepEqual(diff, htracker.Diff(dv)) {
+		t.Errorf("Want diff %v, got %v (%v)", diff, dv, err)
+	}
+}
+
+func Test_MetricsValuer_Scan(t *testing.T) {
+	metrics := &htracker.ChangeMetrics{InsertedChars: 2, DeletedChars: 1, InsertedLines: 1, DeletedLines: 1, Changed: 12.5, Similarity: 0.8}
+
+	tests := []struct {
+		name    string
+		src     interface{}
+		want    *htracker.ChangeMetrics
+		wantErr bool
+	}{
+		{name: "null", src: nil},
+		{name: "empty", src: ""},
+		{name: "json", src: []byte(`{"InsertedChars":2,"DeletedChars":1,"InsertedLines":1,"DeletedLines":1,"Changed":12.5,"Similarity":0.8}`),
+			want: metrics},
+		{name: "broken json", src: "{", wantErr: true},
+		{name: "no text", src: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mv := MetricsValuer{}
+			if err := mv.Scan(tt.src); (err != nil) != tt.wantErr {
+				t.Fatalf("MetricsValuer.Scan error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(tt.want, mv.ChangeMetrics) {
+				t.Errorf("Want metrics %v, got %v", tt.want, mv.ChangeMetrics)
+			}
+		})
+	}
+
+	// the metrics are stored as json and scanned again
+	value, err := MetricsValuer{metrics}.Value()
+	if err != nil {
+		t.Fatalf("MetricsValuer.Value failed: %v", err)
+	}
+	mv := MetricsValuer{}
+	if err := mv.Scan(value); err != nil || !reflect.DeepEqual(metrics, mv.ChangeMetrics) {
+		t.Errorf("Want metrics %v, got %v (%v)", metrics, mv.ChangeMetrics, err)
+	}
+}
+
+func Test_db_Upsert(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+	sub := &htracker.Subscription{URL: "upsertsite1"}
+
+	// every upsert is appending to the content of the site, concurrent upserts must not lose an append
+	const upserts = 10
+	errs := make(chan error, upserts)
+	for i := 0; i < upserts; i++ {
+		go func() {
+			errs <- db.Upsert(ctx, sub, func(archived *htracker.Site) (*htracker.Site, error) {
+				if archived == nil {
+					return &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: []byte("x"), Checksum: "1234"}, nil
+				}
+				archived.Content = append(archived.Content, 'x')
+				return archived, nil
+			})
+		}()
+	}
+	for i := 0; i < upserts; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("db.Upsert() failed: %v", err)
+		}
+	}
+
+	site, err := db.Get(ctx, sub)
+	if err != nil {
+		t.Fatalf("db.Get() failed: %v", err)
+	}
+	if len(site.Content) != upserts {
+		t.Errorf("Expected content of %d bytes, got %d", upserts, len(site.Content))
+	}
+}
@@ -12,12 +12,27 @@ import (
 )
 
 type subscription struct {
-	ID          int
-	URL         string
-	Filter      string
-	ContentType string `db:"content_type"`
-	UseChrome   bool   `db:"use_chrome"`
-	Interval    DurationValuer
+	ID             int
+	URL            string
+	Filter         string
+	ContentType    string `db:"content_type"`
+	UseChrome      bool   `db:"use_chrome"`
+	ChromeFallback bool   `db:"chrome_fallback"`
+	Screenshot     bool
+	Normalize      string
+	DiffMode       string  `db:"diff_mode"`
+	MinChange      float64 `db:"min_change"`
+	MinPixelChange float64 `db:"min_pixel_change"`
+	Method         string
+	Body           string
+	// RequestOptions is the encrypted JSON of the request options.
+	RequestOptions string `db:"request_options"`
+	// Variant is the hash of the settings and request options, see storage.Variant. It is NULL until
+	// computed by updateVariants for subscriptions stored before.
+	Variant sql.NullString
+	// Retention is the retention policy in the format of htracker.ParseRetentionPolicy, empty if nil.
+	Retention string
+	Interval  DurationValuer
 }
 
 type subscriber struct {
@@ -40,27 +55,73 @@ func (db *db) FindBySubscriber(ctx context.Context, email string) ([]*htracker.S
 
 	subscriptions := make([]*htracker.Subscription, len(subs))
 	for i, s := range subs {
-		subscriptions[i] = &htracker.Subscription{
-			URL:         s.URL,
-			Filter:      s.Filter,
-			ContentType: s.ContentType,
-			UseChrome:   s.UseChrome,
-			Interval:    time.Duration(s.Interval),
+		requestOpts, err := storage.DecryptRequestOptions(db.cipher, s.RequestOptions)
+		if err != nil {
+			db.logger.Error("failed to decrypt request options", err, slog.String("method", "FindBySubscriber"), slog.String("url", s.URL))
+			return []*htracker.Subscription{}, err
 		}
+		subscriptions[i] = s.subscription(requestOpts)
 	}
 
 	return subscriptions, nil
 }
 
+// subscription is converting the row s into a subscription with the given decrypted request options.
+func (s *subscription) subscription(requestOpts *htracker.RequestOptions) *htracker.Subscription {
+	return &htracker.Subscription{
+		URL:            s.URL,
+		Filter:         s.Filter,
+		ContentType:    s.ContentType,
+		UseChrome:      s.UseChrome,
+		ChromeFallback: s.ChromeFallback,
+		Screenshot:     s.Screenshot,
+		Normalize:      s.Normalize,
+		DiffMode:       s.DiffMode,
+		MinChange:      s.MinChange,
+		MinPixelChange: s.MinPixelChange,
+		Retention:      retentionPolicy(s.Retention),
+		Method:         s.Method,
+		Body:           s.Body,
+		Interval:       time.Duration(s.Interval),
+		Request:        requestOpts,
+	}
+}
+
+// retentionPolicy is returning the retention policy of the retention column, nil if empty. The policy
+// was formatted by retentionColumn, so it can be parsed.
+func retentionPolicy(retention string) *htracker.RetentionPolicy {
+	if retention == "" {
+		return nil
+	}
+	policy, _ := htracker.ParseRetentionPolicy(retention)
+	return policy
+}
+
+// retentionColumn is returning the value of the retention column of policy.
+func retentionColumn(policy *htracker.RetentionPolicy) string {
+	if policy == nil {
+		return ""
+	}
+	return policy.String()
+}
+
 func (db *db) FindBySubscription(ctx context.Context, subscription *htracker.Subscription) ([]*storage.Subscriber, error) {
 	subs := []*subscriber{}
 
+	variant, err := storage.Variant(db.cipher, subscription)
+	if err != nil {
+		db.logger.Error("failed to compute variant of subscription", err, slog.String("method", "FindBySubscription"), slog.String("url", subscription.URL))
+		return []*storage.Subscriber{}, err
+	}
+
 	query := `SELECT * FROM subscribers WHERE email IN
 		(SELECT subscriber_email FROM subscriber_subscription WHERE subscription_id IN
-			(SELECT id FROM subscriptions WHERE url = $1 AND filter = $2 AND content_type = $3)
+			(SELECT id FROM subscriptions WHERE url = $1 AND filter = $2 AND content_type = $3 AND method = $4 AND md5(body) = md5($5)
+				AND variant = $6)
 		)`
 
-	if err := db.conn.SelectContext(ctx, &subs, query, subscription.URL, subscription.Filter, subscription.ContentType); err != nil {
+	if err := db.conn.SelectContext(ctx, &subs, query, subscription.URL, subscription.Filter, subscription.ContentType,
+		subscription.HTTPMethod(), subscription.Body, variant); err != nil {
 		db.logger.Error("query failed", err, slog.String("method", "FindBySubscription"),
 			slog.String("url", subscription.URL), slog.String("filter", subscription.Filter), slog.String("content_type", subscription.ContentType))
 		return []*storage.Subscriber{}, wrapError(err)
@@ -156,25 +217,33 @@ func (db *db) GetSubscriber(ctx context.Context, email string) (*storage.Subscri
 
 // AddSubscription is creating an entry in the subscriptions table if necessary, and then adds an entry
 // to the subscriber_subscription relation. A foreign key constraint makes sure the related subscriber is
-// existing in the DB.
+// existing in the DB. Subscriptions are only shared by subscribers with the same settings and request options.
 func (db *db) AddSubscription(ctx context.Context, email string, subscription *htracker.Subscription) error {
 
 	logger := slog.New(db.logger.Handler().WithAttrs([]slog.Attr{
 		slog.String("method", "AddSubscription"), slog.String("email", email), slog.String("url", subscription.URL),
 		slog.String("filter", subscription.Filter), slog.String("content_type", subscription.ContentType)}))
 
+	variant, err := storage.Variant(db.cipher, subscription)
+	if err != nil {
+		logger.Error("failed to compute variant of subscription", err)
+		return err
+	}
+
 	tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{})
 	if err != nil {
 		logger.Error("failed to begin a transaction", err)
 		return err
 	}
 
-	query := `SELECT id FROM subscriptions WHERE url = $1 AND filter = $2 AND content_type = $3`
+	query := `SELECT id FROM subscriptions WHERE url = $1 AND filter = $2 AND content_type = $3 AND method = $4 AND md5(body) = md5($5)
+				AND variant = $6`
 
 	var id int64
 
 	// first try to find an existing subscription
-	if err := tx.GetContext(ctx, &id, query, subscription.URL, subscription.Filter, subscription.ContentType); err != nil {
+	if err := tx.GetContext(ctx, &id, query, subscription.URL, subscription.Filter, subscription.ContentType,
+		subscription.HTTPMethod(), subscription.Body, variant); err != nil {
 		if !errors.Is(err, sql.ErrNoRows) {
 			logger.Error("query failed, rolling back transaction", err)
 			if err := tx.Rollback(); err != nil {
@@ -184,13 +253,27 @@ func (db *db) AddSubscription(ctx context.Context, email string, subscription *h
 		}
 
 		// we didn't find a subscription so we create one now
-		query = `INSERT INTO subscriptions(url, filter, content_type, use_chrome)
-				VALUES($1, $2, $3, $4) ON CONFLICT(url, content_type, filter) DO UPDATE
-				SET url = $1, filter = $2, content_type = $3, use_chrome = $4
+		requestOpts, err := storage.EncryptRequestOptions(db.cipher, subscription.Request)
+		if err != nil {
+			logger.Error("failed to encrypt request options, rolling back transaction", err)
+			if err := tx.Rollback(); err != nil {
+				logger.Error("rollback failed", err)
+			}
+			return err
+		}
+
+		query = `INSERT INTO subscriptions(url, filter, content_type, use_chrome, request_options, method, body, chrome_fallback, normalize, diff_mode,
+				min_change, screenshot, variant, min_pixel_change, retention)
+				VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) ON CONFLICT(url, filter, content_type, method, md5(body), variant) DO UPDATE
+				SET url = $1, filter = $2, content_type = $3, use_chrome = $4, request_options = $5, chrome_fallback = $8, normalize = $9,
+				diff_mode = $10, min_change = $11, screenshot = $12, min_pixel_change = $14, retention = $15
 				RETURNING id`
 
-		row := tx.QueryRowxContext(ctx, query, subscription.URL, subscription.Filter, subscription.ContentType, subscription.UseChrome)
-		err := row.Scan(&id)
+		row := tx.QueryRowxContext(ctx, query, subscription.URL, subscription.Filter, subscription.ContentType, subscription.UseChrome,
+			requestOpts, subscription.HTTPMethod(), subscription.Body, subscription.ChromeFallback, subscription.Normalize,
+			subscription.DiffMode, subscription.MinChange, subscription.Screenshot, variant, subscription.MinPixelChange,
+			retentionColumn(subscription.Retention))
+		err = row.Scan(&id)
 		if err != nil {
 			logger.Error("query failed, rolling back transaction", err)
 			if err := tx.Rollback(); err != nil {
@@ -219,11 +302,93 @@ func (db *db) AddSubscription(ctx context.Context, email string, subscription *h
 	return nil
 }
 
+// updateVariants is computing the variants of the subscriptions stored before, or with another definition of
+// the variant, which the migrations can't do as the request options are encrypted. The archived sites of the
+// subscriptions are updated accordingly. Subscriptions with outdated variants are not found by lookups, so it
+// is called by New before the storage is used.
+func (db *db) updateVariants(ctx context.Context) error {
+	subs := []*subscription{}
+
+	if err := db.conn.SelectContext(ctx, &subs, `SELECT * FROM subscriptions`); err != nil {
+		db.logger.Error("query failed", err, slog.String("method", "updateVariants"))
+		return wrapError(err)
+	}
+
+	for _, s := range subs {
+		logger := db.logger.With(slog.String("method", "updateVariants"), slog.String("url", s.URL))
+
+		requestOpts, err := storage.DecryptRequestOptions(db.cipher, s.RequestOptions)
+		if err != nil {
+			// the subscription can't be scraped either without the key, so it is fixed on a restart with the key
+			logger.Warn("failed to decrypt request options, variant not updated", slog.Any("error", err))
+			continue
+		}
+		variant, err := storage.Variant(db.cipher, s.subscription(requestOpts))
+		if err != nil {
+			logger.Error("failed to compute variant of subscription", err)
+			return err
+		}
+		if s.Variant.Valid && s.Variant.String == variant {
+			continue
+		}
+
+		tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{})
+		if err != nil {
+			logger.Error("failed to begin a transaction", err)
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE subscriptions SET variant = $1 WHERE id = $2`, variant, s.ID); err != nil {
+			logger.Error("query failed, rolling back transaction", err)
+			if err := tx.Rollback(); err != nil {
+				logger.Error("rollback failed", err)
+			}
+			return wrapError(err)
+		}
+		query := `UPDATE sites SET variant = $1 WHERE url = $2 AND filter = $3 AND content_type = $4 AND method = $5
+					AND md5(body) = md5($6) AND variant IS NOT DISTINCT FROM $7`
+		if _, err := tx.ExecContext(ctx, query, variant, s.URL, s.Filter, s.ContentType, s.Method, s.Body, s.Variant); err != nil {
+			logger.Error("query failed, rolling back transaction", err)
+			if err := tx.Rollback(); err != nil {
+				logger.Error("rollback failed", err)
+			}
+			return wrapError(err)
+		}
+		if err := tx.Commit(); err != nil {
+			logger.Error("failed to commit the transaction", err)
+			return err
+		}
+		logger.Info("variant of subscription updated")
+	}
+
+	return nil
+}
+
+// deleteOrphanSubscriptionsQuery is deleting the subscriptions which have no subscriber anymore.
+const deleteOrphanSubscriptionsQuery = `DELETE FROM subscriptions s
+				WHERE NOT EXISTS (
+					SELECT FROM subscriber_subscription ss
+					WHERE s.id = ss.subscription_id
+				)`
+
+// deleteOrphanSitesQuery is deleting the archived sites which are not referenced by any subscription.
+const deleteOrphanSitesQuery = `DELETE FROM sites st
+				WHERE NOT EXISTS (
+					SELECT FROM subscriptions s
+					WHERE s.url = st.url AND s.filter = st.filter AND s.content_type = st.content_type
+						AND s.method = st.method AND md5(s.body) = md5(st.body) AND s.variant IS NOT DISTINCT FROM st.variant
+				)`
+
 func (db *db) RemoveSubscription(ctx context.Context, email string, subscription *htracker.Subscription) error {
 	logger := slog.New(db.logger.Handler().WithAttrs([]slog.Attr{
 		slog.String("method", "RemoveSubscription"), slog.String("email", email), slog.String("url", subscription.URL),
 		slog.String("filter", subscription.Filter), slog.String("content_type", subscription.ContentType)}))
 
+	variant, err := storage.Variant(db.cipher, subscription)
+	if err != nil {
+		logger.Error("failed to compute variant of subscription", err)
+		return err
+	}
+
 	tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{})
 	if err != nil {
 		logger.Error("failed to begin a transaction", err)
@@ -231,9 +396,11 @@ func (db *db) RemoveSubscription(ctx context.Context, email string, subscription
 	}
 
 	query := `DELETE FROM subscriber_subscription WHERE subscriber_email = $1 AND subscription_id IN
-				(SELECT id FROM subscriptions WHERE url = $2 AND filter = $3 AND content_type =$4)`
+				(SELECT id FROM subscriptions WHERE url = $2 AND filter = $3 AND content_type = $4 AND method = $5 AND md5(body) = md5($6)
+					AND variant = $7)`
 
-	res, err := tx.ExecContext(ctx, query, email, subscription.URL, subscription.Filter, subscription.ContentType)
+	res, err := tx.ExecContext(ctx, query, email, subscription.URL, subscription.Filter, subscription.ContentType,
+		subscription.HTTPMethod(), subscription.Body, variant)
 	if err != nil {
 		logger.Error("query failed, rolling back transaction", err)
 		if err := tx.Rollback(); err != nil {
@@ -259,13 +426,7 @@ func (db *db) RemoveSubscription(ctx context.Context, email string, subscription
 	}
 
 	// cleanup non-referenced subscriptions
-	query = `DELETE FROM subscriptions s
-				WHERE NOT EXISTS (
-					SELECT FROM subscriber_subscription ss
-					WHERE s.id = ss.subscription_id
-				)`
-
-	if _, err := tx.ExecContext(ctx, query); err != nil {
+	if _, err := tx.ExecContext(ctx, deleteOrphanSubscriptionsQuery); err != nil {
 		logger.Error("query failed, rolling back transaction", err)
 		if err := tx.Rollback(); err != nil {
 			logger.Error("rollback failed", err)
@@ -273,6 +434,15 @@ func (db *db) RemoveSubscription(ctx context.Context, email string, subscription
 		return wrapError(err)
 	}
 
+	// cleanup sites nobody is subscribed to anymore
+	if _, err := tx.ExecContext(ctx, deleteOrphanSitesQuery); err != nil {
+		logger.Error("cleaning up orphan sites failed, rolling back transaction", err)
+		if err := tx.Rollback(); err != nil {
+			logger.Error("rollback failed", err)
+		}
+		return wrapError(err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		logger.Error("failed to commit the transaction", err)
 		return err
@@ -319,13 +489,7 @@ func (db *db) RemoveSubscriber(ctx context.Context, email string) error {
 	}
 
 	// cleanup non-referenced subscriptions
-	query = `DELETE FROM subscriptions s
-				WHERE NOT EXISTS (
-					SELECT FROM subscriber_subscription ss
-					WHERE s.id = ss.subscription_id
-					)`
-
-	if _, err := tx.ExecContext(ctx, query); err != nil {
+	if _, err := tx.ExecContext(ctx, deleteOrphanSubscriptionsQuery); err != nil {
 		logger.Error("cleaning up dangling subscriptions failed, rolling back transaction", err)
 		if err := tx.Rollback(); err != nil {
 			logger.Error("rollback failed", err)
@@ -333,6 +497,15 @@ func (db *db) RemoveSubscriber(ctx context.Context, email string) error {
 		return wrapError(err)
 	}
 
+	// cleanup sites nobody is subscribed to anymore
+	if _, err := tx.ExecContext(ctx, deleteOrphanSitesQuery); err != nil {
+		logger.Error("cleaning up orphan sites failed, rolling back transaction", err)
+		if err := tx.Rollback(); err != nil {
+			logger.Error("rollback failed", err)
+		}
+		return wrapError(err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		logger.Error("failed to commit the transaction", err)
 		return err
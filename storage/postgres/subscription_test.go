@@ -2,6 +2,9 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -338,3 +341,376 @@ func Test_db_RemoveSubscriber(t *testing.T) {
 		})
 	}
 }
+
+func Test_db_AddSubscription_RequestOptions(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	cipher, err := storage.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	logger := slog.Default()
+	db, err := New(URIfromEnvVars(), logger, WithCipher(cipher))
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+	plainDB, err := New(URIfromEnvVars(), logger)
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber := &storage.Subscriber{Email: "reqoptsemail1"}
+	if err := db.AddSubscriber(ctx, subscriber); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+
+	requestOpts := &htracker.RequestOptions{
+		Headers:   map[string]string{"Accept-Language": "de"},
+		Cookies:   map[string]string{"consent": "yes"},
+		BasicAuth: &htracker.BasicAuth{Username: "user", Password: "secret"},
+	}
+	subscription := &htracker.Subscription{URL: "reqoptssite1", Interval: time.Hour, Request: requestOpts}
+
+	if err := plainDB.AddSubscription(ctx, subscriber.Email, subscription); !errors.Is(err, storage.ErrNoCipher) {
+		t.Errorf("Expected ErrNoCipher without cipher, got %v", err)
+	}
+	if err := db.AddSubscription(ctx, subscriber.Email, subscription); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+
+	var stored string
+	if err := db.conn.GetContext(ctx, &stored, `SELECT request_options FROM subscriptions WHERE url = $1`, subscription.URL); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored, "secret") {
+		t.Errorf("Expected request options to be encrypted, got %s", stored)
+	}
+
+	gotSubs, err := db.FindBySubscriber(ctx, subscriber.Email)
+	if err != nil {
+		t.Fatalf("db.FindBySubscriber() failed: %v", err)
+	}
+	if len(gotSubs) != 1 || !reflect.DeepEqual(gotSubs[0].Request, requestOpts) {
+		t.Errorf("Expected request options %v, got %v", requestOpts, gotSubs)
+	}
+
+	if _, err := plainDB.FindBySubscriber(ctx, subscriber.Email); !errors.Is(err, storage.ErrNoCipher) {
+		t.Errorf("Expected ErrNoCipher without cipher, got %v", err)
+	}
+}
+
+func Test_db_AddSubscription_RequestOptions_Subscribers(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	cipher, err := storage.NewCipher([]byte("0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default(), WithCipher(cipher))
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	alice := &storage.Subscriber{Email: "reqoptsalice"}
+	bob := &storage.Subscriber{Email: "reqoptsbob"}
+	for _, s := range []*storage.Subscriber{alice, bob} {
+		if err := db.AddSubscriber(ctx, s); err != nil {
+			t.Fatalf("Setup: failed to add subscriber: %v", err)
+		}
+	}
+
+	aliceSub := &htracker.Subscription{URL: "reqoptssite2", Interval: time.Hour, Request: &htracker.RequestOptions{
+		BasicAuth: &htracker.BasicAuth{Username: "alice", Password: "secret1"}}}
+	bobSub := &htracker.Subscription{URL: "reqoptssite2", Interval: time.Hour, Request: &htracker.RequestOptions{
+		BasicAuth: &htracker.BasicAuth{Username: "bob", Password: "secret2"}}}
+
+	if err := db.AddSubscription(ctx, alice.Email, aliceSub); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+	if err := db.AddSubscription(ctx, bob.Email, bobSub); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+
+	// the subscribers are never sharing their credentials
+	for _, tt := range []struct {
+		email string
+		want  *htracker.Subscription
+	}{{alice.Email, aliceSub}, {bob.Email, bobSub}} {
+		gotSubs, err := db.FindBySubscriber(ctx, tt.email)
+		if err != nil {
+			t.Fatalf("db.FindBySubscriber() failed: %v", err)
+		}
+		if len(gotSubs) != 1 || !reflect.DeepEqual(gotSubs[0].Request, tt.want.Request) {
+			t.Errorf("Expected request options %v of %s, got %v", tt.want.Request, tt.email, gotSubs)
+		}
+
+		gotSubscribers, err := db.FindBySubscription(ctx, tt.want)
+		if err != nil {
+			t.Fatalf("db.FindBySubscription() failed: %v", err)
+		}
+		if len(gotSubscribers) != 1 || gotSubscribers[0].Email != tt.email {
+			t.Errorf("Expected only subscriber %s, got %v", tt.email, gotSubscribers)
+		}
+	}
+
+	// the sites scraped with different credentials are archived separately
+	for _, sub := range []*htracker.Subscription{aliceSub, bobSub} {
+		if err := db.Add(ctx, &htracker.Site{Subscription: sub, Content: []byte(sub.Request.BasicAuth.Username)}); err != nil {
+			t.Fatalf("db.Add() failed: %v", err)
+		}
+	}
+	for _, sub := range []*htracker.Subscription{aliceSub, bobSub} {
+		site, err := db.Get(ctx, sub)
+		if err != nil {
+			t.Fatalf("db.Get() failed: %v", err)
+		}
+		if want, got := sub.Request.BasicAuth.Username, string(site.Content); want != got {
+			t.Errorf("Expected content %s, got %s", want, got)
+		}
+	}
+
+	// removing the subscription of alice is keeping the one of bob
+	if err := db.RemoveSubscription(ctx, alice.Email, bobSub); !errors.Is(err, htracker.ErrNotExist) {
+		t.Errorf("Expected ErrNotExist removing the subscription of another subscriber, got %v", err)
+	}
+	if err := db.RemoveSubscription(ctx, alice.Email, aliceSub); err != nil {
+		t.Fatalf("db.RemoveSubscription() failed: %v", err)
+	}
+	if _, err := db.Get(ctx, aliceSub); !errors.Is(err, htracker.ErrNotExist) {
+		t.Errorf("Expected site of alice to be removed, got %v", err)
+	}
+	if _, err := db.Get(ctx, bobSub); err != nil {
+		t.Errorf("Expected site of bob to be kept, got %v", err)
+	}
+}
+
+func Test_db_AddSubscription_Settings_Subscribers(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	alice := &storage.Subscriber{Email: "settingsalice"}
+	bob := &storage.Subscriber{Email: "settingsbob"}
+	for _, s := range []*storage.Subscriber{alice, bob} {
+		if err := db.AddSubscriber(ctx, s); err != nil {
+			t.Fatalf("Setup: failed to add subscriber: %v", err)
+		}
+	}
+
+	aliceSub := &htracker.Subscription{URL: "settingssite1", Interval: time.Hour, UseChrome: true, ChromeFallback: true,
+		Screenshot: true, Normalize: htracker.NormalizeNone, DiffMode: htracker.DiffModeWord, MinChange: 5, MinPixelChange: 3,
+		Retention: &htracker.RetentionPolicy{KeepLast: 3, KeepDaily: 7}}
+	bobSub := &htracker.Subscription{URL: "settingssite1", Interval: time.Minute}
+
+	if err := db.AddSubscription(ctx, alice.Email, aliceSub); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+	if err := db.AddSubscription(ctx, bob.Email, bobSub); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+
+	// the later subscriber is neither inheriting nor overwriting the settings of the first one
+	for _, tt := range []struct {
+		email string
+		want  *htracker.Subscription
+	}{{alice.Email, aliceSub}, {bob.Email, bobSub}} {
+		gotSubs, err := db.FindBySubscriber(ctx, tt.email)
+		if err != nil {
+			t.Fatalf("db.FindBySubscriber() failed: %v", err)
+		}
+		if len(gotSubs) != 1 || !reflect.DeepEqual(gotSubs[0], tt.want) {
+			t.Errorf("Expected subscription %+v of %s, got %+v", tt.want, tt.email, gotSubs)
+		}
+
+		gotSubscribers, err := db.FindBySubscription(ctx, tt.want)
+		if err != nil {
+			t.Fatalf("db.FindBySubscription() failed: %v", err)
+		}
+		if len(gotSubscribers) != 1 || gotSubscribers[0].Email != tt.email {
+			t.Errorf("Expected only subscriber %s, got %v", tt.email, gotSubscribers)
+		}
+	}
+
+	// the sites are archived separately, as they are compared differently
+	for _, sub := range []*htracker.Subscription{aliceSub, bobSub} {
+		if err := db.Add(ctx, &htracker.Site{Subscription: sub, Content: []byte(sub.DiffMode)}); err != nil {
+			t.Fatalf("db.Add() failed: %v", err)
+		}
+	}
+	for _, sub := range []*htracker.Subscription{aliceSub, bobSub} {
+		site, err := db.Get(ctx, sub)
+		if err != nil {
+			t.Fatalf("db.Get() failed: %v", err)
+		}
+		if want, got := sub.DiffMode, string(site.Content); want != got {
+			t.Errorf("Expected content %q, got %q", want, got)
+		}
+	}
+}
+
+func Test_db_AddSubscription_Method(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber := &storage.Subscriber{Email: "methodemail1"}
+	if err := db.AddSubscriber(ctx, subscriber); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+
+	get := &htracker.Subscription{URL: "methodsite1", Interval: time.Hour}
+	post := &htracker.Subscription{URL: "methodsite1", Method: "POST", Body: "q=foo", Interval: time.Hour}
+	postOtherBody := &htracker.Subscription{URL: "methodsite1", Method: "POST", Body: "q=bar", Interval: time.Hour}
+
+	for _, s := range []*htracker.Subscription{get, post, postOtherBody} {
+		if err := db.AddSubscription(ctx, subscriber.Email, s); err != nil {
+			t.Fatalf("db.AddSubscription(%s %s) failed: %v", s.HTTPMethod(), s.Body, err)
+		}
+	}
+	if err := db.AddSubscription(ctx, subscriber.Email, post); err == nil {
+		t.Error("Expected adding the same POST subscription again to fail")
+	}
+
+	gotSubs, err := db.FindBySubscriber(ctx, subscriber.Email)
+	if err != nil {
+		t.Fatalf("db.FindBySubscriber() failed: %v", err)
+	}
+	if want, got := 3, len(gotSubs); want != got {
+		t.Fatalf("Expected %d subscriptions, got %d", want, got)
+	}
+
+	if err := db.RemoveSubscription(ctx, subscriber.Email, post); err != nil {
+		t.Fatalf("db.RemoveSubscription() failed: %v", err)
+	}
+	gotSubs, err = db.FindBySubscriber(ctx, subscriber.Email)
+	if err != nil {
+		t.Fatalf("db.FindBySubscriber() failed: %v", err)
+	}
+	for _, s := range gotSubs {
+		if s.Equals(post) {
+			t.Errorf("Expected POST subscription to be removed, got %v", s)
+		}
+	}
+}
+
+func Test_db_AddSubscription_ChromeFallback(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber := &storage.Subscriber{Email: "fallbackemail1"}
+	if err := db.AddSubscriber(ctx, subscriber); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+
+	sub := &htracker.Subscription{URL: "fallbacksite1", UseChrome: true, ChromeFallback: true, Interval: time.Hour}
+	if err := db.AddSubscription(ctx, subscriber.Email, sub); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+
+	gotSubs, err := db.FindBySubscriber(ctx, subscriber.Email)
+	if err != nil {
+		t.Fatalf("db.FindBySubscriber() failed: %v", err)
+	}
+	if len(gotSubs) != 1 || !gotSubs[0].ChromeFallback {
+		t.Errorf("Expected subscription with chrome fallback, got %v", gotSubs)
+	}
+}
+
+func Test_db_AddSubscription_Normalize(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber := &storage.Subscriber{Email: "normalizeemail1"}
+	if err := db.AddSubscriber(ctx, subscriber); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+
+	sub := &htracker.Subscription{URL: "normalizesite1", Normalize: htracker.NormalizeWhitespace, Interval: time.Hour}
+	if err := db.AddSubscription(ctx, subscriber.Email, sub); err != nil {
+		t.Fatalf("db.AddSubscription() failed: %v", err)
+	}
+
+	gotSubs, err := db.FindBySubscriber(ctx, subscriber.Email)
+	if err != nil {
+		t.Fatalf("db.FindBySubscriber() failed: %v", err)
+	}
+	if len(gotSubs) != 1 || gotSubs[0].Normalize != htracker.NormalizeWhitespace {
+		t.Errorf("Expected subscription with whitespace normalization, got %v", gotSubs)
+	}
+}
+
+func Test_db_Remove_OrphanSites(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber1 := &storage.Subscriber{Email: "orphanemail1"}
+	subscriber2 := &storage.Subscriber{Email: "orphanemail2"}
+	sub := &htracker.Subscription{URL: "orphansite1", Interval: time.Hour}
+	for _, subscriber := range []*storage.Subscriber{subscriber1, subscriber2} {
+		if err := db.AddSubscriber(ctx, subscriber); err != nil {
+			t.Fatalf("Setup: failed to add subscriber: %v", err)
+		}
+		if err := db.AddSubscription(ctx, subscriber.Email, sub); err != nil {
+			t.Fatalf("Setup: failed to add subscription: %v", err)
+		}
+	}
+	if err := db.Add(ctx, &htracker.Site{Subscription: sub, LastChecked: time.Now(), Content: []byte("content"), Checksum: "1234"}); err != nil {
+		t.Fatalf("Setup: failed to add site: %v", err)
+	}
+
+	// the site is kept as long as somebody is subscribed
+	if err := db.RemoveSubscription(ctx, subscriber1.Email, sub); err != nil {
+		t.Fatalf("db.RemoveSubscription() failed: %v", err)
+	}
+	if _, err := db.Get(ctx, sub); err != nil {
+		t.Errorf("Expected site to be kept, got %v", err)
+	}
+
+	if err := db.RemoveSubscriber(ctx, subscriber2.Email); err != nil {
+		t.Fatalf("db.RemoveSubscriber() failed: %v", err)
+	}
+	if _, err := db.Get(ctx, sub); !errors.Is(err, htracker.ErrNotExist) {
+		t.Errorf("Expected orphan site to be deleted, got %v", err)
+	}
+}
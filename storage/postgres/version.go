@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+// compile time check of interface implementation.
+var _ storage.VersionStorage = &db{}
+
+// version is a row of the site_versions table.
+type version struct {
+	ID       int64
+	SiteID   int64 `db:"site_id"`
+	Archived time.Time
+	Checksum string
+	Content  []byte
+}
+
+// AddVersion is adding the content of the archived site as version scraped at site.LastChecked. It is a
+// no-op if the site is not archived.
+func (db *db) AddVersion(ctx context.Context, site *htracker.Site) error {
+	key, err := db.siteKey(site.Subscription)
+	if err != nil {
+		db.logger.Error("failed to compute variant of subscription", err, slog.String("method", "AddVersion"), slog.String("url", site.Subscription.URL))
+		return err
+	}
+
+	query := `INSERT INTO site_versions (site_id, archived, checksum, content)
+		SELECT id, $7, $8, $9 FROM sites
+		WHERE url = $1 AND filter = $2 AND content_type = $3 AND method = $4 AND md5(body) = md5($5) AND variant = $6`
+	content := site.Content
+	if content == nil {
+		content = []byte{}
+	}
+	args := append(append([]interface{}{}, key...), site.LastChecked, site.Checksum, content)
+	if _, err := db.conn.ExecContext(ctx, query, args...); err != nil {
+		db.logger.Error("query failed", err, slog.String("method", "AddVersion"), slog.String("url", site.Subscription.URL))
+		return wrapError(err)
+	}
+	return nil
+}
+
+// GetVersions is returning the versions of the site of the subscription, latest first.
+func (db *db) GetVersions(ctx context.Context, subscription *htracker.Subscription) ([]*htracker.Version, error) {
+	key, err := db.siteKey(subscription)
+	if err != nil {
+		db.logger.Error("failed to compute variant of subscription", err, slog.String("method", "GetVersions"), slog.String("url", subscription.URL))
+		return nil, err
+	}
+
+	rows := []*version{}
+	query := `SELECT v.* FROM site_versions v, sites s
+		WHERE v.site_id = s.id AND s.url = $1 AND s.filter = $2 AND s.content_type = $3 AND s.method = $4
+			AND md5(s.body) = md5($5) AND s.variant = $6
+		ORDER BY v.archived DESC, v.id DESC`
+	if err := db.conn.SelectContext(ctx, &rows, query, key...); err != nil {
+		db.logger.Error("query failed", err, slog.String("method", "GetVersions"), slog.String("url", subscription.URL))
+		return nil, wrapError(err)
+	}
+
+	versions := make([]*htracker.Version, len(rows))
+	for i, v := range rows {
+		versions[i] = &htracker.Version{Archived: v.Archived, Checksum: v.Checksum, Content: v.Content}
+	}
+	return versions, nil
+}
+
+// siteRetention is the retention column of the subscription of a site with versions.
+type siteRetention struct {
+	SiteID    int64 `db:"site_id"`
+	Retention string
+}
+
+// PruneVersions is removing the versions not kept by the retention policy of the subscription of their
+// site, or by the given default policy if it has none. The versions of each site are pruned in a
+// transaction of their own.
+func (db *db) PruneVersions(ctx context.Context, defaultPolicy htracker.RetentionPolicy) (int, error) {
+	logger := db.logger.With(slog.String("method", "PruneVersions"))
+
+	// the subscription of a site is stored once per subscriber, with the same retention policy
+	sites := []*siteRetention{}
+	query := `SELECT DISTINCT st.id AS site_id, COALESCE(s.retention, '') AS retention FROM sites st
+		LEFT JOIN subscriptions s ON s.url = st.url AND s.filter = st.filter AND s.content_type = st.content_type
+			AND s.method = st.method AND md5(s.body) = md5(st.body) AND s.variant IS NOT DISTINCT FROM st.variant
+		WHERE EXISTS (SELECT FROM site_versions v WHERE v.site_id = st.id)`
+	if err := db.conn.SelectContext(ctx, &sites, query); err != nil {
+		logger.Error("query failed", err)
+		return 0, wrapError(err)
+	}
+
+	now := time.Now()
+	pruned := 0
+	for _, s := range sites {
+		policy := defaultPolicy
+		if p := retentionPolicy(s.Retention); p != nil {
+			policy = *p
+		}
+		count, err := db.pruneSiteVersions(ctx, s.SiteID, policy, now)
+		if err != nil {
+			logger.Error("failed to prune versions of site", err, slog.Int64("site_id", s.SiteID))
+			return pruned, err
+		}
+		pruned += count
+	}
+
+	return pruned, nil
+}
+
+// pruneSiteVersions is removing the versions of the site with the given id not kept by policy.
+func (db *db) pruneSiteVersions(ctx context.Context, siteID int64, policy htracker.RetentionPolicy, now time.Time) (int, error) {
+	tx, err := db.conn.BeginTxx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return 0, err
+	}
+	// rolling back is a no-op after commit
+	defer func() { _ = tx.Rollback() }()
+
+	// the versions are locked, so that concurrent prunes are not deleting more than the policy allows
+	rows := []*version{}
+	query := `SELECT id, archived FROM site_versions WHERE site_id = $1 ORDER BY archived DESC, id DESC FOR UPDATE`
+	if err := tx.SelectContext(ctx, &rows, query, siteID); err != nil {
+		return 0, wrapError(err)
+	}
+
+	archived := make([]time.Time, len(rows))
+	for i, v := range rows {
+		archived[i] = v.Archived
+	}
+	var ids []int64
+	for i, keep := range policy.Keep(archived, now) {
+		if !keep {
+			ids = append(ids, rows[i].ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM site_versions WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+		return 0, wrapError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gitlab.com/henri.philipps/htracker"
+	"gitlab.com/henri.philipps/htracker/storage"
+	"golang.org/x/exp/slog"
+)
+
+func Test_db_Versions(t *testing.T) {
+	if !runIntegrationTests() {
+		t.Skipf("set %s env var to run this test", integrationTestVar)
+	}
+
+	ctx := context.Background()
+	db, err := New(URIfromEnvVars(), slog.Default())
+	if err != nil {
+		t.Fatalf("Failed to open DB connection: %v", err)
+	}
+
+	subscriber := &storage.Subscriber{Email: "versionsemail1"}
+	sub := &htracker.Subscription{URL: "versionssite1", Interval: time.Hour, Retention: &htracker.RetentionPolicy{KeepLast: 2}}
+	if err := db.AddSubscriber(ctx, subscriber); err != nil {
+		t.Fatalf("Setup: failed to add subscriber: %v", err)
+	}
+	if err := db.AddSubscription(ctx, subscriber.Email, sub); err != nil {
+		t.Fatalf("Setup: failed to add subscription: %v", err)
+	}
+	// round to the precision of postgres
+	now := time.Now().Truncate(time.Millisecond)
+	if err := db.Add(ctx, &htracker.Site{Subscription: sub, LastChecked: now}); err != nil {
+		t.Fatalf("Setup: failed to add site: %v", err)
+	}
+	for i := 3; i >= 0; i-- {
+		content := []byte{byte('a' + i)}
+		site := &htracker.Site{Subscription: sub, LastChecked: now.Add(-time.Duration(i) * time.Minute), Checksum: string(content), Content: content}
+		if err := db.AddVersion(ctx, site); err != nil {
+			t.Fatalf("db.AddVersion() failed: %v", err)
+		}
+	}
+
+	versions, err := db.GetVersions(ctx, sub)
+	if err != nil {
+		t.Fatalf("db.GetVersions() failed: %v", err)
+	}
+	if len(versions) < 4 || !versions[0].Archived.Equal(now) || string(versions[0].Content) != "a" {
+		t.Fatalf("Expected at least 4 versions, the latest first, got %v", versions)
+	}
+
+	// other sites are pruned by the default policy keeping all versions
+	pruned, err := db.PruneVersions(ctx, htracker.RetentionPolicy{})
+	if err != nil {
+		t.Fatalf("db.PruneVersions() failed: %v", err)
+	}
+	if want := len(versions) - 2; pruned != want {
+		t.Errorf("Expected %d pruned versions, got %d", want, pruned)
+	}
+	versions, err = db.GetVersions(ctx, sub)
+	if err != nil {
+		t.Fatalf("db.GetVersions() failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Checksum != "a" || versions[1].Checksum != "b" {
+		t.Errorf("Expected the latest 2 versions to be kept, got %v", versions)
+	}
+
+	refs, err := db.BlobReferences(ctx)
+	if err != nil {
+		t.Fatalf("db.BlobReferences() failed: %v", err)
+	}
+	if !refs["b"] {
+		t.Errorf("Expected the checksums of versions to be referenced, got %v", refs)
+	}
+
+	// the versions are removed with their site
+	if err := db.RemoveSubscriber(ctx, subscriber.Email); err != nil {
+		t.Fatalf("db.RemoveSubscriber() failed: %v", err)
+	}
+	if _, err := db.CollectGarbage(ctx); err != nil {
+		t.Fatalf("db.CollectGarbage() failed: %v", err)
+	}
+	if versions, err := db.GetVersions(ctx, sub); err != nil || len(versions) != 0 {
+		t.Errorf("Expected the versions to be removed with their site, got %v, %v", versions, err)
+	}
+}
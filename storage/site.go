@@ -11,4 +11,12 @@ type SiteStorage interface {
 	Get(context.Context, *htracker.Subscription) (*htracker.Site, error)
 	Add(context.Context, *htracker.Site) error
 	Update(context.Context, *htracker.Site) error
+	// Upsert is atomically adding or updating the site of the subscription with the site returned by
+	// the UpdateFunc, so that concurrent upserts of the same site don't overwrite each other.
+	Upsert(context.Context, *htracker.Subscription, UpdateFunc) error
 }
+
+// UpdateFunc is returning the site to be stored, given the archived site or nil if the site is not archived
+// yet. If nil is returned, the storage is left unchanged. Errors are returned by Upsert as is. The function
+// might be called more than once, e.g. if the site was added concurrently, and must not call the storage.
+type UpdateFunc func(archived *htracker.Site) (*htracker.Site, error)
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+
+	"gitlab.com/henri.philipps/htracker"
+)
+
+// VersionStorage is an interface describing a storage keeping the history of the contents of archived
+// sites. The versions of a site are removed with the site.
+type VersionStorage interface {
+	// AddVersion is adding the content of the archived site as version scraped at site.LastChecked.
+	AddVersion(context.Context, *htracker.Site) error
+	// GetVersions is returning the versions of the site of the subscription, latest first.
+	GetVersions(context.Context, *htracker.Subscription) ([]*htracker.Version, error)
+	// PruneVersions is removing the versions not kept by the retention policy of the subscription of
+	// their site, or by the given default policy if it has none, returning their number.
+	PruneVersions(ctx context.Context, defaultPolicy htracker.RetentionPolicy) (int, error)
+}
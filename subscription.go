@@ -1,6 +1,12 @@
 package htracker
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
 
 // Subscription contains the meta data necessary to describe a web site to be watched for updates.
 type Subscription struct {
@@ -9,10 +15,259 @@ type Subscription struct {
 	ContentType string
 	UseChrome   bool
 	Interval    time.Duration
+
+	// Method is the HTTP method used for scraping the site, GET if empty.
+	Method string `json:",omitempty"`
+
+	// Body is sent with the request, e.g. form fields or JSON. It is sent with the content type
+	// application/x-www-form-urlencoded, unless a Content-Type header is set in the request options.
+	Body string `json:",omitempty"`
+
+	// ChromeFallback is allowing to scrape the site without rendering, if chrome is unavailable.
+	ChromeFallback bool `json:",omitempty"`
+
+	// Screenshot is capturing a screenshot of the site when rendered with chrome, of the element
+	// matching the filter or of the full page if there is no filter. Visual changes are reported
+	// like changes of the content, with their own threshold MinPixelChange.
+	Screenshot bool `json:",omitempty"`
+
+	// Normalize is the normalization mode of the content, NormalizeDefault if empty.
+	Normalize string `json:",omitempty"`
+
+	// DiffMode is defining the units compared when diffing the content, DiffModeDefault if empty.
+	DiffMode string `json:",omitempty"`
+
+	// MinChange is the min percentage of changed content, see ChangeMetrics.Changed. Smaller changes
+	// are archived, but not reported as update of the site. 0 means all changes are reported.
+	MinChange float64 `json:",omitempty"`
+
+	// MinPixelChange is the min percentage of changed pixels of the screenshot, see Screenshot.PixelDiff.
+	// Smaller visual changes are archived, but not reported as update of the site. 0 means all visual
+	// changes are reported.
+	MinPixelChange float64 `json:",omitempty"`
+
+	// Retention is defining which archived versions of the content are kept, the default policy of
+	// the archive if nil.
+	Retention *RetentionPolicy `json:",omitempty"`
+
+	// Request is customizing the requests sent for scraping the site. Credentials are encrypted at rest
+	// by the storage backends. If nil, plain requests are sent.
+	Request *RequestOptions `json:",omitempty"`
+}
+
+// RequestOptions are headers, cookies and credentials sent with each request for scraping a site,
+// e.g. an Accept-Language header or a cookie consent cookie, and a script of actions for sites
+// rendered with chrome. They are encrypted at rest, as scripts might be typing credentials as well.
+type RequestOptions struct {
+	Headers   map[string]string `json:",omitempty"`
+	Cookies   map[string]string `json:",omitempty"`
+	BasicAuth *BasicAuth        `json:",omitempty"`
+
+	// Actions is a script executed in chrome after the site was loaded, before its content is captured,
+	// e.g. for accepting cookies or logging in. It requires the site to be rendered with chrome.
+	Actions []ChromeAction `json:",omitempty"`
+}
+
+// BasicAuth are the credentials for sites behind HTTP basic authentication.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// ActionKind is the kind of a ChromeAction.
+type ActionKind string
+
+const (
+	// ActionWait is waiting until the element matching the selector is visible.
+	ActionWait ActionKind = "wait"
+
+	// ActionClick is clicking the element matching the selector.
+	ActionClick ActionKind = "click"
+
+	// ActionType is typing the text into the element matching the selector.
+	ActionType ActionKind = "type"
+
+	// ActionSleep is sleeping for the duration.
+	ActionSleep ActionKind = "sleep"
+
+	// ActionScroll is scrolling the element matching the selector into view,
+	// or to the bottom of the page if the selector is empty.
+	ActionScroll ActionKind = "scroll"
+)
+
+// MaxActionDuration is the max duration of a single ChromeAction.
+const MaxActionDuration = time.Minute
+
+// ChromeAction is a step of a script executed in chrome before capturing the content of a site.
+// Selectors are CSS selectors.
+type ChromeAction struct {
+	Action   ActionKind
+	Selector string `json:",omitempty"`
+	Text     string `json:",omitempty"`
+
+	// Duration is the time to sleep for ActionSleep. For the other actions it is the max time
+	// to wait for the selector to match, with a default of 30s.
+	Duration time.Duration `json:",omitempty"`
+}
+
+// Validate is returning an error wrapping ErrInvalid if the action can't be executed.
+func (a ChromeAction) Validate() error {
+	switch a.Action {
+	case ActionWait, ActionClick, ActionType:
+		if a.Selector == "" {
+			return fmt.Errorf("chrome action %s requires a selector: %w", a.Action, ErrInvalid)
+		}
+	case ActionSleep:
+		if a.Duration <= 0 {
+			return fmt.Errorf("chrome action %s requires a duration: %w", a.Action, ErrInvalid)
+		}
+	case ActionScroll:
+	default:
+		return fmt.Errorf("chrome action %s not supported: %w", a.Action, ErrInvalid)
+	}
+	if a.Duration < 0 || a.Duration > MaxActionDuration {
+		return fmt.Errorf("duration of chrome action %s must be between 0 and %v: %w", a.Action, MaxActionDuration, ErrInvalid)
+	}
+	return nil
 }
 
 // Equal is a method for comparing subscriptions, mainly to deduplicate same subscriptions by different subscribers.
-// The combination of URL, Filter and ContentType must be equal for subscriptions to be equal.
+// All fields but the Interval must be equal for subscriptions to be equal, so that subscribers never share the
+// requests (and with it the credentials) or the settings of the archived site with other subscribers.
 func (s1 *Subscription) Equals(s2 *Subscription) bool {
-	return s1.URL == s2.URL && s1.Filter == s2.Filter && s1.ContentType == s2.ContentType && s1.UseChrome == s2.UseChrome
+	return s1.Key() == s2.Key()
+}
+
+// Key is returning a string identifying the subscription, equal subscriptions have the same key.
+// It contains the request options in clear text and must not be persisted.
+func (s *Subscription) Key() string {
+	key := struct {
+		URL, Filter, ContentType string
+		Method, Body             string
+		Settings                 SubscriptionSettings
+		Request                  *RequestOptions
+	}{s.URL, s.Filter, s.ContentType, s.HTTPMethod(), s.Body, s.Settings(), s.Request}
+
+	// encoding a struct of strings, bools and maps of strings can't fail
+	data, _ := json.Marshal(key)
+	return string(data)
+}
+
+// SubscriptionSettings are the settings of a subscription, which are defining how its site is scraped and
+// compared, besides the request.
+type SubscriptionSettings struct {
+	UseChrome      bool             `json:",omitempty"`
+	ChromeFallback bool             `json:",omitempty"`
+	Screenshot     bool             `json:",omitempty"`
+	Normalize      string           `json:",omitempty"`
+	DiffMode       string           `json:",omitempty"`
+	MinChange      float64          `json:",omitempty"`
+	MinPixelChange float64          `json:",omitempty"`
+	Retention      *RetentionPolicy `json:",omitempty"`
+}
+
+// Settings is returning the settings of the subscription.
+func (s *Subscription) Settings() SubscriptionSettings {
+	return SubscriptionSettings{
+		UseChrome:      s.UseChrome,
+		ChromeFallback: s.ChromeFallback,
+		Screenshot:     s.Screenshot,
+		Normalize:      s.Normalize,
+		DiffMode:       s.DiffMode,
+		MinChange:      s.MinChange,
+		MinPixelChange: s.MinPixelChange,
+		Retention:      s.Retention,
+	}
+}
+
+// HTTPMethod is returning the upper case HTTP method of the subscription, defaulting to GET.
+func (s *Subscription) HTTPMethod() string {
+	if s.Method == "" {
+		return http.MethodGet
+	}
+	return strings.ToUpper(s.Method)
+}
+
+// Redacted is returning a copy of the subscription without request options, as they might contain
+// credentials. It is used for all output of the subscription which is not persisted.
+func (s *Subscription) Redacted() *Subscription {
+	redacted := *s
+	redacted.Request = nil
+	return &redacted
+}
+
+// Validate is returning an error wrapping ErrInvalid if the subscription can't be scraped.
+func (s *Subscription) Validate() error {
+	switch s.HTTPMethod() {
+	case http.MethodGet:
+		if s.Body != "" {
+			return fmt.Errorf("request body not supported with method GET: %w", ErrInvalid)
+		}
+	case http.MethodPost:
+		// chrome is always navigating to the site with GET
+		if s.UseChrome {
+			return fmt.Errorf("method POST not supported for sites rendered with chrome: %w", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("method %s not supported: %w", s.Method, ErrInvalid)
+	}
+
+	if s.IsFeed() {
+		if s.UseChrome {
+			return fmt.Errorf("feeds can't be rendered with chrome: %w", ErrInvalid)
+		}
+		if s.Filter != "" {
+			return fmt.Errorf("filters are not supported for feeds: %w", ErrInvalid)
+		}
+	}
+
+	switch s.Normalize {
+	case NormalizeDefault, NormalizeWhitespace, NormalizeNone:
+	default:
+		return fmt.Errorf("normalization %s not supported: %w", s.Normalize, ErrInvalid)
+	}
+
+	switch s.DiffMode {
+	case DiffModeDefault, DiffModeLine, DiffModeWord:
+	default:
+		return fmt.Errorf("diff mode %s not supported: %w", s.DiffMode, ErrInvalid)
+	}
+
+	if s.MinChange < 0 || s.MinChange > 100 {
+		return fmt.Errorf("min change %v must be between 0 and 100: %w", s.MinChange, ErrInvalid)
+	}
+
+	if s.MinPixelChange < 0 || s.MinPixelChange > 100 {
+		return fmt.Errorf("min pixel change %v must be between 0 and 100: %w", s.MinPixelChange, ErrInvalid)
+	}
+
+	if s.ChromeFallback && !s.UseChrome {
+		return fmt.Errorf("chrome fallback requires the site to be rendered with chrome: %w", ErrInvalid)
+	}
+
+	if s.Screenshot && !s.UseChrome {
+		return fmt.Errorf("screenshots require the site to be rendered with chrome: %w", ErrInvalid)
+	}
+
+	if s.Retention != nil {
+		if err := s.Retention.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if s.MinPixelChange != 0 && !s.Screenshot {
+		return fmt.Errorf("min pixel change requires screenshots of the site: %w", ErrInvalid)
+	}
+
+	if s.Request != nil && len(s.Request.Actions) > 0 {
+		if !s.UseChrome {
+			return fmt.Errorf("chrome actions require the site to be rendered with chrome: %w", ErrInvalid)
+		}
+		for _, a := range s.Request.Actions {
+			if err := a.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -1,6 +1,7 @@
 package htracker
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -21,3 +22,124 @@ func Test_SubscriptionEqual(t *testing.T) {
 		t.Fatalf("Expected sub1.Equals(sub4) == %v, got %v", want, got)
 	}
 }
+
+func Test_SubscriptionEqual_Request(t *testing.T) {
+	get := Subscription{URL: "http://site1.example/search"}
+	getExplicit := Subscription{URL: "http://site1.example/search", Method: "get"}
+	post := Subscription{URL: "http://site1.example/search", Method: "POST", Body: "q=foo"}
+	postOtherBody := Subscription{URL: "http://site1.example/search", Method: "POST", Body: "q=bar"}
+	auth := Subscription{URL: "http://site1.example/search", Request: &RequestOptions{BasicAuth: &BasicAuth{Username: "u", Password: "p"}}}
+	otherAuth := Subscription{URL: "http://site1.example/search", Request: &RequestOptions{BasicAuth: &BasicAuth{Username: "u", Password: "x"}}}
+
+	tests := []struct {
+		name   string
+		s1, s2 *Subscription
+		want   bool
+	}{
+		{name: "default method", s1: &get, s2: &getExplicit, want: true},
+		{name: "different method", s1: &get, s2: &post, want: false},
+		{name: "different body", s1: &post, s2: &postOtherBody, want: false},
+		{name: "same body", s1: &post, s2: &Subscription{URL: post.URL, Method: "post", Body: post.Body}, want: true},
+		{name: "request options", s1: &get, s2: &auth, want: false},
+		{name: "different settings", s1: &get, s2: &Subscription{URL: get.URL, Normalize: NormalizeNone}, want: false},
+		{name: "different min change", s1: &get, s2: &Subscription{URL: get.URL, MinChange: 5}, want: false},
+		{name: "different min pixel change", s1: &get, s2: &Subscription{URL: get.URL, MinPixelChange: 5}, want: false},
+		{name: "different retention", s1: &get, s2: &Subscription{URL: get.URL, Retention: &RetentionPolicy{KeepLast: 5}}, want: false},
+		{name: "same retention", s1: &Subscription{URL: get.URL, Retention: &RetentionPolicy{KeepLast: 5}},
+			s2: &Subscription{URL: get.URL, Retention: &RetentionPolicy{KeepLast: 5}}, want: true},
+		{name: "different interval", s1: &get, s2: &Subscription{URL: get.URL, Interval: time.Minute}, want: true},
+		{name: "different credentials", s1: &auth, s2: &otherAuth, want: false},
+		{name: "same credentials", s1: &auth, s2: &Subscription{URL: auth.URL, Request: &RequestOptions{
+			BasicAuth: &BasicAuth{Username: "u", Password: "p"}}}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.s1.Equals(tt.s2); got != tt.want {
+				t.Errorf("Equals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Subscription_Validate(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription Subscription
+		wantErr      bool
+	}{
+		{name: "get", subscription: Subscription{URL: "http://site1.example"}},
+		{name: "post", subscription: Subscription{URL: "http://site1.example", Method: "post", Body: "q=foo"}},
+		{name: "post without body", subscription: Subscription{URL: "http://site1.example", Method: "POST"}},
+		{name: "get with body", subscription: Subscription{URL: "http://site1.example", Body: "q=foo"}, wantErr: true},
+		{name: "post with chrome", subscription: Subscription{URL: "http://site1.example", Method: "POST", UseChrome: true}, wantErr: true},
+		{name: "unsupported method", subscription: Subscription{URL: "http://site1.example", Method: "DELETE"}, wantErr: true},
+		{name: "chrome fallback", subscription: Subscription{URL: "http://site1.example", UseChrome: true, ChromeFallback: true}},
+		{name: "fallback without chrome", subscription: Subscription{URL: "http://site1.example", ChromeFallback: true}, wantErr: true},
+		{name: "normalize whitespace", subscription: Subscription{URL: "http://site1.example", Normalize: NormalizeWhitespace}},
+		{name: "unknown normalization", subscription: Subscription{URL: "http://site1.example", Normalize: "nfkc"}, wantErr: true},
+		{name: "word diff", subscription: Subscription{URL: "http://site1.example", DiffMode: DiffModeWord}},
+		{name: "unknown diff mode", subscription: Subscription{URL: "http://site1.example", DiffMode: "char"}, wantErr: true},
+		{name: "screenshot", subscription: Subscription{URL: "http://site1.example", UseChrome: true, Screenshot: true}},
+		{name: "screenshot without chrome", subscription: Subscription{URL: "http://site1.example", Screenshot: true}, wantErr: true},
+		{name: "min change", subscription: Subscription{URL: "http://site1.example", MinChange: 2.5}},
+		{name: "negative min change", subscription: Subscription{URL: "http://site1.example", MinChange: -1}, wantErr: true},
+		{name: "min change above 100", subscription: Subscription{URL: "http://site1.example", MinChange: 101}, wantErr: true},
+		{name: "min pixel change", subscription: Subscription{URL: "http://site1.example", UseChrome: true, Screenshot: true, MinPixelChange: 2.5}},
+		{name: "negative min pixel change", subscription: Subscription{URL: "http://site1.example", UseChrome: true, Screenshot: true, MinPixelChange: -1}, wantErr: true},
+		{name: "min pixel change above 100", subscription: Subscription{URL: "http://site1.example", UseChrome: true, Screenshot: true, MinPixelChange: 101}, wantErr: true},
+		{name: "min pixel change without screenshot", subscription: Subscription{URL: "http://site1.example", UseChrome: true, MinPixelChange: 2.5}, wantErr: true},
+		{name: "retention", subscription: Subscription{URL: "http://site1.example", Retention: &RetentionPolicy{KeepLast: 3}}},
+		{name: "negative retention", subscription: Subscription{URL: "http://site1.example", Retention: &RetentionPolicy{KeepDaily: -1}}, wantErr: true},
+		{name: "feed", subscription: Subscription{URL: "http://site1.example/feed.xml", ContentType: ContentTypeFeed}},
+		{name: "feed with chrome", subscription: Subscription{URL: "http://site1.example/feed.xml", ContentType: ContentTypeFeed, UseChrome: true}, wantErr: true},
+		{name: "feed with filter", subscription: Subscription{URL: "http://site1.example/feed.xml", ContentType: ContentTypeFeed, Filter: "item"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.subscription.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Validate() expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func Test_Subscription_Validate_Actions(t *testing.T) {
+	tests := []struct {
+		name      string
+		useChrome bool
+		actions   []ChromeAction
+		wantErr   bool
+	}{
+		{name: "script", useChrome: true, actions: []ChromeAction{
+			{Action: ActionClick, Selector: "button.accept"},
+			{Action: ActionWait, Selector: "#content", Duration: 10 * time.Second},
+			{Action: ActionType, Selector: "input[name=q]", Text: "foo"},
+			{Action: ActionSleep, Duration: time.Second},
+			{Action: ActionScroll},
+		}},
+		{name: "without chrome", actions: []ChromeAction{{Action: ActionScroll}}, wantErr: true},
+		{name: "click without selector", useChrome: true, actions: []ChromeAction{{Action: ActionClick}}, wantErr: true},
+		{name: "sleep without duration", useChrome: true, actions: []ChromeAction{{Action: ActionSleep}}, wantErr: true},
+		{name: "sleep too long", useChrome: true, actions: []ChromeAction{{Action: ActionSleep, Duration: time.Hour}}, wantErr: true},
+		{name: "unknown action", useChrome: true, actions: []ChromeAction{{Action: "hover", Selector: "a"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Subscription{URL: "http://site1.example", UseChrome: tt.useChrome, Request: &RequestOptions{Actions: tt.actions}}
+			err := s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalid) {
+				t.Errorf("Validate() expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
@@ -15,8 +15,40 @@ import (
 
 // Watcher is scraping subscribed sites in regular intervals.
 type Watcher struct {
-	archive     service.SiteArchive
-	subSvc      service.SubscriptionSvc
+	archive service.SiteArchive
+	subSvc  service.SubscriptionSvc
+
+	// mu is guarding the settings below, which can be changed with Reconfigure() while running
+	mu           sync.RWMutex
+	logger       *slog.Logger
+	interval     time.Duration
+	batchSize    int
+	threads      int
+	scraperOpts  []scraper.Opt
+	reconfigured chan struct{}
+
+	// elector is deciding if the watcher is scraping, if several watchers are sharing the subscriptions
+	elector          Elector
+	electionInterval time.Duration
+	// leader is whether the watcher was elected in the latest election, it is only used by Start()
+	// and RunScrapers()
+	leader bool
+}
+
+// Elector is an interface describing the election of the leader of several watchers sharing the subscriptions,
+// e.g. replicas using the same database. Only the leader is scraping, so that sites are not scraped twice.
+type Elector interface {
+	// Elect is trying to make the caller the leader, or keep it the leader, and returning whether it is the leader.
+	Elect(context.Context) (bool, error)
+	// Resign is giving up the leadership, so that another watcher can take over.
+	Resign() error
+}
+
+// DefaultElectionInterval is the default interval of watchers trying to become the leader, see WithElector.
+const DefaultElectionInterval = 10 * time.Second
+
+// settings is a snapshot of the reconfigurable settings of a Watcher, used for one scrape run.
+type settings struct {
 	logger      *slog.Logger
 	interval    time.Duration
 	batchSize   int
@@ -27,12 +59,15 @@ type Watcher struct {
 // NewWatcher is returning a new Watcher instance.
 func NewWatcher(archive service.SiteArchive, subSvc service.SubscriptionSvc, opts ...Opt) *Watcher {
 	watcher := &Watcher{
-		archive:   archive,
-		subSvc:    subSvc,
-		logger:    slog.Default(),
-		interval:  time.Hour,
-		batchSize: 4,
-		threads:   2,
+		archive:      archive,
+		subSvc:       subSvc,
+		logger:       slog.Default(),
+		interval:     time.Hour,
+		batchSize:    4,
+		threads:      2,
+		reconfigured: make(chan struct{}, 1),
+
+		electionInterval: DefaultElectionInterval,
 	}
 
 	for _, opt := range opts {
@@ -80,6 +115,53 @@ func WithLogger(logger *slog.Logger) Opt {
 	}
 }
 
+// WithElector is making the watcher scrape only while it is the leader elected by e. Watchers which are
+// not the leader are trying to become the leader in the election interval, so that one of them is taking
+// over soon if the leader stopped.
+func WithElector(e Elector) Opt {
+	return func(w *Watcher) {
+		w.elector = e
+	}
+}
+
+// WithElectionInterval sets the interval of watchers trying to become the leader, see WithElector.
+func WithElectionInterval(interval time.Duration) Opt {
+	return func(w *Watcher) {
+		w.electionInterval = interval
+	}
+}
+
+// Reconfigure is applying the given options to a running watcher. Scrape runs already in progress
+// are finished with the old settings, the new settings are used starting with the next run.
+// A changed interval is taking effect immediately, without triggering an additional run.
+func (w *Watcher) Reconfigure(opts ...Opt) {
+	w.mu.Lock()
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.mu.Unlock()
+
+	// notify Start() without blocking, a pending notification is sufficient
+	select {
+	case w.reconfigured <- struct{}{}:
+	default:
+	}
+}
+
+// snapshot is returning a copy of the current settings.
+func (w *Watcher) snapshot() settings {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return settings{
+		logger:      w.logger,
+		interval:    w.interval,
+		batchSize:   w.batchSize,
+		threads:     w.threads,
+		scraperOpts: append([]scraper.Opt{}, w.scraperOpts...),
+	}
+}
+
 // GenerateScrapeList is returning a list of Subscriptions to be scraped by going through
 // all subscriptions and deduplicating them.
 func (w *Watcher) GenerateScrapeList(ctx context.Context) (subscriptions []*htracker.Subscription, err error) {
@@ -95,9 +177,10 @@ func (w *Watcher) GenerateScrapeList(ctx context.Context) (subscriptions []*htra
 	for _, subscriber := range subscribers {
 		for _, subscription := range subscriber.Subscriptions {
 			// deduplicate subscriptions
-			if !siteSet[subscription.URL+subscription.Filter+subscription.ContentType] {
+			key := subscription.Key()
+			if !siteSet[key] {
 				subscriptions = append(subscriptions, subscription)
-				siteSet[subscription.URL+subscription.Filter+subscription.ContentType] = true
+				siteSet[key] = true
 			}
 		}
 	}
@@ -107,13 +190,16 @@ func (w *Watcher) GenerateScrapeList(ctx context.Context) (subscriptions []*htra
 
 // RunScrapers is starting up worker threads to scrape the given subscriptions and waits for them to finish.
 // When all scrapers finished there still might be exporters processing the results asynchronously.
+// With an elector, the leadership is confirmed before each batch, and the run is canceled if it was lost.
 func (w *Watcher) RunScrapers(ctx context.Context, subscriptions []*htracker.Subscription) error {
-	tctx, _ := context.WithTimeout(ctx, w.interval)
+	cfg := w.snapshot()
+	tctx, cancel := context.WithTimeout(ctx, cfg.interval)
+	defer cancel()
 	wg := &sync.WaitGroup{}
-	batches := make(chan []*htracker.Subscription, w.threads)
+	batches := make(chan []*htracker.Subscription, cfg.threads)
 
 	// spin up workers
-	w.startWorkers(tctx, batches, wg)
+	w.startWorkers(tctx, cfg, batches, wg)
 
 	batch := []*htracker.Subscription{}
 	count := 0
@@ -123,11 +209,17 @@ func (w *Watcher) RunScrapers(ctx context.Context, subscriptions []*htracker.Sub
 	for i, sub := range subscriptions {
 		count++
 		batch = append(batch, sub)
-		if count == w.batchSize || i == last {
+		if count == cfg.batchSize || i == last {
+			// another watcher might have taken over during a long run, if the lock of the leader was lost
+			if !w.elect(tctx, cfg.logger) {
+				cancel()
+				wg.Wait()
+				return nil
+			}
 			select {
 			case batches <- batch:
 			case <-tctx.Done():
-				w.logger.Debug("watcher: RunScrapers() interrupted", "error", tctx.Err())
+				cfg.logger.Debug("watcher: RunScrapers() interrupted", "error", tctx.Err())
 				return tctx.Err()
 			}
 			count = 0
@@ -137,43 +229,44 @@ func (w *Watcher) RunScrapers(ctx context.Context, subscriptions []*htracker.Sub
 
 	close(batches)
 
-	w.logger.Debug("watcher: waiting for workers to finish")
+	cfg.logger.Debug("watcher: waiting for workers to finish")
 	wg.Wait()
-	w.logger.Debug("watcher: all workers finished")
+	cfg.logger.Debug("watcher: all workers finished")
 
 	return nil
 }
 
 // startWorkers is spinning up scraper threads for concurrent processing of batches of subscriptions.
-func (w *Watcher) startWorkers(ctx context.Context, batches chan []*htracker.Subscription, wg *sync.WaitGroup) {
+func (w *Watcher) startWorkers(ctx context.Context, cfg settings, batches chan []*htracker.Subscription, wg *sync.WaitGroup) {
 
 	exporters := []exporter.Interface{exporter.NewExporter(ctx, w.archive)}
 
-	for i := 0; i < w.threads; i++ {
+	for i := 0; i < cfg.threads; i++ {
 		workerNr := i // capture loop var for use in closure
 		wg.Add(1)
-		w.logger.Debug("watcher: starting worker", "worker", i)
+		cfg.logger.Debug("watcher: starting worker", "worker", i)
 
 		go func() {
 			defer wg.Done()
 			for {
-				w.logger.Debug("watcher: waiting for next batch of subscriptions to process", slog.Int("worker", workerNr))
+				cfg.logger.Debug("watcher: waiting for next batch of subscriptions to process", slog.Int("worker", workerNr))
 				select {
 				case batch, ok := <-batches:
 					if !ok {
-						w.logger.Debug("watcher: no more subscriptions to process - worker shutting down", slog.Int("worker", workerNr))
+						cfg.logger.Debug("watcher: no more subscriptions to process - worker shutting down", slog.Int("worker", workerNr))
 						return
 					}
 
-					opts := append(w.scraperOpts, scraper.WithExporters(exporters), scraper.WithLogger(w.logger))
+					// limit the capacity, so that append is not writing to the array shared by all workers
+					opts := append(cfg.scraperOpts[:len(cfg.scraperOpts):len(cfg.scraperOpts)], scraper.WithExporters(exporters), scraper.WithLogger(cfg.logger), scraper.WithContext(ctx))
 					scraper := scraper.NewScraper(batch, opts...)
 
-					w.logger.Debug("watcher: scraper starting", slog.Int("worker", workerNr))
+					cfg.logger.Debug("watcher: scraper starting", slog.Int("worker", workerNr))
 					scraper.Start()
-					w.logger.Debug("watcher: scraper finished", "worker", workerNr)
+					cfg.logger.Debug("watcher: scraper finished", "worker", workerNr)
 
 				case <-ctx.Done():
-					w.logger.Debug("watcher: worker canceled - shutting down", slog.Int("worker", workerNr), "error", ctx.Err())
+					cfg.logger.Debug("watcher: worker canceled - shutting down", slog.Int("worker", workerNr), "error", ctx.Err())
 					return
 				}
 			}
@@ -184,26 +277,84 @@ func (w *Watcher) startWorkers(ctx context.Context, batches chan []*htracker.Sub
 // Start is making the watcher scrape all subscribed websites in regular intervals.
 // It can be stopped by canceling the given context.
 func (w *Watcher) Start(ctx context.Context) error {
+	cfg := w.snapshot()
+	cfg.logger.Info("Watcher started", "interval", cfg.interval, "threads", cfg.threads, "batchSize", cfg.batchSize)
 
-	w.logger.Info("Watcher started", "interval", w.interval, "threads", w.threads, "batchSize", w.batchSize)
-
-	ticker := time.NewTicker(w.interval)
+	ticker := time.NewTicker(cfg.interval)
 	defer ticker.Stop()
+	if w.elector != nil {
+		defer w.resign(cfg.logger)
+	}
 
 	for {
-		sites, err := w.GenerateScrapeList(ctx)
-		if err != nil {
-			return fmt.Errorf("watcher.GenerateScrapeList(): %w", err)
+		if w.elect(ctx, cfg.logger) {
+			sites, err := w.GenerateScrapeList(ctx)
+			if err != nil {
+				return fmt.Errorf("watcher.GenerateScrapeList(): %w", err)
+			}
+
+			if err := w.RunScrapers(ctx, sites); err != nil {
+				w.snapshot().logger.Error("Watcher: RunScrapers() failed", err)
+			}
 		}
 
-		if err := w.RunScrapers(ctx, sites); err != nil {
-			w.logger.Error("Watcher: RunScrapers() failed", err)
+		// watchers which are not the leader are trying to take over before the next tick
+		var election <-chan time.Time
+		if !w.leader {
+			election = time.After(w.electionInterval)
 		}
 
-		select {
-		case <-ticker.C:
-		case <-ctx.Done():
-			return ctx.Err()
+		// wait for the next tick, applying new settings while waiting
+		for waiting := true; waiting; {
+			select {
+			case <-ticker.C:
+				waiting = false
+			case <-election:
+				waiting = false
+			case <-w.reconfigured:
+				newCfg := w.snapshot()
+				newCfg.logger.Info("Watcher reconfigured", "interval", newCfg.interval, "threads", newCfg.threads, "batchSize", newCfg.batchSize)
+				if newCfg.interval != cfg.interval {
+					ticker.Reset(newCfg.interval)
+				}
+				cfg = newCfg
+			case <-ctx.Done():
+				return ctx.Err()
+			}
 		}
 	}
 }
+
+// elect is returning whether the watcher is the leader and may scrape, which is always true without elector.
+// If the election fails, the watcher is not scraping, as another watcher might be the leader.
+func (w *Watcher) elect(ctx context.Context, logger *slog.Logger) bool {
+	if w.elector == nil {
+		return true
+	}
+
+	leader, err := w.elector.Elect(ctx)
+	if err != nil {
+		logger.Error("Watcher: election failed", err)
+		leader = false
+	}
+	if leader != w.leader {
+		if leader {
+			logger.Info("Watcher elected as leader, starting to scrape")
+		} else {
+			logger.Info("Watcher lost leadership, standing by")
+		}
+	}
+	w.leader = leader
+	return leader
+}
+
+// resign is giving up the leadership of the watcher, if it is the leader.
+func (w *Watcher) resign(logger *slog.Logger) {
+	if !w.leader {
+		return
+	}
+	if err := w.elector.Resign(); err != nil {
+		logger.Error("Watcher: resigning leadership failed", err)
+	}
+	w.leader = false
+}
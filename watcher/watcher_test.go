@@ -2,8 +2,14 @@ package watcher
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -26,10 +32,14 @@ func TestWatcher_GenerateScrapeList(t *testing.T) {
 	sub1a := &htracker.Subscription{URL: "site1.test", Filter: "filter2", ContentType: "text"}
 	sub1b := &htracker.Subscription{URL: "site1.test", Filter: "filter1", ContentType: "html"}
 	sub2 := &htracker.Subscription{URL: "site2.test", Filter: "filter1", ContentType: "text"}
+	sub2Post := &htracker.Subscription{URL: "site2.test", Filter: "filter1", ContentType: "text", Method: "POST", Body: "q=foo"}
+	sub2PostAgain := &htracker.Subscription{URL: "site2.test", Filter: "filter1", ContentType: "text", Method: "post", Body: "q=foo"}
 
-	subscriber1 := &service.Subscriber{email1, []*htracker.Subscription{sub1}, -1}
-	subscriber2 := &service.Subscriber{email2, []*htracker.Subscription{sub1, sub1a, sub1b}, -1}
-	subscriber3 := &service.Subscriber{email3, []*htracker.Subscription{sub1, sub1a, sub1b, sub2}, -1}
+	subscriber1 := &service.Subscriber{Email: email1, Subscriptions: []*htracker.Subscription{sub1}, SubscriptionLimit: -1}
+	subscriber2 := &service.Subscriber{Email: email2, Subscriptions: []*htracker.Subscription{sub1, sub1a, sub1b}, SubscriptionLimit: -1}
+	subscriber3 := &service.Subscriber{Email: email3, Subscriptions: []*htracker.Subscription{sub1, sub1a, sub1b, sub2}, SubscriptionLimit: -1}
+	subscriber4 := &service.Subscriber{Email: "email4@foo.bar", Subscriptions: []*htracker.Subscription{sub2, sub2Post}, SubscriptionLimit: -1}
+	subscriber5 := &service.Subscriber{Email: "email5@foo.bar", Subscriptions: []*htracker.Subscription{sub2PostAgain}, SubscriptionLimit: -1}
 
 	tests := []struct {
 		name              string
@@ -45,6 +55,8 @@ func TestWatcher_GenerateScrapeList(t *testing.T) {
 			wantSubscriptions: []*htracker.Subscription{sub1, sub1a, sub1b}, wantErr: false},
 		{name: "multiple subscribers", subscribers: []*service.Subscriber{subscriber1, subscriber2, subscriber3},
 			wantSubscriptions: []*htracker.Subscription{sub1, sub1a, sub1b, sub2}, wantErr: false},
+		{name: "same site with different methods", subscribers: []*service.Subscriber{subscriber4, subscriber5},
+			wantSubscriptions: []*htracker.Subscription{sub2, sub2Post}, wantErr: false},
 	}
 
 	for _, tt := range tests {
@@ -152,3 +164,187 @@ func TestWatcher_RunScrapers(t *testing.T) {
 		})
 	}
 }
+
+// countingSubscriptionSvc is counting the calls of GetSubscribers(), to observe scrape runs of the watcher.
+type countingSubscriptionSvc struct {
+	service.SubscriptionSvc
+	calls chan struct{}
+}
+
+func (svc *countingSubscriptionSvc) GetSubscribers(ctx context.Context) ([]*service.Subscriber, error) {
+	select {
+	case svc.calls <- struct{}{}:
+	default:
+	}
+	return svc.SubscriptionSvc.GetSubscribers(ctx)
+}
+
+func TestWatcher_Reconfigure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	logger := slog.Default()
+	svc := &countingSubscriptionSvc{
+		SubscriptionSvc: service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger)),
+		calls:           make(chan struct{}, 10),
+	}
+	w := NewWatcher(service.NewSiteArchive(memory.NewSiteStorage(logger)), svc, WithLogger(logger), WithInterval(time.Hour))
+
+	done := make(chan error)
+	go func() { done <- w.Start(ctx) }()
+
+	// first run is starting immediately
+	select {
+	case <-svc.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watcher did not start first run")
+	}
+
+	w.Reconfigure(WithInterval(10*time.Millisecond), WithThreads(3), WithBatchSize(7))
+
+	// with the new interval the next run must start long before the old interval of an hour
+	select {
+	case <-svc.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watcher did not apply new interval")
+	}
+
+	cfg := w.snapshot()
+	if cfg.interval != 10*time.Millisecond || cfg.threads != 3 || cfg.batchSize != 7 {
+		t.Errorf("Unexpected settings after Reconfigure(): %+v", cfg)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watcher.Start() expected context.Canceled, got %v", err)
+	}
+}
+
+// sharedLock is electing the first of the watchers of the same process asking as leader.
+type sharedLock struct {
+	mu     sync.Mutex
+	leader *lockHolder
+}
+
+// lockHolder is the Elector of a watcher using a sharedLock.
+type lockHolder struct {
+	lock *sharedLock
+}
+
+func (h *lockHolder) Elect(ctx context.Context) (bool, error) {
+	h.lock.mu.Lock()
+	defer h.lock.mu.Unlock()
+	if h.lock.leader == nil {
+		h.lock.leader = h
+	}
+	return h.lock.leader == h, nil
+}
+
+func (h *lockHolder) Resign() error {
+	h.lock.mu.Lock()
+	defer h.lock.mu.Unlock()
+	if h.lock.leader == h {
+		h.lock.leader = nil
+	}
+	return nil
+}
+
+func TestWatcher_Start_Elector(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.Default()
+	lock := &sharedLock{}
+
+	// two watchers sharing the subscriptions, the first one started is becoming the leader
+	type instance struct {
+		svc    *countingSubscriptionSvc
+		cancel context.CancelFunc
+		done   chan error
+	}
+	instances := []*instance{}
+	for i := 0; i < 2; i++ {
+		svc := &countingSubscriptionSvc{
+			SubscriptionSvc: service.NewSubscriptionSvc(memory.NewSubscriptionStorage(logger)),
+			calls:           make(chan struct{}, 100),
+		}
+		w := NewWatcher(service.NewSiteArchive(memory.NewSiteStorage(logger)), svc, WithLogger(logger),
+			WithInterval(10*time.Millisecond), WithElector(&lockHolder{lock: lock}), WithElectionInterval(10*time.Millisecond))
+
+		wctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		inst := &instance{svc: svc, cancel: cancel, done: make(chan error)}
+		go func() { inst.done <- w.Start(wctx) }()
+		instances = append(instances, inst)
+
+		// wait for the first watcher to become the leader
+		if i == 0 {
+			select {
+			case <-svc.calls:
+			case <-time.After(5 * time.Second):
+				t.Fatal("first watcher did not start scraping")
+			}
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if runs := len(instances[1].svc.calls); runs > 0 {
+		t.Errorf("Expected the second watcher to stand by, got %d runs", runs)
+	}
+
+	// the second watcher is taking over if the leader stopped
+	instances[0].cancel()
+	if err := <-instances[0].done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Watcher.Start() expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-instances[1].svc.calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second watcher did not take over")
+	}
+
+	instances[1].cancel()
+	<-instances[1].done
+}
+
+// expiringLock is electing the watcher as leader for the given number of elections.
+type expiringLock struct {
+	elections int
+}
+
+func (l *expiringLock) Elect(ctx context.Context) (bool, error) {
+	l.elections--
+	return l.elections >= 0, nil
+}
+
+func (l *expiringLock) Resign() error {
+	return nil
+}
+
+func TestWatcher_RunScrapers_LostLeadership(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	sites := []*htracker.Subscription{}
+	for i := 0; i < 5; i++ {
+		sites = append(sites, &htracker.Subscription{URL: fmt.Sprintf("%s/%d", server.URL, i)})
+	}
+
+	logger := slog.Default()
+	w := NewWatcher(service.NewSiteArchive(memory.NewSiteStorage(logger)), nil, WithLogger(logger),
+		WithInterval(time.Minute), WithBatchSize(1), WithThreads(1), WithElector(&expiringLock{elections: 2}),
+		WithScraperOpts(scraper.WithLogDisabled(true)))
+
+	if err := w.RunScrapers(context.Background(), sites); err != nil {
+		t.Fatalf("Watcher.RunScrapers() failed: %v", err)
+	}
+	// the batches are sent after confirming the leadership, the third one is not sent anymore
+	if got := requests.Load(); got > 2 {
+		t.Errorf("Expected at most 2 sites to be scraped after losing the leadership, got %d", got)
+	}
+	if w.leader {
+		t.Error("Expected the watcher to have lost the leadership")
+	}
+}